@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+	"mini-wallet/domain"
+	"mini-wallet/domain/auth"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/proto/walletpb"
+
+	"google.golang.org/grpc"
+)
+
+type authGrpcServer struct {
+	walletpb.UnimplementedAuthServiceServer
+	authUsecase auth.AuthUsecase
+}
+
+func SetAuthGrpcServer(server *grpc.Server, usecases domain.Usecases) {
+	walletpb.RegisterAuthServiceServer(server, &authGrpcServer{
+		authUsecase: usecases.AuthUsecase,
+	})
+}
+
+func (server *authGrpcServer) InitUser(ctx context.Context, req *walletpb.InitUserRequest) (*walletpb.InitUserResponse, error) {
+	creationRequest := wallet.WalletCreationRequest{
+		CustomerId: req.GetCustomerXid(),
+	}
+	if err := creationRequest.Validate(); err != nil {
+		return nil, response.GRPCError(err)
+	}
+
+	result, err := server.authUsecase.InitUser(ctx, creationRequest.CustomerId)
+	if err != nil {
+		return nil, response.GRPCError(err)
+	}
+
+	return &walletpb.InitUserResponse{
+		Token: result.Data.Token,
+	}, nil
+}
@@ -1,9 +1,9 @@
 package auth
 
 import (
-	"context"
 	"mini-wallet/domain"
 	"mini-wallet/domain/auth"
+	"mini-wallet/domain/common/request"
 	"mini-wallet/domain/wallet"
 	"net/http"
 
@@ -28,19 +28,19 @@ func SetAuthHandler(router *chi.Mux, usecases domain.Usecases) {
 }
 
 func (authHandler *authHandler) InitUser(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 	req := wallet.WalletCreationRequest{}
 	resp := &response.Response[auth.Token]{}
 
-	req.CustomerId = r.FormValue("customer_xid")
-	err := req.Validate()
+	err := request.Decode(w, r, &req)
+	if err == nil {
+		err = req.Validate()
+	}
 	if err != nil {
 		errResp := &response.Response[response.Error]{
-			Data: &response.Error{
-				Error: err.Error(),
-			},
+			Data: response.NewError(err),
 		}
-		errResp.Error(err.Error())
+		errResp.Error(err)
 		errResp.WriteResponse(w)
 		return
 	}
@@ -48,11 +48,9 @@ func (authHandler *authHandler) InitUser(w http.ResponseWriter, r *http.Request)
 	resp, err = authHandler.authUsecase.InitUser(ctx, req.CustomerId)
 	if err != nil {
 		errResp := &response.Response[response.Error]{
-			Data: &response.Error{
-				Error: err.Error(),
-			},
+			Data: response.NewError(err),
 		}
-		errResp.Error(err.Error())
+		errResp.Error(err)
 		errResp.WriteResponse(w)
 		return
 	}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/proto/walletpb"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+var (
+	// the grpc methods called without a token
+	publicGrpcMethods = map[string]struct{}{
+		walletpb.AuthService_InitUser_FullMethodName: {},
+		grpc_health_v1.Health_Check_FullMethodName:   {},
+		grpc_health_v1.Health_Watch_FullMethodName:   {},
+	}
+)
+
+func (usecase *authUsecase) AuthorizeUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	if _, ok := publicGrpcMethods[info.FullMethod]; ok {
+		return handler(ctx, req)
+	}
+
+	ctx, err = usecase.authorizeGrpc(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (usecase *authUsecase) AuthorizeStreamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	if _, ok := publicGrpcMethods[info.FullMethod]; ok {
+		return handler(srv, stream)
+	}
+
+	ctx, err := usecase.authorizeGrpc(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authorizedServerStream{ServerStream: stream, ctx: ctx})
+}
+
+// authorizeGrpc reads the token from the "authorization: Bearer <token>" metadata and sets the "walletId" of ctx,
+// like AuthorizeRequestMiddleware does from the Authorization header
+func (usecase *authUsecase) authorizeGrpc(ctx context.Context) (context.Context, error) {
+	var authValues []string
+	if incoming, ok := metadata.FromIncomingContext(ctx); ok {
+		authValues = incoming.Get("authorization")
+	}
+	if len(authValues) != 1 {
+		return ctx, response.GRPCError(response.ErrUnauthorized)
+	}
+
+	authHeader := strings.Split(authValues[0], "Bearer ")
+	if len(authHeader) != 2 {
+		return ctx, response.GRPCError(response.ErrUnauthorized)
+	}
+
+	walletId, err := usecase.authRepository.GetTokenWalletId(ctx, authHeader[1])
+	if err != nil || walletId == "" {
+		return ctx, response.GRPCError(response.ErrUnauthorized)
+	}
+
+	return context.WithValue(ctx, "walletId", walletId), nil
+}
+
+// authorizedServerStream hands the authorized ctx to the stream handler
+type authorizedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *authorizedServerStream) Context() context.Context {
+	return stream.ctx
+}
@@ -7,19 +7,21 @@ import (
 )
 
 type authRepository struct {
-	cache infrastructure.Cache
+	cache  infrastructure.Cache
+	config infrastructure.Config
 }
 
-func NewAuthRepository(cache infrastructure.Cache) auth.AuthRepository {
+func NewAuthRepository(cache infrastructure.Cache, config infrastructure.Config) auth.AuthRepository {
 	return &authRepository{
-		cache: cache,
+		cache:  cache,
+		config: config,
 	}
 }
 
 func (authRepository *authRepository) AddToken(ctx context.Context, token string, walletId string) (err error) {
-	err = authRepository.cache.SetString(ctx, token, walletId, 6000) // ttl can be defined on .env
+	err = authRepository.cache.SetString(ctx, token, walletId, int(authRepository.config.TOKEN_TTL.Seconds()))
 	if err != nil {
-		infrastructure.Log("got error on authRepository.cache.SetString() - AddToken")
+		infrastructure.LogError(ctx, "got error on authRepository.cache.SetString() - AddToken", err)
 		return nil
 	}
 
@@ -28,9 +30,19 @@ func (authRepository *authRepository) AddToken(ctx context.Context, token string
 func (authRepository *authRepository) GetTokenWalletId(ctx context.Context, token string) (walletId string, err error) {
 	walletId, err = authRepository.cache.GetString(ctx, token)
 	if err != nil {
-		infrastructure.Log("got error on authRepository.cache.GetString() - GetTokenWalletId")
+		infrastructure.LogError(ctx, "got error on authRepository.cache.GetString() - GetTokenWalletId", err)
 		return "", err
 	}
 
 	return walletId, nil
 }
+
+func (authRepository *authRepository) RevokeToken(ctx context.Context, token string) (err error) {
+	err = authRepository.cache.Del(ctx, token)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on authRepository.cache.Del() - RevokeToken", err)
+		return err
+	}
+
+	return nil
+}
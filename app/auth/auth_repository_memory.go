@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"mini-wallet/domain/auth"
+	"mini-wallet/infrastructure"
+	"sync"
+	"time"
+)
+
+type memoryAuthRepository struct {
+	config infrastructure.Config
+
+	mu     sync.Mutex
+	tokens map[string]memoryToken
+}
+
+type memoryToken struct {
+	walletId  string
+	expiresAt time.Time
+}
+
+// NewMemoryAuthRepository keeps the tokens in this process for TOKEN_TTL, for tests and the memory storage.
+// an unknown or expired token has an empty wallet id
+func NewMemoryAuthRepository(config infrastructure.Config) auth.AuthRepository {
+	return &memoryAuthRepository{
+		config: config,
+		tokens: map[string]memoryToken{},
+	}
+}
+
+func (authRepository *memoryAuthRepository) AddToken(ctx context.Context, token string, walletId string) (err error) {
+	authRepository.mu.Lock()
+	defer authRepository.mu.Unlock()
+
+	authRepository.tokens[token] = memoryToken{
+		walletId:  walletId,
+		expiresAt: time.Now().Add(authRepository.config.TOKEN_TTL),
+	}
+
+	return nil
+}
+
+func (authRepository *memoryAuthRepository) GetTokenWalletId(ctx context.Context, token string) (walletId string, err error) {
+	authRepository.mu.Lock()
+	defer authRepository.mu.Unlock()
+
+	storedToken, found := authRepository.tokens[token]
+	if !found {
+		return "", nil
+	}
+	if !time.Now().Before(storedToken.expiresAt) {
+		delete(authRepository.tokens, token)
+		return "", nil
+	}
+
+	return storedToken.walletId, nil
+}
+
+func (authRepository *memoryAuthRepository) RevokeToken(ctx context.Context, token string) (err error) {
+	authRepository.mu.Lock()
+	defer authRepository.mu.Unlock()
+
+	delete(authRepository.tokens, token)
+	return nil
+}
@@ -3,11 +3,13 @@ package auth
 import (
 	"context"
 	"crypto/sha1"
+	"crypto/subtle"
+	"errors"
 	"fmt"
-	"log"
 	"mini-wallet/domain"
 	"mini-wallet/domain/auth"
 	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/member"
 	"mini-wallet/domain/wallet"
 	"mini-wallet/infrastructure"
 	"net/http"
@@ -19,13 +21,17 @@ import (
 
 type authUsecase struct {
 	walletRepository wallet.WalletRepository
+	memberRepository member.MemberRepository
 	authRepository   auth.AuthRepository
+	config           infrastructure.Config
 }
 
-func NewAuthUsecase(repositories domain.Repositories) auth.AuthUsecase {
+func NewAuthUsecase(repositories domain.Repositories, config infrastructure.Config) auth.AuthUsecase {
 	return &authUsecase{
 		walletRepository: repositories.WalletRepository,
+		memberRepository: repositories.MemberRepository,
 		authRepository:   repositories.AuthRepository,
+		config:           config,
 	}
 }
 
@@ -38,25 +44,37 @@ func (usecase *authUsecase) InitUser(ctx context.Context, customerId string) (to
 
 	customerWallet, err := usecase.walletRepository.GetCustomerWallet(ctx, customerId)
 	if err != nil {
-		infrastructure.Log("got error on usecase.walletRepository.GetCustomerWallet()")
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetCustomerWallet() - InitUser", err)
 		return nil, err
 	}
 
 	if customerWallet == nil {
-		walletId, err := uuid.NewV6()
+		newWalletId, err := uuid.NewV7()
 		if err != nil {
-			infrastructure.Log("got error on uuid.NewV6()")
+			infrastructure.LogError(ctx, "got error on uuid.NewV7() - InitUser", err)
 			return nil, err
 		}
+		walletId = newWalletId.String()
 
 		err = usecase.walletRepository.InsertWallet(ctx, wallet.Wallet{
-			Id:      walletId.String(),
+			Id:      walletId,
 			OwnedBy: customerId,
+			Name:    wallet.WALLET_MAIN_POCKET,
 			Balance: 0,
 			Status:  wallet.WALLET_STATUS_DISABLED,
 		})
+		if err != nil && errors.Is(err, response.ErrWalletAlreadyExists) {
+			// a concurrent init of the same customer won, use its wallet
+			customerWallet, err = usecase.walletRepository.GetCustomerWallet(ctx, customerId)
+			if err == nil && customerWallet == nil {
+				err = response.ErrWalletNotFound
+			}
+			if err == nil {
+				walletId = customerWallet.Id
+			}
+		}
 		if err != nil {
-			log.Default().Printf("got error on usecase.walletRepository.InsertWallet()")
+			infrastructure.LogError(ctx, "got error on usecase.walletRepository.InsertWallet() - InitUser", err)
 			return nil, err
 		}
 	} else {
@@ -65,12 +83,12 @@ func (usecase *authUsecase) InitUser(ctx context.Context, customerId string) (to
 
 	return &response.Response[auth.Token]{
 		Data: &auth.Token{
-			Token: usecase.generateToken(walletId),
+			Token: usecase.generateToken(ctx, walletId),
 		},
 	}, nil
 }
 
-func (usecase *authUsecase) generateToken(walletId string) string {
+func (usecase *authUsecase) generateToken(ctx context.Context, walletId string) string {
 	// avoid generating the same token over and over again -> add time
 	var sha = sha1.New()
 	walletIdTimestamp := walletId + time.Now().String()
@@ -78,7 +96,7 @@ func (usecase *authUsecase) generateToken(walletId string) string {
 
 	var token = sha.Sum(nil)
 
-	usecase.authRepository.AddToken(context.Background(), fmt.Sprintf("%x", token), walletId)
+	usecase.authRepository.AddToken(ctx, fmt.Sprintf("%x", token), walletId)
 
 	return fmt.Sprintf("%x", token)
 }
@@ -91,20 +109,16 @@ func (usecase *authUsecase) AuthorizeRequestMiddleware(next http.Handler) http.H
 
 		authHeader := strings.Split(r.Header.Get("Authorization"), "Bearer ")
 		if len(authHeader) != 2 {
-			unauthorizedResp.Data = &response.Error{
-				Error: response.ERROR_UNAUTHORIZED,
-			}
-			unauthorizedResp.Error(response.ERROR_UNAUTHORIZED)
+			unauthorizedResp.Data = response.NewError(response.ErrUnauthorized)
+			unauthorizedResp.Error(response.ErrUnauthorized)
 			unauthorizedResp.WriteResponse(w)
 			return
 		}
 
 		walletId, err := usecase.authRepository.GetTokenWalletId(ctx, authHeader[1])
 		if err != nil || walletId == "" {
-			unauthorizedResp.Data = &response.Error{
-				Error: response.ERROR_UNAUTHORIZED,
-			}
-			unauthorizedResp.Error(response.ERROR_UNAUTHORIZED)
+			unauthorizedResp.Data = response.NewError(response.ErrUnauthorized)
+			unauthorizedResp.Error(response.ErrUnauthorized)
 			unauthorizedResp.WriteResponse(w)
 			return
 		}
@@ -113,3 +127,117 @@ func (usecase *authUsecase) AuthorizeRequestMiddleware(next http.Handler) http.H
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// SelectWalletMiddleware acts on another wallet than the token's main pocket when the pocket_id query or form value names
+// one of the customer's own pockets, with the owner role, or a wallet shared with them, with their member role.
+// The ctx then carries the selected "walletId", the "memberId" behind the token and its "memberRole"
+func (usecase *authUsecase) SelectWalletMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		walletId, _ := ctx.Value("walletId").(string)
+		selectedWalletId, memberId, role, err := usecase.selectWallet(ctx, walletId, r.FormValue("pocket_id"))
+		if err != nil {
+			errResp := response.Response[response.Error]{
+				Data: response.NewError(err),
+			}
+			errResp.Error(err)
+			errResp.WriteResponse(w)
+			return
+		}
+
+		ctx = context.WithValue(ctx, "walletId", selectedWalletId)
+		ctx = context.WithValue(ctx, "memberId", memberId)
+		ctx = context.WithValue(ctx, "memberRole", role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// selectWallet resolves the customer behind the token's wallet and their role on pocketId,
+// a wallet the customer has no access to is reported as not found
+func (usecase *authUsecase) selectWallet(ctx context.Context, walletId string, pocketId string) (selectedWalletId string, memberId string, role string, err error) {
+	tokenWallet, err := usecase.walletRepository.GetWalletById(ctx, walletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - selectWallet", err)
+		return "", "", "", err
+	}
+
+	if tokenWallet == nil {
+		return "", "", "", response.ErrWalletNotFound
+	}
+
+	memberId = tokenWallet.OwnedBy
+	if pocketId == "" || pocketId == walletId {
+		return walletId, memberId, member.MEMBER_ROLE_OWNER, nil
+	}
+
+	pocket, err := usecase.walletRepository.GetWalletById(ctx, pocketId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - selectWallet", err)
+		return "", "", "", err
+	}
+
+	if pocket == nil {
+		return "", "", "", response.ErrPocketNotFound
+	}
+
+	if pocket.OwnedBy == memberId {
+		return pocket.Id, memberId, member.MEMBER_ROLE_OWNER, nil
+	}
+
+	// wired without a member repository, e.g. by the tests of the wallets alone, nobody shares a wallet
+	if usecase.memberRepository == nil {
+		return "", "", "", response.ErrPocketNotFound
+	}
+
+	membership, err := usecase.memberRepository.GetMember(ctx, pocket.Id, memberId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.memberRepository.GetMember() - selectWallet", err)
+		return "", "", "", err
+	}
+
+	if membership == nil || membership.Status != member.MEMBER_STATUS_ACTIVE {
+		return "", "", "", response.ErrPocketNotFound
+	}
+
+	return pocket.Id, memberId, membership.Role, nil
+}
+
+func (usecase *authUsecase) RequireRoleMiddleware(roles ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value("memberRole").(string)
+			for _, allowedRole := range roles {
+				if role == allowedRole {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			forbiddenResp := response.Response[response.Error]{
+				Data: response.NewError(response.ErrMemberForbidden),
+			}
+			forbiddenResp.Error(response.ErrMemberForbidden)
+			forbiddenResp.WriteResponse(w)
+		})
+	}
+}
+
+// AuthorizeAdminMiddleware guards operational endpoints with the static ADMIN_TOKEN,
+// an empty ADMIN_TOKEN disables them entirely
+func (usecase *authUsecase) AuthorizeAdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		unauthorizedResp := response.Response[response.Error]{}
+
+		authHeader := strings.Split(r.Header.Get("Authorization"), "Bearer ")
+		if len(authHeader) != 2 || usecase.config.ADMIN_TOKEN == "" ||
+			subtle.ConstantTimeCompare([]byte(authHeader[1]), []byte(usecase.config.ADMIN_TOKEN)) != 1 {
+			unauthorizedResp.Data = response.NewError(response.ErrUnauthorized)
+			unauthorizedResp.Error(response.ErrUnauthorized)
+			unauthorizedResp.WriteResponse(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	walletApp "mini-wallet/app/wallet"
+	"mini-wallet/domain"
+	"mini-wallet/domain/member"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newMemoryTestUsecase runs the usecase on the memory repositories, without members
+func newMemoryTestUsecase() (*authUsecase, domain.Repositories) {
+	config := infrastructure.Config{TOKEN_TTL: time.Minute}
+	repositories := domain.Repositories{
+		WalletRepository: walletApp.NewMemoryWalletRepository(),
+		AuthRepository:   NewMemoryAuthRepository(config),
+	}
+
+	return NewAuthUsecase(repositories, config).(*authUsecase), repositories
+}
+
+func TestInitUserKeepsOneWalletPerCustomer(t *testing.T) {
+	ctx := context.Background()
+	usecase, repositories := newMemoryTestUsecase()
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := usecase.InitUser(ctx, "customer-1")
+			if err != nil {
+				t.Errorf("init #%d: %v", i, err)
+				return
+			}
+			tokens[i] = result.Data.Token
+		}(i)
+	}
+	wg.Wait()
+
+	wallets, err := repositories.WalletRepository.GetCustomerWallets(ctx, "customer-1")
+	if err != nil || len(wallets) != 1 || wallets[0].Status != wallet.WALLET_STATUS_DISABLED {
+		t.Fatalf("wallets = %+v %v, want a single disabled main pocket", wallets, err)
+	}
+
+	for i, token := range tokens {
+		walletId, err := repositories.AuthRepository.GetTokenWalletId(ctx, token)
+		if err != nil || walletId != wallets[0].Id {
+			t.Errorf("token #%d is for %q %v, want %q", i, walletId, err, wallets[0].Id)
+		}
+	}
+}
+
+func TestAuthorizeRequestMiddleware(t *testing.T) {
+	ctx := context.Background()
+	usecase, repositories := newMemoryTestUsecase()
+
+	result, err := usecase.InitUser(ctx, "customer-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := result.Data.Token
+
+	var authorizedWalletId string
+	handler := usecase.AuthorizeRequestMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizedWalletId, _ = r.Context().Value("walletId").(string)
+	}))
+	authorize := func(authorization string) string {
+		authorizedWalletId = ""
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/wallet", nil)
+		req.Header.Set("Authorization", authorization)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		return authorizedWalletId
+	}
+
+	if walletId := authorize("Bearer " + token); walletId == "" {
+		t.Errorf("a valid token is refused")
+	}
+	if walletId := authorize("Token " + token); walletId != "" {
+		t.Errorf("a token without the Bearer scheme is accepted")
+	}
+	if walletId := authorize("Bearer unknown"); walletId != "" {
+		t.Errorf("an unknown token is accepted")
+	}
+
+	if err = repositories.AuthRepository.RevokeToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if walletId := authorize("Bearer " + token); walletId != "" {
+		t.Errorf("a revoked token is accepted")
+	}
+}
+
+func TestSelectWalletWithoutMembers(t *testing.T) {
+	ctx := context.Background()
+	usecase, repositories := newMemoryTestUsecase()
+
+	for _, customerId := range []string{"customer-1", "customer-2"} {
+		if _, err := usecase.InitUser(ctx, customerId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ownWallet, _ := repositories.WalletRepository.GetCustomerWallet(ctx, "customer-1")
+	otherWallet, _ := repositories.WalletRepository.GetCustomerWallet(ctx, "customer-2")
+
+	selectedWalletId, memberId, role, err := usecase.selectWallet(ctx, ownWallet.Id, "")
+	if err != nil || selectedWalletId != ownWallet.Id || memberId != "customer-1" || role != member.MEMBER_ROLE_OWNER {
+		t.Errorf("own wallet = %q %q %q %v, want the owner of %q", selectedWalletId, memberId, role, err, ownWallet.Id)
+	}
+
+	// without a member repository the wallet of someone else is never shared
+	if _, _, _, err = usecase.selectWallet(ctx, ownWallet.Id, otherWallet.Id); err == nil {
+		t.Errorf("the wallet of another customer is selected")
+	}
+}
@@ -0,0 +1,191 @@
+package batch
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mime"
+	"mini-wallet/domain"
+	"mini-wallet/domain/batch"
+	"mini-wallet/domain/common/response"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	batchBodyLimit = 32 << 20 // 32MB
+)
+
+var (
+	batchReportColumns = []string{"line", "wallet_id", "type", "amount", "reference_id", "status", "error", "transaction_id"}
+)
+
+type batchHandler struct {
+	batchUsecase batch.BatchUsecase
+}
+
+func SetBatchHandler(router *chi.Mux, usecases domain.Usecases) {
+	batchHandler := batchHandler{
+		batchUsecase: usecases.BatchUsecase,
+	}
+
+	// batches move money of many wallets at once, only for partners holding the admin token
+	router.Route("/api/v1/batches", func(r chi.Router) {
+		r.Use(usecases.AuthUsecase.AuthorizeAdminMiddleware)
+
+		// GET
+		r.Get("/{batchId}", batchHandler.GetBatch)
+		r.Get("/{batchId}/report", batchHandler.GetBatchReport)
+
+		// POST
+		r.Post("/", batchHandler.CreateBatch)
+	})
+}
+
+// CreateBatch accepts a json or csv body, or either of them as the multipart "file" field.
+// the mode is read from the json body, the "mode" form field or the query
+func (handler *batchHandler) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, batchBodyLimit)
+
+	req, err := decodeBatchRequest(r)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	result, err := handler.batchUsecase.CreateBatch(r.Context(), req)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[batch.Batch]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	// processed in the background, poll GET /api/v1/batches/{batchId}
+	resp.StatusCode = http.StatusAccepted
+	resp.WriteResponse(w)
+}
+
+func (handler *batchHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	result, err := handler.batchUsecase.GetBatch(r.Context(), chi.URLParam(r, "batchId"))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[batch.Batch]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+// GetBatchReport returns the result of every item, as a csv download or with ?format=json
+func (handler *batchHandler) GetBatchReport(w http.ResponseWriter, r *http.Request) {
+	batchId := chi.URLParam(r, "batchId")
+
+	result, err := handler.batchUsecase.GetBatchItems(r.Context(), batchId)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	if r.URL.Query().Get("format") == batch.BATCH_FORMAT_JSON {
+		resp := &response.Response[[]batch.BatchItem]{}
+		resp = result
+		resp.Success(response.STATUS_SUCCESS, *resp.Data)
+		resp.WriteResponse(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="batch-%s-report.csv"`, batchId))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write(batchReportColumns)
+	for _, item := range *result.Data {
+		writer.Write([]string{
+			strconv.Itoa(item.Line),
+			item.WalletId,
+			item.Type,
+			strconv.Itoa(item.Amount),
+			item.ReferenceId,
+			item.Status,
+			valueOrEmpty(item.Error),
+			valueOrEmpty(item.TransactionId),
+		})
+	}
+	writer.Flush()
+}
+
+// decodeBatchRequest reads the json or csv file itself rather than through request.Decode,
+// the items are not form fields and the file has its own size limit
+func decodeBatchRequest(r *http.Request) (req batch.BatchRequest, err error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var body io.Reader = r.Body
+	format := batchFormat(mediaType, "")
+	if mediaType == "multipart/form-data" {
+		file, fileHeader, err := r.FormFile("file")
+		if err != nil {
+			return req, invalidBatchFile(err)
+		}
+		defer file.Close()
+
+		fileMediaType, _, _ := mime.ParseMediaType(fileHeader.Header.Get("Content-Type"))
+		body = file
+		format = batchFormat(fileMediaType, fileHeader.Filename)
+	}
+
+	req, err = parseBatch(format, body)
+	if err != nil {
+		return req, err
+	}
+
+	if req.Mode == "" {
+		req.Mode = r.FormValue("mode")
+	}
+
+	return req, nil
+}
+
+// batchFormat tells the file format from its media type, or else its file name
+func batchFormat(mediaType string, filename string) string {
+	switch {
+	case mediaType == "application/json" || strings.EqualFold(filepath.Ext(filename), ".json"):
+		return batch.BATCH_FORMAT_JSON
+	case mediaType == "text/csv" || strings.EqualFold(filepath.Ext(filename), ".csv"):
+		return batch.BATCH_FORMAT_CSV
+	}
+
+	return ""
+}
+
+func valueOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
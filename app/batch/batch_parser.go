@@ -0,0 +1,101 @@
+package batch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mini-wallet/domain/batch"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// every column is required, in any order
+	batchCsvColumns = []string{"wallet_id", "type", "amount", "reference_id"}
+)
+
+// parseBatch reads the uploaded file, an item with invalid values is kept as is and fails validation later
+func parseBatch(format string, body io.Reader) (req batch.BatchRequest, err error) {
+	switch format {
+	case batch.BATCH_FORMAT_JSON:
+		return parseBatchJson(body)
+	case batch.BATCH_FORMAT_CSV:
+		return parseBatchCsv(body)
+	}
+
+	return req, response.ErrBatchInvalidFile
+}
+
+// parseBatchJson reads {"mode": "...", "items": [{"wallet_id", "type", "amount", "reference_id"}]}
+func parseBatchJson(body io.Reader) (req batch.BatchRequest, err error) {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&req); err != nil {
+		return req, invalidBatchFile(err)
+	}
+
+	return req, nil
+}
+
+// parseBatchCsv reads a header line naming the columns, then one item per line
+func parseBatchCsv(body io.Reader) (req batch.BatchRequest, err error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return req, invalidBatchFile(err)
+	}
+
+	columns := map[string]int{}
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	for _, column := range batchCsvColumns {
+		if _, ok := columns[column]; !ok {
+			return req, response.ErrBatchInvalidFile
+		}
+	}
+	if len(columns) != len(batchCsvColumns) {
+		return req, response.ErrBatchInvalidFile
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return req, invalidBatchFile(err)
+		}
+
+		// not a number fails the amount validation, like the form handlers
+		amount, err := strconv.Atoi(strings.TrimSpace(record[columns["amount"]]))
+		if err != nil {
+			amount = 0
+		}
+
+		req.Items = append(req.Items, wallet.WalletTransactionRequest{
+			WalletId:    strings.TrimSpace(record[columns["wallet_id"]]),
+			Type:        strings.ToLower(strings.TrimSpace(record[columns["type"]])),
+			Amount:      amount,
+			ReferenceId: strings.TrimSpace(record[columns["reference_id"]]),
+		})
+	}
+
+	return req, nil
+}
+
+// invalidBatchFile tells a body over the size limit apart from a malformed one
+func invalidBatchFile(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return response.ErrBatchTooLarge
+	}
+
+	return response.ErrBatchInvalidFile
+}
@@ -0,0 +1,63 @@
+package batch
+
+import (
+	"errors"
+	"mini-wallet/domain/batch"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBatchCsv(t *testing.T) {
+	req, err := parseBatch(batch.BATCH_FORMAT_CSV, strings.NewReader(
+		"reference_id,wallet_id,type,amount\n"+
+			"ref-1, wallet-a, deposit, 1000\n"+
+			"ref-2,wallet-b,WITHDRAWAL,abc\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []wallet.WalletTransactionRequest{
+		{WalletId: "wallet-a", Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: 1000, ReferenceId: "ref-1"},
+		// kept, fails the amount validation later
+		{WalletId: "wallet-b", Type: wallet.WALLET_TRANSACTION_WITHDRAWAL, Amount: 0, ReferenceId: "ref-2"},
+	}
+	if !reflect.DeepEqual(req.Items, want) {
+		t.Errorf("items = %+v, want %+v", req.Items, want)
+	}
+}
+
+func TestParseBatchJson(t *testing.T) {
+	req, err := parseBatch(batch.BATCH_FORMAT_JSON, strings.NewReader(
+		`{"mode": "all_or_nothing", "items": [{"wallet_id": "wallet-a", "type": "deposit", "amount": 1000, "reference_id": "ref-1"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Mode != batch.BATCH_MODE_ALL_OR_NOTHING || len(req.Items) != 1 || req.Items[0].Amount != 1000 {
+		t.Errorf("req = %+v", req)
+	}
+}
+
+func TestParseBatchRejectsMalformedFiles(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		format string
+		body   string
+	}{
+		"csv missing column":  {batch.BATCH_FORMAT_CSV, "wallet_id,type,amount\nwallet-a,deposit,1\n"},
+		"csv extra column":    {batch.BATCH_FORMAT_CSV, "wallet_id,type,amount,reference_id,note\nwallet-a,deposit,1,ref-1,x\n"},
+		"csv ragged line":     {batch.BATCH_FORMAT_CSV, "wallet_id,type,amount,reference_id\nwallet-a,deposit\n"},
+		"json unknown field":  {batch.BATCH_FORMAT_JSON, `{"items": [{"wallet": "wallet-a"}]}`},
+		"json amount as text": {batch.BATCH_FORMAT_JSON, `{"items": [{"amount": "1000"}]}`},
+		"unknown format":      {"", "wallet_id,type,amount,reference_id\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseBatch(testCase.format, strings.NewReader(testCase.body))
+			if err == nil || !errors.Is(err, response.ErrBatchInvalidFile) {
+				t.Errorf("err = %v, want %q", err, response.ERROR_BATCH_INVALID_FILE)
+			}
+		})
+	}
+}
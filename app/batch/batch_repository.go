@@ -0,0 +1,117 @@
+package batch
+
+import (
+	"context"
+	"mini-wallet/domain/batch"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"gorm.io/gorm"
+)
+
+const (
+	insertBatchItemsChunk = 500
+)
+
+type batchRepository struct {
+	db *gorm.DB
+}
+
+func NewBatchRepository(db *gorm.DB) batch.BatchRepository {
+	return &batchRepository{
+		db: db,
+	}
+}
+
+func (batchRepository *batchRepository) InsertBatch(ctx context.Context, batchData batch.Batch, items []batch.BatchItem) (err error) {
+	return batchRepository.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("tr_batch").Create(&batchData).Error; err != nil {
+			return err
+		}
+
+		return tx.Table("tr_batch_item").CreateInBatches(items, insertBatchItemsChunk).Error
+	})
+}
+
+func (batchRepository *batchRepository) GetBatchById(ctx context.Context, batchId string) (res *batch.Batch, err error) {
+	qry, args, err := batchRepository.selectBatches().Where(sq.Eq{"b.id": batchId}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	result := batchRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+func (batchRepository *batchRepository) GetBatchItems(ctx context.Context, batchId string) (res []batch.BatchItem, err error) {
+	qry, args, err := sq.Select("*").From("tr_batch_item").Where(sq.Eq{"batch_id": batchId}).OrderBy("line").ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = batchRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (batchRepository *batchRepository) GetUnfinishedBatches(ctx context.Context) (res []batch.Batch, err error) {
+	qry, args, err := batchRepository.selectBatches().
+		Where(sq.Eq{"b.status": []string{batch.BATCH_STATUS_PENDING, batch.BATCH_STATUS_PROCESSING}}).
+		OrderBy("b.created_at").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = batchRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (batchRepository *batchRepository) UpdateBatch(ctx context.Context, batchData batch.Batch) (err error) {
+	return batchRepository.db.WithContext(ctx).Table("tr_batch").Where("id", batchData.Id).Updates(map[string]interface{}{
+		"status":      batchData.Status,
+		"error":       batchData.Error,
+		"finished_at": batchData.FinishedAt,
+	}).Error
+}
+
+func (batchRepository *batchRepository) UpdateBatchItems(ctx context.Context, items []batch.BatchItem) (err error) {
+	return batchRepository.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			err := tx.Table("tr_batch_item").Where("batch_id = ? AND line = ?", item.BatchId, item.Line).Updates(map[string]interface{}{
+				"status":         item.Status,
+				"error":          item.Error,
+				"transaction_id": item.TransactionId,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// selectBatches counts the items per status along with every batch
+func (batchRepository *batchRepository) selectBatches() sq.SelectBuilder {
+	return sq.Select(
+		"b.*",
+		"COUNT(i.line) FILTER (WHERE i.status = 'succeeded') AS succeeded_items",
+		"COUNT(i.line) FILTER (WHERE i.status IN ('failed', 'not_applied')) AS failed_items",
+		"COUNT(i.line) FILTER (WHERE i.status = 'pending') AS pending_items",
+	).From("tr_batch b").LeftJoin("tr_batch_item i ON i.batch_id = b.id").GroupBy("b.id")
+}
@@ -0,0 +1,182 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"mini-wallet/domain/batch"
+	"mini-wallet/domain/common/response"
+	"slices"
+	"sort"
+	"sync"
+)
+
+type memoryBatchRepository struct {
+	mu      sync.RWMutex
+	batches map[string]batch.Batch
+	items   map[string][]batch.BatchItem // by batch id, in line order
+}
+
+// NewMemoryBatchRepository keeps the batches and their items in this process, for tests and the memory storage
+func NewMemoryBatchRepository() batch.BatchRepository {
+	return &memoryBatchRepository{
+		batches: map[string]batch.Batch{},
+		items:   map[string][]batch.BatchItem{},
+	}
+}
+
+func (batchRepository *memoryBatchRepository) InsertBatch(ctx context.Context, batchData batch.Batch, items []batch.BatchItem) (err error) {
+	if err = checkBatch(batchData); err != nil {
+		return err
+	}
+
+	storedItems := slices.Clone(items)
+	sort.Slice(storedItems, func(i, j int) bool { return storedItems[i].Line < storedItems[j].Line })
+	for i, item := range storedItems {
+		if item.BatchId != batchData.Id {
+			return fmt.Errorf("batch item %d belongs to batch %s", item.Line, item.BatchId)
+		}
+		if i > 0 && storedItems[i-1].Line == item.Line {
+			return fmt.Errorf("batch item %d already exists", item.Line)
+		}
+		if err = checkBatchItem(item); err != nil {
+			return err
+		}
+	}
+
+	batchRepository.mu.Lock()
+	defer batchRepository.mu.Unlock()
+
+	if _, found := batchRepository.batches[batchData.Id]; found {
+		return fmt.Errorf("batch %s already exists", batchData.Id)
+	}
+	batchRepository.batches[batchData.Id] = batchData
+	batchRepository.items[batchData.Id] = storedItems
+
+	return nil
+}
+
+func (batchRepository *memoryBatchRepository) GetBatchById(ctx context.Context, batchId string) (res *batch.Batch, err error) {
+	batchRepository.mu.RLock()
+	defer batchRepository.mu.RUnlock()
+
+	batchData, found := batchRepository.batches[batchId]
+	if !found {
+		return nil, nil
+	}
+	batchData = batchRepository.countItems(batchData)
+
+	return &batchData, nil
+}
+
+func (batchRepository *memoryBatchRepository) GetBatchItems(ctx context.Context, batchId string) (res []batch.BatchItem, err error) {
+	batchRepository.mu.RLock()
+	defer batchRepository.mu.RUnlock()
+
+	return slices.Clone(batchRepository.items[batchId]), nil
+}
+
+func (batchRepository *memoryBatchRepository) GetUnfinishedBatches(ctx context.Context) (res []batch.Batch, err error) {
+	batchRepository.mu.RLock()
+	defer batchRepository.mu.RUnlock()
+
+	for _, batchData := range batchRepository.batches {
+		if batchData.Status == batch.BATCH_STATUS_PENDING || batchData.Status == batch.BATCH_STATUS_PROCESSING {
+			res = append(res, batchRepository.countItems(batchData))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].CreatedAt != res[j].CreatedAt {
+			return res[i].CreatedAt < res[j].CreatedAt
+		}
+		return res[i].Id < res[j].Id
+	})
+
+	return res, nil
+}
+
+func (batchRepository *memoryBatchRepository) UpdateBatch(ctx context.Context, batchData batch.Batch) (err error) {
+	batchRepository.mu.Lock()
+	defer batchRepository.mu.Unlock()
+
+	storedBatch, found := batchRepository.batches[batchData.Id]
+	if !found {
+		return nil
+	}
+
+	storedBatch.Status = batchData.Status
+	storedBatch.Error = batchData.Error
+	storedBatch.FinishedAt = batchData.FinishedAt
+	if err = checkBatch(storedBatch); err != nil {
+		return err
+	}
+	batchRepository.batches[batchData.Id] = storedBatch
+
+	return nil
+}
+
+// UpdateBatchItems writes every item or none, like the database transaction of the postgres one
+func (batchRepository *memoryBatchRepository) UpdateBatchItems(ctx context.Context, items []batch.BatchItem) (err error) {
+	for _, item := range items {
+		if err = checkBatchItem(item); err != nil {
+			return err
+		}
+	}
+
+	batchRepository.mu.Lock()
+	defer batchRepository.mu.Unlock()
+
+	for _, item := range items {
+		storedItems := batchRepository.items[item.BatchId]
+		i, found := sort.Find(len(storedItems), func(i int) int { return item.Line - storedItems[i].Line })
+		if !found {
+			continue
+		}
+
+		storedItems[i].Status = item.Status
+		storedItems[i].Error = item.Error
+		storedItems[i].TransactionId = item.TransactionId
+	}
+
+	return nil
+}
+
+// countItems counts the items per status like the select of the postgres one, it must be called with mu held
+func (batchRepository *memoryBatchRepository) countItems(batchData batch.Batch) batch.Batch {
+	batchData.SucceededItems, batchData.FailedItems, batchData.PendingItems = 0, 0, 0
+	for _, item := range batchRepository.items[batchData.Id] {
+		switch item.Status {
+		case batch.BATCH_ITEM_STATUS_SUCCEEDED:
+			batchData.SucceededItems++
+		case batch.BATCH_ITEM_STATUS_FAILED, batch.BATCH_ITEM_STATUS_NOT_APPLIED:
+			batchData.FailedItems++
+		case batch.BATCH_ITEM_STATUS_PENDING:
+			batchData.PendingItems++
+		}
+	}
+
+	return batchData
+}
+
+// checkBatch mirrors the check constraints of tr_batch
+func checkBatch(batchData batch.Batch) error {
+	if batchData.Mode != batch.BATCH_MODE_ALL_OR_NOTHING && batchData.Mode != batch.BATCH_MODE_BEST_EFFORT {
+		return response.ErrBadRequest
+	}
+
+	switch batchData.Status {
+	case batch.BATCH_STATUS_PENDING, batch.BATCH_STATUS_PROCESSING, batch.BATCH_STATUS_COMPLETED, batch.BATCH_STATUS_FAILED:
+		return nil
+	}
+
+	return response.ErrBadRequest
+}
+
+// checkBatchItem mirrors the check constraints of tr_batch_item
+func checkBatchItem(item batch.BatchItem) error {
+	switch item.Status {
+	case batch.BATCH_ITEM_STATUS_PENDING, batch.BATCH_ITEM_STATUS_SUCCEEDED, batch.BATCH_ITEM_STATUS_FAILED, batch.BATCH_ITEM_STATUS_NOT_APPLIED:
+		return nil
+	}
+
+	return response.ErrBadRequest
+}
@@ -0,0 +1,410 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mini-wallet/domain"
+	"mini-wallet/domain/batch"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type batchUsecase struct {
+	batchRepository  batch.BatchRepository
+	walletRepository wallet.WalletRepository
+	walletUsecase    wallet.WalletUsecase
+	config           infrastructure.Config
+}
+
+// NewBatchUsecase applies the best_effort items through walletUsecase, so they get the same checks,
+// locking and metrics as a single deposit or withdrawal
+func NewBatchUsecase(repositories domain.Repositories, walletUsecase wallet.WalletUsecase, config infrastructure.Config) batch.BatchUsecase {
+	return &batchUsecase{
+		batchRepository:  repositories.BatchRepository,
+		walletRepository: repositories.WalletRepository,
+		walletUsecase:    walletUsecase,
+		config:           config,
+	}
+}
+
+func (usecase *batchUsecase) CreateBatch(ctx context.Context, req batch.BatchRequest) (res *response.Response[batch.Batch], err error) {
+	if req.Mode == "" {
+		req.Mode = batch.BATCH_MODE_BEST_EFFORT
+	}
+	if req.Mode != batch.BATCH_MODE_BEST_EFFORT && req.Mode != batch.BATCH_MODE_ALL_OR_NOTHING {
+		return nil, response.ErrBadRequest
+	}
+
+	if len(req.Items) == 0 {
+		return nil, response.ErrBatchInvalidFile
+	}
+	if len(req.Items) > usecase.config.BATCH_MAX_ITEMS {
+		return nil, response.ErrBatchTooLarge
+	}
+
+	batchId, err := uuid.NewV7()
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on uuid.NewV7() - CreateBatch", err)
+		return nil, err
+	}
+
+	batchData := batch.Batch{
+		Id:         batchId.String(),
+		Mode:       req.Mode,
+		Status:     batch.BATCH_STATUS_PENDING,
+		TotalItems: len(req.Items),
+		CreatedAt:  time.Now().Format(time.RFC3339),
+	}
+
+	var invalidItem *batch.BatchItem
+	items := make([]batch.BatchItem, len(req.Items))
+	for i, itemRequest := range req.Items {
+		items[i] = batch.BatchItem{
+			BatchId:     batchData.Id,
+			Line:        i + 1,
+			WalletId:    itemRequest.WalletId,
+			Type:        itemRequest.Type,
+			Amount:      itemRequest.Amount,
+			ReferenceId: itemRequest.ReferenceId,
+			Status:      batch.BATCH_ITEM_STATUS_PENDING,
+		}
+
+		if err := validateBatchItem(itemRequest); err != nil {
+			items[i].Fail(err)
+			if invalidItem == nil {
+				invalidItem = &items[i]
+			}
+		}
+	}
+
+	// an all_or_nothing batch with an invalid item fails right away, nothing to process
+	if req.Mode == batch.BATCH_MODE_ALL_OR_NOTHING && invalidItem != nil {
+		failBatch(&batchData, items, invalidItem)
+	}
+
+	if err = usecase.batchRepository.InsertBatch(ctx, batchData, items); err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.batchRepository.InsertBatch() - CreateBatch", err)
+		return nil, err
+	}
+
+	if batchData.Status == batch.BATCH_STATUS_PENDING {
+		// the batch outlives the upload request, the request id and trace are kept for the logs
+		go usecase.processBatch(context.WithoutCancel(ctx), batchData)
+	}
+
+	return usecase.GetBatch(ctx, batchData.Id)
+}
+
+func (usecase *batchUsecase) GetBatch(ctx context.Context, batchId string) (res *response.Response[batch.Batch], err error) {
+	batchData, err := usecase.batchRepository.GetBatchById(ctx, batchId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.batchRepository.GetBatchById() - GetBatch", err)
+		return nil, err
+	}
+
+	if batchData == nil {
+		return nil, response.ErrBatchNotFound
+	}
+
+	return &response.Response[batch.Batch]{
+		Data: batchData,
+	}, nil
+}
+
+func (usecase *batchUsecase) GetBatchItems(ctx context.Context, batchId string) (res *response.Response[[]batch.BatchItem], err error) {
+	if _, err = usecase.GetBatch(ctx, batchId); err != nil {
+		return nil, err
+	}
+
+	items, err := usecase.batchRepository.GetBatchItems(ctx, batchId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.batchRepository.GetBatchItems() - GetBatchItems", err)
+		return nil, err
+	}
+
+	return &response.Response[[]batch.BatchItem]{
+		Data: &items,
+	}, nil
+}
+
+// ResumeBatches processes the batches a previous run left pending or processing, one after another.
+// an item applied before the restart is recognised by its reference id and not applied twice
+func (usecase *batchUsecase) ResumeBatches(ctx context.Context) (err error) {
+	batches, err := usecase.batchRepository.GetUnfinishedBatches(ctx)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.batchRepository.GetUnfinishedBatches() - ResumeBatches", err)
+		return err
+	}
+
+	for _, batchData := range batches {
+		infrastructure.LogInfo(ctx, "resuming batch", "batch_id", batchData.Id, "pending_items", batchData.PendingItems)
+		usecase.processBatch(ctx, batchData)
+	}
+
+	return nil
+}
+
+func (usecase *batchUsecase) processBatch(ctx context.Context, batchData batch.Batch) {
+	var err error
+	ctx, span := infrastructure.StartSpan(ctx, "batchUsecase.processBatch",
+		attribute.String("batch.id", batchData.Id),
+		attribute.String("batch.mode", batchData.Mode),
+	)
+	defer infrastructure.EndSpan(span, &err)
+
+	batchData.Status = batch.BATCH_STATUS_PROCESSING
+	if err = usecase.batchRepository.UpdateBatch(ctx, batchData); err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.batchRepository.UpdateBatch() - processBatch", err, "batch_id", batchData.Id)
+		return
+	}
+
+	items, err := usecase.batchRepository.GetBatchItems(ctx, batchData.Id)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.batchRepository.GetBatchItems() - processBatch", err, "batch_id", batchData.Id)
+		return
+	}
+
+	switch batchData.Mode {
+	case batch.BATCH_MODE_ALL_OR_NOTHING:
+		err = usecase.processAllOrNothing(ctx, &batchData, items)
+	default:
+		err = usecase.processBestEffort(ctx, items)
+	}
+	if err != nil {
+		// left as processing, ResumeBatches picks it up again
+		infrastructure.LogError(ctx, "got error on processing the batch items - processBatch", err, "batch_id", batchData.Id)
+		return
+	}
+
+	if batchData.Status == batch.BATCH_STATUS_PROCESSING {
+		batchData.Status = batch.BATCH_STATUS_COMPLETED
+	}
+	finishedAt := time.Now().Format(time.RFC3339)
+	batchData.FinishedAt = &finishedAt
+	if err = usecase.batchRepository.UpdateBatch(ctx, batchData); err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.batchRepository.UpdateBatch() - processBatch", err, "batch_id", batchData.Id)
+		return
+	}
+
+	infrastructure.LogInfo(ctx, "batch finished", "batch_id", batchData.Id, "mode", batchData.Mode, "status", batchData.Status)
+}
+
+// processBestEffort applies the pending items one by one through walletUsecase.
+// the items of a wallet stay in file order, different wallets are processed concurrently
+func (usecase *batchUsecase) processBestEffort(ctx context.Context, items []batch.BatchItem) (err error) {
+	var walletIds []string
+	itemsByWallet := map[string][]batch.BatchItem{}
+	// the transactions an item of this batch already stands for, by wallet. a wallet is only ever
+	// processed by one worker, so its set is never shared
+	claimedByWallet := map[string]map[string]struct{}{}
+	for _, item := range items {
+		if _, ok := claimedByWallet[item.WalletId]; !ok {
+			claimedByWallet[item.WalletId] = map[string]struct{}{}
+		}
+		if item.TransactionId != nil {
+			claimedByWallet[item.WalletId][*item.TransactionId] = struct{}{}
+		}
+		if item.Status != batch.BATCH_ITEM_STATUS_PENDING {
+			continue
+		}
+		if _, seen := itemsByWallet[item.WalletId]; !seen {
+			walletIds = append(walletIds, item.WalletId)
+		}
+		itemsByWallet[item.WalletId] = append(itemsByWallet[item.WalletId], item)
+	}
+
+	walletQueue := make(chan string)
+	errs := make(chan error, usecase.config.BATCH_CONCURRENCY)
+	var wg sync.WaitGroup
+	for worker := 0; worker < usecase.config.BATCH_CONCURRENCY; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for walletId := range walletQueue {
+				for _, item := range itemsByWallet[walletId] {
+					usecase.applyItem(ctx, &item, claimedByWallet[walletId])
+					if err := usecase.batchRepository.UpdateBatchItems(ctx, []batch.BatchItem{item}); err != nil {
+						errs <- err
+						return
+					}
+					infrastructure.BatchItemsTotal.WithLabelValues(batch.BATCH_MODE_BEST_EFFORT, item.Status).Inc()
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(walletQueue)
+		for _, walletId := range walletIds {
+			select {
+			case walletQueue <- walletId:
+			case err := <-errs:
+				// stop handing out work, the batch is resumed later
+				errs <- err
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	select {
+	case err = <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// applyItem records the outcome on item, a reference id already used by the very same transaction
+// counts as applied, so processing a batch again never applies an item twice. unless another item of the batch
+// stands for that transaction already: a line repeated within the file is a conflict, not a second success
+func (usecase *batchUsecase) applyItem(ctx context.Context, item *batch.BatchItem, claimed map[string]struct{}) {
+	_, err := usecase.walletUsecase.CreateWalletTransaction(ctx, item.ToTransactionRequest())
+	if err != nil && !errors.Is(err, response.ErrReferenceIdConflict) {
+		item.Fail(err)
+		return
+	}
+
+	applied, lookupErr := usecase.findAppliedTransaction(ctx, *item)
+	switch {
+	case lookupErr != nil:
+		item.Fail(lookupErr)
+	case applied == nil && err != nil:
+		item.Fail(err)
+	case applied == nil:
+		item.Fail(response.ErrWalletConcurrent)
+	default:
+		if _, ok := claimed[applied.Id]; ok {
+			item.Fail(response.ErrReferenceIdConflict)
+			return
+		}
+		claimed[applied.Id] = struct{}{}
+		item.Succeed(applied.Id)
+	}
+}
+
+// processAllOrNothing applies every item in one database transaction
+func (usecase *batchUsecase) processAllOrNothing(ctx context.Context, batchData *batch.Batch, items []batch.BatchItem) (err error) {
+	// a previous run may have committed it right before stopping
+	applied, err := usecase.findAppliedTransaction(ctx, items[0])
+	if err != nil {
+		return err
+	}
+	if applied != nil {
+		for i := range items {
+			applied, err := usecase.findAppliedTransaction(ctx, items[i])
+			if err != nil {
+				return err
+			}
+			if applied == nil {
+				return fmt.Errorf("batch %s is partially applied, line %d is missing", batchData.Id, items[i].Line)
+			}
+			items[i].Succeed(applied.Id)
+		}
+
+		return usecase.batchRepository.UpdateBatchItems(ctx, items)
+	}
+
+	// the rows are locked in wallet order, so two batches touching the same wallets can not deadlock,
+	// the file order is kept within a wallet
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return items[order[a]].WalletId < items[order[b]].WalletId
+	})
+
+	createdAt := time.Now().Format(time.RFC3339)
+	walletTransactions := make([]wallet.WalletTransactionEntity, len(order))
+	for i, itemIndex := range order {
+		transactionId, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+
+		walletTransactions[i] = wallet.WalletTransactionEntity{
+			Id:          transactionId.String(),
+			WalletId:    items[itemIndex].WalletId,
+			Amount:      items[itemIndex].Amount,
+			CreatedAt:   createdAt,
+			Type:        items[itemIndex].Type,
+			Status:      wallet.WALLET_TRANSACTION_STATUS_SUCCESS,
+			ReferenceId: items[itemIndex].ReferenceId,
+		}
+	}
+
+	err = usecase.walletRepository.CreateWalletTransactionsAtomically(ctx, walletTransactions)
+	var itemError *wallet.TransactionItemError
+	switch {
+	case errors.As(err, &itemError):
+		failedItem := &items[order[itemError.Index]]
+		failedItem.Fail(itemError.Err)
+		failBatch(batchData, items, failedItem)
+	case err != nil:
+		return err
+	default:
+		for i, itemIndex := range order {
+			items[itemIndex].Succeed(walletTransactions[i].Id)
+		}
+	}
+
+	for _, item := range items {
+		infrastructure.BatchItemsTotal.WithLabelValues(batch.BATCH_MODE_ALL_OR_NOTHING, item.Status).Inc()
+	}
+
+	return usecase.batchRepository.UpdateBatchItems(ctx, items)
+}
+
+func (usecase *batchUsecase) findAppliedTransaction(ctx context.Context, item batch.BatchItem) (res *wallet.WalletTransactionEntity, err error) {
+	walletTransaction, err := usecase.walletRepository.GetWalletTransactionByReferenceId(ctx, item.WalletId, item.ReferenceId)
+	if err != nil {
+		return nil, err
+	}
+
+	if walletTransaction == nil || walletTransaction.Type != item.Type || walletTransaction.Amount != item.Amount {
+		return nil, nil
+	}
+
+	return walletTransaction, nil
+}
+
+// validateBatchItem runs the checks of a single deposit or withdrawal request
+func validateBatchItem(itemRequest wallet.WalletTransactionRequest) error {
+	if len(itemRequest.WalletId) == 0 {
+		return response.ErrBadRequest
+	}
+
+	if err := itemRequest.Validate(); err != nil {
+		return err
+	}
+
+	if limits := infrastructure.GetLimits(); limits.MAX_TRANSACTION_AMOUNT > 0 && itemRequest.Amount > limits.MAX_TRANSACTION_AMOUNT {
+		return response.ErrBadRequest
+	}
+
+	return nil
+}
+
+// failBatch marks every other item as not applied because of failedItem
+func failBatch(batchData *batch.Batch, items []batch.BatchItem, failedItem *batch.BatchItem) {
+	for i := range items {
+		if &items[i] != failedItem && items[i].Status != batch.BATCH_ITEM_STATUS_FAILED {
+			items[i].Status = batch.BATCH_ITEM_STATUS_NOT_APPLIED
+		}
+	}
+
+	batchError := fmt.Sprintf("line %d: %s", failedItem.Line, *failedItem.Error)
+	finishedAt := time.Now().Format(time.RFC3339)
+	batchData.Status = batch.BATCH_STATUS_FAILED
+	batchData.Error = &batchError
+	batchData.FinishedAt = &finishedAt
+}
@@ -0,0 +1,100 @@
+package batch
+
+import (
+	"context"
+	walletApp "mini-wallet/app/wallet"
+	"mini-wallet/domain"
+	"mini-wallet/domain/batch"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// itemRecorder keeps the items written by processBestEffort, the rest of the repository is not needed
+type itemRecorder struct {
+	batch.BatchRepository
+
+	mu    sync.Mutex
+	items map[int]batch.BatchItem
+}
+
+func (recorder *itemRecorder) UpdateBatchItems(ctx context.Context, items []batch.BatchItem) error {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	for _, item := range items {
+		recorder.items[item.Line] = item
+	}
+	return nil
+}
+
+func TestBestEffortRepeatedLineIsAConflict(t *testing.T) {
+	ctx := context.Background()
+	config := infrastructure.Config{
+		WALLET_LOCK_TTL:            time.Second * 8,
+		WALLET_TRANSACTION_TIMEOUT: time.Second * 30,
+		BATCH_CONCURRENCY:          2,
+	}
+	recorder := &itemRecorder{items: map[int]batch.BatchItem{}}
+	repositories := domain.Repositories{
+		WalletRepository: walletApp.NewMemoryWalletRepository(),
+		BatchRepository:  recorder,
+	}
+	walletUsecase := walletApp.NewWalletUsecase(repositories, infrastructure.NewMemoryCache(), walletApp.NewMemoryWalletLocker(config.WALLET_LOCK_TTL), config)
+	usecase := NewBatchUsecase(repositories, walletUsecase, config).(*batchUsecase)
+
+	enabledAt := time.Now().Format(time.RFC3339)
+	testWallet := wallet.Wallet{
+		Id:        uuid.NewString(),
+		OwnedBy:   uuid.NewString(),
+		Name:      wallet.WALLET_MAIN_POCKET,
+		EnabledAt: &enabledAt,
+		Status:    wallet.WALLET_STATUS_ENABLED,
+	}
+	if err := repositories.WalletRepository.InsertWallet(ctx, testWallet); err != nil {
+		t.Fatal(err)
+	}
+
+	// applied by a previous run which stopped before recording line 3
+	if _, err := walletUsecase.CreateWalletTransaction(ctx, wallet.WalletTransactionRequest{
+		WalletId: testWallet.Id, Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: 30, ReferenceId: "ref-3",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	newItem := func(line int, amount int, referenceId string) batch.BatchItem {
+		return batch.BatchItem{
+			Line: line, WalletId: testWallet.Id, Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: amount,
+			ReferenceId: referenceId, Status: batch.BATCH_ITEM_STATUS_PENDING,
+		}
+	}
+	items := []batch.BatchItem{newItem(1, 10, "ref-1"), newItem(2, 10, "ref-1"), newItem(3, 30, "ref-3"), newItem(4, 30, "ref-3")}
+	if err := usecase.processBestEffort(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+
+	for line, want := range map[int]string{
+		1: batch.BATCH_ITEM_STATUS_SUCCEEDED,
+		2: batch.BATCH_ITEM_STATUS_FAILED,
+		3: batch.BATCH_ITEM_STATUS_SUCCEEDED,
+		4: batch.BATCH_ITEM_STATUS_FAILED,
+	} {
+		item := recorder.items[line]
+		if item.Status != want {
+			t.Errorf("line %d = %s, want %s", line, item.Status, want)
+		}
+		if want == batch.BATCH_ITEM_STATUS_FAILED && (item.Error == nil || *item.Error != response.ERROR_REFERENCE_ID_CONFLICT) {
+			t.Errorf("line %d error = %v, want %q", line, item.Error, response.ERROR_REFERENCE_ID_CONFLICT)
+		}
+	}
+
+	walletResult, err := repositories.WalletRepository.GetWalletById(ctx, testWallet.Id)
+	if err != nil || walletResult.Balance != 40 {
+		t.Errorf("wallet = %+v %v, want a balance of 40", walletResult, err)
+	}
+}
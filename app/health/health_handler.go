@@ -0,0 +1,40 @@
+package health
+
+import (
+	"mini-wallet/domain"
+	"mini-wallet/domain/health"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type healthHandler struct {
+	healthUsecase health.HealthUsecase
+}
+
+func SetHealthHandler(router *chi.Mux, usecases domain.Usecases) {
+	healthHandler := healthHandler{
+		healthUsecase: usecases.HealthUsecase,
+	}
+
+	router.Get("/healthz", healthHandler.Liveness)
+	router.Get("/readyz", healthHandler.Readiness)
+
+	router.Route("/debug", func(r chi.Router) {
+		r.Use(usecases.AuthUsecase.AuthorizeAdminMiddleware)
+
+		r.Get("/status", healthHandler.Status)
+	})
+}
+
+func (handler *healthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	handler.healthUsecase.Liveness(r.Context()).WriteResponse(w)
+}
+
+func (handler *healthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	handler.healthUsecase.Readiness(r.Context()).WriteResponse(w)
+}
+
+func (handler *healthHandler) Status(w http.ResponseWriter, r *http.Request) {
+	handler.healthUsecase.Status(r.Context()).WriteResponse(w)
+}
@@ -0,0 +1,182 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/health"
+	"mini-wallet/infrastructure"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis"
+	"gorm.io/gorm"
+)
+
+const (
+	checkTimeout = time.Second * 2
+)
+
+type healthUsecase struct {
+	db          *gorm.DB
+	redisClient redis.Client
+}
+
+func NewHealthUsecase(db *gorm.DB, redisClient redis.Client) health.HealthUsecase {
+	return &healthUsecase{
+		db:          db,
+		redisClient: redisClient,
+	}
+}
+
+// NewMemoryHealthUsecase is always ready, the memory storage has no dependency to check
+func NewMemoryHealthUsecase() health.HealthUsecase {
+	return &healthUsecase{}
+}
+
+// Liveness only tells that the process is able to serve http,
+// dependencies are not checked so the orchestrator won't restart us when postgres is down
+func (usecase *healthUsecase) Liveness(ctx context.Context) (res *response.Response[health.Report]) {
+	return &response.Response[health.Report]{
+		Status:     response.STATUS_SUCCESS,
+		StatusCode: http.StatusOK,
+		Data: &health.Report{
+			Status: health.CHECK_STATUS_UP,
+		},
+	}
+}
+
+func (usecase *healthUsecase) Readiness(ctx context.Context) (res *response.Response[health.Report]) {
+	report := usecase.readinessReport(ctx)
+
+	// /readyz is not protected, error details (hosts, users) are only shown on /debug/status
+	for i := range report.Checks {
+		report.Checks[i].Error = ""
+	}
+
+	res = &response.Response[health.Report]{
+		Status:     response.STATUS_SUCCESS,
+		StatusCode: http.StatusOK,
+		Data:       &report,
+	}
+
+	if report.Status != health.CHECK_STATUS_UP {
+		res.Status = response.STATUS_FAIL
+		res.StatusCode = http.StatusServiceUnavailable
+	}
+
+	return res
+}
+
+func (usecase *healthUsecase) Status(ctx context.Context) (res *response.Response[health.Status]) {
+	status := health.Status{
+		Readiness: usecase.readinessReport(ctx),
+		Build: health.BuildInfo{
+			Version:   infrastructure.Version,
+			Commit:    infrastructure.Commit,
+			BuildTime: infrastructure.BuildTime,
+			GoVersion: infrastructure.GoVersion(),
+		},
+		Uptime: infrastructure.Uptime().Round(time.Second).String(),
+	}
+
+	// the memory storage has no pool to report
+	if usecase.db != nil {
+		if sqlDb, err := usecase.db.DB(); err == nil {
+			status.PostgresPool = sqlDb.Stats()
+		}
+
+		if poolStats := usecase.redisClient.PoolStats(); poolStats != nil {
+			status.RedisPool = health.RedisPoolStats{
+				Hits:       poolStats.Hits,
+				Misses:     poolStats.Misses,
+				Timeouts:   poolStats.Timeouts,
+				TotalConns: poolStats.TotalConns,
+				IdleConns:  poolStats.IdleConns,
+				StaleConns: poolStats.StaleConns,
+			}
+		}
+	}
+
+	lockCounters := infrastructure.GetLockCounters()
+	status.Locks = health.LockStats{
+		Acquired:    lockCounters.Acquired,
+		Failed:      lockCounters.Failed,
+		Released:    lockCounters.Released,
+		WaitMsTotal: lockCounters.WaitMsTotal,
+	}
+
+	return &response.Response[health.Status]{
+		Status:     response.STATUS_SUCCESS,
+		StatusCode: http.StatusOK,
+		Data:       &status,
+	}
+}
+
+func (usecase *healthUsecase) readinessReport(ctx context.Context) health.Report {
+	// the memory storage has no dependency to check
+	if usecase.db == nil {
+		return health.Report{Status: health.CHECK_STATUS_UP, Checks: []health.Check{}}
+	}
+
+	report := health.Report{
+		Status: health.CHECK_STATUS_UP,
+		Checks: []health.Check{
+			usecase.runCheck(ctx, health.CHECK_POSTGRES, usecase.checkPostgres),
+			usecase.runCheck(ctx, health.CHECK_REDIS, usecase.checkRedis),
+			usecase.runCheck(ctx, health.CHECK_MIGRATION_VERSION, usecase.checkMigrationVersion),
+		},
+	}
+
+	if !report.IsUp() {
+		report.Status = health.CHECK_STATUS_DOWN
+	}
+
+	return report
+}
+
+func (usecase *healthUsecase) runCheck(ctx context.Context, name string, check func(ctx context.Context) (detail string, err error)) health.Check {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	startedAt := time.Now()
+	detail, err := check(ctx)
+
+	result := health.Check{
+		Name:      name,
+		Status:    health.CHECK_STATUS_UP,
+		LatencyMs: time.Since(startedAt).Milliseconds(),
+		Detail:    detail,
+	}
+
+	if err != nil {
+		infrastructure.LogWarn(ctx, "readiness check failed", "check", name, "error", err)
+		result.Status = health.CHECK_STATUS_DOWN
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+func (usecase *healthUsecase) checkPostgres(ctx context.Context) (detail string, err error) {
+	sqlDb, err := usecase.db.DB()
+	if err != nil {
+		return "", err
+	}
+
+	return "", sqlDb.PingContext(ctx)
+}
+
+func (usecase *healthUsecase) checkRedis(ctx context.Context) (detail string, err error) {
+	return "", infrastructure.PingRedis(ctx, usecase.redisClient)
+}
+
+// checkMigrationVersion fails when the database is not on the schema version this binary expects
+func (usecase *healthUsecase) checkMigrationVersion(ctx context.Context) (detail string, err error) {
+	version, err := infrastructure.CheckSchemaVersion(ctx, usecase.db)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d", version), nil
+}
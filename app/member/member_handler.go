@@ -0,0 +1,262 @@
+package member
+
+import (
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/request"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/member"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type memberHandler struct {
+	memberUsecase member.MemberUsecase
+}
+
+func SetMemberHandler(router *chi.Mux, usecases domain.Usecases) {
+	memberHandler := memberHandler{
+		memberUsecase: usecases.MemberUsecase,
+	}
+
+	spenders := usecases.AuthUsecase.RequireRoleMiddleware(member.MEMBER_ROLE_OWNER, member.MEMBER_ROLE_SPENDER)
+	owner := usecases.AuthUsecase.RequireRoleMiddleware(member.MEMBER_ROLE_OWNER)
+
+	// the members of the selected wallet, managed by its owner
+	router.Route("/api/v1/wallet/members", func(r chi.Router) {
+		r.Use(usecases.AuthUsecase.AuthorizeRequestMiddleware)
+		r.Use(usecases.AuthUsecase.SelectWalletMiddleware)
+		r.Use(owner)
+
+		// GET
+		r.Get("/", memberHandler.GetMembers)
+
+		// POST
+		r.Post("/", memberHandler.InviteMember)
+
+		// DELETE
+		r.Delete("/{memberId}", memberHandler.RemoveMember)
+	})
+
+	router.Route("/api/v1/wallet/approvals", func(r chi.Router) {
+		r.Use(usecases.AuthUsecase.AuthorizeRequestMiddleware)
+		r.Use(usecases.AuthUsecase.SelectWalletMiddleware)
+
+		// GET
+		r.With(spenders).Get("/", memberHandler.GetSpendingApprovals)
+
+		// POST
+		r.With(owner).Post("/{approvalId}/approve", memberHandler.ApproveSpending)
+		r.With(owner).Post("/{approvalId}/reject", memberHandler.RejectSpending)
+	})
+
+	// the wallets shared with the customer behind the token
+	router.Route("/api/v1/memberships", func(r chi.Router) {
+		r.Use(usecases.AuthUsecase.AuthorizeRequestMiddleware)
+		r.Use(usecases.AuthUsecase.SelectWalletMiddleware)
+
+		// GET
+		r.Get("/", memberHandler.GetMemberships)
+
+		// POST
+		r.Post("/{walletId}/accept", memberHandler.AcceptInvitation)
+
+		// DELETE
+		r.Delete("/{walletId}", memberHandler.LeaveWallet)
+	})
+}
+
+func (handler *memberHandler) GetMembers(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+
+	result, err := handler.memberUsecase.GetMembers(r.Context(), walletId.(string))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[[]member.Member]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+// InviteMember reads member_id (the customer_xid to invite), role and the optional spending_limit and approval_threshold
+func (handler *memberHandler) InviteMember(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+	memberId := r.Context().Value("memberId")
+	req := member.MemberInvitationRequest{}
+
+	err := request.Decode(w, r, &req)
+	if err == nil {
+		req.WalletId = walletId.(string)
+		req.InvitedBy = memberId.(string)
+		err = req.Validate()
+	}
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	result, err := handler.memberUsecase.InviteMember(r.Context(), req)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[member.Member]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.StatusCode = http.StatusCreated
+	resp.WriteResponse(w)
+}
+
+func (handler *memberHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+
+	err := handler.memberUsecase.RemoveMember(r.Context(), walletId.(string), chi.URLParam(r, "memberId"))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[struct{}]{}
+	resp.Success(response.STATUS_SUCCESS, struct{}{})
+	resp.WriteResponse(w)
+}
+
+// GetSpendingApprovals lists every approval of the wallet to its owner, and only their own to a spender
+func (handler *memberHandler) GetSpendingApprovals(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+	memberId := ""
+	if r.Context().Value("memberRole") != member.MEMBER_ROLE_OWNER {
+		memberId = r.Context().Value("memberId").(string)
+	}
+
+	result, err := handler.memberUsecase.GetSpendingApprovals(r.Context(), walletId.(string), memberId)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[[]member.SpendingApproval]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *memberHandler) ApproveSpending(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+	memberId := r.Context().Value("memberId")
+
+	result, err := handler.memberUsecase.ApproveSpending(r.Context(), walletId.(string), chi.URLParam(r, "approvalId"), memberId.(string))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[member.SpendingApproval]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *memberHandler) RejectSpending(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+	memberId := r.Context().Value("memberId")
+
+	result, err := handler.memberUsecase.RejectSpending(r.Context(), walletId.(string), chi.URLParam(r, "approvalId"), memberId.(string))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[member.SpendingApproval]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *memberHandler) GetMemberships(w http.ResponseWriter, r *http.Request) {
+	memberId := r.Context().Value("memberId")
+
+	result, err := handler.memberUsecase.GetMemberships(r.Context(), memberId.(string))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[[]member.Member]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *memberHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	memberId := r.Context().Value("memberId")
+
+	result, err := handler.memberUsecase.AcceptInvitation(r.Context(), memberId.(string), chi.URLParam(r, "walletId"))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[member.Member]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *memberHandler) LeaveWallet(w http.ResponseWriter, r *http.Request) {
+	memberId := r.Context().Value("memberId")
+
+	err := handler.memberUsecase.LeaveWallet(r.Context(), memberId.(string), chi.URLParam(r, "walletId"))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[struct{}]{}
+	resp.Success(response.STATUS_SUCCESS, struct{}{})
+	resp.WriteResponse(w)
+}
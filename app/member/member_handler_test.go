@@ -0,0 +1,80 @@
+package member
+
+import (
+	"context"
+	"encoding/json"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/member"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// invitationRecorder keeps the last invitation reaching the usecase
+type invitationRecorder struct {
+	member.MemberUsecase
+	invitation member.MemberInvitationRequest
+}
+
+func (recorder *invitationRecorder) InviteMember(ctx context.Context, req member.MemberInvitationRequest) (*response.Response[member.Member], error) {
+	recorder.invitation = req
+	return &response.Response[member.Member]{Data: &member.Member{MemberId: req.MemberId}}, nil
+}
+
+func TestInviteMemberDecodesTheBody(t *testing.T) {
+	recorder := &invitationRecorder{}
+	handler := &memberHandler{memberUsecase: recorder}
+
+	invite := func(contentType string, body string) (int, []byte) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		ctx := context.WithValue(r.Context(), "walletId", "w-1")
+		ctx = context.WithValue(ctx, "memberId", "owner")
+		w := httptest.NewRecorder()
+		handler.InviteMember(w, r.WithContext(ctx))
+
+		return w.Code, w.Body.Bytes()
+	}
+
+	code, body := invite("application/json", `{"member_id": "m-1", "role": "spender", "spending_limit": 500, "approval_threshold": null}`)
+	invitation := recorder.invitation
+	if code != http.StatusCreated || invitation.WalletId != "w-1" || invitation.InvitedBy != "owner" ||
+		invitation.SpendingLimit == nil || *invitation.SpendingLimit != 500 || invitation.ApprovalThreshold != nil {
+		t.Errorf("inviting from json = %d %s, invitation %+v", code, body, invitation)
+	}
+
+	code, body = invite("application/x-www-form-urlencoded", "member_id=m-2&role=viewer&spending_limit=")
+	if code != http.StatusCreated || recorder.invitation.MemberId != "m-2" || recorder.invitation.SpendingLimit != nil {
+		t.Errorf("inviting from a form = %d %s, invitation %+v", code, body, recorder.invitation)
+	}
+
+	for name, testCase := range map[string]struct {
+		contentType string
+		body        string
+		want        []response.FieldError
+	}{
+		"limit not a number": {"application/x-www-form-urlencoded", "member_id=m-1&role=spender&spending_limit=lots",
+			[]response.FieldError{{Field: "spending_limit", Message: "must be an integer"}}},
+		"threshold not positive": {"application/json", `{"member_id": "m-1", "role": "spender", "approval_threshold": 0}`,
+			[]response.FieldError{{Field: "approval_threshold", Message: "must be positive"}}},
+		"role missing": {"application/json", `{"member_id": "m-1"}`,
+			[]response.FieldError{{Field: "role", Message: "is required"}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			code, body := invite(testCase.contentType, testCase.body)
+			if code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", code, http.StatusBadRequest)
+			}
+
+			errResp := response.Response[response.Error]{}
+			if err := json.Unmarshal(body, &errResp); err != nil || errResp.Data == nil {
+				t.Fatalf("body = %s %v, want an error", body, err)
+			}
+			if !reflect.DeepEqual(errResp.Data.Fields, testCase.want) {
+				t.Errorf("fields = %+v, want %+v", errResp.Data.Fields, testCase.want)
+			}
+		})
+	}
+}
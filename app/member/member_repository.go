@@ -0,0 +1,249 @@
+package member
+
+import (
+	"context"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/member"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"gorm.io/gorm"
+)
+
+type memberRepository struct {
+	db *gorm.DB
+}
+
+func NewMemberRepository(db *gorm.DB) member.MemberRepository {
+	return &memberRepository{
+		db: db,
+	}
+}
+
+func (memberRepository *memberRepository) InsertMember(ctx context.Context, memberData member.Member) (err error) {
+	err = memberRepository.db.WithContext(ctx).Table("ms_wallet_member").Create(&memberData).Error
+	if err != nil {
+		return translateConstraintViolation(err)
+	}
+
+	return nil
+}
+
+func (memberRepository *memberRepository) GetMember(ctx context.Context, walletId string, memberId string) (res *member.Member, err error) {
+	qry, args, err := sq.Select("*").From("ms_wallet_member").Where(sq.Eq{"wallet_id": walletId, "member_id": memberId}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	result := memberRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+func (memberRepository *memberRepository) GetMembersByWalletId(ctx context.Context, walletId string) (res []member.Member, err error) {
+	qry, args, err := sq.Select("*").From("ms_wallet_member").Where(sq.Eq{"wallet_id": walletId}).OrderBy("created_at").ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = memberRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (memberRepository *memberRepository) GetMembersByMemberId(ctx context.Context, memberId string) (res []member.Member, err error) {
+	qry, args, err := sq.Select("*").From("ms_wallet_member").Where(sq.Eq{"member_id": memberId}).OrderBy("created_at").ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = memberRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (memberRepository *memberRepository) UpdateMember(ctx context.Context, memberData member.Member) (err error) {
+	err = memberRepository.db.WithContext(ctx).Table("ms_wallet_member").
+		Where("wallet_id = ? AND member_id = ?", memberData.WalletId, memberData.MemberId).
+		Updates(map[string]interface{}{
+			"status":      memberData.Status,
+			"accepted_at": memberData.AcceptedAt,
+		}).Error
+	if err != nil {
+		return translateConstraintViolation(err)
+	}
+
+	return nil
+}
+
+// WithMemberForUpdate keeps the membership row locked while fn runs. fn reads and writes through the repositories,
+// other connections, the lock only keeps a second WithMemberForUpdate of the member waiting
+func (memberRepository *memberRepository) WithMemberForUpdate(ctx context.Context, walletId string, memberId string, fn func(membership *member.Member) error) (err error) {
+	return memberRepository.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		qry, args, err := sq.Select("*").From("ms_wallet_member").Where(sq.Eq{"wallet_id": walletId, "member_id": memberId}).Suffix("FOR UPDATE").ToSql()
+		if err != nil {
+			return err
+		}
+
+		var membership *member.Member
+		result := tx.Raw(qry, args...).Scan(&membership)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			membership = nil
+		}
+
+		return fn(membership)
+	})
+}
+
+func (memberRepository *memberRepository) DeleteMember(ctx context.Context, walletId string, memberId string) (deleted bool, err error) {
+	result := memberRepository.db.WithContext(ctx).Table("ms_wallet_member").
+		Where("wallet_id = ? AND member_id = ?", walletId, memberId).
+		Delete(&member.Member{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (memberRepository *memberRepository) GetMemberSpending(ctx context.Context, walletId string, memberId string, since time.Time) (spent int, err error) {
+	// created_at is RFC 3339 text, compared as a timestamp so the offset it was written with does not matter
+	qry, args, err := sq.Select("COALESCE(SUM(amount), 0)").From("tr_wallet_transaction").
+		Where(sq.Eq{"wallet_id": walletId, "created_by": memberId, "type": wallet.WALLET_TRANSACTION_WITHDRAWAL}).
+		Where("created_at_timestamptz(created_at) >= ?", since).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	err = memberRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&spent).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return spent, nil
+}
+
+func (memberRepository *memberRepository) GetMemberPendingSpending(ctx context.Context, walletId string, memberId string) (pending int, err error) {
+	qry, args, err := sq.Select("COALESCE(SUM(amount), 0)").From("tr_spending_approval").
+		Where(sq.Eq{
+			"wallet_id": walletId,
+			"member_id": memberId,
+			"status":    []string{member.SPENDING_APPROVAL_STATUS_PENDING, member.SPENDING_APPROVAL_STATUS_APPROVING},
+		}).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	err = memberRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&pending).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return pending, nil
+}
+
+func (memberRepository *memberRepository) InsertSpendingApproval(ctx context.Context, approval member.SpendingApproval) (err error) {
+	err = memberRepository.db.WithContext(ctx).Table("tr_spending_approval").Create(&approval).Error
+	if err != nil {
+		return translateConstraintViolation(err)
+	}
+
+	return nil
+}
+
+func (memberRepository *memberRepository) GetSpendingApprovalById(ctx context.Context, approvalId string) (res *member.SpendingApproval, err error) {
+	qry, args, err := sq.Select("*").From("tr_spending_approval").Where(sq.Eq{"id": approvalId}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	result := memberRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+func (memberRepository *memberRepository) GetSpendingApprovals(ctx context.Context, walletId string, memberId string) (res []member.SpendingApproval, err error) {
+	builder := sq.Select("*").From("tr_spending_approval").Where(sq.Eq{"wallet_id": walletId}).OrderBy("created_at DESC")
+	if memberId != "" {
+		builder = builder.Where(sq.Eq{"member_id": memberId})
+	}
+
+	qry, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = memberRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (memberRepository *memberRepository) UpdateSpendingApproval(ctx context.Context, approval member.SpendingApproval, previous member.SpendingApproval) (updated bool, err error) {
+	result := memberRepository.db.WithContext(ctx).Table("tr_spending_approval").
+		Where("id = ? AND status = ? AND decided_at IS NOT DISTINCT FROM ?", approval.Id, previous.Status, previous.DecidedAt).
+		Updates(map[string]interface{}{
+			"status":     approval.Status,
+			"error":      approval.Error,
+			"decided_by": approval.DecidedBy,
+			"decided_at": approval.DecidedAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// translateConstraintViolation turns the database constraints into the user errors the usecases return,
+// any other error is returned as is
+func translateConstraintViolation(err error) error {
+	violation, ok := infrastructure.AsConstraintViolation(err)
+	if !ok {
+		return err
+	}
+
+	switch violation.Code {
+	case infrastructure.PG_CHECK_VIOLATION:
+		return response.ErrBadRequest
+	case infrastructure.PG_UNIQUE_VIOLATION:
+		switch violation.Constraint {
+		case "ms_wallet_member_pkey":
+			return response.ErrMemberAlreadyExists
+		case "tr_spending_approval_wallet_id_reference_id_key":
+			return response.ErrReferenceIdConflict
+		}
+	case infrastructure.PG_FOREIGN_KEY_VIOLATION:
+		return response.ErrWalletNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,298 @@
+package member
+
+import (
+	"context"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/member"
+	"mini-wallet/domain/wallet"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryMemberRepository struct {
+	walletRepository wallet.WalletRepository
+
+	mu        sync.RWMutex
+	members   map[[2]string]member.Member // by wallet id and member id
+	approvals map[string]member.SpendingApproval
+
+	memberLocks map[[2]string]*sync.Mutex // by wallet id and member id, held by WithMemberForUpdate
+}
+
+// NewMemoryMemberRepository keeps the members and approvals in this process, for tests and the memory storage.
+// the wallets and the spending of a member are read from walletRepository, like the postgres one joins them
+func NewMemoryMemberRepository(walletRepository wallet.WalletRepository) member.MemberRepository {
+	return &memoryMemberRepository{
+		walletRepository: walletRepository,
+		members:          map[[2]string]member.Member{},
+		approvals:        map[string]member.SpendingApproval{},
+		memberLocks:      map[[2]string]*sync.Mutex{},
+	}
+}
+
+func (memberRepository *memoryMemberRepository) InsertMember(ctx context.Context, memberData member.Member) (err error) {
+	if err = memberRepository.checkWallet(ctx, memberData.WalletId); err != nil {
+		return err
+	}
+	if err = checkMember(memberData); err != nil {
+		return err
+	}
+
+	memberRepository.mu.Lock()
+	defer memberRepository.mu.Unlock()
+
+	key := [2]string{memberData.WalletId, memberData.MemberId}
+	if _, found := memberRepository.members[key]; found {
+		return response.ErrMemberAlreadyExists
+	}
+	memberRepository.members[key] = memberData
+
+	return nil
+}
+
+func (memberRepository *memoryMemberRepository) GetMember(ctx context.Context, walletId string, memberId string) (res *member.Member, err error) {
+	memberRepository.mu.RLock()
+	defer memberRepository.mu.RUnlock()
+
+	memberData, found := memberRepository.members[[2]string{walletId, memberId}]
+	if !found {
+		return nil, nil
+	}
+
+	return &memberData, nil
+}
+
+func (memberRepository *memoryMemberRepository) GetMembersByWalletId(ctx context.Context, walletId string) (res []member.Member, err error) {
+	return memberRepository.filterMembers(func(memberData member.Member) bool { return memberData.WalletId == walletId }), nil
+}
+
+func (memberRepository *memoryMemberRepository) GetMembersByMemberId(ctx context.Context, memberId string) (res []member.Member, err error) {
+	return memberRepository.filterMembers(func(memberData member.Member) bool { return memberData.MemberId == memberId }), nil
+}
+
+// filterMembers returns the matching members oldest first
+func (memberRepository *memoryMemberRepository) filterMembers(match func(memberData member.Member) bool) (res []member.Member) {
+	memberRepository.mu.RLock()
+	defer memberRepository.mu.RUnlock()
+
+	for _, memberData := range memberRepository.members {
+		if match(memberData) {
+			res = append(res, memberData)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt < res[j].CreatedAt })
+
+	return res
+}
+
+func (memberRepository *memoryMemberRepository) UpdateMember(ctx context.Context, memberData member.Member) (err error) {
+	memberRepository.mu.Lock()
+	defer memberRepository.mu.Unlock()
+
+	key := [2]string{memberData.WalletId, memberData.MemberId}
+	storedMember, found := memberRepository.members[key]
+	if !found {
+		return nil
+	}
+
+	storedMember.Status = memberData.Status
+	storedMember.AcceptedAt = memberData.AcceptedAt
+	if err = checkMember(storedMember); err != nil {
+		return err
+	}
+	memberRepository.members[key] = storedMember
+
+	return nil
+}
+
+func (memberRepository *memoryMemberRepository) WithMemberForUpdate(ctx context.Context, walletId string, memberId string, fn func(membership *member.Member) error) (err error) {
+	key := [2]string{walletId, memberId}
+	memberRepository.mu.Lock()
+	memberLock, found := memberRepository.memberLocks[key]
+	if !found {
+		memberLock = &sync.Mutex{}
+		memberRepository.memberLocks[key] = memberLock
+	}
+	memberRepository.mu.Unlock()
+
+	memberLock.Lock()
+	defer memberLock.Unlock()
+
+	membership, err := memberRepository.GetMember(ctx, walletId, memberId)
+	if err != nil {
+		return err
+	}
+
+	return fn(membership)
+}
+
+func (memberRepository *memoryMemberRepository) DeleteMember(ctx context.Context, walletId string, memberId string) (deleted bool, err error) {
+	memberRepository.mu.Lock()
+	defer memberRepository.mu.Unlock()
+
+	key := [2]string{walletId, memberId}
+	if _, found := memberRepository.members[key]; !found {
+		return false, nil
+	}
+	delete(memberRepository.members, key)
+
+	return true, nil
+}
+
+func (memberRepository *memoryMemberRepository) GetMemberSpending(ctx context.Context, walletId string, memberId string, since time.Time) (spent int, err error) {
+	// an empty wallet id would stream every wallet, a spending is always on one
+	if walletId == "" {
+		return 0, nil
+	}
+
+	withdrawal := wallet.WALLET_TRANSACTION_WITHDRAWAL
+	req := wallet.GetWalletTransactionRequest{
+		WalletId:  walletId,
+		Type:      &withdrawal,
+		CreatedBy: &memberId,
+		From:      &since,
+	}
+	err = memberRepository.walletRepository.StreamWalletTransactions(ctx, req, func(walletTransaction wallet.WalletTransactionEntity) error {
+		spent += walletTransaction.Amount
+		return nil
+	})
+
+	return spent, err
+}
+
+func (memberRepository *memoryMemberRepository) GetMemberPendingSpending(ctx context.Context, walletId string, memberId string) (pending int, err error) {
+	memberRepository.mu.RLock()
+	defer memberRepository.mu.RUnlock()
+
+	for _, approval := range memberRepository.approvals {
+		if approval.WalletId == walletId && approval.MemberId == memberId &&
+			(approval.Status == member.SPENDING_APPROVAL_STATUS_PENDING || approval.Status == member.SPENDING_APPROVAL_STATUS_APPROVING) {
+			pending += approval.Amount
+		}
+	}
+
+	return pending, nil
+}
+
+func (memberRepository *memoryMemberRepository) InsertSpendingApproval(ctx context.Context, approval member.SpendingApproval) (err error) {
+	if err = memberRepository.checkWallet(ctx, approval.WalletId); err != nil {
+		return err
+	}
+	if err = checkSpendingApproval(approval); err != nil {
+		return err
+	}
+
+	memberRepository.mu.Lock()
+	defer memberRepository.mu.Unlock()
+
+	if _, found := memberRepository.approvals[approval.Id]; found {
+		return response.ErrBadRequest
+	}
+	for _, existingApproval := range memberRepository.approvals {
+		if existingApproval.WalletId == approval.WalletId && existingApproval.ReferenceId == approval.ReferenceId {
+			return response.ErrReferenceIdConflict
+		}
+	}
+	memberRepository.approvals[approval.Id] = approval
+
+	return nil
+}
+
+func (memberRepository *memoryMemberRepository) GetSpendingApprovalById(ctx context.Context, approvalId string) (res *member.SpendingApproval, err error) {
+	memberRepository.mu.RLock()
+	defer memberRepository.mu.RUnlock()
+
+	approval, found := memberRepository.approvals[approvalId]
+	if !found {
+		return nil, nil
+	}
+
+	return &approval, nil
+}
+
+func (memberRepository *memoryMemberRepository) GetSpendingApprovals(ctx context.Context, walletId string, memberId string) (res []member.SpendingApproval, err error) {
+	memberRepository.mu.RLock()
+	defer memberRepository.mu.RUnlock()
+
+	for _, approval := range memberRepository.approvals {
+		if approval.WalletId == walletId && (memberId == "" || approval.MemberId == memberId) {
+			res = append(res, approval)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt > res[j].CreatedAt })
+
+	return res, nil
+}
+
+func (memberRepository *memoryMemberRepository) UpdateSpendingApproval(ctx context.Context, approval member.SpendingApproval, previous member.SpendingApproval) (updated bool, err error) {
+	memberRepository.mu.Lock()
+	defer memberRepository.mu.Unlock()
+
+	storedApproval, found := memberRepository.approvals[approval.Id]
+	if !found || storedApproval.Status != previous.Status || !sameDecidedAt(storedApproval.DecidedAt, previous.DecidedAt) {
+		return false, nil
+	}
+
+	storedApproval.Status = approval.Status
+	storedApproval.Error = approval.Error
+	storedApproval.DecidedBy = approval.DecidedBy
+	storedApproval.DecidedAt = approval.DecidedAt
+	if err = checkSpendingApproval(storedApproval); err != nil {
+		return false, err
+	}
+	memberRepository.approvals[approval.Id] = storedApproval
+
+	return true, nil
+}
+
+// checkWallet is the foreign key on ms_wallet
+func (memberRepository *memoryMemberRepository) checkWallet(ctx context.Context, walletId string) error {
+	walletData, err := memberRepository.walletRepository.GetWalletById(ctx, walletId)
+	if err != nil {
+		return err
+	}
+	if walletData == nil {
+		return response.ErrWalletNotFound
+	}
+
+	return nil
+}
+
+// checkMember mirrors the check constraints of ms_wallet_member
+func checkMember(memberData member.Member) error {
+	if memberData.Role != member.MEMBER_ROLE_SPENDER && memberData.Role != member.MEMBER_ROLE_VIEWER {
+		return response.ErrBadRequest
+	}
+	if memberData.Status != member.MEMBER_STATUS_INVITED && memberData.Status != member.MEMBER_STATUS_ACTIVE {
+		return response.ErrBadRequest
+	}
+	if (memberData.SpendingLimit != nil && *memberData.SpendingLimit <= 0) || (memberData.ApprovalThreshold != nil && *memberData.ApprovalThreshold <= 0) {
+		return response.ErrBadRequest
+	}
+
+	return nil
+}
+
+// checkSpendingApproval mirrors the check constraints of tr_spending_approval
+func checkSpendingApproval(approval member.SpendingApproval) error {
+	if approval.Amount <= 0 {
+		return response.ErrBadRequest
+	}
+
+	switch approval.Status {
+	case member.SPENDING_APPROVAL_STATUS_PENDING, member.SPENDING_APPROVAL_STATUS_APPROVING, member.SPENDING_APPROVAL_STATUS_APPROVED,
+		member.SPENDING_APPROVAL_STATUS_REJECTED, member.SPENDING_APPROVAL_STATUS_FAILED:
+		return nil
+	}
+
+	return response.ErrBadRequest
+}
+
+func sameDecidedAt(decidedAt *string, other *string) bool {
+	if decidedAt == nil || other == nil {
+		return decidedAt == other
+	}
+
+	return *decidedAt == *other
+}
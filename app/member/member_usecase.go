@@ -0,0 +1,459 @@
+package member
+
+import (
+	"context"
+	"errors"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/member"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// an approved withdrawal refused with one of these is recorded as failed,
+	// anything else (a lock, a database hiccup) leaves the approval pending so the owner can approve again
+	refusedWithdrawalErrors = map[*response.DomainError]struct{}{
+		response.ErrInsufficientFund:    {},
+		response.ErrWalletDisabled:      {},
+		response.ErrWalletNotFound:      {},
+		response.ErrBadRequest:          {},
+		response.ErrSpendingLimit:       {},
+		response.ErrMemberForbidden:     {},
+		response.ErrReferenceIdConflict: {},
+	}
+)
+
+type memberUsecase struct {
+	memberRepository member.MemberRepository
+	walletRepository wallet.WalletRepository
+	walletUsecase    wallet.WalletUsecase
+	config           infrastructure.Config
+}
+
+// NewMemberUsecase withdraws through walletUsecase, so a member's withdrawal gets the same checks,
+// locking and metrics as one made by the owner
+func NewMemberUsecase(repositories domain.Repositories, walletUsecase wallet.WalletUsecase, config infrastructure.Config) member.MemberUsecase {
+	return &memberUsecase{
+		memberRepository: repositories.MemberRepository,
+		walletRepository: repositories.WalletRepository,
+		walletUsecase:    walletUsecase,
+		config:           config,
+	}
+}
+
+func (usecase *memberUsecase) InviteMember(ctx context.Context, req member.MemberInvitationRequest) (res *response.Response[member.Member], err error) {
+	if err = req.Validate(); err != nil {
+		return nil, err
+	}
+
+	walletResult, err := usecase.walletRepository.GetWalletById(ctx, req.WalletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - InviteMember", err)
+		return nil, err
+	}
+
+	if walletResult == nil {
+		return nil, response.ErrWalletNotFound
+	}
+
+	// the owner has every right already
+	if req.MemberId == walletResult.OwnedBy {
+		return nil, response.ErrBadRequest
+	}
+
+	memberData := member.Member{
+		WalletId:          walletResult.Id,
+		MemberId:          req.MemberId,
+		Role:              req.Role,
+		SpendingLimit:     req.SpendingLimit,
+		ApprovalThreshold: req.ApprovalThreshold,
+		Status:            member.MEMBER_STATUS_INVITED,
+		InvitedBy:         req.InvitedBy,
+		CreatedAt:         time.Now().Format(time.RFC3339),
+	}
+
+	if err = usecase.memberRepository.InsertMember(ctx, memberData); err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.memberRepository.InsertMember() - InviteMember", err)
+		return nil, err
+	}
+
+	return &response.Response[member.Member]{
+		Data: &memberData,
+	}, nil
+}
+
+func (usecase *memberUsecase) GetMembers(ctx context.Context, walletId string) (res *response.Response[[]member.Member], err error) {
+	walletResult, err := usecase.walletRepository.GetWalletById(ctx, walletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - GetMembers", err)
+		return nil, err
+	}
+
+	if walletResult == nil {
+		return nil, response.ErrWalletNotFound
+	}
+
+	members, err := usecase.memberRepository.GetMembersByWalletId(ctx, walletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.memberRepository.GetMembersByWalletId() - GetMembers", err)
+		return nil, err
+	}
+
+	// the owner is not stored as a member
+	members = append([]member.Member{{
+		WalletId: walletResult.Id,
+		MemberId: walletResult.OwnedBy,
+		Role:     member.MEMBER_ROLE_OWNER,
+		Status:   member.MEMBER_STATUS_ACTIVE,
+	}}, members...)
+
+	return &response.Response[[]member.Member]{
+		Data: &members,
+	}, nil
+}
+
+func (usecase *memberUsecase) RemoveMember(ctx context.Context, walletId string, memberId string) (err error) {
+	deleted, err := usecase.memberRepository.DeleteMember(ctx, walletId, memberId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.memberRepository.DeleteMember() - RemoveMember", err)
+		return err
+	}
+
+	if !deleted {
+		return response.ErrMemberNotFound
+	}
+
+	return nil
+}
+
+func (usecase *memberUsecase) GetMemberships(ctx context.Context, memberId string) (res *response.Response[[]member.Member], err error) {
+	memberships, err := usecase.memberRepository.GetMembersByMemberId(ctx, memberId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.memberRepository.GetMembersByMemberId() - GetMemberships", err)
+		return nil, err
+	}
+
+	if memberships == nil {
+		memberships = []member.Member{}
+	}
+
+	return &response.Response[[]member.Member]{
+		Data: &memberships,
+	}, nil
+}
+
+func (usecase *memberUsecase) AcceptInvitation(ctx context.Context, memberId string, walletId string) (res *response.Response[member.Member], err error) {
+	membership, err := usecase.memberRepository.GetMember(ctx, walletId, memberId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.memberRepository.GetMember() - AcceptInvitation", err)
+		return nil, err
+	}
+
+	if membership == nil {
+		return nil, response.ErrMemberNotFound
+	}
+
+	// accepting twice changes nothing
+	if membership.Status == member.MEMBER_STATUS_ACTIVE {
+		return &response.Response[member.Member]{
+			Data: membership,
+		}, nil
+	}
+
+	nowString := time.Now().Format(time.RFC3339)
+	membership.Status = member.MEMBER_STATUS_ACTIVE
+	membership.AcceptedAt = &nowString
+	if err = usecase.memberRepository.UpdateMember(ctx, *membership); err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.memberRepository.UpdateMember() - AcceptInvitation", err)
+		return nil, err
+	}
+
+	return &response.Response[member.Member]{
+		Data: membership,
+	}, nil
+}
+
+func (usecase *memberUsecase) LeaveWallet(ctx context.Context, memberId string, walletId string) (err error) {
+	return usecase.RemoveMember(ctx, walletId, memberId)
+}
+
+// CreateMemberWithdrawal checks the spending limit over the last SPENDING_LIMIT_WINDOW, the amounts still waiting for
+// the owner included. The membership stays locked from the check until the withdrawal or the approval is written,
+// so two withdrawals of the same member racing each other cannot both spend the rest of the limit
+func (usecase *memberUsecase) CreateMemberWithdrawal(ctx context.Context, req wallet.WalletTransactionRequest) (res *response.Response[member.MemberWithdrawal], err error) {
+	err = usecase.memberRepository.WithMemberForUpdate(ctx, req.WalletId, req.CreatedBy, func(membership *member.Member) error {
+		if membership == nil || membership.Status != member.MEMBER_STATUS_ACTIVE || membership.Role != member.MEMBER_ROLE_SPENDER {
+			return response.ErrMemberForbidden
+		}
+
+		spent, err := usecase.memberRepository.GetMemberSpending(ctx, req.WalletId, req.CreatedBy, time.Now().Add(-member.SPENDING_LIMIT_WINDOW))
+		if err != nil {
+			infrastructure.LogError(ctx, "got error on usecase.memberRepository.GetMemberSpending() - CreateMemberWithdrawal", err)
+			return err
+		}
+
+		pending, err := usecase.memberRepository.GetMemberPendingSpending(ctx, req.WalletId, req.CreatedBy)
+		if err != nil {
+			infrastructure.LogError(ctx, "got error on usecase.memberRepository.GetMemberPendingSpending() - CreateMemberWithdrawal", err)
+			return err
+		}
+
+		if err = membership.ValidateSpending(req.Amount, spent+pending); err != nil {
+			return err
+		}
+
+		if membership.NeedsApproval(req.Amount) {
+			approval, err := usecase.requestApproval(ctx, req)
+			if err != nil {
+				return err
+			}
+
+			res = &response.Response[member.MemberWithdrawal]{
+				Data: &member.MemberWithdrawal{
+					Approval: approval,
+				},
+			}
+			return nil
+		}
+
+		walletResult, err := usecase.walletUsecase.CreateWalletTransaction(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		res = &response.Response[member.MemberWithdrawal]{
+			Data: &member.MemberWithdrawal{
+				Wallet: walletResult.Data,
+			},
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (usecase *memberUsecase) requestApproval(ctx context.Context, req wallet.WalletTransactionRequest) (res *member.SpendingApproval, err error) {
+	// the withdrawal is made with the reference id later on, refuse one which is already taken
+	walletTransaction, err := usecase.walletRepository.GetWalletTransactionByReferenceId(ctx, req.WalletId, req.ReferenceId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletTransactionByReferenceId() - requestApproval", err)
+		return nil, err
+	}
+
+	if walletTransaction != nil {
+		return nil, response.ErrReferenceIdConflict
+	}
+
+	approvalId, err := uuid.NewV7()
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on uuid.NewV7() - requestApproval", err)
+		return nil, err
+	}
+
+	approval := member.SpendingApproval{
+		Id:          approvalId.String(),
+		WalletId:    req.WalletId,
+		MemberId:    req.CreatedBy,
+		Amount:      req.Amount,
+		ReferenceId: req.ReferenceId,
+		Status:      member.SPENDING_APPROVAL_STATUS_PENDING,
+		CreatedAt:   time.Now().Format(time.RFC3339),
+	}
+
+	if err = usecase.memberRepository.InsertSpendingApproval(ctx, approval); err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.memberRepository.InsertSpendingApproval() - requestApproval", err)
+		return nil, err
+	}
+
+	return &approval, nil
+}
+
+func (usecase *memberUsecase) GetSpendingApprovals(ctx context.Context, walletId string, memberId string) (res *response.Response[[]member.SpendingApproval], err error) {
+	approvals, err := usecase.memberRepository.GetSpendingApprovals(ctx, walletId, memberId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.memberRepository.GetSpendingApprovals() - GetSpendingApprovals", err)
+		return nil, err
+	}
+
+	if approvals == nil {
+		approvals = []member.SpendingApproval{}
+	}
+
+	return &response.Response[[]member.SpendingApproval]{
+		Data: &approvals,
+	}, nil
+}
+
+// ApproveSpending claims the approval before anything else, so a concurrent rejection or approval loses, then checks
+// the member and their spending limit again and withdraws. The withdrawal carries the reference id of the request,
+// so an approval interrupted midway and approved again never withdraws twice
+func (usecase *memberUsecase) ApproveSpending(ctx context.Context, walletId string, approvalId string, ownerId string) (res *response.Response[member.SpendingApproval], err error) {
+	approval, err := usecase.claimApproval(ctx, walletId, approvalId, ownerId, member.SPENDING_APPROVAL_STATUS_APPROVING)
+	if err != nil {
+		return nil, err
+	}
+	claimed := *approval
+
+	approval.Status = member.SPENDING_APPROVAL_STATUS_APPROVED
+	if err = usecase.withdrawApproved(ctx, approval); err != nil {
+		if _, refused := refusedWithdrawalErrors[response.DomainErrorOf(err)]; !refused {
+			// a lock or a database hiccup, the owner can approve again
+			usecase.releaseApproval(ctx, claimed)
+			return nil, err
+		}
+
+		errString := err.Error()
+		approval.Status = member.SPENDING_APPROVAL_STATUS_FAILED
+		approval.Error = &errString
+	}
+
+	return usecase.decideApproval(ctx, approval, claimed)
+}
+
+func (usecase *memberUsecase) RejectSpending(ctx context.Context, walletId string, approvalId string, ownerId string) (res *response.Response[member.SpendingApproval], err error) {
+	approval, err := usecase.claimApproval(ctx, walletId, approvalId, ownerId, member.SPENDING_APPROVAL_STATUS_REJECTED)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.Response[member.SpendingApproval]{
+		Data: approval,
+	}, nil
+}
+
+// claimApproval moves an approval of walletId from pending to status. Only an approval can also take over one whose
+// approval was interrupted, a rejection of it could hide a withdrawal already made
+func (usecase *memberUsecase) claimApproval(ctx context.Context, walletId string, approvalId string, ownerId string, status string) (res *member.SpendingApproval, err error) {
+	approval, err := usecase.memberRepository.GetSpendingApprovalById(ctx, approvalId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.memberRepository.GetSpendingApprovalById() - claimApproval", err)
+		return nil, err
+	}
+
+	if approval == nil || approval.WalletId != walletId {
+		return nil, response.ErrApprovalNotFound
+	}
+
+	now := time.Now()
+	resumed := status == member.SPENDING_APPROVAL_STATUS_APPROVING && approval.Interrupted(now)
+	if approval.Status != member.SPENDING_APPROVAL_STATUS_PENDING && !resumed {
+		return nil, response.ErrApprovalStatus
+	}
+
+	previous := *approval
+	nowString := now.Format(time.RFC3339)
+	approval.Status = status
+	approval.DecidedBy = &ownerId
+	approval.DecidedAt = &nowString
+
+	updated, err := usecase.memberRepository.UpdateSpendingApproval(ctx, *approval, previous)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.memberRepository.UpdateSpendingApproval() - claimApproval", err)
+		return nil, err
+	}
+
+	if !updated {
+		return nil, response.ErrApprovalStatus
+	}
+
+	return approval, nil
+}
+
+// withdrawApproved makes the withdrawal of a claimed approval, unless an interrupted approval made it already
+func (usecase *memberUsecase) withdrawApproved(ctx context.Context, approval *member.SpendingApproval) (err error) {
+	made, err := usecase.approvedWithdrawalMade(ctx, approval)
+	if err != nil || made {
+		return err
+	}
+
+	// the member may have been removed, or spent more, since the request.
+	// the membership stays locked until the withdrawal is made, like in CreateMemberWithdrawal
+	return usecase.memberRepository.WithMemberForUpdate(ctx, approval.WalletId, approval.MemberId, func(membership *member.Member) error {
+		if membership == nil || membership.Status != member.MEMBER_STATUS_ACTIVE || membership.Role != member.MEMBER_ROLE_SPENDER {
+			return response.ErrMemberForbidden
+		}
+
+		spent, err := usecase.memberRepository.GetMemberSpending(ctx, approval.WalletId, approval.MemberId, time.Now().Add(-member.SPENDING_LIMIT_WINDOW))
+		if err != nil {
+			infrastructure.LogError(ctx, "got error on usecase.memberRepository.GetMemberSpending() - withdrawApproved", err)
+			return err
+		}
+
+		if err = membership.ValidateSpending(approval.Amount, spent); err != nil {
+			return err
+		}
+
+		_, err = usecase.walletUsecase.CreateWalletTransaction(ctx, approval.ToTransactionRequest())
+		if errors.Is(err, response.ErrReferenceIdConflict) {
+			// made meanwhile by a resumed approval, or the reference id was taken by another transaction
+			made, lookupErr := usecase.approvedWithdrawalMade(ctx, approval)
+			if made {
+				return nil
+			}
+			if lookupErr != nil {
+				return lookupErr
+			}
+		}
+
+		return err
+	})
+}
+
+// approvedWithdrawalMade looks the withdrawal of the approval up by its reference id. Another transaction holding
+// the reference id is a response.ErrReferenceIdConflict
+func (usecase *memberUsecase) approvedWithdrawalMade(ctx context.Context, approval *member.SpendingApproval) (made bool, err error) {
+	walletTransaction, err := usecase.walletRepository.GetWalletTransactionByReferenceId(ctx, approval.WalletId, approval.ReferenceId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletTransactionByReferenceId() - approvedWithdrawalMade", err)
+		return false, err
+	}
+
+	if walletTransaction == nil {
+		return false, nil
+	}
+
+	if !approval.MadeBy(walletTransaction) {
+		return false, response.ErrReferenceIdConflict
+	}
+
+	return true, nil
+}
+
+// releaseApproval hands a claimed approval back to the owner, pending again
+func (usecase *memberUsecase) releaseApproval(ctx context.Context, claimed member.SpendingApproval) {
+	pending := claimed
+	pending.Status = member.SPENDING_APPROVAL_STATUS_PENDING
+	pending.DecidedBy = nil
+	pending.DecidedAt = nil
+
+	if _, err := usecase.memberRepository.UpdateSpendingApproval(ctx, pending, claimed); err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.memberRepository.UpdateSpendingApproval() - releaseApproval", err, "approval_id", claimed.Id)
+	}
+}
+
+// decideApproval records the outcome of a claimed approval, unless a resumed approval took it over meanwhile
+func (usecase *memberUsecase) decideApproval(ctx context.Context, approval *member.SpendingApproval, claimed member.SpendingApproval) (res *response.Response[member.SpendingApproval], err error) {
+	nowString := time.Now().Format(time.RFC3339)
+	approval.DecidedAt = &nowString
+
+	updated, err := usecase.memberRepository.UpdateSpendingApproval(ctx, *approval, claimed)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.memberRepository.UpdateSpendingApproval() - decideApproval", err)
+		return nil, err
+	}
+
+	if !updated {
+		return nil, response.ErrApprovalStatus
+	}
+
+	return &response.Response[member.SpendingApproval]{
+		Data: approval,
+	}, nil
+}
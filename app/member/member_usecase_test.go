@@ -0,0 +1,446 @@
+package member
+
+import (
+	"context"
+	"errors"
+	walletApp "mini-wallet/app/wallet"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/member"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// set to a disposable database, e.g. "host=localhost user=postgres password=postgres dbname=wallet_test sslmode=disable"
+const testPostgresDsnEnv = "MINI_WALLET_TEST_POSTGRES_DSN"
+
+func TestSpenderWithdrawals(t *testing.T) {
+	dsn := os.Getenv(testPostgresDsnEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testPostgresDsnEnv)
+	}
+
+	ctx := context.Background()
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("connecting to postgres: %v", err)
+	}
+	if err = infrastructure.Migrate(ctx, db, infrastructure.MIGRATE_UP); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	config := infrastructure.Config{
+		WALLET_LOCK_TTL:             time.Second * 8,
+		WALLET_TRANSACTION_TIMEOUT:  time.Second * 30,
+		WALLET_CONCURRENCY_STRATEGY: wallet.CONCURRENCY_STRATEGY_CONDITIONAL_UPDATE,
+	}
+	repositories := domain.Repositories{
+		WalletRepository: walletApp.NewWalletRepository(db, nil),
+		MemberRepository: NewMemberRepository(db),
+	}
+	walletUsecase := walletApp.NewWalletUsecase(repositories, nil, walletApp.NewMemoryWalletLocker(config.WALLET_LOCK_TTL), config)
+	usecase := NewMemberUsecase(repositories, walletUsecase, config)
+
+	enabledAt := time.Now().Format(time.RFC3339)
+	sharedWallet := wallet.Wallet{
+		Id:        uuid.NewString(),
+		OwnedBy:   uuid.NewString(),
+		Name:      wallet.WALLET_MAIN_POCKET,
+		EnabledAt: &enabledAt,
+		Balance:   1000,
+		Status:    wallet.WALLET_STATUS_ENABLED,
+	}
+	if err := repositories.WalletRepository.InsertWallet(ctx, sharedWallet); err != nil {
+		t.Fatalf("inserting wallet: %v", err)
+	}
+
+	spenderId := uuid.NewString()
+	spendingLimit, approvalThreshold := 300, 100
+	_, err = usecase.InviteMember(ctx, member.MemberInvitationRequest{
+		WalletId:          sharedWallet.Id,
+		MemberId:          spenderId,
+		Role:              member.MEMBER_ROLE_SPENDER,
+		SpendingLimit:     &spendingLimit,
+		ApprovalThreshold: &approvalThreshold,
+		InvitedBy:         sharedWallet.OwnedBy,
+	})
+	if err != nil {
+		t.Fatalf("inviting: %v", err)
+	}
+
+	withdraw := func(amount int) (*response.Response[member.MemberWithdrawal], error) {
+		return usecase.CreateMemberWithdrawal(ctx, wallet.WalletTransactionRequest{
+			WalletId:    sharedWallet.Id,
+			Type:        wallet.WALLET_TRANSACTION_WITHDRAWAL,
+			Amount:      amount,
+			ReferenceId: uuid.NewString(),
+			CreatedBy:   spenderId,
+		})
+	}
+
+	if _, err := withdraw(50); err == nil || !errors.Is(err, response.ErrMemberForbidden) {
+		t.Errorf("withdrawing before accepting: err = %v, want %q", err, response.ERROR_MEMBER_FORBIDDEN)
+	}
+
+	if _, err := usecase.AcceptInvitation(ctx, spenderId, sharedWallet.Id); err != nil {
+		t.Fatalf("accepting: %v", err)
+	}
+
+	result, err := withdraw(50)
+	if err != nil {
+		t.Fatalf("withdrawing below the threshold: %v", err)
+	}
+	if result.Data.Wallet == nil || result.Data.Wallet.Balance != 950 {
+		t.Errorf("withdrawal below the threshold = %+v, want it made", result.Data)
+	}
+
+	result, err = withdraw(200)
+	if err != nil {
+		t.Fatalf("withdrawing above the threshold: %v", err)
+	}
+	if result.Data.Approval == nil || result.Data.Approval.Status != member.SPENDING_APPROVAL_STATUS_PENDING {
+		t.Fatalf("withdrawal above the threshold = %+v, want a pending approval", result.Data)
+	}
+
+	approval, err := usecase.ApproveSpending(ctx, sharedWallet.Id, result.Data.Approval.Id, sharedWallet.OwnedBy)
+	if err != nil {
+		t.Fatalf("approving: %v", err)
+	}
+	if approval.Data.Status != member.SPENDING_APPROVAL_STATUS_APPROVED {
+		t.Errorf("approval status = %s, want %s", approval.Data.Status, member.SPENDING_APPROVAL_STATUS_APPROVED)
+	}
+	if _, err := usecase.ApproveSpending(ctx, sharedWallet.Id, result.Data.Approval.Id, sharedWallet.OwnedBy); err == nil || !errors.Is(err, response.ErrApprovalStatus) {
+		t.Errorf("approving twice: err = %v, want %q", err, response.ERROR_APPROVAL_STATUS)
+	}
+
+	// 50 + 200 spent, 300 allowed
+	if _, err := withdraw(51); err == nil || !errors.Is(err, response.ErrSpendingLimit) {
+		t.Errorf("withdrawing over the limit: err = %v, want %q", err, response.ERROR_SPENDING_LIMIT)
+	}
+
+	walletResult, err := repositories.WalletRepository.GetWalletById(ctx, sharedWallet.Id)
+	if err != nil || walletResult == nil {
+		t.Fatalf("reading wallet: %v", err)
+	}
+	if walletResult.Balance != 750 {
+		t.Errorf("balance = %d, want 750", walletResult.Balance)
+	}
+}
+
+func newMemoryMemberUsecase(t *testing.T, spendingLimit int, approvalThreshold int) (member.MemberUsecase, domain.Repositories, wallet.Wallet, string) {
+	t.Helper()
+	ctx := context.Background()
+
+	config := infrastructure.Config{
+		WALLET_LOCK_TTL:             time.Second * 8,
+		WALLET_TRANSACTION_TIMEOUT:  time.Second * 30,
+		WALLET_CONCURRENCY_STRATEGY: wallet.CONCURRENCY_STRATEGY_CONDITIONAL_UPDATE,
+		WALLET_TRANSACTION_CHANNEL:  "wallet-transactions",
+	}
+	walletRepository := walletApp.NewMemoryWalletRepository()
+	repositories := domain.Repositories{
+		WalletRepository: walletRepository,
+		MemberRepository: NewMemoryMemberRepository(walletRepository),
+	}
+	walletUsecase := walletApp.NewWalletUsecase(repositories, infrastructure.NewMemoryCache(), walletApp.NewMemoryWalletLocker(config.WALLET_LOCK_TTL), config)
+	usecase := NewMemberUsecase(repositories, walletUsecase, config)
+
+	enabledAt := time.Now().Format(time.RFC3339)
+	sharedWallet := wallet.Wallet{
+		Id:        uuid.NewString(),
+		OwnedBy:   uuid.NewString(),
+		Name:      wallet.WALLET_MAIN_POCKET,
+		EnabledAt: &enabledAt,
+		Balance:   1000,
+		Status:    wallet.WALLET_STATUS_ENABLED,
+	}
+	if err := walletRepository.InsertWallet(ctx, sharedWallet); err != nil {
+		t.Fatalf("inserting wallet: %v", err)
+	}
+
+	spenderId := uuid.NewString()
+	_, err := usecase.InviteMember(ctx, member.MemberInvitationRequest{
+		WalletId:          sharedWallet.Id,
+		MemberId:          spenderId,
+		Role:              member.MEMBER_ROLE_SPENDER,
+		SpendingLimit:     &spendingLimit,
+		ApprovalThreshold: &approvalThreshold,
+		InvitedBy:         sharedWallet.OwnedBy,
+	})
+	if err != nil {
+		t.Fatalf("inviting: %v", err)
+	}
+	if _, err := usecase.AcceptInvitation(ctx, spenderId, sharedWallet.Id); err != nil {
+		t.Fatalf("accepting: %v", err)
+	}
+
+	return usecase, repositories, sharedWallet, spenderId
+}
+
+func requestSpending(t *testing.T, usecase member.MemberUsecase, walletId string, spenderId string, amount int) (*member.SpendingApproval, error) {
+	t.Helper()
+
+	result, err := usecase.CreateMemberWithdrawal(context.Background(), wallet.WalletTransactionRequest{
+		WalletId:    walletId,
+		Type:        wallet.WALLET_TRANSACTION_WITHDRAWAL,
+		Amount:      amount,
+		ReferenceId: uuid.NewString(),
+		CreatedBy:   spenderId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Data.Approval, nil
+}
+
+func assertMemberBalance(t *testing.T, repositories domain.Repositories, walletId string, want int) {
+	t.Helper()
+
+	walletResult, err := repositories.WalletRepository.GetWalletById(context.Background(), walletId)
+	if err != nil || walletResult == nil || walletResult.Balance != want {
+		t.Errorf("wallet = %+v %v, want a balance of %d", walletResult, err, want)
+	}
+}
+
+func TestApproveAndRejectRace(t *testing.T) {
+	ctx := context.Background()
+	usecase, repositories, sharedWallet, spenderId := newMemoryMemberUsecase(t, 1000, 1)
+
+	const approvals = 20
+	var requested []*member.SpendingApproval
+	for i := 0; i < approvals; i++ {
+		approval, err := requestSpending(t, usecase, sharedWallet.Id, spenderId, 10)
+		if err != nil || approval == nil {
+			t.Fatalf("requesting approval #%d: %+v %v", i, approval, err)
+		}
+		requested = append(requested, approval)
+	}
+
+	approved := make([]error, approvals)
+	rejected := make([]error, approvals)
+	var wg sync.WaitGroup
+	for i, approval := range requested {
+		wg.Add(2)
+		go func(i int, approvalId string) {
+			defer wg.Done()
+			_, approved[i] = usecase.ApproveSpending(ctx, sharedWallet.Id, approvalId, sharedWallet.OwnedBy)
+		}(i, approval.Id)
+		go func(i int, approvalId string) {
+			defer wg.Done()
+			_, rejected[i] = usecase.RejectSpending(ctx, sharedWallet.Id, approvalId, sharedWallet.OwnedBy)
+		}(i, approval.Id)
+	}
+	wg.Wait()
+
+	withdrawn := 0
+	for i, approval := range requested {
+		if (approved[i] == nil) == (rejected[i] == nil) {
+			t.Errorf("approval #%d: approve = %v, reject = %v, want exactly one to win", i, approved[i], rejected[i])
+			continue
+		}
+		for _, err := range []error{approved[i], rejected[i]} {
+			if err != nil && !errors.Is(err, response.ErrApprovalStatus) {
+				t.Errorf("approval #%d: the loser got %v, want %v", i, err, response.ErrApprovalStatus)
+			}
+		}
+
+		stored, _ := repositories.MemberRepository.GetSpendingApprovalById(ctx, approval.Id)
+		walletTransaction, _ := repositories.WalletRepository.GetWalletTransactionByReferenceId(ctx, sharedWallet.Id, approval.ReferenceId)
+		if approved[i] == nil {
+			withdrawn += approval.Amount
+			if stored.Status != member.SPENDING_APPROVAL_STATUS_APPROVED || walletTransaction == nil {
+				t.Errorf("approval #%d won by approve is %s with the withdrawal %+v", i, stored.Status, walletTransaction)
+			}
+		} else if stored.Status != member.SPENDING_APPROVAL_STATUS_REJECTED || walletTransaction != nil {
+			t.Errorf("approval #%d won by reject is %s with the withdrawal %+v", i, stored.Status, walletTransaction)
+		}
+	}
+	assertMemberBalance(t, repositories, sharedWallet.Id, 1000-withdrawn)
+}
+
+// slowSpendingRepository answers the spending of a member late, every withdrawal racing another one
+// has read it before either is made
+type slowSpendingRepository struct {
+	member.MemberRepository
+}
+
+func (repository *slowSpendingRepository) GetMemberSpending(ctx context.Context, walletId string, memberId string, since time.Time) (int, error) {
+	spent, err := repository.MemberRepository.GetMemberSpending(ctx, walletId, memberId, since)
+	time.Sleep(time.Millisecond * 20)
+	return spent, err
+}
+
+func TestParallelWithdrawalsKeepTheSpendingLimit(t *testing.T) {
+	_, repositories, sharedWallet, spenderId := newMemoryMemberUsecase(t, 50, 1000)
+
+	config := infrastructure.Config{
+		WALLET_TRANSACTION_TIMEOUT:  time.Second * 30,
+		WALLET_CONCURRENCY_STRATEGY: wallet.CONCURRENCY_STRATEGY_CONDITIONAL_UPDATE,
+	}
+	repositories.MemberRepository = &slowSpendingRepository{MemberRepository: repositories.MemberRepository}
+	walletUsecase := walletApp.NewWalletUsecase(repositories, nil, nil, config)
+	usecase := NewMemberUsecase(repositories, walletUsecase, config)
+
+	const withdrawals = 10
+	errs := make([]error, withdrawals)
+	var wg sync.WaitGroup
+	for i := 0; i < withdrawals; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = requestSpending(t, usecase, sharedWallet.Id, spenderId, 10)
+		}(i)
+	}
+	wg.Wait()
+
+	made := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			made++
+		case !errors.Is(err, response.ErrSpendingLimit):
+			t.Errorf("withdrawal #%d: err = %v, want nil or %v", i, err, response.ErrSpendingLimit)
+		}
+	}
+	if made != 5 {
+		t.Errorf("withdrawals made = %d, want 5 within a limit of 50", made)
+	}
+	assertMemberBalance(t, repositories, sharedWallet.Id, 950)
+}
+
+func TestPendingApprovalsCountTowardsTheSpendingLimit(t *testing.T) {
+	ctx := context.Background()
+	usecase, repositories, sharedWallet, spenderId := newMemoryMemberUsecase(t, 300, 100)
+
+	pending, err := requestSpending(t, usecase, sharedWallet.Id, spenderId, 200)
+	if err != nil || pending == nil {
+		t.Fatalf("requesting approval: %+v %v", pending, err)
+	}
+	if _, err := requestSpending(t, usecase, sharedWallet.Id, spenderId, 150); !errors.Is(err, response.ErrSpendingLimit) {
+		t.Errorf("requesting over the limit with 200 pending: err = %v, want %v", err, response.ErrSpendingLimit)
+	}
+	if approval, err := requestSpending(t, usecase, sharedWallet.Id, spenderId, 100); err != nil || approval != nil {
+		t.Fatalf("withdrawing up to the limit: %+v %v, want it made", approval, err)
+	}
+
+	result, err := usecase.ApproveSpending(ctx, sharedWallet.Id, pending.Id, sharedWallet.OwnedBy)
+	if err != nil || result.Data.Status != member.SPENDING_APPROVAL_STATUS_APPROVED {
+		t.Fatalf("approving up to the limit: %+v %v", result, err)
+	}
+	assertMemberBalance(t, repositories, sharedWallet.Id, 700)
+}
+
+func TestApproveSpendingChecksTheLimitAgain(t *testing.T) {
+	ctx := context.Background()
+	usecase, repositories, sharedWallet, spenderId := newMemoryMemberUsecase(t, 300, 100)
+
+	pending, err := requestSpending(t, usecase, sharedWallet.Id, spenderId, 200)
+	if err != nil || pending == nil {
+		t.Fatalf("requesting approval: %+v %v", pending, err)
+	}
+
+	// spent meanwhile around the member usecase, e.g. by an earlier schedule
+	_, err = repositories.WalletRepository.CreateWalletTransactionAtomically(ctx, wallet.WalletTransactionEntity{
+		Id:          uuid.NewString(),
+		WalletId:    sharedWallet.Id,
+		Amount:      150,
+		CreatedAt:   time.Now().Format(time.RFC3339),
+		CreatedBy:   spenderId,
+		Type:        wallet.WALLET_TRANSACTION_WITHDRAWAL,
+		Status:      wallet.WALLET_TRANSACTION_STATUS_SUCCESS,
+		ReferenceId: uuid.NewString(),
+	})
+	if err != nil {
+		t.Fatalf("withdrawing: %v", err)
+	}
+
+	result, err := usecase.ApproveSpending(ctx, sharedWallet.Id, pending.Id, sharedWallet.OwnedBy)
+	if err != nil || result.Data.Status != member.SPENDING_APPROVAL_STATUS_FAILED || result.Data.Error == nil ||
+		*result.Data.Error != response.ErrSpendingLimit.Error() {
+		t.Fatalf("approving over the limit = %+v %v, want it failed on the spending limit", result, err)
+	}
+	assertMemberBalance(t, repositories, sharedWallet.Id, 850)
+}
+
+func TestApproveSpendingWithATakenReferenceId(t *testing.T) {
+	ctx := context.Background()
+	usecase, repositories, sharedWallet, spenderId := newMemoryMemberUsecase(t, 1000, 100)
+
+	pending, err := requestSpending(t, usecase, sharedWallet.Id, spenderId, 200)
+	if err != nil || pending == nil {
+		t.Fatalf("requesting approval: %+v %v", pending, err)
+	}
+
+	// the owner deposits with the reference id of the pending request
+	_, err = repositories.WalletRepository.CreateWalletTransactionAtomically(ctx, wallet.WalletTransactionEntity{
+		Id:          uuid.NewString(),
+		WalletId:    sharedWallet.Id,
+		Amount:      200,
+		CreatedAt:   time.Now().Format(time.RFC3339),
+		CreatedBy:   sharedWallet.OwnedBy,
+		Type:        wallet.WALLET_TRANSACTION_DEPOSIT,
+		Status:      wallet.WALLET_TRANSACTION_STATUS_SUCCESS,
+		ReferenceId: pending.ReferenceId,
+	})
+	if err != nil {
+		t.Fatalf("depositing: %v", err)
+	}
+
+	result, err := usecase.ApproveSpending(ctx, sharedWallet.Id, pending.Id, sharedWallet.OwnedBy)
+	if err != nil || result.Data.Status != member.SPENDING_APPROVAL_STATUS_FAILED {
+		t.Fatalf("approving = %+v %v, want it failed rather than approved on the deposit", result, err)
+	}
+	assertMemberBalance(t, repositories, sharedWallet.Id, 1200)
+}
+
+func TestApproveSpendingResumesAnInterruptedApproval(t *testing.T) {
+	ctx := context.Background()
+	usecase, repositories, sharedWallet, spenderId := newMemoryMemberUsecase(t, 1000, 100)
+
+	pending, err := requestSpending(t, usecase, sharedWallet.Id, spenderId, 200)
+	if err != nil || pending == nil {
+		t.Fatalf("requesting approval: %+v %v", pending, err)
+	}
+
+	// claimed and withdrawn two minutes ago, then the process died before recording it
+	interrupted := *pending
+	claimedAt := time.Now().Add(-2 * time.Minute).Format(time.RFC3339)
+	interrupted.Status = member.SPENDING_APPROVAL_STATUS_APPROVING
+	interrupted.DecidedBy = &sharedWallet.OwnedBy
+	interrupted.DecidedAt = &claimedAt
+	if updated, err := repositories.MemberRepository.UpdateSpendingApproval(ctx, interrupted, *pending); err != nil || !updated {
+		t.Fatalf("claiming: %t %v", updated, err)
+	}
+	_, err = repositories.WalletRepository.CreateWalletTransactionAtomically(ctx, wallet.WalletTransactionEntity{
+		Id:          uuid.NewString(),
+		WalletId:    sharedWallet.Id,
+		Amount:      pending.Amount,
+		CreatedAt:   claimedAt,
+		CreatedBy:   spenderId,
+		Type:        wallet.WALLET_TRANSACTION_WITHDRAWAL,
+		Status:      wallet.WALLET_TRANSACTION_STATUS_SUCCESS,
+		ReferenceId: pending.ReferenceId,
+	})
+	if err != nil {
+		t.Fatalf("withdrawing: %v", err)
+	}
+
+	if _, err := usecase.RejectSpending(ctx, sharedWallet.Id, pending.Id, sharedWallet.OwnedBy); !errors.Is(err, response.ErrApprovalStatus) {
+		t.Errorf("rejecting an interrupted approval: err = %v, want %v", err, response.ErrApprovalStatus)
+	}
+
+	result, err := usecase.ApproveSpending(ctx, sharedWallet.Id, pending.Id, sharedWallet.OwnedBy)
+	if err != nil || result.Data.Status != member.SPENDING_APPROVAL_STATUS_APPROVED {
+		t.Fatalf("approving again = %+v %v, want it approved", result, err)
+	}
+	assertMemberBalance(t, repositories, sharedWallet.Id, 800)
+}
@@ -0,0 +1,53 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	// Spec describes every /api/v1 route, the client package is generated from it
+	//go:embed openapi.json
+	Spec []byte
+
+	// swagger ui from its cdn, nothing of it is vendored
+	docsPage = []byte(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>mini-wallet API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`)
+)
+
+type openapiHandler struct{}
+
+func SetOpenapiHandler(router *chi.Mux) {
+	openapiHandler := openapiHandler{}
+
+	router.Get("/openapi.json", openapiHandler.GetSpec)
+	router.Get("/docs", openapiHandler.GetDocs)
+}
+
+func (handler *openapiHandler) GetSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(Spec)
+}
+
+func (handler *openapiHandler) GetDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
@@ -0,0 +1,108 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"mini-wallet/app/auth"
+	"mini-wallet/app/openapi"
+	"mini-wallet/domain"
+	"mini-wallet/infrastructure"
+	"mini-wallet/presentation"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	// chi joins the patterns of nested routes as they are written, e.g. /api/v1/ and /init
+	duplicateSlashes = regexp.MustCompile(`/{2,}`)
+
+	// the probes, the metrics and the docs themselves are no part of the api the spec documents
+	undocumentedRoutes = map[string]bool{
+		"/healthz":      true,
+		"/readyz":       true,
+		"/debug/status": true,
+		"/metrics":      true, // mounted for every method
+		"/openapi.json": true,
+		"/docs":         true,
+	}
+)
+
+func TestSpecMatchesRouter(t *testing.T) {
+	router := chi.NewRouter()
+	usecases := domain.Usecases{
+		AuthUsecase: auth.NewAuthUsecase(domain.Repositories{}, infrastructure.Config{}),
+	}
+	// every handler InitServer mounts, a route added to any of them has to be documented
+	presentation.SetHandlers(router, usecases)
+
+	registered := []string{}
+	err := chi.Walk(router, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		route = duplicateSlashes.ReplaceAllString(route, "/")
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		if !undocumentedRoutes[route] {
+			registered = append(registered, method+" "+route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}{}
+	if err := json.Unmarshal(openapi.Spec, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	documented := []string{}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(registered)
+	sort.Strings(documented)
+	if strings.Join(registered, "\n") != strings.Join(documented, "\n") {
+		t.Errorf("routes of the router:\n%s\n\nroutes of the spec:\n%s", strings.Join(registered, "\n"), strings.Join(documented, "\n"))
+	}
+}
+
+func TestSpecReferencesResolve(t *testing.T) {
+	spec := map[string]any{}
+	if err := json.Unmarshal(openapi.Spec, &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	var walk func(node any)
+	walk = func(node any) {
+		switch node := node.(type) {
+		case map[string]any:
+			if ref, ok := node["$ref"].(string); ok {
+				var target any = spec
+				for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					object, _ := target.(map[string]any)
+					target = object[part]
+				}
+				if target == nil {
+					t.Errorf("%s does not resolve", ref)
+				}
+			}
+			for _, child := range node {
+				walk(child)
+			}
+		case []any:
+			for _, child := range node {
+				walk(child)
+			}
+		}
+	}
+	walk(spec)
+}
@@ -0,0 +1,84 @@
+package reconciliation
+
+import (
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/reconciliation"
+	"mini-wallet/domain/wallet"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type reconciliationHandler struct {
+	reconciliationUsecase reconciliation.ReconciliationUsecase
+}
+
+func SetReconciliationHandler(router *chi.Mux, usecases domain.Usecases) {
+	reconciliationHandler := reconciliationHandler{
+		reconciliationUsecase: usecases.ReconciliationUsecase,
+	}
+
+	// runs are started by the job or the reconcile command, support reads their reports and lifts the freezes
+	router.Route("/api/v1/reconciliations", func(r chi.Router) {
+		r.Use(usecases.AuthUsecase.AuthorizeAdminMiddleware)
+
+		// GET
+		r.Get("/", reconciliationHandler.GetReconciliationRuns)
+		r.Get("/{runId}", reconciliationHandler.GetReconciliationReport)
+
+		// POST
+		r.Post("/wallets/{walletId}/unfreeze", reconciliationHandler.UnfreezeWallet)
+	})
+}
+
+func (handler *reconciliationHandler) GetReconciliationRuns(w http.ResponseWriter, r *http.Request) {
+	result, err := handler.reconciliationUsecase.GetReconciliationRuns(r.Context())
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[[]reconciliation.ReconciliationRun]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *reconciliationHandler) GetReconciliationReport(w http.ResponseWriter, r *http.Request) {
+	result, err := handler.reconciliationUsecase.GetReconciliationReport(r.Context(), chi.URLParam(r, "runId"))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[reconciliation.ReconciliationReport]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *reconciliationHandler) UnfreezeWallet(w http.ResponseWriter, r *http.Request) {
+	result, err := handler.reconciliationUsecase.UnfreezeWallet(r.Context(), chi.URLParam(r, "walletId"))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[wallet.Wallet]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
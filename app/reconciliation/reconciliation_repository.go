@@ -0,0 +1,157 @@
+package reconciliation
+
+import (
+	"context"
+	"mini-wallet/domain/reconciliation"
+	"mini-wallet/domain/wallet"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"gorm.io/gorm"
+)
+
+type reconciliationRepository struct {
+	db *gorm.DB
+}
+
+func NewReconciliationRepository(db *gorm.DB) reconciliation.ReconciliationRepository {
+	return &reconciliationRepository{
+		db: db,
+	}
+}
+
+func (reconciliationRepository *reconciliationRepository) InsertReconciliationRun(ctx context.Context, run reconciliation.ReconciliationRun) (err error) {
+	return reconciliationRepository.db.WithContext(ctx).Table("tr_reconciliation_run").Create(&run).Error
+}
+
+func (reconciliationRepository *reconciliationRepository) UpdateReconciliationRun(ctx context.Context, run reconciliation.ReconciliationRun) (err error) {
+	return reconciliationRepository.db.WithContext(ctx).Table("tr_reconciliation_run").
+		Where("id = ?", run.Id).
+		Updates(map[string]interface{}{
+			"status":          run.Status,
+			"wallets_checked": run.WalletsChecked,
+			"mismatches":      run.Mismatches,
+			"frozen_wallets":  run.FrozenWallets,
+			"error":           run.Error,
+			"finished_at":     run.FinishedAt,
+		}).Error
+}
+
+func (reconciliationRepository *reconciliationRepository) GetReconciliationRuns(ctx context.Context, limit int) (res []reconciliation.ReconciliationRun, err error) {
+	// ids are time ordered
+	qry, args, err := sq.Select("*").From("tr_reconciliation_run").OrderBy("id DESC").Limit(uint64(limit)).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = reconciliationRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (reconciliationRepository *reconciliationRepository) GetReconciliationRunById(ctx context.Context, runId string) (res *reconciliation.ReconciliationRun, err error) {
+	qry, args, err := sq.Select("*").From("tr_reconciliation_run").Where(sq.Eq{"id": runId}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	result := reconciliationRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+func (reconciliationRepository *reconciliationRepository) InsertMismatch(ctx context.Context, mismatch reconciliation.Mismatch) (err error) {
+	return reconciliationRepository.db.WithContext(ctx).Table("tr_reconciliation_mismatch").Create(&mismatch).Error
+}
+
+func (reconciliationRepository *reconciliationRepository) GetMismatches(ctx context.Context, runId string) (res []reconciliation.Mismatch, err error) {
+	qry, args, err := sq.Select("*").From("tr_reconciliation_mismatch").Where(sq.Eq{"run_id": runId}).OrderBy("wallet_id").ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = reconciliationRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (reconciliationRepository *reconciliationRepository) GetWalletBalanceChecks(ctx context.Context, walletId string, afterId string, limit int) (res []reconciliation.WalletBalanceCheck, err error) {
+	// a single statement, the balance and the history come from the same snapshot
+	builder := sq.Select(
+		"w.id AS wallet_id",
+		"w.owned_by",
+		"w.status AS wallet_status",
+		"w.balance AS stored_balance",
+		"COALESCE(h.computed_balance, 0) AS computed_balance",
+		"h.transaction_count",
+		"h.last_transaction_at",
+	).
+		From("ms_wallet w").
+		JoinClause(`LEFT JOIN LATERAL (
+			SELECT SUM(CASE WHEN t.type = ? THEN -t.amount ELSE t.amount END) AS computed_balance,
+				COUNT(*) AS transaction_count,
+				MAX(created_at_timestamptz(t.created_at)) AS last_transaction_at
+			FROM tr_wallet_transaction t
+			WHERE t.wallet_id = w.id
+		) h ON true`, wallet.WALLET_TRANSACTION_WITHDRAWAL).
+		Where(sq.Gt{"w.id": afterId}).
+		OrderBy("w.id").
+		Limit(uint64(limit))
+	if walletId != "" {
+		builder = builder.Where(sq.Eq{"w.id": walletId})
+	}
+
+	qry, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = reconciliationRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (reconciliationRepository *reconciliationRepository) FreezeWallet(ctx context.Context, walletId string) (frozen bool, err error) {
+	// the version bump makes a read-modify-write in flight fail instead of landing on a frozen wallet
+	result := reconciliationRepository.db.WithContext(ctx).Table("ms_wallet").
+		Where("id = ? AND status <> ?", walletId, wallet.WALLET_STATUS_FROZEN).
+		Updates(map[string]interface{}{
+			"status":  wallet.WALLET_STATUS_FROZEN,
+			"version": gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (reconciliationRepository *reconciliationRepository) UnfreezeWallet(ctx context.Context, walletId string) (unfrozen bool, err error) {
+	result := reconciliationRepository.db.WithContext(ctx).Table("ms_wallet").
+		Where("id = ? AND status = ?", walletId, wallet.WALLET_STATUS_FROZEN).
+		Updates(map[string]interface{}{
+			"status":     wallet.WALLET_STATUS_DISABLED,
+			"enabled_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,243 @@
+package reconciliation
+
+import (
+	"context"
+	"fmt"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/reconciliation"
+	"mini-wallet/domain/wallet"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryReconciliationRepository struct {
+	walletRepository wallet.WalletRepository
+
+	mu         sync.RWMutex
+	runs       map[string]reconciliation.ReconciliationRun
+	mismatches map[string][]reconciliation.Mismatch // by run id
+
+	// FreezeWallet and UnfreezeWallet read the status before writing it, one at a time
+	statusMu sync.Mutex
+}
+
+// NewMemoryReconciliationRepository keeps the runs and their mismatches in this process, for tests and the memory storage.
+// the wallets and their transactions are read and frozen through walletRepository, like the postgres one queries them
+func NewMemoryReconciliationRepository(walletRepository wallet.WalletRepository) reconciliation.ReconciliationRepository {
+	return &memoryReconciliationRepository{
+		walletRepository: walletRepository,
+		runs:             map[string]reconciliation.ReconciliationRun{},
+		mismatches:       map[string][]reconciliation.Mismatch{},
+	}
+}
+
+func (reconciliationRepository *memoryReconciliationRepository) InsertReconciliationRun(ctx context.Context, run reconciliation.ReconciliationRun) (err error) {
+	if err = checkReconciliationRun(run); err != nil {
+		return err
+	}
+
+	reconciliationRepository.mu.Lock()
+	defer reconciliationRepository.mu.Unlock()
+
+	if _, found := reconciliationRepository.runs[run.Id]; found {
+		return fmt.Errorf("reconciliation run %s already exists", run.Id)
+	}
+	reconciliationRepository.runs[run.Id] = run
+
+	return nil
+}
+
+func (reconciliationRepository *memoryReconciliationRepository) UpdateReconciliationRun(ctx context.Context, run reconciliation.ReconciliationRun) (err error) {
+	reconciliationRepository.mu.Lock()
+	defer reconciliationRepository.mu.Unlock()
+
+	storedRun, found := reconciliationRepository.runs[run.Id]
+	if !found {
+		return nil
+	}
+
+	storedRun.Status = run.Status
+	storedRun.WalletsChecked = run.WalletsChecked
+	storedRun.Mismatches = run.Mismatches
+	storedRun.FrozenWallets = run.FrozenWallets
+	storedRun.Error = run.Error
+	storedRun.FinishedAt = run.FinishedAt
+	if err = checkReconciliationRun(storedRun); err != nil {
+		return err
+	}
+	reconciliationRepository.runs[run.Id] = storedRun
+
+	return nil
+}
+
+func (reconciliationRepository *memoryReconciliationRepository) GetReconciliationRuns(ctx context.Context, limit int) (res []reconciliation.ReconciliationRun, err error) {
+	reconciliationRepository.mu.RLock()
+	defer reconciliationRepository.mu.RUnlock()
+
+	// ids are time ordered
+	for _, run := range reconciliationRepository.runs {
+		res = append(res, run)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Id > res[j].Id })
+
+	return res[:min(limit, len(res))], nil
+}
+
+func (reconciliationRepository *memoryReconciliationRepository) GetReconciliationRunById(ctx context.Context, runId string) (res *reconciliation.ReconciliationRun, err error) {
+	reconciliationRepository.mu.RLock()
+	defer reconciliationRepository.mu.RUnlock()
+
+	run, found := reconciliationRepository.runs[runId]
+	if !found {
+		return nil, nil
+	}
+
+	return &run, nil
+}
+
+func (reconciliationRepository *memoryReconciliationRepository) InsertMismatch(ctx context.Context, mismatch reconciliation.Mismatch) (err error) {
+	// the foreign key on ms_wallet
+	walletData, err := reconciliationRepository.walletRepository.GetWalletById(ctx, mismatch.WalletId)
+	if err != nil {
+		return err
+	}
+	if walletData == nil {
+		return response.ErrWalletNotFound
+	}
+
+	reconciliationRepository.mu.Lock()
+	defer reconciliationRepository.mu.Unlock()
+
+	if _, found := reconciliationRepository.runs[mismatch.RunId]; !found {
+		return fmt.Errorf("reconciliation run %s does not exist", mismatch.RunId)
+	}
+	for _, storedMismatch := range reconciliationRepository.mismatches[mismatch.RunId] {
+		if storedMismatch.WalletId == mismatch.WalletId {
+			return fmt.Errorf("mismatch of wallet %s already exists in run %s", mismatch.WalletId, mismatch.RunId)
+		}
+	}
+	reconciliationRepository.mismatches[mismatch.RunId] = append(reconciliationRepository.mismatches[mismatch.RunId], mismatch)
+
+	return nil
+}
+
+func (reconciliationRepository *memoryReconciliationRepository) GetMismatches(ctx context.Context, runId string) (res []reconciliation.Mismatch, err error) {
+	reconciliationRepository.mu.RLock()
+	defer reconciliationRepository.mu.RUnlock()
+
+	res = append(res, reconciliationRepository.mismatches[runId]...)
+	sort.Slice(res, func(i, j int) bool { return res[i].WalletId < res[j].WalletId })
+
+	return res, nil
+}
+
+func (reconciliationRepository *memoryReconciliationRepository) GetWalletBalanceChecks(ctx context.Context, walletId string, afterId string, limit int) (res []reconciliation.WalletBalanceCheck, err error) {
+	var wallets []wallet.Wallet
+	if walletId != "" {
+		walletData, err := reconciliationRepository.walletRepository.GetWalletById(ctx, walletId)
+		if err != nil {
+			return nil, err
+		}
+		if walletData != nil && walletData.Id > afterId && limit > 0 {
+			wallets = append(wallets, *walletData)
+		}
+	} else if wallets, err = reconciliationRepository.walletRepository.GetWallets(ctx, afterId, limit); err != nil {
+		return nil, err
+	}
+
+	for _, walletData := range wallets {
+		check, err := reconciliationRepository.checkWalletBalance(ctx, walletData)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, check)
+	}
+
+	return res, nil
+}
+
+// checkWalletBalance adds up the history of walletData. there is no snapshot to read the balance and the history from,
+// so the wallet is read again afterwards: a version moved meanwhile means a transaction landed in between,
+// and the wallet is checked again rather than reported as drifting
+func (reconciliationRepository *memoryReconciliationRepository) checkWalletBalance(ctx context.Context, walletData wallet.Wallet) (check reconciliation.WalletBalanceCheck, err error) {
+	for {
+		check = reconciliation.WalletBalanceCheck{
+			WalletId:      walletData.Id,
+			OwnedBy:       walletData.OwnedBy,
+			WalletStatus:  walletData.Status,
+			StoredBalance: walletData.Balance,
+		}
+		err = reconciliationRepository.walletRepository.StreamWalletTransactions(ctx, wallet.GetWalletTransactionRequest{WalletId: walletData.Id}, func(walletTransaction wallet.WalletTransactionEntity) error {
+			check.ComputedBalance += walletTransaction.BalanceDelta()
+			check.TransactionCount++
+			// streamed oldest first
+			if createdAt, err := time.Parse(time.RFC3339, walletTransaction.CreatedAt); err == nil {
+				check.LastTransactionAt = &createdAt
+			}
+			return nil
+		})
+		if err != nil {
+			return check, err
+		}
+
+		rereadWallet, err := reconciliationRepository.walletRepository.GetWalletById(ctx, walletData.Id)
+		if err != nil {
+			return check, err
+		}
+		if rereadWallet == nil || rereadWallet.Version == walletData.Version {
+			return check, nil
+		}
+		walletData = *rereadWallet
+	}
+}
+
+func (reconciliationRepository *memoryReconciliationRepository) FreezeWallet(ctx context.Context, walletId string) (frozen bool, err error) {
+	reconciliationRepository.statusMu.Lock()
+	defer reconciliationRepository.statusMu.Unlock()
+
+	walletData, err := reconciliationRepository.walletRepository.GetWalletById(ctx, walletId)
+	if err != nil || walletData == nil || walletData.Status == wallet.WALLET_STATUS_FROZEN {
+		return false, err
+	}
+
+	// UpdateWallet bumps the version too, a read-modify-write in flight fails instead of landing on a frozen wallet
+	walletData.Status = wallet.WALLET_STATUS_FROZEN
+	if err = reconciliationRepository.walletRepository.UpdateWallet(ctx, *walletData); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (reconciliationRepository *memoryReconciliationRepository) UnfreezeWallet(ctx context.Context, walletId string) (unfrozen bool, err error) {
+	reconciliationRepository.statusMu.Lock()
+	defer reconciliationRepository.statusMu.Unlock()
+
+	walletData, err := reconciliationRepository.walletRepository.GetWalletById(ctx, walletId)
+	if err != nil || walletData == nil || walletData.Status != wallet.WALLET_STATUS_FROZEN {
+		return false, err
+	}
+
+	walletData.Status = wallet.WALLET_STATUS_DISABLED
+	walletData.EnabledAt = nil
+	if err = reconciliationRepository.walletRepository.UpdateWallet(ctx, *walletData); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// checkReconciliationRun mirrors the check constraints of tr_reconciliation_run
+func checkReconciliationRun(run reconciliation.ReconciliationRun) error {
+	if run.Trigger != reconciliation.RECONCILIATION_TRIGGER_JOB && run.Trigger != reconciliation.RECONCILIATION_TRIGGER_COMMAND {
+		return response.ErrBadRequest
+	}
+
+	switch run.Status {
+	case reconciliation.RECONCILIATION_STATUS_RUNNING, reconciliation.RECONCILIATION_STATUS_COMPLETED, reconciliation.RECONCILIATION_STATUS_FAILED:
+		return nil
+	}
+
+	return response.ErrBadRequest
+}
@@ -0,0 +1,319 @@
+package reconciliation
+
+import (
+	"context"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/reconciliation"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	walletBalanceChecksPage = 500
+	reconciliationRunsLimit = 50
+)
+
+type reconciliationUsecase struct {
+	reconciliationRepository reconciliation.ReconciliationRepository
+	walletRepository         wallet.WalletRepository
+	config                   infrastructure.Config
+}
+
+func NewReconciliationUsecase(repositories domain.Repositories, config infrastructure.Config) reconciliation.ReconciliationUsecase {
+	return &reconciliationUsecase{
+		reconciliationRepository: repositories.ReconciliationRepository,
+		walletRepository:         repositories.WalletRepository,
+		config:                   config,
+	}
+}
+
+func (usecase *reconciliationUsecase) Reconcile(ctx context.Context, options reconciliation.ReconciliationOptions) (res *response.Response[reconciliation.ReconciliationReport], err error) {
+	runId, err := uuid.NewV7()
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on uuid.NewV7() - Reconcile", err)
+		return nil, err
+	}
+
+	run := reconciliation.ReconciliationRun{
+		Id:            runId.String(),
+		Trigger:       options.Trigger,
+		FreezeWallets: options.FreezeWallets,
+		RepairDryRun:  options.RepairDryRun,
+		Status:        reconciliation.RECONCILIATION_STATUS_RUNNING,
+		StartedAt:     time.Now().Format(time.RFC3339),
+	}
+	if options.WalletId != "" {
+		run.WalletId = &options.WalletId
+	}
+
+	err = usecase.reconciliationRepository.InsertReconciliationRun(ctx, run)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.reconciliationRepository.InsertReconciliationRun() - Reconcile", err)
+		return nil, err
+	}
+
+	mismatches, driftAmount, checkErr := usecase.checkWallets(ctx, &run, options)
+
+	finishedAt := time.Now().Format(time.RFC3339)
+	run.FinishedAt = &finishedAt
+	run.Status = reconciliation.RECONCILIATION_STATUS_COMPLETED
+	if checkErr != nil {
+		errString := checkErr.Error()
+		run.Status = reconciliation.RECONCILIATION_STATUS_FAILED
+		run.Error = &errString
+	}
+
+	// the run is recorded as finished even when ctx was cancelled halfway
+	err = usecase.reconciliationRepository.UpdateReconciliationRun(context.WithoutCancel(ctx), run)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.reconciliationRepository.UpdateReconciliationRun() - Reconcile", err, "run_id", run.Id)
+		return nil, err
+	}
+
+	infrastructure.ReconciliationRunsTotal.WithLabelValues(run.Status).Inc()
+	// a partial run or a single wallet says nothing about the others
+	if run.Status == reconciliation.RECONCILIATION_STATUS_COMPLETED && options.WalletId == "" {
+		infrastructure.ReconciliationDriftingWallets.Set(float64(run.Mismatches))
+		infrastructure.ReconciliationDriftAmount.Set(float64(driftAmount))
+		infrastructure.ReconciliationLastCompletedTimestamp.SetToCurrentTime()
+	}
+
+	infrastructure.LogInfo(ctx, "reconciliation finished", "run_id", run.Id, "status", run.Status, "wallets_checked", run.WalletsChecked, "mismatches", run.Mismatches, "frozen_wallets", run.FrozenWallets)
+
+	return &response.Response[reconciliation.ReconciliationReport]{
+		Data: &reconciliation.ReconciliationReport{
+			Run:        run,
+			Mismatches: mismatches,
+		},
+	}, nil
+}
+
+func (usecase *reconciliationUsecase) GetReconciliationRuns(ctx context.Context) (res *response.Response[[]reconciliation.ReconciliationRun], err error) {
+	runs, err := usecase.reconciliationRepository.GetReconciliationRuns(ctx, reconciliationRunsLimit)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.reconciliationRepository.GetReconciliationRuns() - GetReconciliationRuns", err)
+		return nil, err
+	}
+
+	if runs == nil {
+		runs = []reconciliation.ReconciliationRun{}
+	}
+
+	return &response.Response[[]reconciliation.ReconciliationRun]{
+		Data: &runs,
+	}, nil
+}
+
+func (usecase *reconciliationUsecase) GetReconciliationReport(ctx context.Context, runId string) (res *response.Response[reconciliation.ReconciliationReport], err error) {
+	run, err := usecase.reconciliationRepository.GetReconciliationRunById(ctx, runId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.reconciliationRepository.GetReconciliationRunById() - GetReconciliationReport", err)
+		return nil, err
+	}
+
+	if run == nil {
+		return nil, response.ErrReconciliationNotFound
+	}
+
+	mismatches, err := usecase.reconciliationRepository.GetMismatches(ctx, runId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.reconciliationRepository.GetMismatches() - GetReconciliationReport", err)
+		return nil, err
+	}
+
+	if mismatches == nil {
+		mismatches = []reconciliation.Mismatch{}
+	}
+
+	return &response.Response[reconciliation.ReconciliationReport]{
+		Data: &reconciliation.ReconciliationReport{
+			Run:        *run,
+			Mismatches: mismatches,
+		},
+	}, nil
+}
+
+func (usecase *reconciliationUsecase) FreezeWallet(ctx context.Context, walletId string) (res *response.Response[wallet.Wallet], err error) {
+	frozen, err := usecase.reconciliationRepository.FreezeWallet(ctx, walletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.reconciliationRepository.FreezeWallet() - FreezeWallet", err)
+		return nil, err
+	}
+
+	walletResult, err := usecase.walletRepository.GetWalletById(ctx, walletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - FreezeWallet", err)
+		return nil, err
+	}
+
+	if walletResult == nil {
+		return nil, response.ErrWalletNotFound
+	}
+
+	if frozen {
+		infrastructure.LogInfo(ctx, "wallet frozen", "wallet_id", walletId)
+	}
+
+	return &response.Response[wallet.Wallet]{
+		Data: walletResult,
+	}, nil
+}
+
+func (usecase *reconciliationUsecase) UnfreezeWallet(ctx context.Context, walletId string) (res *response.Response[wallet.Wallet], err error) {
+	unfrozen, err := usecase.reconciliationRepository.UnfreezeWallet(ctx, walletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.reconciliationRepository.UnfreezeWallet() - UnfreezeWallet", err)
+		return nil, err
+	}
+
+	walletResult, err := usecase.walletRepository.GetWalletById(ctx, walletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - UnfreezeWallet", err)
+		return nil, err
+	}
+
+	if walletResult == nil {
+		return nil, response.ErrWalletNotFound
+	}
+
+	// lifting the freeze twice is not an error, anything else never was frozen
+	if !unfrozen && walletResult.Status != wallet.WALLET_STATUS_DISABLED {
+		return nil, response.ErrBadRequest
+	}
+
+	if unfrozen {
+		infrastructure.LogInfo(ctx, "wallet unfrozen", "wallet_id", walletId)
+	}
+
+	return &response.Response[wallet.Wallet]{
+		Data: walletResult,
+	}, nil
+}
+
+func (usecase *reconciliationUsecase) RunReconciliationJob(ctx context.Context) {
+	ticker := time.NewTicker(usecase.config.RECONCILIATION_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := usecase.Reconcile(ctx, reconciliation.ReconciliationOptions{
+				Trigger:       reconciliation.RECONCILIATION_TRIGGER_JOB,
+				FreezeWallets: usecase.config.RECONCILIATION_FREEZE,
+			})
+			if err != nil {
+				infrastructure.LogError(ctx, "got error on usecase.Reconcile() - RunReconciliationJob", err)
+			}
+		}
+	}
+}
+
+// checkWallets pages through the wallets and records their mismatches on the run, driftAmount is the sum of the absolute drifts
+func (usecase *reconciliationUsecase) checkWallets(ctx context.Context, run *reconciliation.ReconciliationRun, options reconciliation.ReconciliationOptions) (mismatches []reconciliation.Mismatch, driftAmount int, err error) {
+	mismatches = []reconciliation.Mismatch{}
+
+	afterId := ""
+	for {
+		checks, err := usecase.reconciliationRepository.GetWalletBalanceChecks(ctx, options.WalletId, afterId, walletBalanceChecksPage)
+		if err != nil {
+			infrastructure.LogError(ctx, "got error on usecase.reconciliationRepository.GetWalletBalanceChecks() - checkWallets", err, "run_id", run.Id)
+			return mismatches, driftAmount, err
+		}
+
+		for _, check := range checks {
+			run.WalletsChecked++
+			if check.Drift() == 0 {
+				continue
+			}
+
+			mismatch := newMismatch(run.Id, check, options.RepairDryRun)
+
+			infrastructure.LogWarn(ctx, "wallet balance drifting from its history",
+				"run_id", run.Id,
+				"wallet_id", check.WalletId,
+				"owned_by", check.OwnedBy,
+				"stored_balance", check.StoredBalance,
+				"computed_balance", check.ComputedBalance,
+				"drift", mismatch.Drift,
+				"transaction_count", check.TransactionCount,
+			)
+
+			if options.FreezeWallets && check.WalletStatus != wallet.WALLET_STATUS_FROZEN {
+				frozen, err := usecase.reconciliationRepository.FreezeWallet(ctx, check.WalletId)
+				if err != nil {
+					infrastructure.LogError(ctx, "got error on usecase.reconciliationRepository.FreezeWallet() - checkWallets", err, "wallet_id", check.WalletId)
+					return mismatches, driftAmount, err
+				}
+
+				if frozen {
+					mismatch.Frozen = true
+					run.FrozenWallets++
+					infrastructure.ReconciliationFrozenWalletsTotal.Inc()
+				}
+			}
+
+			err = usecase.reconciliationRepository.InsertMismatch(ctx, mismatch)
+			if err != nil {
+				infrastructure.LogError(ctx, "got error on usecase.reconciliationRepository.InsertMismatch() - checkWallets", err, "wallet_id", check.WalletId)
+				return mismatches, driftAmount, err
+			}
+
+			mismatches = append(mismatches, mismatch)
+			run.Mismatches++
+			driftAmount += absInt(mismatch.Drift)
+		}
+
+		if len(checks) < walletBalanceChecksPage {
+			return mismatches, driftAmount, nil
+		}
+		afterId = checks[len(checks)-1].WalletId
+	}
+}
+
+// newMismatch proposes the adjustment on a repair dry run. its reference id is derived from the run and the wallet,
+// applying the proposals of one run twice would be refused as a reference id conflict
+func newMismatch(runId string, check reconciliation.WalletBalanceCheck, repairDryRun bool) reconciliation.Mismatch {
+	mismatch := reconciliation.Mismatch{
+		RunId:             runId,
+		WalletId:          check.WalletId,
+		OwnedBy:           check.OwnedBy,
+		WalletStatus:      check.WalletStatus,
+		StoredBalance:     check.StoredBalance,
+		ComputedBalance:   check.ComputedBalance,
+		Drift:             check.Drift(),
+		TransactionCount:  check.TransactionCount,
+		LastTransactionAt: check.LastTransactionAt,
+	}
+
+	if !repairDryRun || mismatch.Drift == 0 {
+		return mismatch
+	}
+
+	// a stored balance above the history is brought down by a withdrawal
+	adjustmentType := wallet.WALLET_TRANSACTION_WITHDRAWAL
+	if mismatch.Drift < 0 {
+		adjustmentType = wallet.WALLET_TRANSACTION_DEPOSIT
+	}
+	adjustmentAmount := absInt(mismatch.Drift)
+	adjustmentReferenceId := uuid.NewSHA1(uuid.NameSpaceURL, []byte("reconciliation/"+runId+"/"+check.WalletId)).String()
+
+	mismatch.AdjustmentType = &adjustmentType
+	mismatch.AdjustmentAmount = &adjustmentAmount
+	mismatch.AdjustmentReferenceId = &adjustmentReferenceId
+
+	return mismatch
+}
+
+func absInt(value int) int {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
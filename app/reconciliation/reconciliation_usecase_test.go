@@ -0,0 +1,133 @@
+package reconciliation
+
+import (
+	"context"
+	walletApp "mini-wallet/app/wallet"
+	"mini-wallet/domain"
+	"mini-wallet/domain/reconciliation"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewMismatchProposesAdjustment(t *testing.T) {
+	for _, tc := range []struct {
+		stored, computed int
+		wantType         string
+		wantAmount       int
+	}{
+		{stored: 150, computed: 100, wantType: wallet.WALLET_TRANSACTION_WITHDRAWAL, wantAmount: 50},
+		{stored: 100, computed: 130, wantType: wallet.WALLET_TRANSACTION_DEPOSIT, wantAmount: 30},
+	} {
+		check := reconciliation.WalletBalanceCheck{WalletId: "w1", StoredBalance: tc.stored, ComputedBalance: tc.computed}
+		mismatch := newMismatch("r1", check, true)
+
+		if mismatch.Drift != tc.stored-tc.computed {
+			t.Fatalf("drift = %d, want %d", mismatch.Drift, tc.stored-tc.computed)
+		}
+		if mismatch.AdjustmentType == nil || *mismatch.AdjustmentType != tc.wantType {
+			t.Fatalf("adjustment type = %v, want %s", mismatch.AdjustmentType, tc.wantType)
+		}
+		if *mismatch.AdjustmentAmount != tc.wantAmount {
+			t.Fatalf("adjustment amount = %d, want %d", *mismatch.AdjustmentAmount, tc.wantAmount)
+		}
+
+		// the adjustment brings the stored balance to the computed one
+		adjusted := wallet.Wallet{Status: wallet.WALLET_STATUS_ENABLED, Balance: tc.stored}
+		err := adjusted.ApplyTransaction(wallet.WalletTransactionEntity{Type: *mismatch.AdjustmentType, Amount: *mismatch.AdjustmentAmount})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if adjusted.Balance != tc.computed {
+			t.Fatalf("adjusted balance = %d, want %d", adjusted.Balance, tc.computed)
+		}
+	}
+}
+
+func TestNewMismatchReferenceId(t *testing.T) {
+	check := reconciliation.WalletBalanceCheck{WalletId: "w1", StoredBalance: 10}
+
+	first := newMismatch("r1", check, true)
+	again := newMismatch("r1", check, true)
+	if *first.AdjustmentReferenceId != *again.AdjustmentReferenceId {
+		t.Fatalf("reference id changed within a run: %s, %s", *first.AdjustmentReferenceId, *again.AdjustmentReferenceId)
+	}
+
+	other := newMismatch("r2", check, true)
+	if *first.AdjustmentReferenceId == *other.AdjustmentReferenceId {
+		t.Fatalf("reference id shared by two runs: %s", *first.AdjustmentReferenceId)
+	}
+}
+
+func TestNewMismatchWithoutRepair(t *testing.T) {
+	check := reconciliation.WalletBalanceCheck{WalletId: "w1", StoredBalance: 10}
+
+	mismatch := newMismatch("r1", check, false)
+	if mismatch.AdjustmentType != nil || mismatch.AdjustmentAmount != nil || mismatch.AdjustmentReferenceId != nil {
+		t.Fatalf("adjustment proposed without a repair dry run: %+v", mismatch)
+	}
+}
+
+func TestReconcileOnTheMemoryStorage(t *testing.T) {
+	ctx := context.Background()
+	walletRepository := walletApp.NewMemoryWalletRepository()
+	usecase := NewReconciliationUsecase(domain.Repositories{
+		WalletRepository:         walletRepository,
+		ReconciliationRepository: NewMemoryReconciliationRepository(walletRepository),
+	}, infrastructure.Config{})
+
+	deposit := func(walletData wallet.Wallet, storedBalance int) {
+		t.Helper()
+		walletTransaction := wallet.WalletTransactionEntity{
+			Id:          uuid.NewString(),
+			WalletId:    walletData.Id,
+			Amount:      100,
+			CreatedAt:   time.Now().Format(time.RFC3339),
+			Type:        wallet.WALLET_TRANSACTION_DEPOSIT,
+			Status:      wallet.WALLET_TRANSACTION_STATUS_SUCCESS,
+			ReferenceId: uuid.NewString(),
+		}
+		walletData.Balance = storedBalance
+		if err := walletRepository.CreateWalletTransaction(ctx, walletData, walletTransaction); err != nil {
+			t.Fatalf("depositing: %v", err)
+		}
+	}
+
+	var consistent, drifting wallet.Wallet
+	for _, walletData := range []*wallet.Wallet{&consistent, &drifting} {
+		*walletData = wallet.Wallet{Id: uuid.NewString(), OwnedBy: uuid.NewString(), Name: wallet.WALLET_MAIN_POCKET, Status: wallet.WALLET_STATUS_ENABLED}
+		if err := walletRepository.InsertWallet(ctx, *walletData); err != nil {
+			t.Fatalf("inserting a wallet: %v", err)
+		}
+	}
+	deposit(consistent, 100)
+	// the balance written is not what the deposit adds up to
+	deposit(drifting, 150)
+
+	result, err := usecase.Reconcile(ctx, reconciliation.ReconciliationOptions{Trigger: reconciliation.RECONCILIATION_TRIGGER_COMMAND, FreezeWallets: true})
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+
+	report := result.Data
+	if report.Run.Status != reconciliation.RECONCILIATION_STATUS_COMPLETED || report.Run.WalletsChecked != 2 || report.Run.FrozenWallets != 1 {
+		t.Errorf("run = %+v, want completed with 2 wallets checked and 1 frozen", report.Run)
+	}
+	if len(report.Mismatches) != 1 || report.Mismatches[0].WalletId != drifting.Id || report.Mismatches[0].Drift != 50 || report.Mismatches[0].TransactionCount != 1 {
+		t.Errorf("mismatches = %+v, want the drift of 50 of %s", report.Mismatches, drifting.Id)
+	}
+
+	for walletId, wantStatus := range map[string]string{consistent.Id: wallet.WALLET_STATUS_ENABLED, drifting.Id: wallet.WALLET_STATUS_FROZEN} {
+		if walletData, err := walletRepository.GetWalletById(ctx, walletId); err != nil || walletData.Status != wantStatus {
+			t.Errorf("wallet %s = %+v %v, want %s", walletId, walletData, err, wantStatus)
+		}
+	}
+
+	stored, err := usecase.GetReconciliationReport(ctx, report.Run.Id)
+	if err != nil || stored.Data.Run.Status != reconciliation.RECONCILIATION_STATUS_COMPLETED || len(stored.Data.Mismatches) != 1 {
+		t.Errorf("GetReconciliationReport = %+v %v, want the completed run and its mismatch", stored, err)
+	}
+}
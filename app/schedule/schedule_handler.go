@@ -0,0 +1,147 @@
+package schedule
+
+import (
+	"context"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/request"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/member"
+	"mini-wallet/domain/schedule"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type scheduleHandler struct {
+	scheduleUsecase schedule.ScheduleUsecase
+}
+
+func SetScheduleHandler(router *chi.Mux, usecases domain.Usecases) {
+	scheduleHandler := scheduleHandler{
+		scheduleUsecase: usecases.ScheduleUsecase,
+	}
+
+	router.Route("/api/v1/wallet/schedules", func(r chi.Router) {
+		r.Use(usecases.AuthUsecase.AuthorizeRequestMiddleware)
+		r.Use(usecases.AuthUsecase.SelectWalletMiddleware)
+		// a scheduled withdrawal would bypass the limits of a spender
+		r.Use(usecases.AuthUsecase.RequireRoleMiddleware(member.MEMBER_ROLE_OWNER))
+
+		// GET
+		r.Get("/", scheduleHandler.GetSchedules)
+		r.Get("/{scheduleId}/executions", scheduleHandler.GetScheduleExecutions)
+
+		// POST
+		r.Post("/", scheduleHandler.CreateSchedule)
+		r.Post("/{scheduleId}/pause", scheduleHandler.PauseSchedule)
+		r.Post("/{scheduleId}/resume", scheduleHandler.ResumeSchedule)
+
+		// DELETE
+		r.Delete("/{scheduleId}", scheduleHandler.CancelSchedule)
+	})
+}
+
+// CreateSchedule reads type, amount, rule_type, rule, timezone, start_at, end_at (RFC 3339) and max_count
+func (handler *scheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+	req := schedule.ScheduleRequest{}
+
+	err := request.Decode(w, r, &req)
+	if err == nil {
+		req.WalletId = walletId.(string)
+		err = req.Validate()
+	}
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	result, err := handler.scheduleUsecase.CreateSchedule(r.Context(), req)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[schedule.Schedule]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.StatusCode = http.StatusCreated
+	resp.WriteResponse(w)
+}
+
+func (handler *scheduleHandler) GetSchedules(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+
+	result, err := handler.scheduleUsecase.GetSchedules(r.Context(), walletId.(string))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[[]schedule.Schedule]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *scheduleHandler) GetScheduleExecutions(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+
+	result, err := handler.scheduleUsecase.GetScheduleExecutions(r.Context(), walletId.(string), chi.URLParam(r, "scheduleId"))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[[]schedule.ScheduleExecution]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *scheduleHandler) PauseSchedule(w http.ResponseWriter, r *http.Request) {
+	handler.changeSchedule(w, r, handler.scheduleUsecase.PauseSchedule)
+}
+
+func (handler *scheduleHandler) ResumeSchedule(w http.ResponseWriter, r *http.Request) {
+	handler.changeSchedule(w, r, handler.scheduleUsecase.ResumeSchedule)
+}
+
+func (handler *scheduleHandler) CancelSchedule(w http.ResponseWriter, r *http.Request) {
+	handler.changeSchedule(w, r, handler.scheduleUsecase.CancelSchedule)
+}
+
+func (handler *scheduleHandler) changeSchedule(w http.ResponseWriter, r *http.Request, change func(ctx context.Context, walletId string, scheduleId string) (*response.Response[schedule.Schedule], error)) {
+	walletId := r.Context().Value("walletId")
+
+	result, err := change(r.Context(), walletId.(string), chi.URLParam(r, "scheduleId"))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[schedule.Schedule]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
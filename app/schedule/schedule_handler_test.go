@@ -0,0 +1,49 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"mini-wallet/domain/common/response"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCreateScheduleRefusesBadFields(t *testing.T) {
+	// refused before the usecase is reached
+	handler := &scheduleHandler{}
+
+	for name, testCase := range map[string]struct {
+		contentType string
+		body        string
+		want        []response.FieldError
+	}{
+		"amount not a number": {"application/x-www-form-urlencoded", "type=deposit&amount=ten&rule_type=cron&rule=0+9+1+*+*",
+			[]response.FieldError{{Field: "amount", Message: "must be an integer"}}},
+		"start_at not a time": {"application/json", `{"type": "deposit", "amount": 100, "rule_type": "cron", "rule": "0 9 1 * *", "start_at": "tomorrow"}`,
+			[]response.FieldError{{Field: "start_at", Message: "must be an RFC 3339 time"}}},
+		"ends before it starts": {"application/json", `{"type": "deposit", "amount": 100, "rule_type": "cron", "rule": "0 9 1 * *", "start_at": "2026-11-01T00:00:00Z", "end_at": "2026-10-01T00:00:00Z"}`,
+			[]response.FieldError{{Field: "end_at", Message: "must be after start_at"}}},
+		"max_count not positive": {"application/x-www-form-urlencoded", "type=withdrawal&amount=100&rule_type=rrule&rule=FREQ%3DDAILY&max_count=0",
+			[]response.FieldError{{Field: "max_count", Message: "must be positive"}}},
+		"amount missing": {"application/json", `{"type": "deposit", "rule_type": "cron", "rule": "0 9 1 * *"}`,
+			[]response.FieldError{{Field: "amount", Message: "is required"}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(testCase.body))
+			r.Header.Set("Content-Type", testCase.contentType)
+			w := httptest.NewRecorder()
+			handler.CreateSchedule(w, r.WithContext(context.WithValue(r.Context(), "walletId", "w-1")))
+
+			errResp := response.Response[response.Error]{}
+			if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil || errResp.Data == nil {
+				t.Fatalf("body = %s %v, want an error", w.Body.String(), err)
+			}
+			if !reflect.DeepEqual(errResp.Data.Fields, testCase.want) {
+				t.Errorf("fields = %+v, want %+v", errResp.Data.Fields, testCase.want)
+			}
+		})
+	}
+}
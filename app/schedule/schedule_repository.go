@@ -0,0 +1,113 @@
+package schedule
+
+import (
+	"context"
+	"mini-wallet/domain/schedule"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type scheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduleRepository(db *gorm.DB) schedule.ScheduleRepository {
+	return &scheduleRepository{
+		db: db,
+	}
+}
+
+func (scheduleRepository *scheduleRepository) InsertSchedule(ctx context.Context, scheduleData schedule.Schedule) (err error) {
+	return scheduleRepository.db.WithContext(ctx).Table("ms_schedule").Create(&scheduleData).Error
+}
+
+func (scheduleRepository *scheduleRepository) GetScheduleById(ctx context.Context, scheduleId string) (res *schedule.Schedule, err error) {
+	qry, args, err := sq.Select("*").From("ms_schedule").Where(sq.Eq{"id": scheduleId}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	result := scheduleRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+func (scheduleRepository *scheduleRepository) GetSchedulesByWalletId(ctx context.Context, walletId string) (res []schedule.Schedule, err error) {
+	qry, args, err := sq.Select("*").From("ms_schedule").Where(sq.Eq{"wallet_id": walletId}).OrderBy("created_at").ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = scheduleRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (scheduleRepository *scheduleRepository) GetDueSchedules(ctx context.Context, now time.Time, limit int) (res []schedule.Schedule, err error) {
+	qry, args, err := sq.Select("*").From("ms_schedule").
+		Where(sq.Eq{"status": schedule.SCHEDULE_STATUS_ACTIVE}).
+		Where(sq.LtOrEq{"next_run_at": now}).
+		OrderBy("next_run_at").
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = scheduleRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (scheduleRepository *scheduleRepository) UpdateSchedule(ctx context.Context, scheduleData schedule.Schedule) (updated bool, err error) {
+	result := scheduleRepository.db.WithContext(ctx).Table("ms_schedule").
+		Where("id = ? AND version = ?", scheduleData.Id, scheduleData.Version).
+		Updates(map[string]interface{}{
+			"status":          scheduleData.Status,
+			"paused_reason":   scheduleData.PausedReason,
+			"execution_count": scheduleData.ExecutionCount,
+			"next_run_at":     scheduleData.NextRunAt,
+			"version":         gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (scheduleRepository *scheduleRepository) InsertScheduleExecution(ctx context.Context, execution schedule.ScheduleExecution) (err error) {
+	return scheduleRepository.db.WithContext(ctx).Table("tr_schedule_execution").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&execution).Error
+}
+
+func (scheduleRepository *scheduleRepository) GetScheduleExecutions(ctx context.Context, scheduleId string) (res []schedule.ScheduleExecution, err error) {
+	qry, args, err := sq.Select("*").From("tr_schedule_execution").Where(sq.Eq{"schedule_id": scheduleId}).OrderBy("scheduled_at DESC").ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = scheduleRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,179 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/schedule"
+	"mini-wallet/domain/wallet"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryScheduleRepository struct {
+	walletRepository wallet.WalletRepository
+
+	mu         sync.RWMutex
+	schedules  map[string]schedule.Schedule
+	executions map[string][]schedule.ScheduleExecution // by schedule id
+}
+
+// NewMemoryScheduleRepository keeps the schedules and their executions in this process, for tests and the memory storage.
+// the wallets are read from walletRepository, like the postgres one references them
+func NewMemoryScheduleRepository(walletRepository wallet.WalletRepository) schedule.ScheduleRepository {
+	return &memoryScheduleRepository{
+		walletRepository: walletRepository,
+		schedules:        map[string]schedule.Schedule{},
+		executions:       map[string][]schedule.ScheduleExecution{},
+	}
+}
+
+func (scheduleRepository *memoryScheduleRepository) InsertSchedule(ctx context.Context, scheduleData schedule.Schedule) (err error) {
+	// the foreign key on ms_wallet
+	walletData, err := scheduleRepository.walletRepository.GetWalletById(ctx, scheduleData.WalletId)
+	if err != nil {
+		return err
+	}
+	if walletData == nil {
+		return response.ErrWalletNotFound
+	}
+	if err = checkSchedule(scheduleData); err != nil {
+		return err
+	}
+
+	scheduleRepository.mu.Lock()
+	defer scheduleRepository.mu.Unlock()
+
+	if _, found := scheduleRepository.schedules[scheduleData.Id]; found {
+		return fmt.Errorf("schedule %s already exists", scheduleData.Id)
+	}
+	scheduleRepository.schedules[scheduleData.Id] = scheduleData
+
+	return nil
+}
+
+func (scheduleRepository *memoryScheduleRepository) GetScheduleById(ctx context.Context, scheduleId string) (res *schedule.Schedule, err error) {
+	scheduleRepository.mu.RLock()
+	defer scheduleRepository.mu.RUnlock()
+
+	scheduleData, found := scheduleRepository.schedules[scheduleId]
+	if !found {
+		return nil, nil
+	}
+
+	return &scheduleData, nil
+}
+
+func (scheduleRepository *memoryScheduleRepository) GetSchedulesByWalletId(ctx context.Context, walletId string) (res []schedule.Schedule, err error) {
+	scheduleRepository.mu.RLock()
+	defer scheduleRepository.mu.RUnlock()
+
+	for _, scheduleData := range scheduleRepository.schedules {
+		if scheduleData.WalletId == walletId {
+			res = append(res, scheduleData)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].CreatedAt != res[j].CreatedAt {
+			return res[i].CreatedAt < res[j].CreatedAt
+		}
+		return res[i].Id < res[j].Id
+	})
+
+	return res, nil
+}
+
+func (scheduleRepository *memoryScheduleRepository) GetDueSchedules(ctx context.Context, now time.Time, limit int) (res []schedule.Schedule, err error) {
+	scheduleRepository.mu.RLock()
+	defer scheduleRepository.mu.RUnlock()
+
+	for _, scheduleData := range scheduleRepository.schedules {
+		if scheduleData.Status == schedule.SCHEDULE_STATUS_ACTIVE && scheduleData.NextRunAt != nil && !scheduleData.NextRunAt.After(now) {
+			res = append(res, scheduleData)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].NextRunAt.Equal(*res[j].NextRunAt) {
+			return res[i].NextRunAt.Before(*res[j].NextRunAt)
+		}
+		return res[i].Id < res[j].Id
+	})
+
+	return res[:min(limit, len(res))], nil
+}
+
+func (scheduleRepository *memoryScheduleRepository) UpdateSchedule(ctx context.Context, scheduleData schedule.Schedule) (updated bool, err error) {
+	scheduleRepository.mu.Lock()
+	defer scheduleRepository.mu.Unlock()
+
+	storedSchedule, found := scheduleRepository.schedules[scheduleData.Id]
+	if !found || storedSchedule.Version != scheduleData.Version {
+		return false, nil
+	}
+
+	storedSchedule.Status = scheduleData.Status
+	storedSchedule.PausedReason = scheduleData.PausedReason
+	storedSchedule.ExecutionCount = scheduleData.ExecutionCount
+	storedSchedule.NextRunAt = scheduleData.NextRunAt
+	storedSchedule.Version++
+	if err = checkSchedule(storedSchedule); err != nil {
+		return false, err
+	}
+	scheduleRepository.schedules[scheduleData.Id] = storedSchedule
+
+	return true, nil
+}
+
+func (scheduleRepository *memoryScheduleRepository) InsertScheduleExecution(ctx context.Context, execution schedule.ScheduleExecution) (err error) {
+	switch execution.Status {
+	case schedule.SCHEDULE_EXECUTION_STATUS_SUCCEEDED, schedule.SCHEDULE_EXECUTION_STATUS_FAILED, schedule.SCHEDULE_EXECUTION_STATUS_SKIPPED:
+	default:
+		return response.ErrBadRequest
+	}
+
+	scheduleRepository.mu.Lock()
+	defer scheduleRepository.mu.Unlock()
+
+	if _, found := scheduleRepository.schedules[execution.ScheduleId]; !found {
+		return fmt.Errorf("schedule %s does not exist", execution.ScheduleId)
+	}
+	for _, storedExecution := range scheduleRepository.executions[execution.ScheduleId] {
+		if storedExecution.ScheduledAt.Equal(execution.ScheduledAt) {
+			return nil
+		}
+	}
+	scheduleRepository.executions[execution.ScheduleId] = append(scheduleRepository.executions[execution.ScheduleId], execution)
+
+	return nil
+}
+
+func (scheduleRepository *memoryScheduleRepository) GetScheduleExecutions(ctx context.Context, scheduleId string) (res []schedule.ScheduleExecution, err error) {
+	scheduleRepository.mu.RLock()
+	defer scheduleRepository.mu.RUnlock()
+
+	res = append(res, scheduleRepository.executions[scheduleId]...)
+	sort.Slice(res, func(i, j int) bool { return res[i].ScheduledAt.After(res[j].ScheduledAt) })
+
+	return res, nil
+}
+
+// checkSchedule mirrors the check constraints of ms_schedule
+func checkSchedule(scheduleData schedule.Schedule) error {
+	if scheduleData.Type != wallet.WALLET_TRANSACTION_DEPOSIT && scheduleData.Type != wallet.WALLET_TRANSACTION_WITHDRAWAL {
+		return response.ErrBadRequest
+	}
+	if scheduleData.Amount <= 0 || (scheduleData.MaxCount != nil && *scheduleData.MaxCount <= 0) {
+		return response.ErrBadRequest
+	}
+	if scheduleData.RuleType != schedule.SCHEDULE_RULE_CRON && scheduleData.RuleType != schedule.SCHEDULE_RULE_RRULE {
+		return response.ErrBadRequest
+	}
+
+	switch scheduleData.Status {
+	case schedule.SCHEDULE_STATUS_ACTIVE, schedule.SCHEDULE_STATUS_PAUSED, schedule.SCHEDULE_STATUS_FINISHED, schedule.SCHEDULE_STATUS_CANCELLED:
+		return nil
+	}
+
+	return response.ErrBadRequest
+}
@@ -0,0 +1,89 @@
+package schedule
+
+import (
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/schedule"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/teambition/rrule-go"
+)
+
+// occurrenceRule yields the occurrences of a schedule in order
+type occurrenceRule interface {
+	// after returns the first occurrence strictly after t, false when there is none
+	after(t time.Time) (occurrence time.Time, ok bool)
+}
+
+func newOccurrenceRule(scheduleData schedule.Schedule) (rule occurrenceRule, err error) {
+	location, err := time.LoadLocation(scheduleData.Timezone)
+	if err != nil {
+		return nil, response.ErrBadRequest
+	}
+
+	switch scheduleData.RuleType {
+	case schedule.SCHEDULE_RULE_CRON:
+		cronSchedule, err := cron.ParseStandard(scheduleData.Rule)
+		if err != nil {
+			return nil, response.ErrBadRequest
+		}
+		return &cronRule{schedule: cronSchedule, location: location}, nil
+	case schedule.SCHEDULE_RULE_RRULE:
+		option, err := rrule.StrToROption(scheduleData.Rule)
+		if err != nil {
+			return nil, response.ErrBadRequest
+		}
+		option.Dtstart = scheduleData.StartAt.In(location)
+		recurrence, err := rrule.NewRRule(*option)
+		if err != nil {
+			return nil, response.ErrBadRequest
+		}
+		return &recurrenceRule{recurrence: recurrence}, nil
+	}
+
+	return nil, response.ErrBadRequest
+}
+
+// nextRunAt is the first occurrence after t that is still within end_at and max_count, nil when the schedule is done
+func nextRunAt(scheduleData schedule.Schedule, t time.Time) (res *time.Time, err error) {
+	if scheduleData.MaxCount != nil && scheduleData.ExecutionCount >= *scheduleData.MaxCount {
+		return nil, nil
+	}
+
+	rule, err := newOccurrenceRule(scheduleData)
+	if err != nil {
+		return nil, err
+	}
+
+	// nothing before the start
+	if t.Before(scheduleData.StartAt) {
+		t = scheduleData.StartAt.Add(-time.Nanosecond)
+	}
+
+	occurrence, ok := rule.after(t)
+	if !ok || (scheduleData.EndAt != nil && occurrence.After(*scheduleData.EndAt)) {
+		return nil, nil
+	}
+
+	occurrence = occurrence.UTC()
+	return &occurrence, nil
+}
+
+type cronRule struct {
+	schedule cron.Schedule
+	location *time.Location
+}
+
+func (rule *cronRule) after(t time.Time) (occurrence time.Time, ok bool) {
+	occurrence = rule.schedule.Next(t.In(rule.location))
+	return occurrence, !occurrence.IsZero()
+}
+
+type recurrenceRule struct {
+	recurrence *rrule.RRule
+}
+
+func (rule *recurrenceRule) after(t time.Time) (occurrence time.Time, ok bool) {
+	occurrence = rule.recurrence.After(t, false)
+	return occurrence, !occurrence.IsZero()
+}
@@ -0,0 +1,119 @@
+package schedule
+
+import (
+	"mini-wallet/domain/schedule"
+	"testing"
+	"time"
+)
+
+func TestNextRunAtCron(t *testing.T) {
+	startAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	scheduleData := schedule.Schedule{
+		RuleType: schedule.SCHEDULE_RULE_CRON,
+		Rule:     "0 9 1 * *",
+		Timezone: "Asia/Jakarta",
+		StartAt:  startAt,
+	}
+
+	next, err := nextRunAt(scheduleData, startAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 09:00 in Jakarta is 02:00 UTC
+	if want := time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC); next == nil || !next.Equal(want) {
+		t.Fatalf("next = %v, want %v", next, want)
+	}
+
+	next, err = nextRunAt(scheduleData, *next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 2, 1, 2, 0, 0, 0, time.UTC); next == nil || !next.Equal(want) {
+		t.Fatalf("next = %v, want %v", next, want)
+	}
+}
+
+func TestNextRunAtRrule(t *testing.T) {
+	startAt := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	scheduleData := schedule.Schedule{
+		RuleType: schedule.SCHEDULE_RULE_RRULE,
+		Rule:     "FREQ=WEEKLY;BYDAY=MO",
+		Timezone: "UTC",
+		StartAt:  startAt,
+	}
+
+	// the start itself is the first occurrence
+	next, err := nextRunAt(scheduleData, startAt.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == nil || !next.Equal(startAt) {
+		t.Fatalf("next = %v, want %v", next, startAt)
+	}
+
+	next, err = nextRunAt(scheduleData, startAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := startAt.AddDate(0, 0, 7); next == nil || !next.Equal(want) {
+		t.Fatalf("next = %v, want %v", next, want)
+	}
+}
+
+func TestNextRunAtStopsAtEndAndMaxCount(t *testing.T) {
+	startAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	endAt := startAt.Add(time.Hour * 35)
+	maxCount := 2
+	scheduleData := schedule.Schedule{
+		RuleType: schedule.SCHEDULE_RULE_CRON,
+		Rule:     "0 12 * * *",
+		Timezone: "UTC",
+		StartAt:  startAt,
+		EndAt:    &endAt,
+	}
+
+	next, err := nextRunAt(scheduleData, startAt.Add(time.Hour*13))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != nil {
+		t.Fatalf("next = %v after end_at, want none", next)
+	}
+
+	scheduleData.EndAt = nil
+	scheduleData.MaxCount = &maxCount
+	scheduleData.ExecutionCount = 2
+	next, err = nextRunAt(scheduleData, startAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != nil {
+		t.Fatalf("next = %v after max_count, want none", next)
+	}
+}
+
+func TestNextRunAtRejectsInvalidRule(t *testing.T) {
+	for _, scheduleData := range []schedule.Schedule{
+		{RuleType: schedule.SCHEDULE_RULE_CRON, Rule: "every day", Timezone: "UTC"},
+		{RuleType: schedule.SCHEDULE_RULE_RRULE, Rule: "FREQ=SOMETIMES", Timezone: "UTC"},
+		{RuleType: schedule.SCHEDULE_RULE_CRON, Rule: "0 9 * * *", Timezone: "Mars/Olympus"},
+	} {
+		if _, err := nextRunAt(scheduleData, time.Now()); err == nil {
+			t.Errorf("%s %q in %s: want an error", scheduleData.RuleType, scheduleData.Rule, scheduleData.Timezone)
+		}
+	}
+}
+
+func TestOccurrenceReferenceIdIsDeterministic(t *testing.T) {
+	occurrence := time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC)
+
+	if occurrenceReferenceId("a", occurrence) != occurrenceReferenceId("a", occurrence.In(time.FixedZone("WIB", 7*3600))) {
+		t.Error("same occurrence in another zone got another reference id")
+	}
+	if occurrenceReferenceId("a", occurrence) == occurrenceReferenceId("a", occurrence.Add(time.Minute)) {
+		t.Error("different occurrences share a reference id")
+	}
+	if occurrenceReferenceId("a", occurrence) == occurrenceReferenceId("b", occurrence) {
+		t.Error("different schedules share a reference id")
+	}
+}
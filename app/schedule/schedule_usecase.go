@@ -0,0 +1,394 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/schedule"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	dueSchedulesPage = 100
+)
+
+var (
+	// reference ids of scheduled transactions are derived from it, see occurrenceReferenceId
+	scheduleReferenceNamespace = uuid.MustParse("6f1c2c2e-2f5b-4d0a-9b8e-3f7d6c1a9e42")
+
+	// an occurrence failing with one of these is recorded as failed and not retried,
+	// anything else (a lock, a database hiccup) is retried on the next tick
+	finalExecutionErrors = map[*response.DomainError]struct{}{
+		response.ErrInsufficientFund:    {},
+		response.ErrWalletNotFound:      {},
+		response.ErrBadRequest:          {},
+		response.ErrReferenceIdConflict: {},
+	}
+)
+
+type scheduleUsecase struct {
+	scheduleRepository schedule.ScheduleRepository
+	walletRepository   wallet.WalletRepository
+	walletUsecase      wallet.WalletUsecase
+	config             infrastructure.Config
+}
+
+// NewScheduleUsecase fires occurrences through walletUsecase, so they get the same checks,
+// locking and metrics as a deposit or withdrawal made by the customer
+func NewScheduleUsecase(repositories domain.Repositories, walletUsecase wallet.WalletUsecase, config infrastructure.Config) schedule.ScheduleUsecase {
+	return &scheduleUsecase{
+		scheduleRepository: repositories.ScheduleRepository,
+		walletRepository:   repositories.WalletRepository,
+		walletUsecase:      walletUsecase,
+		config:             config,
+	}
+}
+
+func (usecase *scheduleUsecase) CreateSchedule(ctx context.Context, req schedule.ScheduleRequest) (res *response.Response[schedule.Schedule], err error) {
+	if err = req.Validate(); err != nil {
+		return nil, err
+	}
+
+	if limits := infrastructure.GetLimits(); limits.MAX_TRANSACTION_AMOUNT > 0 && req.Amount > limits.MAX_TRANSACTION_AMOUNT {
+		return nil, response.ErrBadRequest
+	}
+
+	walletResult, err := usecase.walletRepository.GetWalletById(ctx, req.WalletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - CreateSchedule", err)
+		return nil, err
+	}
+
+	if walletResult == nil {
+		return nil, response.ErrWalletNotFound
+	}
+
+	if err = walletResult.ValidateWalletStatus(); err != nil {
+		return nil, err
+	}
+
+	scheduleId, err := uuid.NewV7()
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on uuid.NewV7() - CreateSchedule", err)
+		return nil, err
+	}
+
+	scheduleData := schedule.Schedule{
+		Id:        scheduleId.String(),
+		WalletId:  walletResult.Id,
+		Type:      req.Type,
+		Amount:    req.Amount,
+		RuleType:  req.RuleType,
+		Rule:      req.Rule,
+		Timezone:  req.Timezone,
+		StartAt:   time.Now().UTC().Truncate(time.Second),
+		EndAt:     req.EndAt,
+		MaxCount:  req.MaxCount,
+		Status:    schedule.SCHEDULE_STATUS_ACTIVE,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	if scheduleData.Timezone == "" {
+		scheduleData.Timezone = "UTC"
+	}
+	if req.StartAt != nil {
+		scheduleData.StartAt = req.StartAt.UTC().Truncate(time.Second)
+	}
+
+	scheduleData.NextRunAt, err = nextRunAt(scheduleData, scheduleData.StartAt.Add(-time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+
+	// a rule which never fires is most likely a mistake
+	if scheduleData.NextRunAt == nil {
+		return nil, response.ErrBadRequest
+	}
+
+	if err = usecase.scheduleRepository.InsertSchedule(ctx, scheduleData); err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.scheduleRepository.InsertSchedule() - CreateSchedule", err)
+		return nil, err
+	}
+
+	return &response.Response[schedule.Schedule]{
+		Data: &scheduleData,
+	}, nil
+}
+
+func (usecase *scheduleUsecase) GetSchedules(ctx context.Context, walletId string) (res *response.Response[[]schedule.Schedule], err error) {
+	schedules, err := usecase.scheduleRepository.GetSchedulesByWalletId(ctx, walletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.scheduleRepository.GetSchedulesByWalletId() - GetSchedules", err)
+		return nil, err
+	}
+
+	if schedules == nil {
+		schedules = []schedule.Schedule{}
+	}
+
+	return &response.Response[[]schedule.Schedule]{
+		Data: &schedules,
+	}, nil
+}
+
+func (usecase *scheduleUsecase) GetScheduleExecutions(ctx context.Context, walletId string, scheduleId string) (res *response.Response[[]schedule.ScheduleExecution], err error) {
+	if _, err = usecase.getWalletSchedule(ctx, walletId, scheduleId); err != nil {
+		return nil, err
+	}
+
+	executions, err := usecase.scheduleRepository.GetScheduleExecutions(ctx, scheduleId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.scheduleRepository.GetScheduleExecutions() - GetScheduleExecutions", err)
+		return nil, err
+	}
+
+	if executions == nil {
+		executions = []schedule.ScheduleExecution{}
+	}
+
+	return &response.Response[[]schedule.ScheduleExecution]{
+		Data: &executions,
+	}, nil
+}
+
+func (usecase *scheduleUsecase) PauseSchedule(ctx context.Context, walletId string, scheduleId string) (res *response.Response[schedule.Schedule], err error) {
+	scheduleData, err := usecase.getWalletSchedule(ctx, walletId, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheduleData.Status != schedule.SCHEDULE_STATUS_ACTIVE {
+		return nil, response.ErrScheduleStatus
+	}
+
+	scheduleData.Status = schedule.SCHEDULE_STATUS_PAUSED
+	scheduleData.PausedReason = nil
+
+	return usecase.updateSchedule(ctx, scheduleData)
+}
+
+func (usecase *scheduleUsecase) ResumeSchedule(ctx context.Context, walletId string, scheduleId string) (res *response.Response[schedule.Schedule], err error) {
+	scheduleData, err := usecase.getWalletSchedule(ctx, walletId, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheduleData.Status != schedule.SCHEDULE_STATUS_PAUSED {
+		return nil, response.ErrScheduleStatus
+	}
+
+	// resuming on a disabled wallet would only pause it again on the next occurrence
+	walletResult, err := usecase.walletRepository.GetWalletById(ctx, walletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - ResumeSchedule", err)
+		return nil, err
+	}
+	if walletResult == nil {
+		return nil, response.ErrWalletNotFound
+	}
+	if err = walletResult.ValidateWalletStatus(); err != nil {
+		return nil, err
+	}
+
+	scheduleData.NextRunAt, err = nextRunAt(*scheduleData, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	scheduleData.Status = schedule.SCHEDULE_STATUS_ACTIVE
+	scheduleData.PausedReason = nil
+	if scheduleData.NextRunAt == nil {
+		scheduleData.Status = schedule.SCHEDULE_STATUS_FINISHED
+	}
+
+	return usecase.updateSchedule(ctx, scheduleData)
+}
+
+func (usecase *scheduleUsecase) CancelSchedule(ctx context.Context, walletId string, scheduleId string) (res *response.Response[schedule.Schedule], err error) {
+	scheduleData, err := usecase.getWalletSchedule(ctx, walletId, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheduleData.Status != schedule.SCHEDULE_STATUS_ACTIVE && scheduleData.Status != schedule.SCHEDULE_STATUS_PAUSED {
+		return nil, response.ErrScheduleStatus
+	}
+
+	scheduleData.Status = schedule.SCHEDULE_STATUS_CANCELLED
+	scheduleData.NextRunAt = nil
+
+	return usecase.updateSchedule(ctx, scheduleData)
+}
+
+func (usecase *scheduleUsecase) RunScheduler(ctx context.Context) {
+	ticker := time.NewTicker(usecase.config.SCHEDULER_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := usecase.RunDueSchedules(ctx, now); err != nil {
+				infrastructure.LogError(ctx, "got error on usecase.RunDueSchedules() - RunScheduler", err)
+			}
+		}
+	}
+}
+
+// RunDueSchedules fires the due occurrences one by one, an occurrence missed while the service
+// was down is caught up on the following ticks
+func (usecase *scheduleUsecase) RunDueSchedules(ctx context.Context, now time.Time) (err error) {
+	for {
+		dueSchedules, err := usecase.scheduleRepository.GetDueSchedules(ctx, now, dueSchedulesPage)
+		if err != nil {
+			infrastructure.LogError(ctx, "got error on usecase.scheduleRepository.GetDueSchedules() - RunDueSchedules", err)
+			return err
+		}
+
+		fired := 0
+		for _, scheduleData := range dueSchedules {
+			if usecase.runSchedule(ctx, scheduleData, now) {
+				fired++
+			}
+		}
+
+		// a short page, or nothing could be fired and the same page would come back
+		if len(dueSchedules) < dueSchedulesPage || fired == 0 {
+			return nil
+		}
+	}
+}
+
+// runSchedule fires the occurrence at schedule.NextRunAt and advances the schedule, false when it is left for a later tick.
+// the reference id is derived from the occurrence, so firing it again never applies it twice
+func (usecase *scheduleUsecase) runSchedule(ctx context.Context, scheduleData schedule.Schedule, now time.Time) (advanced bool) {
+	var err error
+	ctx, span := infrastructure.StartSpan(ctx, "scheduleUsecase.runSchedule",
+		attribute.String("schedule.id", scheduleData.Id),
+		attribute.String("wallet.id", scheduleData.WalletId),
+	)
+	defer infrastructure.EndSpan(span, &err)
+	ctx = context.WithValue(ctx, "walletId", scheduleData.WalletId)
+
+	occurrence := *scheduleData.NextRunAt
+	execution := schedule.ScheduleExecution{
+		ScheduleId:  scheduleData.Id,
+		ScheduledAt: occurrence,
+		ReferenceId: occurrenceReferenceId(scheduleData.Id, occurrence),
+		Status:      schedule.SCHEDULE_EXECUTION_STATUS_SUCCEEDED,
+		ExecutedAt:  now.Format(time.RFC3339),
+	}
+
+	_, err = usecase.walletUsecase.CreateWalletTransaction(ctx, wallet.WalletTransactionRequest{
+		WalletId:    scheduleData.WalletId,
+		Type:        scheduleData.Type,
+		Amount:      scheduleData.Amount,
+		ReferenceId: execution.ReferenceId,
+		Timestamp:   int(now.Unix()),
+	})
+	if err == nil || errors.Is(err, response.ErrReferenceIdConflict) {
+		// a conflict is the very same occurrence fired before, e.g. by a run which stopped right after
+		walletTransaction, lookupErr := usecase.walletRepository.GetWalletTransactionByReferenceId(ctx, scheduleData.WalletId, execution.ReferenceId)
+		if lookupErr != nil {
+			err = lookupErr
+			infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletTransactionByReferenceId() - runSchedule", err)
+			return false
+		}
+		if walletTransaction != nil {
+			execution.TransactionId = &walletTransaction.Id
+			err = nil
+		}
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, response.ErrWalletDisabled):
+		reason := response.ERROR_WALLET_DISABLED
+		execution.Status = schedule.SCHEDULE_EXECUTION_STATUS_SKIPPED
+		execution.Error = &reason
+		scheduleData.Status = schedule.SCHEDULE_STATUS_PAUSED
+		scheduleData.PausedReason = &reason
+	default:
+		if _, final := finalExecutionErrors[response.DomainErrorOf(err)]; !final {
+			infrastructure.LogError(ctx, "got error on usecase.walletUsecase.CreateWalletTransaction() - runSchedule, retried on the next tick", err)
+			return false
+		}
+		msg := err.Error()
+		execution.Status = schedule.SCHEDULE_EXECUTION_STATUS_FAILED
+		execution.Error = &msg
+	}
+	infrastructure.ScheduleExecutionsTotal.WithLabelValues(execution.Status).Inc()
+
+	if err = usecase.scheduleRepository.InsertScheduleExecution(ctx, execution); err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.scheduleRepository.InsertScheduleExecution() - runSchedule", err)
+		return false
+	}
+
+	if execution.Status != schedule.SCHEDULE_EXECUTION_STATUS_SKIPPED {
+		scheduleData.ExecutionCount++
+		scheduleData.NextRunAt, err = nextRunAt(scheduleData, occurrence)
+		if err != nil {
+			infrastructure.LogError(ctx, "got error on nextRunAt() - runSchedule", err)
+			return false
+		}
+		if scheduleData.NextRunAt == nil {
+			scheduleData.Status = schedule.SCHEDULE_STATUS_FINISHED
+		}
+	}
+
+	// losing means another instance advanced it, or the customer paused or cancelled it meanwhile
+	updated, err := usecase.scheduleRepository.UpdateSchedule(ctx, scheduleData)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.scheduleRepository.UpdateSchedule() - runSchedule", err)
+		return false
+	}
+
+	infrastructure.LogInfo(ctx, "schedule fired", "schedule_id", scheduleData.Id, "scheduled_at", occurrence,
+		"execution_status", execution.Status, "schedule_status", scheduleData.Status, "updated", updated)
+
+	return updated
+}
+
+func (usecase *scheduleUsecase) getWalletSchedule(ctx context.Context, walletId string, scheduleId string) (res *schedule.Schedule, err error) {
+	scheduleData, err := usecase.scheduleRepository.GetScheduleById(ctx, scheduleId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.scheduleRepository.GetScheduleById() - getWalletSchedule", err)
+		return nil, err
+	}
+
+	// someone else's schedule is not found either
+	if scheduleData == nil || scheduleData.WalletId != walletId {
+		return nil, response.ErrScheduleNotFound
+	}
+
+	return scheduleData, nil
+}
+
+func (usecase *scheduleUsecase) updateSchedule(ctx context.Context, scheduleData *schedule.Schedule) (res *response.Response[schedule.Schedule], err error) {
+	updated, err := usecase.scheduleRepository.UpdateSchedule(ctx, *scheduleData)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.scheduleRepository.UpdateSchedule() - updateSchedule", err)
+		return nil, err
+	}
+
+	// the scheduler advanced it meanwhile
+	if !updated {
+		return nil, response.ErrWalletConcurrent
+	}
+	scheduleData.Version++
+
+	return &response.Response[schedule.Schedule]{
+		Data: scheduleData,
+	}, nil
+}
+
+// occurrenceReferenceId is the same for every attempt of an occurrence, and fits the 36 characters of a reference id
+func occurrenceReferenceId(scheduleId string, occurrence time.Time) string {
+	return uuid.NewSHA1(scheduleReferenceNamespace, []byte(scheduleId+"/"+occurrence.UTC().Format(time.RFC3339))).String()
+}
@@ -0,0 +1,202 @@
+package settlement
+
+import (
+	"errors"
+	"io"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/request"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/settlement"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	settlementBodyLimit = 32 << 20 // 32MB
+)
+
+type settlementHandler struct {
+	settlementUsecase settlement.SettlementUsecase
+}
+
+func SetSettlementHandler(router *chi.Mux, usecases domain.Usecases) {
+	settlementHandler := settlementHandler{
+		settlementUsecase: usecases.SettlementUsecase,
+	}
+
+	// the daily files of the bank, uploaded and looked into by finance holding the admin token
+	router.Route("/api/v1/settlements", func(r chi.Router) {
+		r.Use(usecases.AuthUsecase.AuthorizeAdminMiddleware)
+
+		// GET
+		r.Get("/", settlementHandler.GetSettlements)
+		r.Get("/exceptions", settlementHandler.GetSettlementExceptions)
+		r.Get("/{settlementId}", settlementHandler.GetSettlementReport)
+
+		// POST
+		r.Post("/", settlementHandler.IngestSettlement)
+		r.Post("/exceptions/{exceptionId}/resolve", settlementHandler.ResolveSettlementException)
+	})
+}
+
+// IngestSettlement reads the multipart "file" field, its "format" (csv or fixed_width, told from a .csv file name
+// when empty) and "settlement_date" (YYYY-MM-DD, optional for fixed_width)
+func (handler *settlementHandler) IngestSettlement(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, settlementBodyLimit)
+
+	req, err := decodeSettlementRequest(r)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	result, err := handler.settlementUsecase.IngestSettlement(r.Context(), req)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[settlement.Settlement]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *settlementHandler) GetSettlements(w http.ResponseWriter, r *http.Request) {
+	result, err := handler.settlementUsecase.GetSettlements(r.Context())
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[[]settlement.Settlement]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+// GetSettlementReport returns the file with every result, or the results of ?status= only
+func (handler *settlementHandler) GetSettlementReport(w http.ResponseWriter, r *http.Request) {
+	result, err := handler.settlementUsecase.GetSettlementReport(r.Context(), chi.URLParam(r, "settlementId"), r.URL.Query().Get("status"))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[settlement.SettlementReport]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+// GetSettlementExceptions reads ?status=open (default) or resolved
+func (handler *settlementHandler) GetSettlementExceptions(w http.ResponseWriter, r *http.Request) {
+	exceptionStatus := r.URL.Query().Get("status")
+	if exceptionStatus == "" {
+		exceptionStatus = settlement.SETTLEMENT_EXCEPTION_OPEN
+	}
+
+	result, err := handler.settlementUsecase.GetSettlementExceptions(r.Context(), exceptionStatus)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[[]settlement.SettlementResult]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+// ResolveSettlementException reads the required note
+func (handler *settlementHandler) ResolveSettlementException(w http.ResponseWriter, r *http.Request) {
+	req := settlement.ExceptionResolutionRequest{}
+
+	err := request.Decode(w, r, &req)
+	if err == nil {
+		err = req.Validate()
+	}
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	result, err := handler.settlementUsecase.ResolveSettlementException(r.Context(), chi.URLParam(r, "exceptionId"), req.Note)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[settlement.SettlementResult]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+// decodeSettlementRequest reads the multipart upload itself rather than through request.Decode,
+// the file has its own size limit and format and settlement_date go along with it
+func decodeSettlementRequest(r *http.Request) (req settlement.SettlementRequest, err error) {
+	file, fileHeader, err := r.FormFile("file")
+	if err != nil {
+		return req, invalidSettlementFile(err)
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return req, invalidSettlementFile(err)
+	}
+
+	req = settlement.SettlementRequest{
+		FileName:       fileHeader.Filename,
+		Format:         r.FormValue("format"),
+		SettlementDate: r.FormValue("settlement_date"),
+		Content:        content,
+	}
+	if req.Format == "" && strings.EqualFold(filepath.Ext(fileHeader.Filename), ".csv") {
+		req.Format = settlement.SETTLEMENT_FORMAT_CSV
+	}
+
+	return req, nil
+}
+
+// invalidSettlementFile tells a body over the size limit apart from a malformed one
+func invalidSettlementFile(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return response.ErrSettlementTooLarge
+	}
+
+	return response.ErrSettlementInvalidFile
+}
@@ -0,0 +1,96 @@
+package settlement
+
+import (
+	"mini-wallet/domain/settlement"
+	"mini-wallet/domain/wallet"
+	"time"
+)
+
+// matchSettlement classifies every line against the deposits not settled yet, then every deposit created within
+// [from, to) no line claimed. a deposit is claimed by one line only, a line settled twice is missing internally
+func matchSettlement(lines []settlement.SettlementLine, candidates []wallet.WalletTransactionEntity, from time.Time, to time.Time) (results []settlement.SettlementResult) {
+	byReferenceId := map[string][]int{}
+	for i, candidate := range candidates {
+		byReferenceId[candidate.ReferenceId] = append(byReferenceId[candidate.ReferenceId], i)
+	}
+	claimed := make([]bool, len(candidates))
+
+	for _, line := range lines {
+		result := settlement.SettlementResult{
+			Status:         settlement.SETTLEMENT_RESULT_MISSING_INTERNALLY,
+			Line:           intPointer(line.Line),
+			ReferenceId:    line.ReferenceId,
+			ExternalAmount: intPointer(line.Amount),
+		}
+
+		// the same amount first, a reference id is only unique per wallet
+		found := -1
+		for _, i := range byReferenceId[line.ReferenceId] {
+			if claimed[i] {
+				continue
+			}
+			if candidates[i].Amount == line.Amount {
+				found = i
+				break
+			}
+			if found == -1 {
+				found = i
+			}
+		}
+
+		if found != -1 {
+			claimed[found] = true
+			result.Status = settlement.SETTLEMENT_RESULT_MATCHED
+			if candidates[found].Amount != line.Amount {
+				result.Status = settlement.SETTLEMENT_RESULT_AMOUNT_MISMATCH
+			}
+			setCandidate(&result, candidates[found])
+		}
+
+		results = append(results, result)
+	}
+
+	for i, candidate := range candidates {
+		if claimed[i] || !createdWithin(candidate, from, to) {
+			continue
+		}
+
+		result := settlement.SettlementResult{
+			Status:      settlement.SETTLEMENT_RESULT_MISSING_EXTERNALLY,
+			ReferenceId: candidate.ReferenceId,
+		}
+		setCandidate(&result, candidate)
+		results = append(results, result)
+	}
+
+	for i := range results {
+		if results[i].Status != settlement.SETTLEMENT_RESULT_MATCHED {
+			exceptionStatus := settlement.SETTLEMENT_EXCEPTION_OPEN
+			results[i].ExceptionStatus = &exceptionStatus
+		}
+	}
+
+	return results
+}
+
+func setCandidate(result *settlement.SettlementResult, candidate wallet.WalletTransactionEntity) {
+	transactionId := candidate.Id
+	walletId := candidate.WalletId
+	result.TransactionId = &transactionId
+	result.WalletId = &walletId
+	result.InternalAmount = intPointer(candidate.Amount)
+}
+
+// createdWithin is false for a created_at which can not be read, such a deposit is only matched by its reference id
+func createdWithin(candidate wallet.WalletTransactionEntity, from time.Time, to time.Time) bool {
+	createdAt, err := time.Parse(time.RFC3339, candidate.CreatedAt)
+	if err != nil {
+		return false
+	}
+
+	return !createdAt.Before(from) && createdAt.Before(to)
+}
+
+func intPointer(value int) *int {
+	return &value
+}
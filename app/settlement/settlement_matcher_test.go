@@ -0,0 +1,86 @@
+package settlement
+
+import (
+	"mini-wallet/domain/settlement"
+	"mini-wallet/domain/wallet"
+	"testing"
+	"time"
+)
+
+func TestMatchSettlement(t *testing.T) {
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+
+	lines := []settlement.SettlementLine{
+		{Line: 2, ReferenceId: "ref-1", Amount: 1000},
+		{Line: 3, ReferenceId: "ref-2", Amount: 300},
+		{Line: 4, ReferenceId: "ref-3", Amount: 50},
+		// settled twice by the bank
+		{Line: 5, ReferenceId: "ref-1", Amount: 1000},
+		// a deposit of the day before, settled late
+		{Line: 6, ReferenceId: "ref-5", Amount: 70},
+	}
+	candidates := []wallet.WalletTransactionEntity{
+		{Id: "t1", WalletId: "w1", ReferenceId: "ref-1", Amount: 1000, CreatedAt: "2026-10-01T08:00:00Z"},
+		{Id: "t2", WalletId: "w2", ReferenceId: "ref-2", Amount: 250, CreatedAt: "2026-10-01T09:00:00Z"},
+		{Id: "t4", WalletId: "w1", ReferenceId: "ref-4", Amount: 80, CreatedAt: "2026-10-01T23:59:59Z"},
+		{Id: "t5", WalletId: "w2", ReferenceId: "ref-5", Amount: 70, CreatedAt: "2026-09-30T23:00:00Z"},
+		// the next day, left for the next file
+		{Id: "t6", WalletId: "w2", ReferenceId: "ref-6", Amount: 10, CreatedAt: "2026-10-02T00:00:00Z"},
+	}
+
+	results := matchSettlement(lines, candidates, from, to)
+
+	want := []struct {
+		status        string
+		transactionId string
+	}{
+		{settlement.SETTLEMENT_RESULT_MATCHED, "t1"},
+		{settlement.SETTLEMENT_RESULT_AMOUNT_MISMATCH, "t2"},
+		{settlement.SETTLEMENT_RESULT_MISSING_INTERNALLY, ""},
+		{settlement.SETTLEMENT_RESULT_MISSING_INTERNALLY, ""},
+		{settlement.SETTLEMENT_RESULT_MATCHED, "t5"},
+		{settlement.SETTLEMENT_RESULT_MISSING_EXTERNALLY, "t4"},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+
+	for i, result := range results {
+		transactionId := ""
+		if result.TransactionId != nil {
+			transactionId = *result.TransactionId
+		}
+		if result.Status != want[i].status || transactionId != want[i].transactionId {
+			t.Errorf("result %d = %s %q, want %s %q", i, result.Status, transactionId, want[i].status, want[i].transactionId)
+		}
+
+		open := result.ExceptionStatus != nil && *result.ExceptionStatus == settlement.SETTLEMENT_EXCEPTION_OPEN
+		if open == (result.Status == settlement.SETTLEMENT_RESULT_MATCHED) {
+			t.Errorf("result %d %s exception status = %v", i, result.Status, result.ExceptionStatus)
+		}
+	}
+
+	if *results[1].ExternalAmount != 300 || *results[1].InternalAmount != 250 {
+		t.Errorf("amount mismatch amounts = %d, %d", *results[1].ExternalAmount, *results[1].InternalAmount)
+	}
+}
+
+func TestMatchSettlementPrefersSameAmount(t *testing.T) {
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	// a reference id is only unique per wallet
+	candidates := []wallet.WalletTransactionEntity{
+		{Id: "t1", WalletId: "w1", ReferenceId: "ref-1", Amount: 500, CreatedAt: "2026-10-01T08:00:00Z"},
+		{Id: "t2", WalletId: "w2", ReferenceId: "ref-1", Amount: 1000, CreatedAt: "2026-10-01T09:00:00Z"},
+	}
+	lines := []settlement.SettlementLine{{Line: 2, ReferenceId: "ref-1", Amount: 1000}}
+
+	results := matchSettlement(lines, candidates, from, from.AddDate(0, 0, 1))
+	if results[0].Status != settlement.SETTLEMENT_RESULT_MATCHED || *results[0].TransactionId != "t2" {
+		t.Fatalf("line matched %s %s, want t2", results[0].Status, *results[0].TransactionId)
+	}
+	if len(results) != 2 || results[1].Status != settlement.SETTLEMENT_RESULT_MISSING_EXTERNALLY || *results[1].TransactionId != "t1" {
+		t.Fatalf("results = %+v", results)
+	}
+}
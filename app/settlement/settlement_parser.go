@@ -0,0 +1,162 @@
+package settlement
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/settlement"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	fixedWidthDetailLength  = 1 + settlement.SETTLEMENT_FIXED_WIDTH_REFERENCE + settlement.SETTLEMENT_FIXED_WIDTH_AMOUNT
+	fixedWidthTrailerLength = 1 + settlement.SETTLEMENT_FIXED_WIDTH_COUNT + settlement.SETTLEMENT_FIXED_WIDTH_AMOUNT
+	fixedWidthHeaderLength  = 1 + len(settlement.SETTLEMENT_FIXED_WIDTH_DATE_LAYOUT)
+)
+
+var (
+	// required in any order, the other columns of the bank are ignored
+	settlementCsvColumns = []string{"reference_id", "amount"}
+)
+
+// parseSettlement reads every line of the file, settlementDate is only known from a fixed width header.
+// unlike a batch a single invalid line refuses the whole file, it would be matched wrong otherwise
+func parseSettlement(format string, content []byte) (lines []settlement.SettlementLine, settlementDate string, err error) {
+	switch format {
+	case settlement.SETTLEMENT_FORMAT_CSV:
+		lines, err = parseSettlementCsv(content)
+		return lines, "", err
+	case settlement.SETTLEMENT_FORMAT_FIXED_WIDTH:
+		return parseSettlementFixedWidth(content)
+	}
+
+	return nil, "", response.ErrSettlementInvalidFile
+}
+
+// parseSettlementCsv reads a header line naming the columns, then one settled deposit per line
+func parseSettlementCsv(content []byte) (lines []settlement.SettlementLine, err error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, response.ErrSettlementInvalidFile
+	}
+
+	columns := map[string]int{}
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	for _, column := range settlementCsvColumns {
+		if _, ok := columns[column]; !ok {
+			return nil, response.ErrSettlementInvalidFile
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, response.ErrSettlementInvalidFile
+		}
+
+		line, _ := reader.FieldPos(0)
+		settlementLine, err := newSettlementLine(line, record[columns["reference_id"]], record[columns["amount"]])
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, settlementLine)
+	}
+
+	return lines, nil
+}
+
+// parseSettlementFixedWidth reads a header, the detail records and a trailer holding their count and total amount
+func parseSettlementFixedWidth(content []byte) (lines []settlement.SettlementLine, settlementDate string, err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	var (
+		line       int
+		total      int
+		headerSeen bool
+		ended      bool
+	)
+	for scanner.Scan() {
+		line++
+		record := strings.TrimRight(scanner.Text(), "\r")
+		if record == "" {
+			continue
+		}
+
+		// the header comes first, nothing is expected after the trailer
+		if ended || (!headerSeen && record[0] != settlement.SETTLEMENT_FIXED_WIDTH_HEADER) {
+			return nil, "", response.ErrSettlementInvalidFile
+		}
+
+		switch record[0] {
+		case settlement.SETTLEMENT_FIXED_WIDTH_HEADER:
+			if headerSeen || len(record) != fixedWidthHeaderLength {
+				return nil, "", response.ErrSettlementInvalidFile
+			}
+
+			date, err := time.Parse(settlement.SETTLEMENT_FIXED_WIDTH_DATE_LAYOUT, record[1:])
+			if err != nil {
+				return nil, "", response.ErrSettlementInvalidFile
+			}
+			settlementDate = date.Format(settlement.SETTLEMENT_DATE_LAYOUT)
+			headerSeen = true
+		case settlement.SETTLEMENT_FIXED_WIDTH_DETAIL:
+			if len(record) != fixedWidthDetailLength {
+				return nil, "", response.ErrSettlementInvalidFile
+			}
+
+			referenceEnd := 1 + settlement.SETTLEMENT_FIXED_WIDTH_REFERENCE
+			settlementLine, err := newSettlementLine(line, record[1:referenceEnd], record[referenceEnd:])
+			if err != nil {
+				return nil, "", err
+			}
+			lines = append(lines, settlementLine)
+			total += settlementLine.Amount
+		case settlement.SETTLEMENT_FIXED_WIDTH_TRAILER:
+			if len(record) != fixedWidthTrailerLength {
+				return nil, "", response.ErrSettlementInvalidFile
+			}
+
+			countEnd := 1 + settlement.SETTLEMENT_FIXED_WIDTH_COUNT
+			count, errCount := strconv.Atoi(record[1:countEnd])
+			amount, errAmount := strconv.Atoi(record[countEnd:])
+			// a truncated or edited file
+			if errCount != nil || errAmount != nil || count != len(lines) || amount != total {
+				return nil, "", response.ErrSettlementInvalidFile
+			}
+			ended = true
+		default:
+			return nil, "", response.ErrSettlementInvalidFile
+		}
+	}
+	if err := scanner.Err(); err != nil || !ended {
+		return nil, "", response.ErrSettlementInvalidFile
+	}
+
+	return lines, settlementDate, nil
+}
+
+func newSettlementLine(line int, referenceId string, amount string) (res settlement.SettlementLine, err error) {
+	res = settlement.SettlementLine{
+		Line:        line,
+		ReferenceId: strings.TrimSpace(referenceId),
+	}
+
+	res.Amount, err = strconv.Atoi(strings.TrimSpace(amount))
+	if err != nil || res.Amount <= 0 || res.ReferenceId == "" {
+		return res, response.ErrSettlementInvalidFile
+	}
+
+	return res, nil
+}
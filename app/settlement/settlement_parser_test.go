@@ -0,0 +1,85 @@
+package settlement
+
+import (
+	"errors"
+	"fmt"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/settlement"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func fixedWidthDetail(referenceId string, amount int) string {
+	return fmt.Sprintf("D%-36s%015d", referenceId, amount)
+}
+
+func TestParseSettlementCsv(t *testing.T) {
+	lines, settlementDate, err := parseSettlement(settlement.SETTLEMENT_FORMAT_CSV, []byte(
+		"value_date,Amount,reference_id,narrative\n"+
+			"2026-10-01, 1000, ref-1, \"top up, bank a\"\n"+
+			"2026-10-01,250,ref-2,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []settlement.SettlementLine{
+		{Line: 2, ReferenceId: "ref-1", Amount: 1000},
+		{Line: 3, ReferenceId: "ref-2", Amount: 250},
+	}
+	if !reflect.DeepEqual(lines, want) || settlementDate != "" {
+		t.Errorf("lines = %+v, date = %q, want %+v", lines, settlementDate, want)
+	}
+}
+
+func TestParseSettlementFixedWidth(t *testing.T) {
+	content := strings.Join([]string{
+		"H20261001",
+		fixedWidthDetail("ref-1", 1000),
+		fixedWidthDetail("ref-2", 250),
+		"T000000002000000000001250",
+		"",
+	}, "\r\n")
+
+	lines, settlementDate, err := parseSettlement(settlement.SETTLEMENT_FORMAT_FIXED_WIDTH, []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []settlement.SettlementLine{
+		{Line: 2, ReferenceId: "ref-1", Amount: 1000},
+		{Line: 3, ReferenceId: "ref-2", Amount: 250},
+	}
+	if !reflect.DeepEqual(lines, want) || settlementDate != "2026-10-01" {
+		t.Errorf("lines = %+v, date = %q, want %+v", lines, settlementDate, want)
+	}
+}
+
+func TestParseSettlementRejectsMalformedFiles(t *testing.T) {
+	detail := fixedWidthDetail("ref-1", 1000)
+
+	for name, testCase := range map[string]struct {
+		format string
+		body   string
+	}{
+		"csv missing column":       {settlement.SETTLEMENT_FORMAT_CSV, "reference_id\nref-1\n"},
+		"csv amount as text":       {settlement.SETTLEMENT_FORMAT_CSV, "reference_id,amount\nref-1,ten\n"},
+		"csv negative amount":      {settlement.SETTLEMENT_FORMAT_CSV, "reference_id,amount\nref-1,-10\n"},
+		"csv empty reference id":   {settlement.SETTLEMENT_FORMAT_CSV, "reference_id,amount\n,10\n"},
+		"fixed width no header":    {settlement.SETTLEMENT_FORMAT_FIXED_WIDTH, detail + "\nT000000001000000000001000\n"},
+		"fixed width no trailer":   {settlement.SETTLEMENT_FORMAT_FIXED_WIDTH, "H20261001\n" + detail + "\n"},
+		"fixed width wrong count":  {settlement.SETTLEMENT_FORMAT_FIXED_WIDTH, "H20261001\n" + detail + "\nT000000002000000000001000\n"},
+		"fixed width wrong total":  {settlement.SETTLEMENT_FORMAT_FIXED_WIDTH, "H20261001\n" + detail + "\nT000000001000000000001001\n"},
+		"fixed width short record": {settlement.SETTLEMENT_FORMAT_FIXED_WIDTH, "H20261001\n" + detail[:40] + "\nT000000001000000000001000\n"},
+		"fixed width after trailer": {settlement.SETTLEMENT_FORMAT_FIXED_WIDTH,
+			"H20261001\n" + detail + "\nT000000001000000000001000\n" + detail + "\n"},
+		"unknown format": {"xlsx", "reference_id,amount\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := parseSettlement(testCase.format, []byte(testCase.body))
+			if err == nil || !errors.Is(err, response.ErrSettlementInvalidFile) {
+				t.Errorf("err = %v, want %q", err, response.ERROR_SETTLEMENT_INVALID_FILE)
+			}
+		})
+	}
+}
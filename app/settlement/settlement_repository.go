@@ -0,0 +1,243 @@
+package settlement
+
+import (
+	"context"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/settlement"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"gorm.io/gorm"
+)
+
+const (
+	insertSettlementResultsChunk = 500
+	settlementCandidatesChunk    = 1000 // reference ids looked up at once
+)
+
+type settlementRepository struct {
+	db *gorm.DB
+}
+
+func NewSettlementRepository(db *gorm.DB) settlement.SettlementRepository {
+	return &settlementRepository{
+		db: db,
+	}
+}
+
+func (settlementRepository *settlementRepository) GetSettlementByChecksum(ctx context.Context, checksum string) (res *settlement.Settlement, err error) {
+	qry, args, err := sq.Select("*").From("tr_settlement").Where(sq.Eq{"checksum": checksum}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	result := settlementRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+func (settlementRepository *settlementRepository) GetSettlementCandidates(ctx context.Context, referenceIds []string, from time.Time, to time.Time) (res []wallet.WalletTransactionEntity, err error) {
+	seen := map[string]struct{}{}
+	collect := func(condition sq.Sqlizer) error {
+		qry, args, err := selectUnsettledDeposits().Where(condition).ToSql()
+		if err != nil {
+			return err
+		}
+
+		var chunk []wallet.WalletTransactionEntity
+		if err = settlementRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&chunk).Error; err != nil {
+			return err
+		}
+
+		for _, walletTransaction := range chunk {
+			if _, ok := seen[walletTransaction.Id]; ok {
+				continue
+			}
+			seen[walletTransaction.Id] = struct{}{}
+			res = append(res, walletTransaction)
+		}
+
+		return nil
+	}
+
+	err = collect(sq.Expr("created_at_timestamptz(t.created_at) >= ? AND created_at_timestamptz(t.created_at) < ?", from, to))
+	if err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(referenceIds); start += settlementCandidatesChunk {
+		end := min(start+settlementCandidatesChunk, len(referenceIds))
+		if err = collect(sq.Eq{"t.reference_id": referenceIds[start:end]}); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func (settlementRepository *settlementRepository) InsertSettlement(ctx context.Context, settlementData settlement.Settlement, results []settlement.SettlementResult) (err error) {
+	err = settlementRepository.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("tr_settlement").Create(&settlementData).Error; err != nil {
+			return err
+		}
+
+		if len(results) > 0 {
+			if err := tx.Table("tr_settlement_result").CreateInBatches(results, insertSettlementResultsChunk).Error; err != nil {
+				return err
+			}
+		}
+
+		// the deposits an earlier file was missing are settled by this one
+		note := "settled by " + settlementData.Id
+		return tx.Exec(`
+			UPDATE tr_settlement_result SET exception_status = ?, resolution_note = ?, resolved_at = ?
+			WHERE status = ? AND exception_status = ? AND transaction_id IN (
+				SELECT transaction_id FROM tr_settlement_result WHERE settlement_id = ? AND status IN (?, ?)
+			)`,
+			settlement.SETTLEMENT_EXCEPTION_RESOLVED, note, settlementData.CreatedAt,
+			settlement.SETTLEMENT_RESULT_MISSING_EXTERNALLY, settlement.SETTLEMENT_EXCEPTION_OPEN,
+			settlementData.Id, settlement.SETTLEMENT_RESULT_MATCHED, settlement.SETTLEMENT_RESULT_AMOUNT_MISMATCH,
+		).Error
+	})
+
+	return translateConstraintViolation(err)
+}
+
+func (settlementRepository *settlementRepository) GetSettlements(ctx context.Context, limit int) (res []settlement.Settlement, err error) {
+	// ids are time ordered
+	qry, args, err := sq.Select("*").From("tr_settlement").OrderBy("id DESC").Limit(uint64(limit)).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = settlementRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (settlementRepository *settlementRepository) GetSettlementById(ctx context.Context, settlementId string) (res *settlement.Settlement, err error) {
+	qry, args, err := sq.Select("*").From("tr_settlement").Where(sq.Eq{"id": settlementId}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	result := settlementRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+func (settlementRepository *settlementRepository) GetSettlementResults(ctx context.Context, settlementId string, status string) (res []settlement.SettlementResult, err error) {
+	builder := sq.Select("*").From("tr_settlement_result").Where(sq.Eq{"settlement_id": settlementId})
+	if status != "" {
+		builder = builder.Where(sq.Eq{"status": status})
+	}
+
+	// the missing deposits have no line, they come last
+	qry, args, err := builder.OrderBy("line NULLS LAST", "id").ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = settlementRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (settlementRepository *settlementRepository) GetSettlementExceptions(ctx context.Context, exceptionStatus string, limit int) (res []settlement.SettlementResult, err error) {
+	qry, args, err := sq.Select("*").From("tr_settlement_result").
+		Where(sq.Eq{"exception_status": exceptionStatus}).
+		OrderBy("id").
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = settlementRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (settlementRepository *settlementRepository) GetSettlementResultById(ctx context.Context, resultId string) (res *settlement.SettlementResult, err error) {
+	qry, args, err := sq.Select("*").From("tr_settlement_result").Where(sq.Eq{"id": resultId}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	result := settlementRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+func (settlementRepository *settlementRepository) ResolveSettlementException(ctx context.Context, resultId string, note string, resolvedAt string) (resolved bool, err error) {
+	result := settlementRepository.db.WithContext(ctx).Table("tr_settlement_result").
+		Where("id = ? AND exception_status = ?", resultId, settlement.SETTLEMENT_EXCEPTION_OPEN).
+		Updates(map[string]interface{}{
+			"exception_status": settlement.SETTLEMENT_EXCEPTION_RESOLVED,
+			"resolution_note":  note,
+			"resolved_at":      resolvedAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// selectUnsettledDeposits leaves out the deposits a line of any file claimed already
+func selectUnsettledDeposits() sq.SelectBuilder {
+	return sq.Select("t.*").
+		From("tr_wallet_transaction t").
+		Where(sq.Eq{"t.type": wallet.WALLET_TRANSACTION_DEPOSIT}).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM tr_settlement_result r
+			WHERE r.transaction_id = t.id AND r.status IN (?, ?)
+		)`, settlement.SETTLEMENT_RESULT_MATCHED, settlement.SETTLEMENT_RESULT_AMOUNT_MISMATCH).
+		OrderBy("created_at_timestamptz(t.created_at)", "t.id")
+}
+
+func translateConstraintViolation(err error) error {
+	violation, ok := infrastructure.AsConstraintViolation(err)
+	if !ok {
+		return err
+	}
+
+	if violation.Code == infrastructure.PG_UNIQUE_VIOLATION {
+		switch violation.Constraint {
+		case "tr_settlement_checksum_key", "tr_settlement_result_settled_transaction_id_key":
+			return response.ErrSettlementConcurrent
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,282 @@
+package settlement
+
+import (
+	"context"
+	"fmt"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/settlement"
+	"mini-wallet/domain/wallet"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memorySettlementRepository struct {
+	walletRepository wallet.WalletRepository
+
+	mu          sync.RWMutex
+	settlements map[string]settlement.Settlement
+	results     []settlement.SettlementResult // in insertion order
+}
+
+// NewMemorySettlementRepository keeps the settlements and their results in this process, for tests and the memory storage.
+// the deposits are read from walletRepository, like the postgres one queries them
+func NewMemorySettlementRepository(walletRepository wallet.WalletRepository) settlement.SettlementRepository {
+	return &memorySettlementRepository{
+		walletRepository: walletRepository,
+		settlements:      map[string]settlement.Settlement{},
+	}
+}
+
+func (settlementRepository *memorySettlementRepository) GetSettlementByChecksum(ctx context.Context, checksum string) (res *settlement.Settlement, err error) {
+	settlementRepository.mu.RLock()
+	defer settlementRepository.mu.RUnlock()
+
+	for _, settlementData := range settlementRepository.settlements {
+		if settlementData.Checksum == checksum {
+			return &settlementData, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (settlementRepository *memorySettlementRepository) GetSettlementCandidates(ctx context.Context, referenceIds []string, from time.Time, to time.Time) (res []wallet.WalletTransactionEntity, err error) {
+	wanted := map[string]struct{}{}
+	for _, referenceId := range referenceIds {
+		wanted[referenceId] = struct{}{}
+	}
+
+	// an empty wallet id streams the deposits of every wallet, the bank knows the reference id only
+	deposit := wallet.WALLET_TRANSACTION_DEPOSIT
+	err = settlementRepository.walletRepository.StreamWalletTransactions(ctx, wallet.GetWalletTransactionRequest{Type: &deposit}, func(walletTransaction wallet.WalletTransactionEntity) error {
+		createdAt, _ := time.Parse(time.RFC3339, walletTransaction.CreatedAt)
+		_, referenced := wanted[walletTransaction.ReferenceId]
+		if !referenced && (createdAt.Before(from) || !createdAt.Before(to)) {
+			return nil
+		}
+
+		if !settlementRepository.settled(walletTransaction.Id) {
+			res = append(res, walletTransaction)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// settled tells whether a line of any file claimed the deposit already
+func (settlementRepository *memorySettlementRepository) settled(transactionId string) bool {
+	settlementRepository.mu.RLock()
+	defer settlementRepository.mu.RUnlock()
+
+	for _, result := range settlementRepository.results {
+		if settlesTransaction(result, transactionId) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (settlementRepository *memorySettlementRepository) InsertSettlement(ctx context.Context, settlementData settlement.Settlement, results []settlement.SettlementResult) (err error) {
+	if settlementData.Format != settlement.SETTLEMENT_FORMAT_CSV && settlementData.Format != settlement.SETTLEMENT_FORMAT_FIXED_WIDTH {
+		return response.ErrBadRequest
+	}
+	for _, result := range results {
+		if err = checkSettlementResult(result); err != nil {
+			return err
+		}
+
+		// the foreign key on tr_wallet_transaction
+		if result.TransactionId == nil {
+			continue
+		}
+		walletTransaction, err := settlementRepository.walletRepository.GetWalletTransactionById(ctx, *result.TransactionId)
+		if err != nil {
+			return err
+		}
+		if walletTransaction == nil {
+			return fmt.Errorf("wallet transaction %s does not exist", *result.TransactionId)
+		}
+	}
+
+	settlementRepository.mu.Lock()
+	defer settlementRepository.mu.Unlock()
+
+	if _, found := settlementRepository.settlements[settlementData.Id]; found {
+		return fmt.Errorf("settlement %s already exists", settlementData.Id)
+	}
+	for _, storedSettlement := range settlementRepository.settlements {
+		if storedSettlement.Checksum == settlementData.Checksum {
+			return response.ErrSettlementConcurrent
+		}
+	}
+
+	// the unique index on the settled transaction ids, across the stored results and the new ones
+	settledIds := map[string]struct{}{}
+	for _, storedResult := range settlementRepository.results {
+		if storedResult.TransactionId != nil && settlesTransaction(storedResult, *storedResult.TransactionId) {
+			settledIds[*storedResult.TransactionId] = struct{}{}
+		}
+	}
+	for _, result := range results {
+		if result.TransactionId == nil || !settlesTransaction(result, *result.TransactionId) {
+			continue
+		}
+		if _, found := settledIds[*result.TransactionId]; found {
+			return response.ErrSettlementConcurrent
+		}
+		settledIds[*result.TransactionId] = struct{}{}
+	}
+
+	// the deposits an earlier file was missing are settled by this one
+	note := "settled by " + settlementData.Id
+	resolved := settlement.SETTLEMENT_EXCEPTION_RESOLVED
+	for i, result := range settlementRepository.results {
+		if result.Status != settlement.SETTLEMENT_RESULT_MISSING_EXTERNALLY || result.ExceptionStatus == nil ||
+			*result.ExceptionStatus != settlement.SETTLEMENT_EXCEPTION_OPEN || result.TransactionId == nil {
+			continue
+		}
+		for _, newResult := range results {
+			if settlesTransaction(newResult, *result.TransactionId) {
+				settlementRepository.results[i].ExceptionStatus = &resolved
+				settlementRepository.results[i].ResolutionNote = &note
+				settlementRepository.results[i].ResolvedAt = &settlementData.CreatedAt
+				break
+			}
+		}
+	}
+
+	settlementRepository.settlements[settlementData.Id] = settlementData
+	settlementRepository.results = append(settlementRepository.results, results...)
+
+	return nil
+}
+
+func (settlementRepository *memorySettlementRepository) GetSettlements(ctx context.Context, limit int) (res []settlement.Settlement, err error) {
+	settlementRepository.mu.RLock()
+	defer settlementRepository.mu.RUnlock()
+
+	// ids are time ordered
+	for _, settlementData := range settlementRepository.settlements {
+		res = append(res, settlementData)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Id > res[j].Id })
+
+	return res[:min(limit, len(res))], nil
+}
+
+func (settlementRepository *memorySettlementRepository) GetSettlementById(ctx context.Context, settlementId string) (res *settlement.Settlement, err error) {
+	settlementRepository.mu.RLock()
+	defer settlementRepository.mu.RUnlock()
+
+	settlementData, found := settlementRepository.settlements[settlementId]
+	if !found {
+		return nil, nil
+	}
+
+	return &settlementData, nil
+}
+
+func (settlementRepository *memorySettlementRepository) GetSettlementResults(ctx context.Context, settlementId string, status string) (res []settlement.SettlementResult, err error) {
+	settlementRepository.mu.RLock()
+	defer settlementRepository.mu.RUnlock()
+
+	for _, result := range settlementRepository.results {
+		if result.SettlementId == settlementId && (status == "" || result.Status == status) {
+			res = append(res, result)
+		}
+	}
+
+	// the missing deposits have no line, they come last
+	sort.Slice(res, func(i, j int) bool {
+		if (res[i].Line == nil) != (res[j].Line == nil) {
+			return res[j].Line == nil
+		}
+		if res[i].Line != nil && *res[i].Line != *res[j].Line {
+			return *res[i].Line < *res[j].Line
+		}
+		return res[i].Id < res[j].Id
+	})
+
+	return res, nil
+}
+
+func (settlementRepository *memorySettlementRepository) GetSettlementExceptions(ctx context.Context, exceptionStatus string, limit int) (res []settlement.SettlementResult, err error) {
+	settlementRepository.mu.RLock()
+	defer settlementRepository.mu.RUnlock()
+
+	for _, result := range settlementRepository.results {
+		if result.ExceptionStatus != nil && *result.ExceptionStatus == exceptionStatus {
+			res = append(res, result)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Id < res[j].Id })
+
+	return res[:min(limit, len(res))], nil
+}
+
+func (settlementRepository *memorySettlementRepository) GetSettlementResultById(ctx context.Context, resultId string) (res *settlement.SettlementResult, err error) {
+	settlementRepository.mu.RLock()
+	defer settlementRepository.mu.RUnlock()
+
+	for _, result := range settlementRepository.results {
+		if result.Id == resultId {
+			return &result, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (settlementRepository *memorySettlementRepository) ResolveSettlementException(ctx context.Context, resultId string, note string, resolvedAt string) (resolved bool, err error) {
+	settlementRepository.mu.Lock()
+	defer settlementRepository.mu.Unlock()
+
+	for i, result := range settlementRepository.results {
+		if result.Id != resultId {
+			continue
+		}
+		if result.ExceptionStatus == nil || *result.ExceptionStatus != settlement.SETTLEMENT_EXCEPTION_OPEN {
+			return false, nil
+		}
+
+		exceptionStatus := settlement.SETTLEMENT_EXCEPTION_RESOLVED
+		settlementRepository.results[i].ExceptionStatus = &exceptionStatus
+		settlementRepository.results[i].ResolutionNote = &note
+		settlementRepository.results[i].ResolvedAt = &resolvedAt
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// settlesTransaction tells whether result is the line claiming the deposit transactionId
+func settlesTransaction(result settlement.SettlementResult, transactionId string) bool {
+	return result.TransactionId != nil && *result.TransactionId == transactionId &&
+		(result.Status == settlement.SETTLEMENT_RESULT_MATCHED || result.Status == settlement.SETTLEMENT_RESULT_AMOUNT_MISMATCH)
+}
+
+// checkSettlementResult mirrors the check constraints of tr_settlement_result
+func checkSettlementResult(result settlement.SettlementResult) error {
+	switch result.Status {
+	case settlement.SETTLEMENT_RESULT_MATCHED:
+		if result.ExceptionStatus == nil {
+			return nil
+		}
+	case settlement.SETTLEMENT_RESULT_MISSING_INTERNALLY, settlement.SETTLEMENT_RESULT_MISSING_EXTERNALLY, settlement.SETTLEMENT_RESULT_AMOUNT_MISMATCH:
+	default:
+		return response.ErrBadRequest
+	}
+
+	if result.ExceptionStatus == nil ||
+		(*result.ExceptionStatus != settlement.SETTLEMENT_EXCEPTION_OPEN && *result.ExceptionStatus != settlement.SETTLEMENT_EXCEPTION_RESOLVED) {
+		return response.ErrBadRequest
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package settlement
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/settlement"
+	"mini-wallet/infrastructure"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	settlementsLimit          = 50
+	settlementExceptionsLimit = 100
+)
+
+type settlementUsecase struct {
+	settlementRepository settlement.SettlementRepository
+	location             *time.Location
+}
+
+// NewSettlementUsecase cuts the settlement date into a day in SETTLEMENT_TIMEZONE, the zone of the bank
+func NewSettlementUsecase(repositories domain.Repositories, config infrastructure.Config) settlement.SettlementUsecase {
+	// validated with the config already, the fallback only serves a config built by hand
+	location, err := time.LoadLocation(config.SETTLEMENT_TIMEZONE)
+	if err != nil {
+		location = time.UTC
+	}
+
+	return &settlementUsecase{
+		settlementRepository: repositories.SettlementRepository,
+		location:             location,
+	}
+}
+
+func (usecase *settlementUsecase) IngestSettlement(ctx context.Context, req settlement.SettlementRequest) (res *response.Response[settlement.Settlement], err error) {
+	if err = req.Validate(); err != nil {
+		return nil, err
+	}
+
+	checksum := sha256.Sum256(req.Content)
+	settlementData := settlement.Settlement{
+		FileName:       req.FileName,
+		Format:         req.Format,
+		SettlementDate: req.SettlementDate,
+		Checksum:       hex.EncodeToString(checksum[:]),
+		CreatedAt:      time.Now().Format(time.RFC3339),
+	}
+
+	lines, fileDate, err := parseSettlement(req.Format, req.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	// the header of a fixed width file is the authority, a date given with the upload has to agree with it
+	if fileDate != "" {
+		if settlementData.SettlementDate != "" && settlementData.SettlementDate != fileDate {
+			return nil, response.ErrBadRequest
+		}
+		settlementData.SettlementDate = fileDate
+	}
+
+	from, err := time.ParseInLocation(settlement.SETTLEMENT_DATE_LAYOUT, settlementData.SettlementDate, usecase.location)
+	if err != nil {
+		return nil, response.ErrBadRequest
+	}
+	to := from.AddDate(0, 0, 1)
+
+	ingested, err := usecase.getIngestedSettlement(ctx, settlementData.Checksum)
+	if err != nil || ingested != nil {
+		return ingested, err
+	}
+
+	settlementId, err := uuid.NewV7()
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on uuid.NewV7() - IngestSettlement", err)
+		return nil, err
+	}
+	settlementData.Id = settlementId.String()
+	settlementData.TotalLines = len(lines)
+
+	referenceIds := make([]string, 0, len(lines))
+	for _, line := range lines {
+		referenceIds = append(referenceIds, line.ReferenceId)
+	}
+
+	candidates, err := usecase.settlementRepository.GetSettlementCandidates(ctx, referenceIds, from, to)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.settlementRepository.GetSettlementCandidates() - IngestSettlement", err)
+		return nil, err
+	}
+
+	results := matchSettlement(lines, candidates, from, to)
+	for i := range results {
+		resultId, err := uuid.NewV7()
+		if err != nil {
+			infrastructure.LogError(ctx, "got error on uuid.NewV7() - IngestSettlement", err)
+			return nil, err
+		}
+
+		results[i].Id = resultId.String()
+		results[i].SettlementId = settlementData.Id
+		settlementData.Count(results[i].Status)
+	}
+
+	err = usecase.settlementRepository.InsertSettlement(ctx, settlementData, results)
+	if err != nil && errors.Is(err, response.ErrSettlementConcurrent) {
+		// the same file uploaded twice at once, the first one wins
+		if ingested, lookupErr := usecase.getIngestedSettlement(ctx, settlementData.Checksum); lookupErr == nil && ingested != nil {
+			return ingested, nil
+		}
+	}
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.settlementRepository.InsertSettlement() - IngestSettlement", err)
+		return nil, err
+	}
+
+	infrastructure.LogInfo(ctx, "settlement ingested",
+		"settlement_id", settlementData.Id,
+		"settlement_date", settlementData.SettlementDate,
+		"total_lines", settlementData.TotalLines,
+		"matched", settlementData.Matched,
+		"missing_internally", settlementData.MissingInternally,
+		"missing_externally", settlementData.MissingExternally,
+		"amount_mismatch", settlementData.AmountMismatch,
+	)
+
+	return &response.Response[settlement.Settlement]{
+		Data: &settlementData,
+	}, nil
+}
+
+func (usecase *settlementUsecase) GetSettlements(ctx context.Context) (res *response.Response[[]settlement.Settlement], err error) {
+	settlements, err := usecase.settlementRepository.GetSettlements(ctx, settlementsLimit)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.settlementRepository.GetSettlements() - GetSettlements", err)
+		return nil, err
+	}
+
+	if settlements == nil {
+		settlements = []settlement.Settlement{}
+	}
+
+	return &response.Response[[]settlement.Settlement]{
+		Data: &settlements,
+	}, nil
+}
+
+func (usecase *settlementUsecase) GetSettlementReport(ctx context.Context, settlementId string, status string) (res *response.Response[settlement.SettlementReport], err error) {
+	settlementData, err := usecase.settlementRepository.GetSettlementById(ctx, settlementId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.settlementRepository.GetSettlementById() - GetSettlementReport", err)
+		return nil, err
+	}
+
+	if settlementData == nil {
+		return nil, response.ErrSettlementNotFound
+	}
+
+	results, err := usecase.settlementRepository.GetSettlementResults(ctx, settlementId, status)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.settlementRepository.GetSettlementResults() - GetSettlementReport", err)
+		return nil, err
+	}
+
+	if results == nil {
+		results = []settlement.SettlementResult{}
+	}
+
+	return &response.Response[settlement.SettlementReport]{
+		Data: &settlement.SettlementReport{
+			Settlement: *settlementData,
+			Results:    results,
+		},
+	}, nil
+}
+
+func (usecase *settlementUsecase) GetSettlementExceptions(ctx context.Context, exceptionStatus string) (res *response.Response[[]settlement.SettlementResult], err error) {
+	if exceptionStatus != settlement.SETTLEMENT_EXCEPTION_OPEN && exceptionStatus != settlement.SETTLEMENT_EXCEPTION_RESOLVED {
+		return nil, response.ErrBadRequest
+	}
+
+	exceptions, err := usecase.settlementRepository.GetSettlementExceptions(ctx, exceptionStatus, settlementExceptionsLimit)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.settlementRepository.GetSettlementExceptions() - GetSettlementExceptions", err)
+		return nil, err
+	}
+
+	if exceptions == nil {
+		exceptions = []settlement.SettlementResult{}
+	}
+
+	return &response.Response[[]settlement.SettlementResult]{
+		Data: &exceptions,
+	}, nil
+}
+
+func (usecase *settlementUsecase) ResolveSettlementException(ctx context.Context, resultId string, note string) (res *response.Response[settlement.SettlementResult], err error) {
+	// whoever looks at the queue later needs to know why it was closed
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, response.ErrBadRequest
+	}
+
+	resolved, err := usecase.settlementRepository.ResolveSettlementException(ctx, resultId, note, time.Now().Format(time.RFC3339))
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.settlementRepository.ResolveSettlementException() - ResolveSettlementException", err)
+		return nil, err
+	}
+
+	result, err := usecase.settlementRepository.GetSettlementResultById(ctx, resultId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.settlementRepository.GetSettlementResultById() - ResolveSettlementException", err)
+		return nil, err
+	}
+
+	if result == nil || result.ExceptionStatus == nil {
+		return nil, response.ErrExceptionNotFound
+	}
+
+	if !resolved {
+		return nil, response.ErrExceptionResolved
+	}
+
+	return &response.Response[settlement.SettlementResult]{
+		Data: result,
+	}, nil
+}
+
+func (usecase *settlementUsecase) getIngestedSettlement(ctx context.Context, checksum string) (res *response.Response[settlement.Settlement], err error) {
+	ingested, err := usecase.settlementRepository.GetSettlementByChecksum(ctx, checksum)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.settlementRepository.GetSettlementByChecksum() - getIngestedSettlement", err)
+		return nil, err
+	}
+
+	if ingested == nil {
+		return nil, nil
+	}
+
+	return &response.Response[settlement.Settlement]{
+		Data: ingested,
+	}, nil
+}
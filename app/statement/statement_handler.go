@@ -0,0 +1,116 @@
+package statement
+
+import (
+	"fmt"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/member"
+	"mini-wallet/domain/statement"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type statementHandler struct {
+	statementUsecase statement.StatementUsecase
+}
+
+func SetStatementHandler(router *chi.Mux, usecases domain.Usecases) {
+	statementHandler := statementHandler{
+		statementUsecase: usecases.StatementUsecase,
+	}
+
+	router.Route("/api/v1/wallet/statements", func(r chi.Router) {
+		r.Use(usecases.AuthUsecase.AuthorizeRequestMiddleware)
+		r.Use(usecases.AuthUsecase.SelectWalletMiddleware)
+		r.Use(usecases.AuthUsecase.RequireRoleMiddleware(member.MEMBER_ROLE_OWNER, member.MEMBER_ROLE_SPENDER, member.MEMBER_ROLE_VIEWER))
+
+		// GET
+		r.Get("/", statementHandler.GetWalletStatement)
+	})
+
+	// statements of any wallet, for regulators and support holding the admin token
+	router.Route("/api/v1/statements", func(r chi.Router) {
+		r.Use(usecases.AuthUsecase.AuthorizeAdminMiddleware)
+
+		// GET
+		r.Get("/{walletId}", statementHandler.GetStatement)
+	})
+}
+
+func (handler *statementHandler) GetWalletStatement(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+
+	handler.writeStatement(w, r, walletId.(string))
+}
+
+func (handler *statementHandler) GetStatement(w http.ResponseWriter, r *http.Request) {
+	handler.writeStatement(w, r, chi.URLParam(r, "walletId"))
+}
+
+// writeStatement reads from and to (YYYY-MM-DD, both inclusive) or month (YYYY-MM), and format (json, csv or pdf, json by default)
+// from the query. the stored bytes are written as they are, not wrapped in the usual response
+func (handler *statementHandler) writeStatement(w http.ResponseWriter, r *http.Request, walletId string) {
+	req, err := statementRequestFromQuery(r, walletId)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	result, err := handler.statementUsecase.GetStatement(r.Context(), req)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	document := result.Data
+	w.Header().Set("Content-Type", document.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, document.FileName()))
+	w.Header().Set("Content-Length", strconv.Itoa(len(document.Content)))
+	w.Header().Set("ETag", strconv.Quote(document.Checksum))
+	w.WriteHeader(http.StatusOK)
+	w.Write(document.Content)
+}
+
+// statementRequestFromQuery reads the filters of a GET from the query, there is no body for request.Decode to read
+func statementRequestFromQuery(r *http.Request, walletId string) (req statement.StatementRequest, err error) {
+	query := r.URL.Query()
+	req = statement.StatementRequest{
+		WalletId: walletId,
+		From:     query.Get("from"),
+		To:       query.Get("to"),
+		Format:   query.Get("format"),
+	}
+	if req.Format == "" {
+		req.Format = statement.STATEMENT_FORMAT_JSON
+	}
+
+	if month := query.Get("month"); month != "" {
+		validationError := &response.ValidationError{}
+		if req.From != "" || req.To != "" {
+			validationError.Add("month", "must not be given with from or to")
+		}
+
+		monthStart, err := time.Parse("2006-01", month)
+		if err != nil {
+			validationError.Add("month", "must be YYYY-MM")
+		}
+		if err = validationError.Err(); err != nil {
+			return req, err
+		}
+		req.From = monthStart.Format(statement.STATEMENT_DATE_LAYOUT)
+		req.To = monthStart.AddDate(0, 1, -1).Format(statement.STATEMENT_DATE_LAYOUT)
+	}
+
+	return req, nil
+}
@@ -0,0 +1,84 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	pdfLinesPerPage = 72
+	pdfFontSize     = 8
+	pdfLeading      = 10
+	pdfMarginLeft   = 40
+	pdfMarginTop    = 800 // from the bottom of an A4 page, 842pt high
+)
+
+// renderPdf lays lines out in Courier on A4 pages. the document is uncompressed and carries no
+// creation date or id, so the same lines always give the same bytes
+func renderPdf(lines []string) []byte {
+	var pages [][]string
+	for len(lines) > pdfLinesPerPage {
+		pages = append(pages, lines[:pdfLinesPerPage])
+		lines = lines[pdfLinesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	var buffer bytes.Buffer
+	var offsets []int
+	writeObject := func(body string) {
+		offsets = append(offsets, buffer.Len())
+		fmt.Fprintf(&buffer, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buffer.WriteString("%PDF-1.4\n")
+
+	// 1 catalog, 2 page tree, 3 font, then a page and its content for every page
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+i*2)
+	}
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	writeObject("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+
+	for i, pageLines := range pages {
+		var content strings.Builder
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLeading, pdfMarginLeft, pdfMarginTop)
+		for _, line := range pageLines {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(line))
+		}
+		fmt.Fprintf(&content, "(%s) Tj\nET", pdfEscape(fmt.Sprintf("Page %d of %d", i+1, len(pages))))
+
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+i*2))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+
+	xrefOffset := buffer.Len()
+	fmt.Fprintf(&buffer, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buffer, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buffer, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOffset)
+
+	return buffer.Bytes()
+}
+
+// pdfEscape makes line a literal string, characters outside of printable ascii are replaced
+// as the standard fonts can not show them
+func pdfEscape(line string) string {
+	var escaped strings.Builder
+	for _, char := range line {
+		switch {
+		case char == '(' || char == ')' || char == '\\':
+			escaped.WriteRune('\\')
+			escaped.WriteRune(char)
+		case char < 32 || char > 126:
+			escaped.WriteRune('?')
+		default:
+			escaped.WriteRune(char)
+		}
+	}
+
+	return escaped.String()
+}
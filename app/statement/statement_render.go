@@ -0,0 +1,149 @@
+package statement
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/statement"
+	"mini-wallet/domain/wallet"
+	"strconv"
+)
+
+var (
+	statementCsvColumns = []string{"created_at", "id", "type", "amount", "balance", "reference_id", "created_by"}
+)
+
+// buildStatement runs the balance from opening through transactions, which are expected oldest first
+func buildStatement(walletId string, from string, to string, timezone string, opening int, transactions []wallet.WalletTransactionEntity) statement.Statement {
+	statementData := statement.Statement{
+		WalletId:       walletId,
+		From:           from,
+		To:             to,
+		Timezone:       timezone,
+		OpeningBalance: opening,
+		Transactions:   []statement.StatementLine{},
+		// both types are always listed, in this order
+		Totals: []statement.StatementTotal{
+			{Type: wallet.WALLET_TRANSACTION_DEPOSIT},
+			{Type: wallet.WALLET_TRANSACTION_WITHDRAWAL},
+		},
+	}
+
+	balance := opening
+	for _, transaction := range transactions {
+		balance += transaction.BalanceDelta()
+		statementData.Transactions = append(statementData.Transactions, statement.StatementLine{
+			Id:          transaction.Id,
+			CreatedAt:   transaction.CreatedAt,
+			CreatedBy:   transaction.CreatedBy,
+			Type:        transaction.Type,
+			Amount:      transaction.Amount,
+			ReferenceId: transaction.ReferenceId,
+			Balance:     balance,
+		})
+
+		for i := range statementData.Totals {
+			if statementData.Totals[i].Type == transaction.Type {
+				statementData.Totals[i].Count++
+				statementData.Totals[i].Amount += transaction.Amount
+			}
+		}
+	}
+	statementData.ClosingBalance = balance
+
+	return statementData
+}
+
+// renderStatement encodes the statement in format, the content never depends on when it is rendered
+func renderStatement(statementData statement.Statement, format string, createdAt string) (res statement.StatementDocument, err error) {
+	res = statement.StatementDocument{
+		WalletId:   statementData.WalletId,
+		PeriodFrom: statementData.From,
+		PeriodTo:   statementData.To,
+		Format:     format,
+		CreatedAt:  createdAt,
+	}
+
+	switch format {
+	case statement.STATEMENT_FORMAT_JSON:
+		res.Content, err = json.Marshal(statementData)
+	case statement.STATEMENT_FORMAT_CSV:
+		res.Content, err = renderCsv(statementData)
+	case statement.STATEMENT_FORMAT_PDF:
+		res.Content = renderPdf(statementText(statementData))
+	default:
+		err = response.ErrBadRequest
+	}
+	if err != nil {
+		return res, err
+	}
+
+	checksum := sha256.Sum256(res.Content)
+	res.Checksum = hex.EncodeToString(checksum[:])
+
+	return res, nil
+}
+
+// renderCsv writes the summary rows first, then a blank row and one row per transaction
+func renderCsv(statementData statement.Statement) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	writer.Write([]string{"wallet_id", statementData.WalletId})
+	writer.Write([]string{"from", statementData.From})
+	writer.Write([]string{"to", statementData.To})
+	writer.Write([]string{"timezone", statementData.Timezone})
+	writer.Write([]string{"opening_balance", strconv.Itoa(statementData.OpeningBalance)})
+	for _, total := range statementData.Totals {
+		writer.Write([]string{"total_" + total.Type, strconv.Itoa(total.Count), strconv.Itoa(total.Amount)})
+	}
+	writer.Write([]string{"closing_balance", strconv.Itoa(statementData.ClosingBalance)})
+	writer.Write(nil)
+
+	writer.Write(statementCsvColumns)
+	for _, line := range statementData.Transactions {
+		writer.Write([]string{
+			line.CreatedAt,
+			line.Id,
+			line.Type,
+			strconv.Itoa(line.Amount),
+			strconv.Itoa(line.Balance),
+			line.ReferenceId,
+			line.CreatedBy,
+		})
+	}
+	writer.Flush()
+
+	return buffer.Bytes(), writer.Error()
+}
+
+// statementText lays the statement out as fixed width lines, the body of the pdf
+func statementText(statementData statement.Statement) []string {
+	lines := []string{
+		"ACCOUNT STATEMENT",
+		"",
+		fmt.Sprintf("Wallet           %s", statementData.WalletId),
+		fmt.Sprintf("Period           %s to %s (%s)", statementData.From, statementData.To, statementData.Timezone),
+		fmt.Sprintf("Opening balance  %d", statementData.OpeningBalance),
+		"",
+		fmt.Sprintf("%-25s  %-10s  %12s  %12s  %s", "Date", "Type", "Amount", "Balance", "Reference"),
+	}
+	for _, line := range statementData.Transactions {
+		lines = append(lines, fmt.Sprintf("%-25s  %-10s  %12d  %12d  %s", line.CreatedAt, line.Type, line.Amount, line.Balance, line.ReferenceId))
+	}
+	if len(statementData.Transactions) == 0 {
+		lines = append(lines, "No transactions in this period")
+	}
+
+	lines = append(lines, "")
+	for _, total := range statementData.Totals {
+		lines = append(lines, fmt.Sprintf("Total %-10s  %d transaction(s), %d", total.Type, total.Count, total.Amount))
+	}
+	lines = append(lines, fmt.Sprintf("Closing balance  %d", statementData.ClosingBalance))
+
+	return lines
+}
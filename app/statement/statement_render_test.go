@@ -0,0 +1,149 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"mini-wallet/domain/statement"
+	"mini-wallet/domain/wallet"
+	"strings"
+	"testing"
+)
+
+func testTransactions() []wallet.WalletTransactionEntity {
+	return []wallet.WalletTransactionEntity{
+		{Id: "t1", CreatedAt: "2026-09-01T10:00:00+07:00", CreatedBy: "c1", Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: 500, ReferenceId: "r1"},
+		{Id: "t2", CreatedAt: "2026-09-02T10:00:00+07:00", CreatedBy: "c1", Type: wallet.WALLET_TRANSACTION_WITHDRAWAL, Amount: 200, ReferenceId: "r2"},
+		{Id: "t3", CreatedAt: "2026-09-03T10:00:00+07:00", CreatedBy: "c2", Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: 50, ReferenceId: "r3"},
+	}
+}
+
+func TestBuildStatement(t *testing.T) {
+	statementData := buildStatement("w1", "2026-09-01", "2026-09-30", "UTC", 1000, testTransactions())
+
+	if statementData.ClosingBalance != 1350 {
+		t.Fatalf("closing balance = %d, want 1350", statementData.ClosingBalance)
+	}
+
+	balances := []int{}
+	for _, line := range statementData.Transactions {
+		balances = append(balances, line.Balance)
+	}
+	if fmt.Sprint(balances) != "[1500 1300 1350]" {
+		t.Fatalf("running balances = %v", balances)
+	}
+
+	want := []statement.StatementTotal{
+		{Type: wallet.WALLET_TRANSACTION_DEPOSIT, Count: 2, Amount: 550},
+		{Type: wallet.WALLET_TRANSACTION_WITHDRAWAL, Count: 1, Amount: 200},
+	}
+	if fmt.Sprint(statementData.Totals) != fmt.Sprint(want) {
+		t.Fatalf("totals = %v, want %v", statementData.Totals, want)
+	}
+}
+
+func TestBuildStatementWithoutTransactions(t *testing.T) {
+	statementData := buildStatement("w1", "2026-09-01", "2026-09-30", "UTC", 700, nil)
+
+	if statementData.OpeningBalance != 700 || statementData.ClosingBalance != 700 {
+		t.Fatalf("balances = %d / %d, want 700 / 700", statementData.OpeningBalance, statementData.ClosingBalance)
+	}
+	if statementData.Transactions == nil || len(statementData.Totals) != 2 {
+		t.Fatalf("transactions and totals must be listed even when empty: %+v", statementData)
+	}
+}
+
+// a statement rendered again, at another time, must give the exact same bytes
+func TestRenderStatementIsDeterministic(t *testing.T) {
+	statementData := buildStatement("w1", "2026-09-01", "2026-09-30", "Asia/Jakarta", 1000, testTransactions())
+
+	for _, format := range statement.StatementFormats {
+		first, err := renderStatement(statementData, format, "2026-10-01T00:00:00Z")
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := renderStatement(statementData, format, "2026-10-19T12:00:00Z")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(first.Content, second.Content) || first.Checksum != second.Checksum {
+			t.Fatalf("%s: rendering twice gave different bytes", format)
+		}
+		if len(first.Content) == 0 || len(first.Checksum) != 64 {
+			t.Fatalf("%s: empty content or bad checksum %q", format, first.Checksum)
+		}
+	}
+}
+
+func TestRenderCsv(t *testing.T) {
+	statementData := buildStatement("w1", "2026-09-01", "2026-09-30", "UTC", 1000, testTransactions())
+
+	document, err := renderStatement(statementData, statement.STATEMENT_FORMAT_CSV, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := string(document.Content)
+	for _, want := range []string{
+		"opening_balance,1000\n",
+		"total_deposit,2,550\n",
+		"closing_balance,1350\n",
+		"created_at,id,type,amount,balance,reference_id,created_by\n",
+		"2026-09-02T10:00:00+07:00,t2,withdrawal,200,1300,r2,c1\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("csv is missing %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestRenderPdf(t *testing.T) {
+	lines := []string{"a (b) \\ c", "naïve"}
+	for i := 0; i < pdfLinesPerPage*2; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+
+	content := renderPdf(lines)
+
+	if !bytes.HasPrefix(content, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(content, []byte("%%EOF\n")) {
+		t.Fatal("not a pdf")
+	}
+	if !bytes.Contains(content, []byte("/Count 3")) {
+		t.Fatal("expected 3 pages")
+	}
+	if !bytes.Contains(content, []byte(`(a \(b\) \\ c) Tj`)) || !bytes.Contains(content, []byte("(na?ve) Tj")) {
+		t.Fatal("lines are not escaped")
+	}
+
+	// every xref entry has to point at its object
+	xref := bytes.LastIndex(content, []byte("xref\n"))
+	entries := strings.Split(string(content[xref:]), "\n")[3:]
+	for i, entry := range entries {
+		if !strings.HasSuffix(entry, " n ") {
+			break
+		}
+		var offset int
+		fmt.Sscanf(entry, "%d", &offset)
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !bytes.HasPrefix(content[offset:], []byte(want)) {
+			t.Fatalf("xref entry %d points at %q", i+1, content[offset:offset+10])
+		}
+	}
+}
+
+func TestStatementRequestValidate(t *testing.T) {
+	for _, req := range []statement.StatementRequest{
+		{From: "2026-09-01", To: "2026-09-30", Format: "xml"},
+		{From: "2026-09-30", To: "2026-09-01", Format: statement.STATEMENT_FORMAT_JSON},
+		{From: "2026-09", To: "2026-09-30", Format: statement.STATEMENT_FORMAT_JSON},
+		{From: "2025-01-01", To: "2026-09-30", Format: statement.STATEMENT_FORMAT_JSON},
+	} {
+		if err := req.Validate(); err == nil {
+			t.Fatalf("%+v should be refused", req)
+		}
+	}
+
+	req := statement.StatementRequest{From: "2026-09-01", To: "2026-09-01", Format: statement.STATEMENT_FORMAT_PDF}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,132 @@
+package statement
+
+import (
+	"context"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/statement"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type statementRepository struct {
+	db *gorm.DB
+}
+
+func NewStatementRepository(db *gorm.DB) statement.StatementRepository {
+	return &statementRepository{
+		db: db,
+	}
+}
+
+func (statementRepository *statementRepository) GetStatementDocument(ctx context.Context, walletId string, periodFrom string, periodTo string, format string) (res *statement.StatementDocument, err error) {
+	qry, args, err := sq.Select("*").From("tr_statement").
+		Where(sq.Eq{"wallet_id": walletId, "period_from": periodFrom, "period_to": periodTo, "format": format}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	result := statementRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+func (statementRepository *statementRepository) InsertStatementDocument(ctx context.Context, document statement.StatementDocument) (inserted bool, err error) {
+	result := statementRepository.db.WithContext(ctx).Table("tr_statement").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&document)
+	if result.Error != nil {
+		return false, translateConstraintViolation(result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (statementRepository *statementRepository) GetWalletIdsWithoutStatements(ctx context.Context, periodFrom string, periodTo string, afterId string, limit int) (res []string, err error) {
+	qry, args, err := sq.Select("w.id").From("ms_wallet w").
+		Where(sq.Gt{"w.id": afterId}).
+		Where("(SELECT COUNT(*) FROM tr_statement s WHERE s.wallet_id = w.id AND s.period_from = ? AND s.period_to = ?) < ?",
+			periodFrom, periodTo, len(statement.StatementFormats)).
+		OrderBy("w.id").
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = statementRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (statementRepository *statementRepository) GetBalanceBefore(ctx context.Context, walletId string, before time.Time) (balance int, err error) {
+	// created_at is RFC 3339 text, compared as a timestamp so the offset it was written with does not matter
+	qry, args, err := sq.Select().
+		Column("COALESCE(SUM(CASE WHEN type = ? THEN -amount ELSE amount END), 0)", wallet.WALLET_TRANSACTION_WITHDRAWAL).
+		From("tr_wallet_transaction").
+		Where(sq.Eq{"wallet_id": walletId}).
+		Where("created_at_timestamptz(created_at) < ?", before).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	err = statementRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&balance).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+func (statementRepository *statementRepository) GetTransactionsBetween(ctx context.Context, walletId string, start time.Time, end time.Time) (res []wallet.WalletTransactionEntity, err error) {
+	// ids are time ordered, they settle the transactions made within the same second
+	qry, args, err := sq.Select("*").From("tr_wallet_transaction").
+		Where(sq.Eq{"wallet_id": walletId}).
+		Where("created_at_timestamptz(created_at) >= ? AND created_at_timestamptz(created_at) < ?", start, end).
+		OrderBy("created_at_timestamptz(created_at)", "id").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = statementRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// translateConstraintViolation turns the database constraints into the user errors the usecases return,
+// any other error is returned as is
+func translateConstraintViolation(err error) error {
+	violation, ok := infrastructure.AsConstraintViolation(err)
+	if !ok {
+		return err
+	}
+
+	switch violation.Code {
+	case infrastructure.PG_CHECK_VIOLATION:
+		return response.ErrBadRequest
+	case infrastructure.PG_FOREIGN_KEY_VIOLATION:
+		return response.ErrWalletNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,146 @@
+package statement
+
+import (
+	"context"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/statement"
+	"mini-wallet/domain/wallet"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	memoryWalletsPage = 100 // wallets read at once while looking for those without statements
+)
+
+type memoryStatementRepository struct {
+	walletRepository wallet.WalletRepository
+
+	mu        sync.RWMutex
+	documents map[[4]string]statement.StatementDocument // by wallet id, period from, period to and format
+}
+
+// NewMemoryStatementRepository keeps the statement documents in this process, for tests and the memory storage.
+// the wallets and their transactions are read from walletRepository, like the postgres one queries them
+func NewMemoryStatementRepository(walletRepository wallet.WalletRepository) statement.StatementRepository {
+	return &memoryStatementRepository{
+		walletRepository: walletRepository,
+		documents:        map[[4]string]statement.StatementDocument{},
+	}
+}
+
+func (statementRepository *memoryStatementRepository) GetStatementDocument(ctx context.Context, walletId string, periodFrom string, periodTo string, format string) (res *statement.StatementDocument, err error) {
+	statementRepository.mu.RLock()
+	defer statementRepository.mu.RUnlock()
+
+	document, found := statementRepository.documents[[4]string{walletId, periodFrom, periodTo, format}]
+	if !found {
+		return nil, nil
+	}
+
+	return &document, nil
+}
+
+func (statementRepository *memoryStatementRepository) InsertStatementDocument(ctx context.Context, document statement.StatementDocument) (inserted bool, err error) {
+	// the foreign key on ms_wallet and the check constraints of tr_statement
+	walletData, err := statementRepository.walletRepository.GetWalletById(ctx, document.WalletId)
+	if err != nil {
+		return false, err
+	}
+	if walletData == nil {
+		return false, response.ErrWalletNotFound
+	}
+	if !slices.Contains(statement.StatementFormats, document.Format) || document.PeriodFrom > document.PeriodTo {
+		return false, response.ErrBadRequest
+	}
+
+	statementRepository.mu.Lock()
+	defer statementRepository.mu.Unlock()
+
+	key := [4]string{document.WalletId, document.PeriodFrom, document.PeriodTo, document.Format}
+	if _, found := statementRepository.documents[key]; found {
+		return false, nil
+	}
+	document.Content = slices.Clone(document.Content)
+	statementRepository.documents[key] = document
+
+	return true, nil
+}
+
+func (statementRepository *memoryStatementRepository) GetWalletIdsWithoutStatements(ctx context.Context, periodFrom string, periodTo string, afterId string, limit int) (res []string, err error) {
+	for len(res) < limit {
+		wallets, err := statementRepository.walletRepository.GetWallets(ctx, afterId, memoryWalletsPage)
+		if err != nil {
+			return nil, err
+		}
+		if len(wallets) == 0 {
+			break
+		}
+
+		for _, walletData := range wallets {
+			if len(res) < limit && statementRepository.countDocuments(walletData.Id, periodFrom, periodTo) < len(statement.StatementFormats) {
+				res = append(res, walletData.Id)
+			}
+			afterId = walletData.Id
+		}
+	}
+
+	return res, nil
+}
+
+func (statementRepository *memoryStatementRepository) countDocuments(walletId string, periodFrom string, periodTo string) (count int) {
+	statementRepository.mu.RLock()
+	defer statementRepository.mu.RUnlock()
+
+	for _, format := range statement.StatementFormats {
+		if _, found := statementRepository.documents[[4]string{walletId, periodFrom, periodTo, format}]; found {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (statementRepository *memoryStatementRepository) GetBalanceBefore(ctx context.Context, walletId string, before time.Time) (balance int, err error) {
+	// an empty wallet id would stream every wallet
+	if walletId == "" {
+		return 0, nil
+	}
+
+	req := wallet.GetWalletTransactionRequest{
+		WalletId: walletId,
+		To:       &before,
+	}
+	err = statementRepository.walletRepository.StreamWalletTransactions(ctx, req, func(walletTransaction wallet.WalletTransactionEntity) error {
+		balance += walletTransaction.BalanceDelta()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+func (statementRepository *memoryStatementRepository) GetTransactionsBetween(ctx context.Context, walletId string, start time.Time, end time.Time) (res []wallet.WalletTransactionEntity, err error) {
+	res = []wallet.WalletTransactionEntity{}
+	if walletId == "" {
+		return res, nil
+	}
+
+	req := wallet.GetWalletTransactionRequest{
+		WalletId: walletId,
+		From:     &start,
+		To:       &end,
+	}
+	err = statementRepository.walletRepository.StreamWalletTransactions(ctx, req, func(walletTransaction wallet.WalletTransactionEntity) error {
+		res = append(res, walletTransaction)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,210 @@
+package statement
+
+import (
+	"context"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/statement"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"time"
+)
+
+const (
+	walletsWithoutStatementsPage = 100
+)
+
+type statementUsecase struct {
+	statementRepository statement.StatementRepository
+	walletRepository    wallet.WalletRepository
+	location            *time.Location
+	config              infrastructure.Config
+}
+
+// NewStatementUsecase cuts periods into days in STATEMENT_TIMEZONE. changing it later does not
+// change the statements already stored
+func NewStatementUsecase(repositories domain.Repositories, config infrastructure.Config) statement.StatementUsecase {
+	// validated with the config already, the fallback only serves a config built by hand
+	location, err := time.LoadLocation(config.STATEMENT_TIMEZONE)
+	if err != nil {
+		location = time.UTC
+	}
+
+	return &statementUsecase{
+		statementRepository: repositories.StatementRepository,
+		walletRepository:    repositories.WalletRepository,
+		location:            location,
+		config:              config,
+	}
+}
+
+func (usecase *statementUsecase) GetStatement(ctx context.Context, req statement.StatementRequest) (res *response.Response[statement.StatementDocument], err error) {
+	if err = req.Validate(); err != nil {
+		return nil, err
+	}
+
+	start, end, err := req.Period(usecase.location)
+	if err != nil {
+		return nil, response.ErrBadRequest
+	}
+
+	now := time.Now()
+	// nothing to show yet
+	if start.After(now) {
+		return nil, response.ErrBadRequest
+	}
+
+	walletResult, err := usecase.walletRepository.GetWalletById(ctx, req.WalletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - GetStatement", err)
+		return nil, err
+	}
+
+	if walletResult == nil {
+		return nil, response.ErrWalletNotFound
+	}
+
+	// transactions can still be made within a period which is not over, it is neither read from nor written to the store
+	closed := !end.After(now)
+	if closed {
+		stored, err := usecase.statementRepository.GetStatementDocument(ctx, req.WalletId, req.From, req.To, req.Format)
+		if err != nil {
+			infrastructure.LogError(ctx, "got error on usecase.statementRepository.GetStatementDocument() - GetStatement", err)
+			return nil, err
+		}
+
+		if stored != nil {
+			return &response.Response[statement.StatementDocument]{
+				Data: stored,
+			}, nil
+		}
+	}
+
+	statementData, err := usecase.buildStatement(ctx, req.WalletId, req.From, req.To, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := renderStatement(statementData, req.Format, now.Format(time.RFC3339))
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on renderStatement() - GetStatement", err)
+		return nil, err
+	}
+
+	if closed {
+		if document, err = usecase.storeStatementDocument(ctx, document); err != nil {
+			return nil, err
+		}
+	}
+
+	return &response.Response[statement.StatementDocument]{
+		Data: &document,
+	}, nil
+}
+
+func (usecase *statementUsecase) GenerateMonthlyStatements(ctx context.Context, now time.Time) (err error) {
+	localNow := now.In(usecase.location)
+	monthStart := time.Date(localNow.Year(), localNow.Month(), 1, 0, 0, 0, 0, usecase.location)
+	start := monthStart.AddDate(0, -1, 0)
+	from := start.Format(statement.STATEMENT_DATE_LAYOUT)
+	to := monthStart.AddDate(0, 0, -1).Format(statement.STATEMENT_DATE_LAYOUT)
+
+	// paging by id, a wallet failing is left for the next run instead of being listed again
+	afterId := ""
+	for {
+		walletIds, err := usecase.statementRepository.GetWalletIdsWithoutStatements(ctx, from, to, afterId, walletsWithoutStatementsPage)
+		if err != nil {
+			infrastructure.LogError(ctx, "got error on usecase.statementRepository.GetWalletIdsWithoutStatements() - GenerateMonthlyStatements", err)
+			return err
+		}
+
+		for _, walletId := range walletIds {
+			if err := usecase.generateStatements(ctx, walletId, from, to, start, monthStart, now); err != nil {
+				infrastructure.LogError(ctx, "got error on usecase.generateStatements() - GenerateMonthlyStatements", err, "wallet_id", walletId)
+			}
+		}
+
+		if len(walletIds) < walletsWithoutStatementsPage {
+			return nil
+		}
+		afterId = walletIds[len(walletIds)-1]
+	}
+}
+
+func (usecase *statementUsecase) RunStatementJob(ctx context.Context) {
+	ticker := time.NewTicker(usecase.config.STATEMENT_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := usecase.GenerateMonthlyStatements(ctx, now); err != nil {
+				infrastructure.LogError(ctx, "got error on usecase.GenerateMonthlyStatements() - RunStatementJob", err)
+			}
+		}
+	}
+}
+
+// generateStatements stores the period in every format, the formats stored already are kept
+func (usecase *statementUsecase) generateStatements(ctx context.Context, walletId string, from string, to string, start time.Time, end time.Time, now time.Time) (err error) {
+	statementData, err := usecase.buildStatement(ctx, walletId, from, to, start, end)
+	if err != nil {
+		return err
+	}
+
+	for _, format := range statement.StatementFormats {
+		document, err := renderStatement(statementData, format, now.Format(time.RFC3339))
+		if err != nil {
+			return err
+		}
+
+		if _, err = usecase.storeStatementDocument(ctx, document); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (usecase *statementUsecase) buildStatement(ctx context.Context, walletId string, from string, to string, start time.Time, end time.Time) (res statement.Statement, err error) {
+	opening, err := usecase.statementRepository.GetBalanceBefore(ctx, walletId, start)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.statementRepository.GetBalanceBefore() - buildStatement", err)
+		return res, err
+	}
+
+	transactions, err := usecase.statementRepository.GetTransactionsBetween(ctx, walletId, start, end)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.statementRepository.GetTransactionsBetween() - buildStatement", err)
+		return res, err
+	}
+
+	return buildStatement(walletId, from, to, usecase.location.String(), opening, transactions), nil
+}
+
+// storeStatementDocument returns the document stored first when another request or instance got there before
+func (usecase *statementUsecase) storeStatementDocument(ctx context.Context, document statement.StatementDocument) (res statement.StatementDocument, err error) {
+	inserted, err := usecase.statementRepository.InsertStatementDocument(ctx, document)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.statementRepository.InsertStatementDocument() - storeStatementDocument", err)
+		return res, err
+	}
+
+	if inserted {
+		return document, nil
+	}
+
+	stored, err := usecase.statementRepository.GetStatementDocument(ctx, document.WalletId, document.PeriodFrom, document.PeriodTo, document.Format)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.statementRepository.GetStatementDocument() - storeStatementDocument", err)
+		return res, err
+	}
+
+	if stored == nil {
+		return document, nil
+	}
+
+	return *stored, nil
+}
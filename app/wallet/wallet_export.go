@@ -0,0 +1,117 @@
+package wallet
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mini-wallet/domain/wallet"
+)
+
+// transactionExporter writes the selected columns of every transaction, buffered until End
+type transactionExporter interface {
+	Begin() error
+	Write(walletTransaction *wallet.WalletTransactionEntity) error
+	End() error
+}
+
+func newTransactionExporter(format string, columns []string, w io.Writer) transactionExporter {
+	if len(columns) == 0 {
+		columns = wallet.WalletTransactionColumns
+	}
+
+	if format == wallet.WALLET_EXPORT_FORMAT_NDJSON {
+		return &ndjsonTransactionExporter{
+			writer:  bufio.NewWriter(w),
+			columns: columns,
+		}
+	}
+
+	return &csvTransactionExporter{
+		writer:  csv.NewWriter(w),
+		columns: columns,
+		record:  make([]string, len(columns)),
+	}
+}
+
+type csvTransactionExporter struct {
+	writer  *csv.Writer
+	columns []string
+	record  []string
+}
+
+// Begin writes the header row
+func (exporter *csvTransactionExporter) Begin() error {
+	return exporter.writer.Write(exporter.columns)
+}
+
+func (exporter *csvTransactionExporter) Write(walletTransaction *wallet.WalletTransactionEntity) error {
+	for i, column := range exporter.columns {
+		exporter.record[i] = fmt.Sprint(walletTransactionColumnValue(walletTransaction, column))
+	}
+
+	return exporter.writer.Write(exporter.record)
+}
+
+func (exporter *csvTransactionExporter) End() error {
+	exporter.writer.Flush()
+	return exporter.writer.Error()
+}
+
+// ndjsonTransactionExporter writes one object per line, its keys in the order of the selected columns
+type ndjsonTransactionExporter struct {
+	writer  *bufio.Writer
+	columns []string
+}
+
+func (exporter *ndjsonTransactionExporter) Begin() error {
+	return nil
+}
+
+func (exporter *ndjsonTransactionExporter) Write(walletTransaction *wallet.WalletTransactionEntity) error {
+	exporter.writer.WriteByte('{')
+	for i, column := range exporter.columns {
+		value, err := json.Marshal(walletTransactionColumnValue(walletTransaction, column))
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			exporter.writer.WriteByte(',')
+		}
+		fmt.Fprintf(exporter.writer, "%q:", column)
+		exporter.writer.Write(value)
+	}
+	_, err := exporter.writer.WriteString("}\n")
+
+	return err
+}
+
+func (exporter *ndjsonTransactionExporter) End() error {
+	return exporter.writer.Flush()
+}
+
+// walletTransactionColumnValue reads one of wallet.WalletTransactionColumns
+func walletTransactionColumnValue(walletTransaction *wallet.WalletTransactionEntity, column string) interface{} {
+	switch column {
+	case "id":
+		return walletTransaction.Id
+	case "wallet_id":
+		return walletTransaction.WalletId
+	case "type":
+		return walletTransaction.Type
+	case "amount":
+		return walletTransaction.Amount
+	case "status":
+		return walletTransaction.Status
+	case "reference_id":
+		return walletTransaction.ReferenceId
+	case "created_at":
+		return walletTransaction.CreatedAt
+	case "created_by":
+		return walletTransaction.CreatedBy
+	}
+
+	return nil
+}
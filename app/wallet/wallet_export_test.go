@@ -0,0 +1,84 @@
+package wallet
+
+import (
+	"bytes"
+	"mini-wallet/domain/wallet"
+	"testing"
+)
+
+func exportTestTransactions() []wallet.WalletTransactionEntity {
+	return []wallet.WalletTransactionEntity{
+		{Id: "t1", WalletId: "w1", Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: 100, Status: wallet.WALLET_TRANSACTION_STATUS_SUCCESS, ReferenceId: "r,1", CreatedAt: "2026-09-01T10:00:00Z", CreatedBy: "c1"},
+		{Id: "t2", WalletId: "w1", Type: wallet.WALLET_TRANSACTION_WITHDRAWAL, Amount: 40, Status: wallet.WALLET_TRANSACTION_STATUS_SUCCESS, ReferenceId: "r\"2", CreatedAt: "2026-09-02T10:00:00Z", CreatedBy: "c1"},
+	}
+}
+
+func runExport(t *testing.T, format string, columns []string) string {
+	var buffer bytes.Buffer
+	exporter := newTransactionExporter(format, columns, &buffer)
+
+	if err := exporter.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	for _, walletTransaction := range exportTestTransactions() {
+		if err := exporter.Write(&walletTransaction); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := exporter.End(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.String()
+}
+
+func TestCsvExport(t *testing.T) {
+	got := runExport(t, wallet.WALLET_EXPORT_FORMAT_CSV, []string{"amount", "reference_id", "type"})
+	want := "amount,reference_id,type\n100,\"r,1\",deposit\n40,\"r\"\"2\",withdrawal\n"
+	if got != want {
+		t.Fatalf("csv export = %q, want %q", got, want)
+	}
+}
+
+func TestCsvExportEveryColumn(t *testing.T) {
+	got := runExport(t, wallet.WALLET_EXPORT_FORMAT_CSV, nil)
+	want := "id,wallet_id,type,amount,status,reference_id,created_at,created_by\n" +
+		"t1,w1,deposit,100,success,\"r,1\",2026-09-01T10:00:00Z,c1\n" +
+		"t2,w1,withdrawal,40,success,\"r\"\"2\",2026-09-02T10:00:00Z,c1\n"
+	if got != want {
+		t.Fatalf("csv export = %q, want %q", got, want)
+	}
+}
+
+func TestNdjsonExport(t *testing.T) {
+	got := runExport(t, wallet.WALLET_EXPORT_FORMAT_NDJSON, []string{"id", "amount", "reference_id"})
+	want := "{\"id\":\"t1\",\"amount\":100,\"reference_id\":\"r,1\"}\n{\"id\":\"t2\",\"amount\":40,\"reference_id\":\"r\\\"2\"}\n"
+	if got != want {
+		t.Fatalf("ndjson export = %q, want %q", got, want)
+	}
+}
+
+func TestExportRequestValidate(t *testing.T) {
+	withdrawal := wallet.WALLET_TRANSACTION_WITHDRAWAL
+	unknown := "refund"
+
+	valid := wallet.WalletTransactionExportRequest{
+		Filter:  wallet.GetWalletTransactionRequest{Type: &withdrawal},
+		Format:  wallet.WALLET_EXPORT_FORMAT_NDJSON,
+		Columns: []string{"id", "amount"},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, req := range []wallet.WalletTransactionExportRequest{
+		{Format: "xlsx"},
+		{Format: wallet.WALLET_EXPORT_FORMAT_CSV, Columns: []string{"id", "balance"}},
+		{Format: wallet.WALLET_EXPORT_FORMAT_CSV, Columns: []string{"id", "id"}},
+		{Format: wallet.WALLET_EXPORT_FORMAT_CSV, Filter: wallet.GetWalletTransactionRequest{Type: &unknown}},
+	} {
+		if err := req.Validate(); err == nil {
+			t.Fatalf("%+v should be refused", req)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package wallet
+
+import (
+	"context"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/proto/walletpb"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+type walletGrpcServer struct {
+	walletpb.UnimplementedWalletServiceServer
+	walletUsecase wallet.WalletUsecase
+}
+
+// SetWalletGrpcServer serves the main pocket of the token like the /api/v1/wallet routes without a pocket_id,
+// the auth interceptors of the server set its "walletId"
+func SetWalletGrpcServer(server *grpc.Server, usecases domain.Usecases) {
+	walletpb.RegisterWalletServiceServer(server, &walletGrpcServer{
+		walletUsecase: usecases.WalletUsecase,
+	})
+}
+
+func (server *walletGrpcServer) EnableWallet(ctx context.Context, req *walletpb.EnableWalletRequest) (*walletpb.Wallet, error) {
+	walletId := ctx.Value("walletId")
+
+	result, err := server.walletUsecase.EnableWallet(ctx, walletId.(string))
+	if err != nil {
+		return nil, response.GRPCError(err)
+	}
+
+	return toWalletMessage(result.Data), nil
+}
+
+func (server *walletGrpcServer) DisableWallet(ctx context.Context, req *walletpb.DisableWalletRequest) (*walletpb.Wallet, error) {
+	walletId := ctx.Value("walletId")
+
+	result, err := server.walletUsecase.DisableWallet(ctx, walletId.(string))
+	if err != nil {
+		return nil, response.GRPCError(err)
+	}
+
+	return toWalletMessage(result.Data), nil
+}
+
+func (server *walletGrpcServer) GetWalletBalance(ctx context.Context, req *walletpb.GetWalletBalanceRequest) (*walletpb.Wallet, error) {
+	walletId := ctx.Value("walletId")
+
+	result, err := server.walletUsecase.GetWalletBalance(ctx, walletId.(string))
+	if err != nil {
+		return nil, response.GRPCError(err)
+	}
+
+	return toWalletMessage(result.Data), nil
+}
+
+func (server *walletGrpcServer) CreateTransaction(ctx context.Context, req *walletpb.CreateTransactionRequest) (*walletpb.Wallet, error) {
+	walletId := ctx.Value("walletId")
+	transactionRequest := wallet.WalletTransactionRequest{
+		WalletId:    walletId.(string),
+		Type:        fromTransactionType(req.GetType()),
+		Amount:      int(req.GetAmount()),
+		ReferenceId: req.GetReferenceId(),
+		Timestamp:   int(time.Now().Unix()),
+	}
+
+	if err := transactionRequest.Validate(); err != nil {
+		return nil, response.GRPCError(err)
+	}
+
+	result, err := server.walletUsecase.CreateWalletTransaction(ctx, transactionRequest)
+	if err != nil {
+		return nil, response.GRPCError(err)
+	}
+
+	return toWalletMessage(result.Data), nil
+}
+
+func (server *walletGrpcServer) ListTransactions(ctx context.Context, req *walletpb.ListTransactionsRequest) (*walletpb.ListTransactionsResponse, error) {
+	walletId := ctx.Value("walletId")
+	filter := wallet.GetWalletTransactionRequest{
+		WalletId:  walletId.(string),
+		Type:      optionalString(fromTransactionType(req.GetType())),
+		CreatedBy: optionalString(req.GetCreatedBy()),
+	}
+
+	var err error
+	if filter.From, err = optionalTime(req.GetFrom()); err != nil {
+		return nil, response.GRPCError(err)
+	}
+	if filter.To, err = optionalTime(req.GetTo()); err != nil {
+		return nil, response.GRPCError(err)
+	}
+
+	result, err := server.walletUsecase.GetWalletTransactions(ctx, filter)
+	if err != nil {
+		return nil, response.GRPCError(err)
+	}
+
+	res := &walletpb.ListTransactionsResponse{}
+	for _, walletTransaction := range *result.Data {
+		res.Transactions = append(res.Transactions, toListedTransactionMessage(filter.WalletId, walletTransaction))
+	}
+
+	return res, nil
+}
+
+func (server *walletGrpcServer) StreamTransactionEvents(req *walletpb.StreamTransactionEventsRequest, stream walletpb.WalletService_StreamTransactionEventsServer) error {
+	ctx := stream.Context()
+	walletId := ctx.Value("walletId")
+
+	err := server.walletUsecase.SubscribeWalletTransactions(ctx, walletId.(string), func(walletTransaction wallet.WalletTransactionEntity) error {
+		return stream.Send(toTransactionMessage(walletTransaction))
+	})
+
+	return response.GRPCError(err)
+}
+
+func toWalletMessage(walletResult *wallet.Wallet) *walletpb.Wallet {
+	res := &walletpb.Wallet{
+		Id:      walletResult.Id,
+		OwnedBy: walletResult.OwnedBy,
+		Name:    walletResult.Name,
+		Balance: int64(walletResult.Balance),
+		Status:  walletResult.Status,
+	}
+	if walletResult.EnabledAt != nil {
+		res.EnabledAt = *walletResult.EnabledAt
+	}
+
+	return res
+}
+
+func toTransactionMessage(walletTransaction wallet.WalletTransactionEntity) *walletpb.Transaction {
+	return &walletpb.Transaction{
+		Id:          walletTransaction.Id,
+		WalletId:    walletTransaction.WalletId,
+		Type:        toTransactionType(walletTransaction.Type),
+		Amount:      int64(walletTransaction.Amount),
+		Status:      walletTransaction.Status,
+		ReferenceId: walletTransaction.ReferenceId,
+		CreatedAt:   walletTransaction.CreatedAt,
+		CreatedBy:   walletTransaction.CreatedBy,
+	}
+}
+
+// toListedTransactionMessage reads the type of the listed transaction from whether it was deposited or withdrawn
+func toListedTransactionMessage(walletId string, walletTransaction wallet.WalletTransaction) *walletpb.Transaction {
+	res := &walletpb.Transaction{
+		Id:          walletTransaction.Id,
+		WalletId:    walletId,
+		Amount:      int64(walletTransaction.Amount),
+		Status:      walletTransaction.Status,
+		ReferenceId: walletTransaction.ReferenceId,
+	}
+
+	if walletTransaction.DepositedAt != nil {
+		res.Type = walletpb.TransactionType_TRANSACTION_TYPE_DEPOSIT
+		res.CreatedAt = *walletTransaction.DepositedAt
+		if walletTransaction.DepositedBy != nil {
+			res.CreatedBy = *walletTransaction.DepositedBy
+		}
+	} else if walletTransaction.WithdrawnAt != nil {
+		res.Type = walletpb.TransactionType_TRANSACTION_TYPE_WITHDRAWAL
+		res.CreatedAt = *walletTransaction.WithdrawnAt
+		if walletTransaction.WithdrawnBy != nil {
+			res.CreatedBy = *walletTransaction.WithdrawnBy
+		}
+	}
+
+	return res
+}
+
+func toTransactionType(transactionType string) walletpb.TransactionType {
+	switch transactionType {
+	case wallet.WALLET_TRANSACTION_DEPOSIT:
+		return walletpb.TransactionType_TRANSACTION_TYPE_DEPOSIT
+	case wallet.WALLET_TRANSACTION_WITHDRAWAL:
+		return walletpb.TransactionType_TRANSACTION_TYPE_WITHDRAWAL
+	}
+
+	return walletpb.TransactionType_TRANSACTION_TYPE_UNSPECIFIED
+}
+
+// fromTransactionType is empty for TRANSACTION_TYPE_UNSPECIFIED
+func fromTransactionType(transactionType walletpb.TransactionType) string {
+	switch transactionType {
+	case walletpb.TransactionType_TRANSACTION_TYPE_DEPOSIT:
+		return wallet.WALLET_TRANSACTION_DEPOSIT
+	case walletpb.TransactionType_TRANSACTION_TYPE_WITHDRAWAL:
+		return wallet.WALLET_TRANSACTION_WITHDRAWAL
+	}
+
+	return ""
+}
@@ -0,0 +1,166 @@
+package wallet
+
+import (
+	"context"
+	"mini-wallet/app/auth"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"mini-wallet/proto/walletpb"
+	"net"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type grpcTestAuthRepository struct {
+	tokens map[string]string
+}
+
+func (repository *grpcTestAuthRepository) AddToken(ctx context.Context, token string, walletId string) (err error) {
+	repository.tokens[token] = walletId
+	return nil
+}
+
+func (repository *grpcTestAuthRepository) GetTokenWalletId(ctx context.Context, token string) (walletId string, err error) {
+	return repository.tokens[token], nil
+}
+
+func (repository *grpcTestAuthRepository) RevokeToken(ctx context.Context, token string) (err error) {
+	delete(repository.tokens, token)
+	return nil
+}
+
+// grpcTestWalletUsecase answers from a single wallet, the transactions sent on events are yielded to the subscriber
+type grpcTestWalletUsecase struct {
+	wallet.WalletUsecase
+	wallet wallet.Wallet
+	events chan wallet.WalletTransactionEntity
+}
+
+func (usecase *grpcTestWalletUsecase) GetWalletBalance(ctx context.Context, walletId string) (res *response.Response[wallet.Wallet], err error) {
+	if walletId != usecase.wallet.Id {
+		return nil, response.ErrWalletNotFound
+	}
+
+	return &response.Response[wallet.Wallet]{Data: &usecase.wallet}, nil
+}
+
+func (usecase *grpcTestWalletUsecase) CreateWalletTransaction(ctx context.Context, req wallet.WalletTransactionRequest) (res *response.Response[wallet.Wallet], err error) {
+	walletResult := usecase.wallet
+	if err = walletResult.ApplyTransaction(wallet.WalletTransactionEntity{Type: req.Type, Amount: req.Amount}); err != nil {
+		return nil, err
+	}
+
+	return &response.Response[wallet.Wallet]{Data: &walletResult}, nil
+}
+
+func (usecase *grpcTestWalletUsecase) SubscribeWalletTransactions(ctx context.Context, walletId string, yield func(walletTransaction wallet.WalletTransactionEntity) error) (err error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case walletTransaction := <-usecase.events:
+			if err = yield(walletTransaction); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func TestWalletGrpcServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	walletUsecase := &grpcTestWalletUsecase{
+		wallet: wallet.Wallet{Id: "wallet-1", OwnedBy: "customer-1", Name: wallet.WALLET_MAIN_POCKET, Balance: 100, Status: wallet.WALLET_STATUS_ENABLED},
+		events: make(chan wallet.WalletTransactionEntity),
+	}
+	authRepository := &grpcTestAuthRepository{tokens: map[string]string{"token-1": "wallet-1"}}
+	usecases := domain.Usecases{
+		WalletUsecase: walletUsecase,
+		AuthUsecase:   auth.NewAuthUsecase(domain.Repositories{AuthRepository: authRepository}, infrastructure.Config{}),
+	}
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(usecases.AuthUsecase.AuthorizeUnaryInterceptor),
+		grpc.StreamInterceptor(usecases.AuthUsecase.AuthorizeStreamInterceptor),
+	)
+	SetWalletGrpcServer(server, usecases)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := walletpb.NewWalletServiceClient(conn)
+
+	if _, err = client.GetWalletBalance(ctx, &walletpb.GetWalletBalanceRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("without a token = %v, want Unauthenticated", err)
+	}
+
+	unknownCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer unknown")
+	if _, err = client.GetWalletBalance(unknownCtx, &walletpb.GetWalletBalanceRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("with an unknown token = %v, want Unauthenticated", err)
+	}
+
+	authorizedCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer token-1")
+	balance, err := client.GetWalletBalance(authorizedCtx, &walletpb.GetWalletBalanceRequest{})
+	if err != nil || balance.Id != "wallet-1" || balance.Balance != 100 {
+		t.Errorf("balance = %v %v, want wallet-1 with 100", balance, err)
+	}
+
+	deposited, err := client.CreateTransaction(authorizedCtx, &walletpb.CreateTransactionRequest{
+		Type: walletpb.TransactionType_TRANSACTION_TYPE_DEPOSIT, Amount: 50, ReferenceId: "ref-1",
+	})
+	if err != nil || deposited.Balance != 150 {
+		t.Errorf("deposit = %v %v, want a balance of 150", deposited, err)
+	}
+
+	_, err = client.CreateTransaction(authorizedCtx, &walletpb.CreateTransactionRequest{
+		Type: walletpb.TransactionType_TRANSACTION_TYPE_WITHDRAWAL, Amount: 500, ReferenceId: "ref-2",
+	})
+	if reason := errorInfoReason(err); status.Code(err) != codes.FailedPrecondition || reason != "insufficient_fund" {
+		t.Errorf("withdrawing more than the balance = %s %q, want FailedPrecondition insufficient_fund", status.Code(err), reason)
+	}
+
+	_, err = client.CreateTransaction(authorizedCtx, &walletpb.CreateTransactionRequest{Type: walletpb.TransactionType_TRANSACTION_TYPE_DEPOSIT})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("a deposit without amount = %v, want InvalidArgument", err)
+	}
+
+	stream, err := client.StreamTransactionEvents(authorizedCtx, &walletpb.StreamTransactionEventsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	walletUsecase.events <- wallet.WalletTransactionEntity{
+		Id: "transaction-1", WalletId: "wallet-1", Type: wallet.WALLET_TRANSACTION_WITHDRAWAL, Amount: 20, ReferenceId: "ref-3",
+	}
+	event, err := stream.Recv()
+	if err != nil || event.Id != "transaction-1" || event.Type != walletpb.TransactionType_TRANSACTION_TYPE_WITHDRAWAL || event.Amount != 20 {
+		t.Errorf("event = %v %v, want the withdrawal transaction-1 of 20", event, err)
+	}
+}
+
+func errorInfoReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if errorInfo, ok := detail.(*errdetails.ErrorInfo); ok {
+			return errorInfo.Reason
+		}
+	}
+
+	return ""
+}
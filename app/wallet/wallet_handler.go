@@ -1,12 +1,15 @@
 package wallet
 
 import (
+	"fmt"
 	"mini-wallet/domain"
 	"mini-wallet/domain/auth"
+	"mini-wallet/domain/common/request"
 	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/member"
 	"mini-wallet/domain/wallet"
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -15,29 +18,51 @@ import (
 type walletHandler struct {
 	walletUsecase wallet.WalletUsecase
 	authUsecase   auth.AuthUsecase
+	memberUsecase member.MemberUsecase
 }
 
 func SetWalletHandler(router *chi.Mux, usecases domain.Usecases) {
 	walletHandler := walletHandler{
 		walletUsecase: usecases.WalletUsecase,
 		authUsecase:   usecases.AuthUsecase,
+		memberUsecase: usecases.MemberUsecase,
 	}
 
+	anyRole := usecases.AuthUsecase.RequireRoleMiddleware(member.MEMBER_ROLE_OWNER, member.MEMBER_ROLE_SPENDER, member.MEMBER_ROLE_VIEWER)
+	spenders := usecases.AuthUsecase.RequireRoleMiddleware(member.MEMBER_ROLE_OWNER, member.MEMBER_ROLE_SPENDER)
+	owner := usecases.AuthUsecase.RequireRoleMiddleware(member.MEMBER_ROLE_OWNER)
+
 	router.Route("/api/v1/wallet", func(r chi.Router) {
+		// before SelectWalletMiddleware parses the form
+		r.Use(request.BodyLimitMiddleware)
 		r.Use(usecases.AuthUsecase.AuthorizeRequestMiddleware)
+		// every endpoint acts on the main pocket unless pocket_id names another pocket or a shared wallet
+		r.Use(usecases.AuthUsecase.SelectWalletMiddleware)
 
 		// GET
-		r.Get("/", walletHandler.GetWalletBalance)
-		r.Get("/transactions", walletHandler.GetWalletTransactions)
+		r.With(anyRole).Get("/", walletHandler.GetWalletBalance)
+		r.With(anyRole).Get("/transactions", walletHandler.GetWalletTransactions)
+		r.With(anyRole).Get("/transactions/export", walletHandler.ExportWalletTransactions)
+		r.With(owner).Get("/pockets", walletHandler.GetPockets)
 
 		// POST
-		r.Post("/", walletHandler.EnableWallet)
-		r.Post("/deposits", walletHandler.CreateWalletDepositTransaction)
-		r.Post("/withdrawals", walletHandler.CreateWalletWithdrawalTransaction)
+		r.With(owner).Post("/", walletHandler.EnableWallet)
+		r.With(spenders).Post("/deposits", walletHandler.CreateWalletDepositTransaction)
+		r.With(spenders).Post("/withdrawals", walletHandler.CreateWalletWithdrawalTransaction)
+		r.With(owner).Post("/pockets", walletHandler.CreatePocket)
+		r.With(owner).Post("/pockets/moves", walletHandler.MovePocketBalance)
 
 		// PATCH
-		r.Patch("/", walletHandler.DisableWallet)
+		r.With(owner).Patch("/", walletHandler.DisableWallet)
+
+	})
 
+	// the history of every wallet, or of the one given as wallet_id, for finance holding the admin token
+	router.Route("/api/v1/transactions", func(r chi.Router) {
+		r.Use(usecases.AuthUsecase.AuthorizeAdminMiddleware)
+
+		// GET
+		r.Get("/export", walletHandler.ExportTransactions)
 	})
 
 }
@@ -49,11 +74,9 @@ func (handler *walletHandler) GetWalletBalance(w http.ResponseWriter, r *http.Re
 	result, err := handler.walletUsecase.GetWalletBalance(r.Context(), walletId.(string))
 	if err != nil {
 		errResp := &response.Response[response.Error]{
-			Data: &response.Error{
-				Error: err.Error(),
-			},
+			Data: response.NewError(err),
 		}
-		errResp.Error(err.Error())
+		errResp.Error(err)
 		errResp.WriteResponse(w)
 		return
 	}
@@ -70,11 +93,9 @@ func (handler *walletHandler) EnableWallet(w http.ResponseWriter, r *http.Reques
 	result, err := handler.walletUsecase.EnableWallet(r.Context(), walletId.(string))
 	if err != nil {
 		errResp := &response.Response[response.Error]{
-			Data: &response.Error{
-				Error: err.Error(),
-			},
+			Data: response.NewError(err),
 		}
-		errResp.Error(err.Error())
+		errResp.Error(err)
 		errResp.WriteResponse(w)
 		return
 	}
@@ -90,11 +111,9 @@ func (handler *walletHandler) DisableWallet(w http.ResponseWriter, r *http.Reque
 	result, err := handler.walletUsecase.DisableWallet(r.Context(), walletId.(string))
 	if err != nil {
 		errResp := &response.Response[response.Error]{
-			Data: &response.Error{
-				Error: err.Error(),
-			},
+			Data: response.NewError(err),
 		}
-		errResp.Error(err.Error())
+		errResp.Error(err)
 		errResp.WriteResponse(w)
 		return
 	}
@@ -107,40 +126,76 @@ func (handler *walletHandler) DisableWallet(w http.ResponseWriter, r *http.Reque
 
 func (handler *walletHandler) CreateWalletDepositTransaction(w http.ResponseWriter, r *http.Request) {
 	walletId := r.Context().Value("walletId")
-	req := wallet.WalletTransactionRequest{
-		WalletId: walletId.(string),
+	memberId := r.Context().Value("memberId")
+	req := wallet.WalletTransactionRequest{}
+
+	err := request.Decode(w, r, &req)
+	if err == nil {
+		req.WalletId = walletId.(string)
+		req.CreatedBy = memberId.(string)
+		req.Type = wallet.WALLET_TRANSACTION_DEPOSIT
+		req.Timestamp = int(time.Now().Unix())
+		err = req.Validate()
+	}
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
 	}
 
-	transactionAmount := r.FormValue("amount")
-	referenceId := r.FormValue("reference_id")
-	transactionAmountInt, err := strconv.Atoi(transactionAmount)
+	result, err := handler.walletUsecase.CreateWalletTransaction(r.Context(), req)
 	if err != nil {
-		transactionAmountInt = int(0)
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
 	}
 
-	req.Amount = transactionAmountInt
-	req.ReferenceId = referenceId
-	req.Type = wallet.WALLET_TRANSACTION_DEPOSIT
-	req.Timestamp = int(time.Now().Unix())
-	if err = req.Validate(); err != nil {
+	resp := &response.Response[wallet.Wallet]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *walletHandler) CreateWalletWithdrawalTransaction(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+	memberId := r.Context().Value("memberId")
+	req := wallet.WalletTransactionRequest{}
+
+	err := request.Decode(w, r, &req)
+	if err == nil {
+		req.WalletId = walletId.(string)
+		req.CreatedBy = memberId.(string)
+		req.Type = wallet.WALLET_TRANSACTION_WITHDRAWAL
+		req.Timestamp = int(time.Now().Unix())
+		err = req.Validate()
+	}
+	if err != nil {
 		errResp := &response.Response[response.Error]{
-			Data: &response.Error{
-				Error: err.Error(),
-			},
+			Data: response.NewError(err),
 		}
-		errResp.Error(err.Error())
+		errResp.Error(err)
 		errResp.WriteResponse(w)
 		return
 	}
 
+	// a spender withdraws within their limits, maybe only once the owner approved
+	if r.Context().Value("memberRole") == member.MEMBER_ROLE_SPENDER {
+		handler.createMemberWithdrawal(w, r, req)
+		return
+	}
+
 	result, err := handler.walletUsecase.CreateWalletTransaction(r.Context(), req)
 	if err != nil {
 		errResp := &response.Response[response.Error]{
-			Data: &response.Error{
-				Error: err.Error(),
-			},
+			Data: response.NewError(err),
 		}
-		errResp.Error(err.Error())
+		errResp.Error(err)
 		errResp.WriteResponse(w)
 		return
 	}
@@ -151,42 +206,134 @@ func (handler *walletHandler) CreateWalletDepositTransaction(w http.ResponseWrit
 	resp.WriteResponse(w)
 }
 
-func (handler *walletHandler) CreateWalletWithdrawalTransaction(w http.ResponseWriter, r *http.Request) {
+// GetWalletTransactions reads the optional filters type, created_by, from and to (RFC 3339, to excluded) from the query
+func (handler *walletHandler) GetWalletTransactions(w http.ResponseWriter, r *http.Request) {
 	walletId := r.Context().Value("walletId")
-	req := wallet.WalletTransactionRequest{
-		WalletId: walletId.(string),
+
+	req, err := transactionFilterFromQuery(r, walletId.(string))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
 	}
 
-	transactionAmount := r.FormValue("amount")
-	referenceId := r.FormValue("reference_id")
-	transactionAmountInt, err := strconv.Atoi(transactionAmount)
+	result, err := handler.walletUsecase.GetWalletTransactions(r.Context(), req)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[[]wallet.WalletTransaction]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *walletHandler) ExportWalletTransactions(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+
+	handler.exportTransactions(w, r, walletId.(string))
+}
+
+func (handler *walletHandler) ExportTransactions(w http.ResponseWriter, r *http.Request) {
+	handler.exportTransactions(w, r, r.URL.Query().Get("wallet_id"))
+}
+
+// exportTransactions reads the filters of GetWalletTransactions, format (csv or ndjson, csv by default)
+// and columns (comma separated, every column by default) from the query, and streams the export as a download
+func (handler *walletHandler) exportTransactions(w http.ResponseWriter, r *http.Request, walletId string) {
+	req, err := exportRequestFromQuery(r, walletId)
+	if err == nil {
+		err = req.Validate()
+	}
 	if err != nil {
-		transactionAmountInt = int(0)
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	fileName := "transactions-all"
+	if walletId != "" {
+		fileName = "transactions-" + walletId
 	}
+	contentType := "text/csv"
+	if req.Format == wallet.WALLET_EXPORT_FORMAT_NDJSON {
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, fileName, req.Format))
 
-	req.Amount = transactionAmountInt
-	req.ReferenceId = referenceId
-	req.Type = wallet.WALLET_TRANSACTION_WITHDRAWAL
-	req.Timestamp = int(time.Now().Unix())
-	if err = req.Validate(); err != nil {
+	exportWriter := &exportResponseWriter{ResponseWriter: w}
+	err = handler.walletUsecase.ExportWalletTransactions(r.Context(), req, exportWriter)
+	if err != nil && !exportWriter.written {
+		w.Header().Del("Content-Disposition")
 		errResp := &response.Response[response.Error]{
-			Data: &response.Error{
-				Error: err.Error(),
-			},
+			Data: response.NewError(err),
 		}
-		errResp.Error(err.Error())
+		errResp.Error(err)
 		errResp.WriteResponse(w)
 		return
 	}
+	if err != nil {
+		// the status is sent already, breaking the connection tells the client the body is incomplete
+		panic(http.ErrAbortHandler)
+	}
+}
 
-	result, err := handler.walletUsecase.CreateWalletTransaction(r.Context(), req)
+func (handler *walletHandler) GetPockets(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+
+	result, err := handler.walletUsecase.GetPockets(r.Context(), walletId.(string))
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[[]wallet.Wallet]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *walletHandler) CreatePocket(w http.ResponseWriter, r *http.Request) {
+	walletId := r.Context().Value("walletId")
+	req := wallet.PocketCreationRequest{}
+
+	err := request.Decode(w, r, &req)
+	if err == nil {
+		req.WalletId = walletId.(string)
+		req.Name = strings.TrimSpace(req.Name)
+		err = req.Validate()
+	}
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	result, err := handler.walletUsecase.CreatePocket(r.Context(), req)
 	if err != nil {
 		errResp := &response.Response[response.Error]{
-			Data: &response.Error{
-				Error: err.Error(),
-			},
+			Data: response.NewError(err),
 		}
-		errResp.Error(err.Error())
+		errResp.Error(err)
 		errResp.WriteResponse(w)
 		return
 	}
@@ -194,26 +341,129 @@ func (handler *walletHandler) CreateWalletWithdrawalTransaction(w http.ResponseW
 	resp := &response.Response[wallet.Wallet]{}
 	resp = result
 	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.StatusCode = http.StatusCreated
 	resp.WriteResponse(w)
 }
 
-func (handler *walletHandler) GetWalletTransactions(w http.ResponseWriter, r *http.Request) {
+// MovePocketBalance moves amount from from_pocket_id, or else the selected pocket, to to_pocket_id
+func (handler *walletHandler) MovePocketBalance(w http.ResponseWriter, r *http.Request) {
 	walletId := r.Context().Value("walletId")
+	req := wallet.PocketMoveRequest{}
 
-	result, err := handler.walletUsecase.GetWalletTransactions(r.Context(), walletId.(string))
+	err := request.Decode(w, r, &req)
+	if err == nil {
+		req.WalletId = walletId.(string)
+		if req.FromPocketId == "" {
+			req.FromPocketId = req.WalletId
+		}
+		err = req.Validate()
+	}
 	if err != nil {
 		errResp := &response.Response[response.Error]{
-			Data: &response.Error{
-				Error: err.Error(),
-			},
+			Data: response.NewError(err),
 		}
-		errResp.Error(err.Error())
+		errResp.Error(err)
 		errResp.WriteResponse(w)
 		return
 	}
 
-	resp := &response.Response[[]wallet.WalletTransaction]{}
+	result, err := handler.walletUsecase.MovePocketBalance(r.Context(), req)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[wallet.PocketMove]{}
+	resp = result
+	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	resp.WriteResponse(w)
+}
+
+func (handler *walletHandler) createMemberWithdrawal(w http.ResponseWriter, r *http.Request, req wallet.WalletTransactionRequest) {
+	result, err := handler.memberUsecase.CreateMemberWithdrawal(r.Context(), req)
+	if err != nil {
+		errResp := &response.Response[response.Error]{
+			Data: response.NewError(err),
+		}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+		return
+	}
+
+	resp := &response.Response[member.MemberWithdrawal]{}
 	resp = result
 	resp.Success(response.STATUS_SUCCESS, *resp.Data)
+	if resp.Data.Approval != nil {
+		resp.StatusCode = http.StatusAccepted
+	}
 	resp.WriteResponse(w)
 }
+
+func transactionFilterFromQuery(r *http.Request, walletId string) (req wallet.GetWalletTransactionRequest, err error) {
+	query := r.URL.Query()
+	req = wallet.GetWalletTransactionRequest{
+		WalletId:  walletId,
+		Type:      optionalString(query.Get("type")),
+		CreatedBy: optionalString(query.Get("created_by")),
+	}
+
+	if req.From, err = optionalTime(query.Get("from")); err != nil {
+		return req, err
+	}
+	if req.To, err = optionalTime(query.Get("to")); err != nil {
+		return req, err
+	}
+
+	return req, nil
+}
+
+func exportRequestFromQuery(r *http.Request, walletId string) (req wallet.WalletTransactionExportRequest, err error) {
+	req = wallet.WalletTransactionExportRequest{
+		Format: r.URL.Query().Get("format"),
+	}
+	if req.Format == "" {
+		req.Format = wallet.WALLET_EXPORT_FORMAT_CSV
+	}
+	if columns := r.URL.Query().Get("columns"); columns != "" {
+		req.Columns = strings.Split(columns, ",")
+	}
+
+	req.Filter, err = transactionFilterFromQuery(r, walletId)
+	return req, err
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+func optionalTime(value string) (res *time.Time, err error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, response.ErrBadRequest
+	}
+
+	return &parsed, nil
+}
+
+// exportResponseWriter remembers whether the export reached the client, an error before that can still be answered
+type exportResponseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *exportResponseWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pocketRecorder keeps the last request reaching the usecase
+type pocketRecorder struct {
+	wallet.WalletUsecase
+	creation wallet.PocketCreationRequest
+	move     wallet.PocketMoveRequest
+}
+
+func (recorder *pocketRecorder) CreatePocket(ctx context.Context, req wallet.PocketCreationRequest) (*response.Response[wallet.Wallet], error) {
+	recorder.creation = req
+	return &response.Response[wallet.Wallet]{Data: &wallet.Wallet{Name: req.Name}}, nil
+}
+
+func (recorder *pocketRecorder) MovePocketBalance(ctx context.Context, req wallet.PocketMoveRequest) (*response.Response[wallet.PocketMove], error) {
+	recorder.move = req
+	return &response.Response[wallet.PocketMove]{Data: &wallet.PocketMove{}}, nil
+}
+
+func TestPocketHandlersDecodeTheBody(t *testing.T) {
+	recorder := &pocketRecorder{}
+	handler := &walletHandler{walletUsecase: recorder}
+
+	serve := func(handle http.HandlerFunc, contentType string, body string) (int, []byte) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		ctx := context.WithValue(r.Context(), "walletId", "main")
+		ctx = context.WithValue(ctx, "memberId", "")
+		handle(w, r.WithContext(ctx))
+
+		return w.Code, w.Body.Bytes()
+	}
+
+	if code, body := serve(handler.CreatePocket, "application/json", `{"name": " Savings "}`); code != http.StatusCreated || recorder.creation.Name != "Savings" {
+		t.Errorf("creating a pocket from json = %d %s, name %q", code, body, recorder.creation.Name)
+	}
+
+	want := wallet.PocketMoveRequest{WalletId: "main", FromPocketId: "main", ToPocketId: "savings", Amount: 100, ReferenceId: "move-1"}
+	if code, body := serve(handler.MovePocketBalance, "application/x-www-form-urlencoded", "to_pocket_id=savings&amount=100&reference_id=move-1"); code != http.StatusOK || recorder.move != want {
+		t.Errorf("moving from a form = %d %s, request %+v", code, body, recorder.move)
+	}
+	want.FromPocketId = "travel"
+	if code, body := serve(handler.MovePocketBalance, "application/json", `{"from_pocket_id": "travel", "to_pocket_id": "savings", "amount": 100, "reference_id": "move-1"}`); code != http.StatusOK || recorder.move != want {
+		t.Errorf("moving from json = %d %s, request %+v", code, body, recorder.move)
+	}
+
+	for name, testCase := range map[string]struct {
+		handle      http.HandlerFunc
+		contentType string
+		body        string
+		want        []response.FieldError
+	}{
+		"amount not a number": {
+			handler.MovePocketBalance, "application/x-www-form-urlencoded", "to_pocket_id=savings&amount=ten&reference_id=move-3",
+			[]response.FieldError{{Field: "amount", Message: "must be an integer"}},
+		},
+		"amount missing": {
+			handler.MovePocketBalance, "application/json", `{"to_pocket_id": "savings", "reference_id": "move-3"}`,
+			[]response.FieldError{{Field: "amount", Message: "is required"}},
+		},
+		"same pocket": {
+			handler.MovePocketBalance, "application/json", `{"to_pocket_id": "main", "amount": 100, "reference_id": "move-3"}`,
+			[]response.FieldError{{Field: "to_pocket_id", Message: "must differ from from_pocket_id"}},
+		},
+		"reference_id too long": {
+			handler.CreateWalletDepositTransaction, "application/json", `{"amount": 100, "reference_id": "` + strings.Repeat("r", 37) + `"}`,
+			[]response.FieldError{{Field: "reference_id", Message: "must be at most 36 characters"}},
+		},
+		"move reference_id too long": {
+			handler.MovePocketBalance, "application/json", `{"to_pocket_id": "savings", "amount": 100, "reference_id": "` + strings.Repeat("r", 37) + `"}`,
+			[]response.FieldError{{Field: "reference_id", Message: "must be at most 36 characters"}},
+		},
+		"name blank": {
+			handler.CreatePocket, "application/x-www-form-urlencoded", "name=++",
+			[]response.FieldError{{Field: "name", Message: "must be 1 to 50 characters"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			code, body := serve(testCase.handle, testCase.contentType, testCase.body)
+			if code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", code, http.StatusBadRequest)
+			}
+
+			errResp := response.Response[response.Error]{}
+			if err := json.Unmarshal(body, &errResp); err != nil || errResp.Data == nil {
+				t.Fatalf("body = %s %v, want an error", body, err)
+			}
+			if !reflect.DeepEqual(errResp.Data.Fields, testCase.want) {
+				t.Errorf("fields = %+v, want %+v", errResp.Data.Fields, testCase.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package wallet
+
+import (
+	"context"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"sync"
+	"time"
+)
+
+type memoryWalletLocker struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	locks     map[string]*memoryWalletLock
+	lastToken int64
+}
+
+// NewMemoryWalletLocker locks wallets within this process only,
+// for tests and deployments running a single instance
+func NewMemoryWalletLocker(ttl time.Duration) wallet.WalletLocker {
+	return &memoryWalletLocker{
+		ttl:   ttl,
+		locks: map[string]*memoryWalletLock{},
+	}
+}
+
+func (locker *memoryWalletLocker) Acquire(ctx context.Context, walletId string) (lock wallet.WalletLock, err error) {
+	for {
+		locker.mu.Lock()
+		heldLock, held := locker.locks[walletId]
+		if !held || heldLock.expired() {
+			acquiredLock := &memoryWalletLock{
+				locker:    locker,
+				walletId:  walletId,
+				token:     locker.nextToken(),
+				expiresAt: time.Now().Add(locker.ttl),
+				released:  make(chan struct{}),
+			}
+			locker.locks[walletId] = acquiredLock
+			locker.mu.Unlock()
+
+			return acquiredLock, nil
+		}
+		released, expiresIn := heldLock.released, time.Until(heldLock.expiresAt)
+		locker.mu.Unlock()
+
+		// wake up on release or on expiry, whichever comes first
+		timer := time.NewTimer(expiresIn)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, response.ErrWalletLocked
+		case <-released:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// nextToken is seeded with the current time, like the redis one,
+// so a restarted process keeps handing out tokens newer than the ones already written
+func (locker *memoryWalletLocker) nextToken() int64 {
+	token := time.Now().UnixNano()
+	if token <= locker.lastToken {
+		token = locker.lastToken + 1
+	}
+	locker.lastToken = token
+
+	return token
+}
+
+type memoryWalletLock struct {
+	locker   *memoryWalletLocker
+	walletId string
+	token    int64
+
+	// guarded by locker.mu
+	expiresAt time.Time
+	released  chan struct{}
+}
+
+func (lock *memoryWalletLock) Token() int64 {
+	return lock.token
+}
+
+func (lock *memoryWalletLock) Extend(ctx context.Context) (err error) {
+	lock.locker.mu.Lock()
+	defer lock.locker.mu.Unlock()
+
+	if !lock.held() {
+		return response.ErrWalletLockExpired
+	}
+	lock.expiresAt = time.Now().Add(lock.locker.ttl)
+
+	return nil
+}
+
+func (lock *memoryWalletLock) Release(ctx context.Context) (err error) {
+	lock.locker.mu.Lock()
+	defer lock.locker.mu.Unlock()
+
+	if lock.locker.locks[lock.walletId] != lock {
+		select {
+		case <-lock.released:
+			// released before
+			return nil
+		default:
+			return response.ErrWalletLockExpired
+		}
+	}
+
+	expired := lock.expired()
+	delete(lock.locker.locks, lock.walletId)
+	close(lock.released)
+	if expired {
+		return response.ErrWalletLockExpired
+	}
+
+	return nil
+}
+
+// held must be called with locker.mu held
+func (lock *memoryWalletLock) held() bool {
+	return lock.locker.locks[lock.walletId] == lock && !lock.expired()
+}
+
+func (lock *memoryWalletLock) expired() bool {
+	return !time.Now().Before(lock.expiresAt)
+}
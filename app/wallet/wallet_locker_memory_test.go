@@ -0,0 +1,116 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"mini-wallet/domain/common/response"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryWalletLockerIsMutuallyExclusive(t *testing.T) {
+	locker := NewMemoryWalletLocker(time.Second * 5)
+	ctx := context.Background()
+
+	var (
+		wg      sync.WaitGroup
+		holders int
+		counter int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := locker.Acquire(ctx, "wallet-a")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer lock.Release(ctx)
+
+			holders++
+			if holders != 1 {
+				t.Errorf("%d holders at once", holders)
+			}
+			counter++
+			holders--
+		}()
+	}
+	wg.Wait()
+
+	if counter != 50 {
+		t.Errorf("counter = %d, want 50", counter)
+	}
+}
+
+func TestMemoryWalletLockerAcquireHonoursContext(t *testing.T) {
+	locker := NewMemoryWalletLocker(time.Minute)
+	lock, err := locker.Acquire(context.Background(), "wallet-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	if _, err := locker.Acquire(ctx, "wallet-a"); err == nil || !errors.Is(err, response.ErrWalletLocked) {
+		t.Fatalf("err = %v, want %q", err, response.ERROR_WALLET_LOCKED)
+	}
+
+	// other wallets are not affected
+	otherLock, err := locker.Acquire(ctx, "wallet-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherLock.Release(ctx)
+}
+
+func TestMemoryWalletLockerExpiryHandsOverWithNewerToken(t *testing.T) {
+	locker := NewMemoryWalletLocker(time.Millisecond * 20)
+	ctx := context.Background()
+
+	staleLock, err := locker.Acquire(ctx, "wallet-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// waits for the expiry instead of a release
+	lock, err := locker.Acquire(ctx, "wallet-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release(ctx)
+
+	if lock.Token() <= staleLock.Token() {
+		t.Errorf("token %d is not newer than the expired %d", lock.Token(), staleLock.Token())
+	}
+	if err := staleLock.Extend(ctx); err == nil || !errors.Is(err, response.ErrWalletLockExpired) {
+		t.Errorf("extend of the expired lock: err = %v, want %q", err, response.ERROR_WALLET_LOCK_EXPIRED)
+	}
+	if err := staleLock.Release(ctx); err == nil || !errors.Is(err, response.ErrWalletLockExpired) {
+		t.Errorf("release of the expired lock: err = %v, want %q", err, response.ERROR_WALLET_LOCK_EXPIRED)
+	}
+	if err := lock.Extend(ctx); err != nil {
+		t.Errorf("extend of the held lock: %v", err)
+	}
+}
+
+func TestMemoryWalletLockReleaseIsIdempotent(t *testing.T) {
+	locker := NewMemoryWalletLocker(time.Minute)
+	ctx := context.Background()
+
+	lock, err := locker.Acquire(ctx, "wallet-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := lock.Release(ctx); err != nil {
+			t.Fatalf("release #%d: %v", i+1, err)
+		}
+	}
+
+	if _, err := locker.Acquire(ctx, "wallet-a"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,43 @@
+package wallet
+
+import (
+	"context"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"time"
+)
+
+type instrumentedWalletLocker struct {
+	walletLocker wallet.WalletLocker
+}
+
+// NewInstrumentedWalletLocker records the lock wait, failures and releases shown on /metrics and /debug/status
+func NewInstrumentedWalletLocker(walletLocker wallet.WalletLocker) wallet.WalletLocker {
+	return &instrumentedWalletLocker{
+		walletLocker: walletLocker,
+	}
+}
+
+func (locker *instrumentedWalletLocker) Acquire(ctx context.Context, walletId string) (lock wallet.WalletLock, err error) {
+	startedAt := time.Now()
+	lock, err = locker.walletLocker.Acquire(ctx, walletId)
+	if err != nil {
+		infrastructure.RecordLockFailed(time.Since(startedAt))
+		return nil, err
+	}
+	infrastructure.RecordLockAcquired(time.Since(startedAt))
+
+	return &instrumentedWalletLock{WalletLock: lock}, nil
+}
+
+type instrumentedWalletLock struct {
+	wallet.WalletLock
+}
+
+func (lock *instrumentedWalletLock) Release(ctx context.Context) (err error) {
+	if err = lock.WalletLock.Release(ctx); err == nil {
+		infrastructure.RecordLockReleased()
+	}
+
+	return err
+}
@@ -0,0 +1,120 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/go-redsync/redsync/v4"
+	"github.com/go-redsync/redsync/v4/redis/goredis"
+)
+
+const (
+	mutexKey = "wallet-%s"
+	fenceKey = "wallet-fence-%s"
+
+	lockRetryDelay = time.Millisecond * 50
+)
+
+type redisWalletLocker struct {
+	redisClient   redis.Client
+	mutexProvider *redsync.Redsync
+	ttl           time.Duration
+}
+
+// NewRedisWalletLocker locks wallets across every instance with redsync,
+// fencing tokens come from a per wallet INCR
+func NewRedisWalletLocker(redisClient redis.Client, ttl time.Duration) wallet.WalletLocker {
+	return &redisWalletLocker{
+		redisClient:   redisClient,
+		mutexProvider: redsync.New(goredis.NewPool(&redisClient)),
+		ttl:           ttl,
+	}
+}
+
+func (locker *redisWalletLocker) Acquire(ctx context.Context, walletId string) (lock wallet.WalletLock, err error) {
+	// the caller's ctx bounds the wait, not a number of tries
+	walletMutex := locker.mutexProvider.NewMutex(fmt.Sprintf(mutexKey, walletId),
+		redsync.WithExpiry(locker.ttl),
+		redsync.WithTries(math.MaxInt32),
+		redsync.WithRetryDelay(lockRetryDelay),
+	)
+
+	if err = walletMutex.LockContext(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil, response.ErrWalletLocked
+		}
+		return nil, err
+	}
+
+	token, err := locker.nextToken(ctx, walletId)
+	if err != nil {
+		walletMutex.UnlockContext(ctx)
+		return nil, err
+	}
+
+	return &redisWalletLock{
+		mutex: walletMutex,
+		token: token,
+	}, nil
+}
+
+// nextToken seeds the counter with the current time so a flushed redis still hands out tokens
+// newer than the ones already written to postgres
+func (locker *redisWalletLocker) nextToken(ctx context.Context, walletId string) (token int64, err error) {
+	client := locker.redisClient.WithContext(ctx)
+	walletFenceKey := fmt.Sprintf(fenceKey, walletId)
+
+	if err = client.SetNX(walletFenceKey, time.Now().UnixNano(), 0).Err(); err != nil {
+		return 0, err
+	}
+
+	return client.Incr(walletFenceKey).Result()
+}
+
+type redisWalletLock struct {
+	mutex *redsync.Mutex
+	token int64
+
+	releaseOnce sync.Once
+	releaseErr  error
+}
+
+func (lock *redisWalletLock) Token() int64 {
+	return lock.token
+}
+
+func (lock *redisWalletLock) Extend(ctx context.Context) (err error) {
+	ok, err := lock.mutex.ExtendContext(ctx)
+	if ok {
+		return nil
+	}
+
+	return lockLostOr(err)
+}
+
+func (lock *redisWalletLock) Release(ctx context.Context) (err error) {
+	lock.releaseOnce.Do(func() {
+		if ok, unlockErr := lock.mutex.UnlockContext(ctx); !ok {
+			lock.releaseErr = lockLostOr(unlockErr)
+		}
+	})
+
+	return lock.releaseErr
+}
+
+// lockLostOr tells a lock which expired or was taken over apart from redis being unreachable
+func lockLostOr(err error) error {
+	var redisErr *redsync.RedisError
+	if errors.As(err, &redisErr) {
+		return err
+	}
+
+	return response.ErrWalletLockExpired
+}
@@ -0,0 +1,52 @@
+package wallet
+
+import (
+	"context"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type tracedWalletLocker struct {
+	walletLocker wallet.WalletLocker
+}
+
+// NewTracedWalletLocker opens a span for the lock wait, extensions and the release
+func NewTracedWalletLocker(walletLocker wallet.WalletLocker) wallet.WalletLocker {
+	return &tracedWalletLocker{
+		walletLocker: walletLocker,
+	}
+}
+
+func (locker *tracedWalletLocker) Acquire(ctx context.Context, walletId string) (lock wallet.WalletLock, err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletLocker.Acquire", attribute.String("wallet.id", walletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	lock, err = locker.walletLocker.Acquire(ctx, walletId)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int64("wallet.lock_token", lock.Token()))
+
+	return &tracedWalletLock{WalletLock: lock, walletId: walletId}, nil
+}
+
+type tracedWalletLock struct {
+	wallet.WalletLock
+	walletId string
+}
+
+func (lock *tracedWalletLock) Extend(ctx context.Context) (err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletLock.Extend", attribute.String("wallet.id", lock.walletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return lock.WalletLock.Extend(ctx)
+}
+
+func (lock *tracedWalletLock) Release(ctx context.Context) (err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletLock.Release", attribute.String("wallet.id", lock.walletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return lock.WalletLock.Release(ctx)
+}
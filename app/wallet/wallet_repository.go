@@ -3,6 +3,8 @@ package wallet
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"mini-wallet/domain/common/response"
 	"mini-wallet/domain/wallet"
 	"mini-wallet/infrastructure"
 
@@ -11,6 +13,10 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	streamFetchSize = 1000 // rows fetched from the cursor at once by StreamWalletTransactions
+)
+
 type walletRepository struct {
 	db    *gorm.DB
 	cache infrastructure.Cache
@@ -23,17 +29,23 @@ func NewWalletRepository(db *gorm.DB, cache infrastructure.Cache) wallet.WalletR
 	}
 }
 
-func (walletRepository *walletRepository) GetWalletTransactionsByWalletId(ctx context.Context, walletId string, page int, size int) (res []wallet.WalletTransactionEntity, err error) {
-	builder := sq.Select("*").From("tr_wallet_transaction").Where(sq.Eq{"wallet_id": walletId}).Limit(uint64(size)).Offset(uint64((page - 1) * size))
+func (walletRepository *walletRepository) GetWalletTransactionsByWalletId(ctx context.Context, req wallet.GetWalletTransactionRequest, page int, size int) (res []wallet.WalletTransactionEntity, err error) {
+	if page < 1 || size < 1 {
+		return nil, response.ErrBadRequest
+	}
+
+	// newest first, the id settles the transactions made within the same second so the pages never overlap
+	builder := filterWalletTransactions(sq.Select("*").From("tr_wallet_transaction").Where(sq.Eq{"wallet_id": req.WalletId}), req).
+		OrderBy("created_at_timestamptz(created_at) DESC", "id DESC").
+		Limit(uint64(size)).Offset(uint64((page - 1) * size))
 	qry, args, err := builder.ToSql()
 	if err != nil {
 		return res, err
 	}
 
+	// Scan leaves res untouched without rows
+	res = []wallet.WalletTransactionEntity{}
 	err = walletRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
 	if err != nil {
 		return nil, err
 	}
@@ -41,17 +53,85 @@ func (walletRepository *walletRepository) GetWalletTransactionsByWalletId(ctx co
 	return res, nil
 }
 
-func (walletRepository *walletRepository) GetWalletTransactionByReferenceId(ctx context.Context, referenceId string) (res *wallet.WalletTransactionEntity, err error) {
-	builder := sq.Select("*").From("tr_wallet_transaction").Where(sq.Eq{"reference_id": referenceId})
+func (walletRepository *walletRepository) StreamWalletTransactions(ctx context.Context, req wallet.GetWalletTransactionRequest, yield func(walletTransaction wallet.WalletTransactionEntity) error) (err error) {
+	builder := sq.Select("*").From("tr_wallet_transaction")
+	if req.WalletId != "" {
+		builder = builder.Where(sq.Eq{"wallet_id": req.WalletId})
+	}
+	// ids are time ordered, they settle the transactions made within the same second
+	qry, args, err := filterWalletTransactions(builder, req).OrderBy("created_at_timestamptz(created_at)", "id").ToSql()
+	if err != nil {
+		return err
+	}
+
+	// a cursor only lives within its database transaction
+	return walletRepository.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DECLARE wallet_transaction_export NO SCROLL CURSOR FOR "+qry, args...).Error; err != nil {
+			return err
+		}
+
+		for {
+			var page []wallet.WalletTransactionEntity
+			if err := tx.Raw(fmt.Sprintf("FETCH %d FROM wallet_transaction_export", streamFetchSize)).Scan(&page).Error; err != nil {
+				return err
+			}
+
+			for _, walletTransaction := range page {
+				if err := yield(walletTransaction); err != nil {
+					return err
+				}
+			}
+
+			if len(page) < streamFetchSize {
+				return nil
+			}
+		}
+	}, &sql.TxOptions{ReadOnly: true})
+}
+
+// filterWalletTransactions applies the optional filters of req, the wallet is left to the caller
+func filterWalletTransactions(builder sq.SelectBuilder, req wallet.GetWalletTransactionRequest) sq.SelectBuilder {
+	if req.Type != nil {
+		builder = builder.Where(sq.Eq{"type": *req.Type})
+	}
+	if req.CreatedBy != nil {
+		builder = builder.Where(sq.Eq{"created_by": *req.CreatedBy})
+	}
+	// created_at is RFC 3339 text, compared as a timestamp so the offset it was written with does not matter.
+	// always through created_at_timestamptz, the indexes are on it and a bare cast would not use them
+	if req.From != nil {
+		builder = builder.Where("created_at_timestamptz(created_at) >= ?", *req.From)
+	}
+	if req.To != nil {
+		builder = builder.Where("created_at_timestamptz(created_at) < ?", *req.To)
+	}
+
+	return builder
+}
+
+func (walletRepository *walletRepository) GetWalletTransactionByReferenceId(ctx context.Context, walletId string, referenceId string) (res *wallet.WalletTransactionEntity, err error) {
+	builder := sq.Select("*").From("tr_wallet_transaction").Where(sq.Eq{"wallet_id": walletId, "reference_id": referenceId})
 	qry, args, err := builder.ToSql()
 	if err != nil {
 		return res, err
 	}
 
 	err = walletRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
-	if err == sql.ErrNoRows {
-		return nil, nil
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func (walletRepository *walletRepository) GetWalletTransactionById(ctx context.Context, transactionId string) (res *wallet.WalletTransactionEntity, err error) {
+	builder := sq.Select("*").From("tr_wallet_transaction").Where(sq.Eq{"id": transactionId})
+	qry, args, err := builder.ToSql()
+	if err != nil {
+		return res, err
 	}
+
+	err = walletRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
 	if err != nil {
 		return nil, err
 	}
@@ -70,13 +150,26 @@ func (walletRepository *walletRepository) CreateWalletTransaction(ctx context.Co
 	err = tx.WithContext(ctx).Table("tr_wallet_transaction").Create(walletTransaction).Error
 	if err != nil {
 		tx.Rollback()
-		return err
+		return translateConstraintViolation(err)
 	}
 
-	err = tx.WithContext(ctx).Table("ms_wallet").Where("id", walletTransaction.WalletId).Update("balance", updatedWallet.Balance).Error
-	if err != nil {
+	// the version guard turns a write based on a stale read into a conflict instead of a lost update,
+	// the fencing token does the same for a lock holder which outlived its lock
+	result := tx.WithContext(ctx).Table("ms_wallet").
+		Where("id = ? AND version = ? AND lock_token <= ?", walletTransaction.WalletId, updatedWallet.Version, updatedWallet.LockToken).
+		Updates(map[string]interface{}{
+			"balance":    updatedWallet.Balance,
+			"version":    gorm.Expr("version + 1"),
+			"lock_token": updatedWallet.LockToken,
+		})
+	if err = result.Error; err != nil {
 		tx.Rollback()
-		return err
+		return translateConstraintViolation(err)
+	}
+
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return response.ErrWalletConcurrent
 	}
 
 	res := tx.Commit()
@@ -87,6 +180,133 @@ func (walletRepository *walletRepository) CreateWalletTransaction(ctx context.Co
 	return nil
 }
 
+func (walletRepository *walletRepository) CreateWalletTransactionForUpdate(ctx context.Context, walletId string, apply func(lockedWallet *wallet.Wallet) (walletTransaction wallet.WalletTransactionEntity, err error)) (res *wallet.Wallet, err error) {
+	err = walletRepository.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		qry, args, err := sq.Select("*").From("ms_wallet").Where(sq.Eq{"id": walletId}).Suffix("FOR UPDATE").ToSql()
+		if err != nil {
+			return err
+		}
+
+		lockedWallet := wallet.Wallet{}
+		result := tx.Raw(qry, args...).Scan(&lockedWallet)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return response.ErrWalletNotFound
+		}
+
+		walletTransaction, err := apply(&lockedWallet)
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Table("tr_wallet_transaction").Create(walletTransaction).Error; err != nil {
+			return translateConstraintViolation(err)
+		}
+
+		lockedWallet.Version++
+		err = tx.Table("ms_wallet").Where("id", walletId).Updates(map[string]interface{}{
+			"balance": lockedWallet.Balance,
+			"version": lockedWallet.Version,
+		}).Error
+		if err != nil {
+			return translateConstraintViolation(err)
+		}
+
+		res = &lockedWallet
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (walletRepository *walletRepository) CreateWalletTransactionAtomically(ctx context.Context, walletTransaction wallet.WalletTransactionEntity) (res *wallet.Wallet, err error) {
+	err = walletRepository.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res, err = walletRepository.applyWalletTransaction(tx, walletTransaction)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (walletRepository *walletRepository) CreateWalletTransactionsAtomically(ctx context.Context, walletTransactions []wallet.WalletTransactionEntity) (err error) {
+	return walletRepository.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, walletTransaction := range walletTransactions {
+			if _, err := walletRepository.applyWalletTransaction(tx, walletTransaction); err != nil {
+				return &wallet.TransactionItemError{Index: i, Err: err}
+			}
+		}
+
+		return nil
+	})
+}
+
+// applyWalletTransaction moves the balance with a single conditional UPDATE and records the transaction, within tx
+func (walletRepository *walletRepository) applyWalletTransaction(tx *gorm.DB, walletTransaction wallet.WalletTransactionEntity) (res *wallet.Wallet, err error) {
+	delta := walletTransaction.BalanceDelta()
+
+	// status and funds are checked by postgres itself, the row lock is only held until commit
+	qry, args, err := sq.Update("ms_wallet").
+		Set("balance", sq.Expr("balance + ?", delta)).
+		Set("version", sq.Expr("version + 1")).
+		Where(sq.Eq{"id": walletTransaction.WalletId, "status": wallet.WALLET_STATUS_ENABLED}).
+		Where("balance + ? >= 0", delta).
+		Suffix("RETURNING *").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	updatedWallet := wallet.Wallet{}
+	result := tx.Raw(qry, args...).Scan(&updatedWallet)
+	if result.Error != nil {
+		return nil, translateConstraintViolation(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, walletRepository.explainRejectedUpdate(tx, walletTransaction.WalletId)
+	}
+
+	if walletTransaction.CreatedBy == "" {
+		walletTransaction.CreatedBy = updatedWallet.OwnedBy
+	}
+	if err = tx.Table("tr_wallet_transaction").Create(walletTransaction).Error; err != nil {
+		return nil, translateConstraintViolation(err)
+	}
+
+	return &updatedWallet, nil
+}
+
+// explainRejectedUpdate tells why the conditional update matched no row
+func (walletRepository *walletRepository) explainRejectedUpdate(tx *gorm.DB, walletId string) error {
+	qry, args, err := sq.Select("*").From("ms_wallet").Where(sq.Eq{"id": walletId}).ToSql()
+	if err != nil {
+		return err
+	}
+
+	currentWallet := wallet.Wallet{}
+	result := tx.Raw(qry, args...).Scan(&currentWallet)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return response.ErrWalletNotFound
+	}
+
+	if err = currentWallet.ValidateWalletStatus(); err != nil {
+		return err
+	}
+
+	return response.ErrInsufficientFund
+}
+
 func (walletRepository *walletRepository) GetWalletById(ctx context.Context, walletId string) (res *wallet.Wallet, err error) {
 	builder := sq.Select("*").From("ms_wallet").Where(sq.Eq{"id": walletId})
 	qry, args, err := builder.ToSql()
@@ -95,9 +315,6 @@ func (walletRepository *walletRepository) GetWalletById(ctx context.Context, wal
 	}
 
 	err = walletRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
 	if err != nil {
 		return nil, err
 	}
@@ -106,16 +323,13 @@ func (walletRepository *walletRepository) GetWalletById(ctx context.Context, wal
 }
 
 func (walletRepository *walletRepository) GetCustomerWallet(ctx context.Context, customerId string) (res *wallet.Wallet, err error) {
-	builder := sq.Select("*").From("ms_wallet").Where(sq.Eq{"owned_by": customerId})
+	builder := sq.Select("*").From("ms_wallet").Where(sq.Eq{"owned_by": customerId, "name": wallet.WALLET_MAIN_POCKET})
 	qry, args, err := builder.ToSql()
 	if err != nil {
 		return res, err
 	}
 
 	err = walletRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
 	if err != nil {
 		return nil, err
 	}
@@ -123,19 +337,91 @@ func (walletRepository *walletRepository) GetCustomerWallet(ctx context.Context,
 	return
 }
 
+func (walletRepository *walletRepository) GetCustomerWallets(ctx context.Context, customerId string) (res []wallet.Wallet, err error) {
+	builder := sq.Select("*").From("ms_wallet").Where(sq.Eq{"owned_by": customerId}).OrderBy("name")
+	qry, args, err := builder.ToSql()
+	if err != nil {
+		return res, err
+	}
+
+	res = []wallet.Wallet{}
+	err = walletRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (walletRepository *walletRepository) GetWallets(ctx context.Context, afterId string, limit int) (res []wallet.Wallet, err error) {
+	qry, args, err := sq.Select("*").From("ms_wallet").Where(sq.Gt{"id": afterId}).OrderBy("id").Limit(uint64(limit)).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	res = []wallet.Wallet{}
+	err = walletRepository.db.WithContext(ctx).Raw(qry, args...).Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func (walletRepository *walletRepository) InsertWallet(ctx context.Context, wallet wallet.Wallet) (err error) {
 	err = walletRepository.db.WithContext(ctx).Table("ms_wallet").Create(wallet).Error
 	if err != nil {
-		return err
+		return translateConstraintViolation(err)
 	}
 
 	return nil
 }
 
-func (walletRepository *walletRepository) UpdateWallet(ctx context.Context, wallet wallet.Wallet) (err error) {
-	err = walletRepository.db.WithContext(ctx).Table("ms_wallet").UpdateColumns(wallet).Error
-	if err != nil {
+// UpdateWallet only writes the status, the balance is owned by the transaction methods
+// so a status change can never overwrite a concurrent deposit or withdrawal
+func (walletRepository *walletRepository) UpdateWallet(ctx context.Context, walletData wallet.Wallet) (err error) {
+	result := walletRepository.db.WithContext(ctx).Table("ms_wallet").Where("id", walletData.Id).Updates(map[string]interface{}{
+		"status":     walletData.Status,
+		"enabled_at": walletData.EnabledAt,
+		"version":    gorm.Expr("version + 1"),
+	})
+	if err = result.Error; err != nil {
+		return translateConstraintViolation(err)
+	}
+
+	if result.RowsAffected == 0 {
+		return response.ErrWalletNotFound
+	}
+
+	return nil
+}
+
+// translateConstraintViolation turns the database constraints into the user errors the usecases return,
+// any other error is returned as is
+func translateConstraintViolation(err error) error {
+	violation, ok := infrastructure.AsConstraintViolation(err)
+	if !ok {
 		return err
 	}
-	return
+
+	switch violation.Code {
+	case infrastructure.PG_CHECK_VIOLATION:
+		if violation.Constraint == "ms_wallet_balance_non_negative" {
+			return response.ErrInsufficientFund
+		}
+		return response.ErrBadRequest
+	case infrastructure.PG_UNIQUE_VIOLATION:
+		switch violation.Constraint {
+		case "tr_wallet_transaction_wallet_id_reference_id_key":
+			return response.ErrReferenceIdConflict
+		case "ms_wallet_owned_by_name_key":
+			return response.ErrWalletAlreadyExists
+		}
+	case infrastructure.PG_FOREIGN_KEY_VIOLATION:
+		if violation.Constraint == "tr_wallet_transaction_wallet_id_fkey" {
+			return response.ErrWalletNotFound
+		}
+	}
+
+	return err
 }
@@ -0,0 +1,644 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestMemoryWalletRepositoryContract(t *testing.T) {
+	testWalletRepositoryContract(t, func(t *testing.T) wallet.WalletRepository {
+		return NewMemoryWalletRepository()
+	})
+}
+
+func TestPostgresWalletRepositoryContract(t *testing.T) {
+	dsn := os.Getenv(testPostgresDsnEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testPostgresDsnEnv)
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("connecting to postgres: %v", err)
+	}
+	if err = infrastructure.Migrate(context.Background(), db, infrastructure.MIGRATE_UP); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	testWalletRepositoryContract(t, func(t *testing.T) wallet.WalletRepository {
+		return NewWalletRepository(db, nil)
+	})
+}
+
+// testWalletRepositoryContract checks that a WalletRepository behaves like every other one. newRepository may hand out
+// the same repository to every subtest, e.g. on one database, so each subtest only looks at the wallets it inserted
+func testWalletRepositoryContract(t *testing.T, newRepository func(t *testing.T) wallet.WalletRepository) {
+	t.Run("not found", func(t *testing.T) {
+		ctx := context.Background()
+		repository := newRepository(t)
+		unknownId := uuid.NewString()
+
+		if res, err := repository.GetWalletById(ctx, unknownId); res != nil || err != nil {
+			t.Errorf("GetWalletById = %v %v, want nil, nil", res, err)
+		}
+		if res, err := repository.GetCustomerWallet(ctx, unknownId); res != nil || err != nil {
+			t.Errorf("GetCustomerWallet = %v %v, want nil, nil", res, err)
+		}
+		if res, err := repository.GetCustomerWallets(ctx, unknownId); res == nil || len(res) != 0 || err != nil {
+			t.Errorf("GetCustomerWallets = %#v %v, want an empty slice", res, err)
+		}
+		if res, err := repository.GetWalletTransactionById(ctx, unknownId); res != nil || err != nil {
+			t.Errorf("GetWalletTransactionById = %v %v, want nil, nil", res, err)
+		}
+		if res, err := repository.GetWalletTransactionByReferenceId(ctx, unknownId, "ref"); res != nil || err != nil {
+			t.Errorf("GetWalletTransactionByReferenceId = %v %v, want nil, nil", res, err)
+		}
+		if res, err := repository.GetWalletTransactionsByWalletId(ctx, wallet.GetWalletTransactionRequest{WalletId: unknownId}, 1, 10); res == nil || len(res) != 0 || err != nil {
+			t.Errorf("GetWalletTransactionsByWalletId = %#v %v, want an empty slice", res, err)
+		}
+
+		if err := repository.UpdateWallet(ctx, wallet.Wallet{Id: unknownId, Status: wallet.WALLET_STATUS_DISABLED}); !errors.Is(err, response.ErrWalletNotFound) {
+			t.Errorf("UpdateWallet: err = %v, want %v", err, response.ErrWalletNotFound)
+		}
+		if err := repository.CreateWalletTransaction(ctx, wallet.Wallet{Id: unknownId}, newContractTransaction(unknownId, wallet.WALLET_TRANSACTION_DEPOSIT, 10, time.Now())); !errors.Is(err, response.ErrWalletNotFound) {
+			t.Errorf("CreateWalletTransaction: err = %v, want %v", err, response.ErrWalletNotFound)
+		}
+		applied := false
+		if _, err := repository.CreateWalletTransactionForUpdate(ctx, unknownId, func(lockedWallet *wallet.Wallet) (wallet.WalletTransactionEntity, error) {
+			applied = true
+			return newContractTransaction(unknownId, wallet.WALLET_TRANSACTION_DEPOSIT, 10, time.Now()), nil
+		}); !errors.Is(err, response.ErrWalletNotFound) || applied {
+			t.Errorf("CreateWalletTransactionForUpdate: err = %v, applied = %t, want %v before apply", err, applied, response.ErrWalletNotFound)
+		}
+		if _, err := repository.CreateWalletTransactionAtomically(ctx, newContractTransaction(unknownId, wallet.WALLET_TRANSACTION_DEPOSIT, 10, time.Now())); !errors.Is(err, response.ErrWalletNotFound) {
+			t.Errorf("CreateWalletTransactionAtomically: err = %v, want %v", err, response.ErrWalletNotFound)
+		}
+		err := repository.CreateWalletTransactionsAtomically(ctx, []wallet.WalletTransactionEntity{newContractTransaction(unknownId, wallet.WALLET_TRANSACTION_DEPOSIT, 10, time.Now())})
+		var itemError *wallet.TransactionItemError
+		if !errors.As(err, &itemError) || itemError.Index != 0 || !errors.Is(err, response.ErrWalletNotFound) {
+			t.Errorf("CreateWalletTransactionsAtomically: err = %v, want item 0 not found", err)
+		}
+	})
+
+	t.Run("wallets", func(t *testing.T) {
+		ctx := context.Background()
+		repository := newRepository(t)
+		mainPocket := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+
+		if res, err := repository.GetWalletById(ctx, mainPocket.Id); err != nil || !sameWallet(res, mainPocket) {
+			t.Errorf("GetWalletById = %+v %v, want %+v", res, err, mainPocket)
+		}
+
+		duplicate := mainPocket
+		duplicate.Id = uuid.NewString()
+		if err := repository.InsertWallet(ctx, duplicate); !errors.Is(err, response.ErrWalletAlreadyExists) {
+			t.Errorf("a second main pocket: err = %v, want %v", err, response.ErrWalletAlreadyExists)
+		}
+		if err := repository.InsertWallet(ctx, mainPocket); err == nil {
+			t.Errorf("a second wallet with the same id is inserted")
+		}
+
+		savings := mainPocket
+		savings.Id = uuid.NewString()
+		savings.Name = "Savings"
+		savings.Balance = 0
+		if err := repository.InsertWallet(ctx, savings); err != nil {
+			t.Fatalf("inserting a pocket: %v", err)
+		}
+
+		if res, err := repository.GetCustomerWallet(ctx, mainPocket.OwnedBy); err != nil || !sameWallet(res, mainPocket) {
+			t.Errorf("GetCustomerWallet = %+v %v, want the main pocket", res, err)
+		}
+		pockets, err := repository.GetCustomerWallets(ctx, mainPocket.OwnedBy)
+		if err != nil || len(pockets) != 2 || pockets[0].Id != mainPocket.Id || pockets[1].Id != savings.Id {
+			t.Errorf("GetCustomerWallets = %+v %v, want Main then Savings", pockets, err)
+		}
+
+		// the pages hold the wallets of the other subtests too, both pockets are found on them in id order
+		found := 0
+		for afterId := ""; ; {
+			page, err := repository.GetWallets(ctx, afterId, 100)
+			if err != nil {
+				t.Fatalf("GetWallets: %v", err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			for _, walletData := range page {
+				if walletData.Id <= afterId {
+					t.Fatalf("GetWallets after %s returned %s", afterId, walletData.Id)
+				}
+				if walletData.Id == mainPocket.Id || walletData.Id == savings.Id {
+					found++
+				}
+				afterId = walletData.Id
+			}
+		}
+		if found != 2 {
+			t.Errorf("GetWallets found %d of the two pockets", found)
+		}
+
+		// the status is written, the balance is left to the transactions
+		disabled := mainPocket
+		disabled.Status = wallet.WALLET_STATUS_DISABLED
+		disabled.EnabledAt = nil
+		disabled.Balance = 999
+		if err := repository.UpdateWallet(ctx, disabled); err != nil {
+			t.Fatalf("UpdateWallet: %v", err)
+		}
+		res, err := repository.GetWalletById(ctx, mainPocket.Id)
+		if err != nil || res.Status != wallet.WALLET_STATUS_DISABLED || res.EnabledAt != nil || res.Balance != 100 || res.Version != mainPocket.Version+1 {
+			t.Errorf("updated wallet = %+v %v, want disabled with a balance of 100 and the next version", res, err)
+		}
+
+		invalid := mainPocket
+		invalid.Status = "closed"
+		if err := repository.UpdateWallet(ctx, invalid); !errors.Is(err, response.ErrBadRequest) {
+			t.Errorf("an unknown status: err = %v, want %v", err, response.ErrBadRequest)
+		}
+	})
+
+	t.Run("reference ids", func(t *testing.T) {
+		ctx := context.Background()
+		repository := newRepository(t)
+		walletA := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+		walletB := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+
+		// a reference id is unique per wallet only
+		depositA := newContractTransaction(walletA.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 10, time.Now())
+		depositB := newContractTransaction(walletB.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 20, time.Now())
+		depositB.ReferenceId = depositA.ReferenceId
+		for _, deposit := range []wallet.WalletTransactionEntity{depositA, depositB} {
+			if _, err := repository.CreateWalletTransactionAtomically(ctx, deposit); err != nil {
+				t.Fatalf("deposit on %s: %v", deposit.WalletId, err)
+			}
+		}
+
+		for _, deposit := range []wallet.WalletTransactionEntity{depositA, depositB} {
+			res, err := repository.GetWalletTransactionByReferenceId(ctx, deposit.WalletId, deposit.ReferenceId)
+			if err != nil || res == nil || res.Id != deposit.Id || res.Amount != deposit.Amount {
+				t.Errorf("GetWalletTransactionByReferenceId(%s) = %+v %v, want %s", deposit.WalletId, res, err, deposit.Id)
+			}
+			res, err = repository.GetWalletTransactionById(ctx, deposit.Id)
+			if err != nil || res == nil || res.ReferenceId != deposit.ReferenceId || res.CreatedBy != deposit.CreatedBy {
+				t.Errorf("GetWalletTransactionById(%s) = %+v %v, want %+v", deposit.Id, res, err, deposit)
+			}
+		}
+
+		reused := newContractTransaction(walletA.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 30, time.Now())
+		reused.ReferenceId = depositA.ReferenceId
+		if _, err := repository.CreateWalletTransactionAtomically(ctx, reused); !errors.Is(err, response.ErrReferenceIdConflict) {
+			t.Errorf("a reused reference id: err = %v, want %v", err, response.ErrReferenceIdConflict)
+		}
+		assertContractBalance(t, repository, walletA.Id, 110)
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		ctx := context.Background()
+		repository := newRepository(t)
+		testWallet := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 0)
+
+		startedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+		var deposits []wallet.WalletTransactionEntity
+		for i := 0; i < 5; i++ {
+			deposit := newContractTransaction(testWallet.Id, wallet.WALLET_TRANSACTION_DEPOSIT, i+1, startedAt.Add(time.Second*time.Duration(i)))
+			if _, err := repository.CreateWalletTransactionAtomically(ctx, deposit); err != nil {
+				t.Fatalf("deposit #%d: %v", i, err)
+			}
+			deposits = append(deposits, deposit)
+		}
+		req := wallet.GetWalletTransactionRequest{WalletId: testWallet.Id}
+
+		for _, testCase := range []struct {
+			page, size int
+			want       []int // amounts, newest first
+		}{
+			{1, 2, []int{5, 4}},
+			{2, 2, []int{3, 2}},
+			{3, 2, []int{1}},
+			{4, 2, []int{}},
+			{1, 10, []int{5, 4, 3, 2, 1}},
+		} {
+			res, err := repository.GetWalletTransactionsByWalletId(ctx, req, testCase.page, testCase.size)
+			if err != nil || res == nil || fmt.Sprint(contractAmounts(res)) != fmt.Sprint(testCase.want) {
+				t.Errorf("page %d of %d = %v %v, want %v", testCase.page, testCase.size, contractAmounts(res), err, testCase.want)
+			}
+		}
+
+		for _, bounds := range [][2]int{{0, 10}, {-1, 10}, {1, 0}, {1, -1}} {
+			if _, err := repository.GetWalletTransactionsByWalletId(ctx, req, bounds[0], bounds[1]); !errors.Is(err, response.ErrBadRequest) {
+				t.Errorf("page %d of %d: err = %v, want %v", bounds[0], bounds[1], err, response.ErrBadRequest)
+			}
+		}
+
+		// transactions of the same second are settled by their id, so the pages neither overlap nor skip one
+		sameSecond := startedAt.Add(time.Minute)
+		for i := 0; i < 3; i++ {
+			if _, err := repository.CreateWalletTransactionAtomically(ctx, newContractTransaction(testWallet.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 10, sameSecond)); err != nil {
+				t.Fatalf("deposit in the same second #%d: %v", i, err)
+			}
+		}
+		seen := map[string]bool{}
+		for page := 1; page <= 3; page++ {
+			res, err := repository.GetWalletTransactionsByWalletId(ctx, req, page, 1)
+			if err != nil || len(res) != 1 || res[0].Amount != 10 || seen[res[0].Id] {
+				t.Errorf("page %d of the same second = %+v %v, want another deposit of 10", page, res, err)
+				continue
+			}
+			seen[res[0].Id] = true
+		}
+	})
+
+	t.Run("filters and stream", func(t *testing.T) {
+		ctx := context.Background()
+		repository := newRepository(t)
+		walletA := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+		walletB := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+
+		startedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+		transactions := []wallet.WalletTransactionEntity{
+			newContractTransaction(walletA.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 1, startedAt),
+			newContractTransaction(walletA.Id, wallet.WALLET_TRANSACTION_WITHDRAWAL, 2, startedAt.Add(time.Second)),
+			newContractTransaction(walletB.Id, wallet.WALLET_TRANSACTION_WITHDRAWAL, 3, startedAt.Add(time.Second*2)),
+			newContractTransaction(walletA.Id, wallet.WALLET_TRANSACTION_WITHDRAWAL, 4, startedAt.Add(time.Second*3)),
+		}
+		transactions[3].CreatedBy = "member-1"
+		for i, walletTransaction := range transactions {
+			if _, err := repository.CreateWalletTransactionAtomically(ctx, walletTransaction); err != nil {
+				t.Fatalf("transaction #%d: %v", i, err)
+			}
+		}
+
+		withdrawal := wallet.WALLET_TRANSACTION_WITHDRAWAL
+		memberId := "member-1"
+		from, to := startedAt.Add(time.Second), startedAt.Add(time.Second*3)
+		for _, testCase := range []struct {
+			name string
+			req  wallet.GetWalletTransactionRequest
+			want []int
+		}{
+			{"type", wallet.GetWalletTransactionRequest{WalletId: walletA.Id, Type: &withdrawal}, []int{4, 2}},
+			{"created by", wallet.GetWalletTransactionRequest{WalletId: walletA.Id, CreatedBy: &memberId}, []int{4}},
+			{"from is inclusive, to exclusive", wallet.GetWalletTransactionRequest{WalletId: walletA.Id, From: &from, To: &to}, []int{2}},
+		} {
+			res, err := repository.GetWalletTransactionsByWalletId(ctx, testCase.req, 1, 10)
+			if err != nil || fmt.Sprint(contractAmounts(res)) != fmt.Sprint(testCase.want) {
+				t.Errorf("%s = %v %v, want %v", testCase.name, contractAmounts(res), err, testCase.want)
+			}
+		}
+
+		// without a wallet every wallet is streamed, oldest first. the other wallets of a shared database are skipped
+		var streamed []int
+		err := repository.StreamWalletTransactions(ctx, wallet.GetWalletTransactionRequest{Type: &withdrawal}, func(walletTransaction wallet.WalletTransactionEntity) error {
+			if walletTransaction.WalletId == walletA.Id || walletTransaction.WalletId == walletB.Id {
+				streamed = append(streamed, walletTransaction.Amount)
+			}
+			return nil
+		})
+		if err != nil || fmt.Sprint(streamed) != fmt.Sprint([]int{2, 3, 4}) {
+			t.Errorf("streamed withdrawals = %v %v, want [2 3 4]", streamed, err)
+		}
+
+		errStop := errors.New("stop")
+		yielded := 0
+		err = repository.StreamWalletTransactions(ctx, wallet.GetWalletTransactionRequest{WalletId: walletA.Id}, func(walletTransaction wallet.WalletTransactionEntity) error {
+			yielded++
+			return errStop
+		})
+		if !errors.Is(err, errStop) || yielded != 1 {
+			t.Errorf("stream stopped by yield: err = %v after %d, want %v after 1", err, yielded, errStop)
+		}
+	})
+
+	t.Run("create wallet transaction is atomic", func(t *testing.T) {
+		ctx := context.Background()
+		repository := newRepository(t)
+		testWallet := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+
+		readWallet, _ := repository.GetWalletById(ctx, testWallet.Id)
+		updatedWallet := *readWallet
+		updatedWallet.Balance += 10
+		updatedWallet.LockToken = 100
+		deposit := newContractTransaction(testWallet.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 10, time.Now())
+		if err := repository.CreateWalletTransaction(ctx, updatedWallet, deposit); err != nil {
+			t.Fatalf("CreateWalletTransaction: %v", err)
+		}
+		storedWallet, _ := repository.GetWalletById(ctx, testWallet.Id)
+		if storedWallet.Balance != 110 || storedWallet.Version != readWallet.Version+1 || storedWallet.LockToken != 100 {
+			t.Errorf("wallet = %+v, want a balance of 110, the next version and the lock token 100", storedWallet)
+		}
+
+		// every refused write leaves both the balance and the history untouched
+		staleVersion := updatedWallet
+		staleVersion.Balance += 10
+		staleLockToken := *storedWallet
+		staleLockToken.Balance += 10
+		staleLockToken.LockToken = 50
+		overdrawn := *storedWallet
+		overdrawn.Balance = -1
+		reusedReference := *storedWallet
+		reusedReference.Balance += 10
+		for _, testCase := range []struct {
+			name          string
+			updatedWallet wallet.Wallet
+			reuseRef      bool
+			want          error
+		}{
+			{"a stale version", staleVersion, false, response.ErrWalletConcurrent},
+			{"a stale lock token", staleLockToken, false, response.ErrWalletConcurrent},
+			{"a negative balance", overdrawn, false, response.ErrInsufficientFund},
+			{"a reused reference id", reusedReference, true, response.ErrReferenceIdConflict},
+		} {
+			refused := newContractTransaction(testWallet.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 10, time.Now())
+			if testCase.reuseRef {
+				refused.ReferenceId = deposit.ReferenceId
+			}
+
+			if err := repository.CreateWalletTransaction(ctx, testCase.updatedWallet, refused); !errors.Is(err, testCase.want) {
+				t.Errorf("%s: err = %v, want %v", testCase.name, err, testCase.want)
+			}
+			if res, err := repository.GetWalletTransactionById(ctx, refused.Id); res != nil || err != nil {
+				t.Errorf("%s: the transaction is written", testCase.name)
+			}
+			assertContractBalance(t, repository, testWallet.Id, 110)
+		}
+	})
+
+	t.Run("create wallet transaction for update", func(t *testing.T) {
+		ctx := context.Background()
+		repository := newRepository(t)
+		testWallet := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+
+		errApply := errors.New("refused by apply")
+		for _, testCase := range []struct {
+			name  string
+			delta int
+			err   error
+			want  error
+		}{
+			{"an error of apply", 10, errApply, errApply},
+			{"a negative balance", -101, nil, response.ErrInsufficientFund},
+		} {
+			refused := newContractTransaction(testWallet.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 10, time.Now())
+			_, err := repository.CreateWalletTransactionForUpdate(ctx, testWallet.Id, func(lockedWallet *wallet.Wallet) (wallet.WalletTransactionEntity, error) {
+				lockedWallet.Balance += testCase.delta
+				return refused, testCase.err
+			})
+			if !errors.Is(err, testCase.want) {
+				t.Errorf("%s: err = %v, want %v", testCase.name, err, testCase.want)
+			}
+			if res, _ := repository.GetWalletTransactionById(ctx, refused.Id); res != nil {
+				t.Errorf("%s: the transaction is written", testCase.name)
+			}
+			assertContractBalance(t, repository, testWallet.Id, 100)
+		}
+
+		deposit := newContractTransaction(testWallet.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 10, time.Now())
+		reason := "double settlement"
+		deposit.Reason = &reason
+		res, err := repository.CreateWalletTransactionForUpdate(ctx, testWallet.Id, func(lockedWallet *wallet.Wallet) (wallet.WalletTransactionEntity, error) {
+			return deposit, lockedWallet.ApplyTransaction(deposit)
+		})
+		if err != nil || res.Balance != 110 || res.Version != testWallet.Version+1 {
+			t.Errorf("CreateWalletTransactionForUpdate = %+v %v, want a balance of 110 and the next version", res, err)
+		}
+		assertContractBalance(t, repository, testWallet.Id, 110)
+		if stored, err := repository.GetWalletTransactionById(ctx, deposit.Id); err != nil || stored == nil || stored.Reason == nil || *stored.Reason != reason {
+			t.Errorf("GetWalletTransactionById = %+v %v, want the reason %q", stored, err, reason)
+		}
+	})
+
+	t.Run("create wallet transaction atomically", func(t *testing.T) {
+		ctx := context.Background()
+		repository := newRepository(t)
+		testWallet := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+		disabledWallet := insertContractWallet(t, repository, wallet.WALLET_STATUS_DISABLED, 100)
+
+		withdrawal := newContractTransaction(testWallet.Id, wallet.WALLET_TRANSACTION_WITHDRAWAL, 40, time.Now())
+		withdrawal.CreatedBy = ""
+		res, err := repository.CreateWalletTransactionAtomically(ctx, withdrawal)
+		if err != nil || res.Balance != 60 || res.Version != testWallet.Version+1 {
+			t.Fatalf("CreateWalletTransactionAtomically = %+v %v, want a balance of 60 and the next version", res, err)
+		}
+		if stored, _ := repository.GetWalletTransactionById(ctx, withdrawal.Id); stored == nil || stored.CreatedBy != testWallet.OwnedBy {
+			t.Errorf("a transaction without creator = %+v, want it created by the owner", stored)
+		}
+
+		overdraw := newContractTransaction(testWallet.Id, wallet.WALLET_TRANSACTION_WITHDRAWAL, 61, time.Now())
+		if _, err = repository.CreateWalletTransactionAtomically(ctx, overdraw); !errors.Is(err, response.ErrInsufficientFund) {
+			t.Errorf("more than the balance: err = %v, want %v", err, response.ErrInsufficientFund)
+		}
+		assertContractBalance(t, repository, testWallet.Id, 60)
+
+		want := disabledWallet.ValidateWalletStatus()
+		if _, err = repository.CreateWalletTransactionAtomically(ctx, newContractTransaction(disabledWallet.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 10, time.Now())); !errors.Is(err, want) {
+			t.Errorf("a disabled wallet: err = %v, want %v", err, want)
+		}
+		assertContractBalance(t, repository, disabledWallet.Id, 100)
+	})
+
+	t.Run("create wallet transactions atomically", func(t *testing.T) {
+		ctx := context.Background()
+		repository := newRepository(t)
+		walletA := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+		walletB := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+
+		rejected := []wallet.WalletTransactionEntity{
+			newContractTransaction(walletA.Id, wallet.WALLET_TRANSACTION_WITHDRAWAL, 60, time.Now()),
+			newContractTransaction(walletB.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 60, time.Now()),
+			newContractTransaction(walletA.Id, wallet.WALLET_TRANSACTION_WITHDRAWAL, 60, time.Now()),
+		}
+		err := repository.CreateWalletTransactionsAtomically(ctx, rejected)
+		var itemError *wallet.TransactionItemError
+		if !errors.As(err, &itemError) || itemError.Index != 2 || !errors.Is(err, response.ErrInsufficientFund) {
+			t.Fatalf("overdrawing on the third item: err = %v, want item 2 insufficient fund", err)
+		}
+		for _, walletTransaction := range rejected {
+			if res, _ := repository.GetWalletTransactionById(ctx, walletTransaction.Id); res != nil {
+				t.Errorf("transaction %s of the rejected batch is written", walletTransaction.Id)
+			}
+		}
+		assertContractBalance(t, repository, walletA.Id, 100)
+		assertContractBalance(t, repository, walletB.Id, 100)
+
+		if err = repository.CreateWalletTransactionsAtomically(ctx, rejected[:2]); err != nil {
+			t.Fatalf("the first two items alone: %v", err)
+		}
+		assertContractBalance(t, repository, walletA.Id, 40)
+		assertContractBalance(t, repository, walletB.Id, 160)
+	})
+
+	t.Run("concurrent writes", func(t *testing.T) {
+		ctx := context.Background()
+		repository := newRepository(t)
+		const writers = 20
+
+		// every conditional update is applied once
+		testWallet := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+		errs := runConcurrently(writers*2, func(i int) error {
+			transactionType := wallet.WALLET_TRANSACTION_DEPOSIT
+			if i%2 == 1 {
+				transactionType = wallet.WALLET_TRANSACTION_WITHDRAWAL
+			}
+			_, err := repository.CreateWalletTransactionAtomically(ctx, newContractTransaction(testWallet.Id, transactionType, 5, time.Now()))
+			return err
+		})
+		for _, err := range errs {
+			if err != nil {
+				t.Errorf("CreateWalletTransactionAtomically: %v", err)
+			}
+		}
+		assertContractBalance(t, repository, testWallet.Id, 100)
+		if res, err := repository.GetWalletTransactionsByWalletId(ctx, wallet.GetWalletTransactionRequest{WalletId: testWallet.Id}, 1, writers*4); err != nil || len(res) != writers*2 {
+			t.Errorf("transactions = %d %v, want %d", len(res), err, writers*2)
+		}
+
+		// so is every update under the row lock
+		lockedWallet := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 0)
+		errs = runConcurrently(writers, func(i int) error {
+			deposit := newContractTransaction(lockedWallet.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 5, time.Now())
+			_, err := repository.CreateWalletTransactionForUpdate(ctx, lockedWallet.Id, func(lockedWallet *wallet.Wallet) (wallet.WalletTransactionEntity, error) {
+				return deposit, lockedWallet.ApplyTransaction(deposit)
+			})
+			return err
+		})
+		for _, err := range errs {
+			if err != nil {
+				t.Errorf("CreateWalletTransactionForUpdate: %v", err)
+			}
+		}
+		assertContractBalance(t, repository, lockedWallet.Id, writers*5)
+
+		// only one write based on the same read wins, the others are told to retry
+		versionedWallet := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+		errs = runConcurrently(writers, func(i int) error {
+			updatedWallet := versionedWallet
+			updatedWallet.Balance += 5
+			return repository.CreateWalletTransaction(ctx, updatedWallet, newContractTransaction(versionedWallet.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 5, time.Now()))
+		})
+		if succeeded := countContractErrors(t, errs, response.ErrWalletConcurrent); succeeded != 1 {
+			t.Errorf("CreateWalletTransaction on the same version: %d succeeded, want 1", succeeded)
+		}
+		assertContractBalance(t, repository, versionedWallet.Id, 105)
+
+		// and a reference id is only taken once
+		referencedWallet := insertContractWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+		referenceId := uuid.NewString()
+		errs = runConcurrently(writers, func(i int) error {
+			deposit := newContractTransaction(referencedWallet.Id, wallet.WALLET_TRANSACTION_DEPOSIT, 5, time.Now())
+			deposit.ReferenceId = referenceId
+			_, err := repository.CreateWalletTransactionAtomically(ctx, deposit)
+			return err
+		})
+		if succeeded := countContractErrors(t, errs, response.ErrReferenceIdConflict); succeeded != 1 {
+			t.Errorf("the same reference id: %d succeeded, want 1", succeeded)
+		}
+		assertContractBalance(t, repository, referencedWallet.Id, 105)
+	})
+}
+
+func insertContractWallet(t *testing.T, repository wallet.WalletRepository, status string, balance int) wallet.Wallet {
+	t.Helper()
+
+	enabledAt := time.Now().Format(time.RFC3339)
+	contractWallet := wallet.Wallet{
+		Id:        uuid.NewString(),
+		OwnedBy:   uuid.NewString(),
+		Name:      wallet.WALLET_MAIN_POCKET,
+		EnabledAt: &enabledAt,
+		Balance:   balance,
+		Status:    status,
+	}
+	if err := repository.InsertWallet(context.Background(), contractWallet); err != nil {
+		t.Fatalf("inserting wallet: %v", err)
+	}
+
+	return contractWallet
+}
+
+func newContractTransaction(walletId string, transactionType string, amount int, createdAt time.Time) wallet.WalletTransactionEntity {
+	return wallet.WalletTransactionEntity{
+		Id:          uuid.NewString(),
+		WalletId:    walletId,
+		Amount:      amount,
+		CreatedAt:   createdAt.Format(time.RFC3339),
+		CreatedBy:   "contract",
+		Type:        transactionType,
+		Status:      wallet.WALLET_TRANSACTION_STATUS_SUCCESS,
+		ReferenceId: uuid.NewString(),
+	}
+}
+
+func sameWallet(res *wallet.Wallet, want wallet.Wallet) bool {
+	if res == nil || (res.EnabledAt == nil) != (want.EnabledAt == nil) || (res.EnabledAt != nil && *res.EnabledAt != *want.EnabledAt) {
+		return false
+	}
+
+	return res.Id == want.Id && res.OwnedBy == want.OwnedBy && res.Name == want.Name && res.Balance == want.Balance && res.Status == want.Status
+}
+
+func assertContractBalance(t *testing.T, repository wallet.WalletRepository, walletId string, want int) {
+	t.Helper()
+
+	res, err := repository.GetWalletById(context.Background(), walletId)
+	if err != nil || res == nil || res.Balance != want {
+		t.Errorf("wallet %s = %+v %v, want a balance of %d", walletId, res, err, want)
+	}
+}
+
+func contractAmounts(walletTransactions []wallet.WalletTransactionEntity) []int {
+	amounts := []int{}
+	for _, walletTransaction := range walletTransactions {
+		amounts = append(amounts, walletTransaction.Amount)
+	}
+
+	return amounts
+}
+
+// runConcurrently calls fn n times at once and returns the errors in call order
+func runConcurrently(n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = fn(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	return errs
+}
+
+// countContractErrors returns how many calls succeeded, every failure must be expected
+func countContractErrors(t *testing.T, errs []error, expected error) (succeeded int) {
+	t.Helper()
+
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, expected):
+			t.Errorf("err = %v, want nil or %v", err, expected)
+		}
+	}
+
+	return succeeded
+}
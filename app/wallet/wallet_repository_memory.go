@@ -0,0 +1,387 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryWalletRepository struct {
+	mu           sync.RWMutex
+	wallets      map[string]wallet.Wallet
+	transactions []wallet.WalletTransactionEntity // in insertion order
+}
+
+// NewMemoryWalletRepository keeps the wallets in this process, for tests and the memory storage.
+// it answers like the postgres one: the same constraints turn into the same errors and every write is all or nothing
+func NewMemoryWalletRepository() wallet.WalletRepository {
+	return &memoryWalletRepository{
+		wallets: map[string]wallet.Wallet{},
+	}
+}
+
+func (walletRepository *memoryWalletRepository) GetCustomerWallet(ctx context.Context, customerId string) (res *wallet.Wallet, err error) {
+	walletRepository.mu.RLock()
+	defer walletRepository.mu.RUnlock()
+
+	for _, walletData := range walletRepository.wallets {
+		if walletData.OwnedBy == customerId && walletData.Name == wallet.WALLET_MAIN_POCKET {
+			return &walletData, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (walletRepository *memoryWalletRepository) GetCustomerWallets(ctx context.Context, customerId string) (res []wallet.Wallet, err error) {
+	walletRepository.mu.RLock()
+	defer walletRepository.mu.RUnlock()
+
+	res = []wallet.Wallet{}
+	for _, walletData := range walletRepository.wallets {
+		if walletData.OwnedBy == customerId {
+			res = append(res, walletData)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res, nil
+}
+
+func (walletRepository *memoryWalletRepository) GetWalletById(ctx context.Context, walletId string) (res *wallet.Wallet, err error) {
+	walletRepository.mu.RLock()
+	defer walletRepository.mu.RUnlock()
+
+	walletData, found := walletRepository.wallets[walletId]
+	if !found {
+		return nil, nil
+	}
+
+	return &walletData, nil
+}
+
+func (walletRepository *memoryWalletRepository) GetWallets(ctx context.Context, afterId string, limit int) (res []wallet.Wallet, err error) {
+	walletRepository.mu.RLock()
+	defer walletRepository.mu.RUnlock()
+
+	res = []wallet.Wallet{}
+	for _, walletData := range walletRepository.wallets {
+		if walletData.Id > afterId {
+			res = append(res, walletData)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Id < res[j].Id })
+
+	return res[:min(limit, len(res))], nil
+}
+
+func (walletRepository *memoryWalletRepository) InsertWallet(ctx context.Context, walletData wallet.Wallet) (err error) {
+	walletRepository.mu.Lock()
+	defer walletRepository.mu.Unlock()
+
+	if _, found := walletRepository.wallets[walletData.Id]; found {
+		return fmt.Errorf("wallet %s already exists", walletData.Id)
+	}
+	for _, existingWallet := range walletRepository.wallets {
+		if existingWallet.OwnedBy == walletData.OwnedBy && existingWallet.Name == walletData.Name {
+			return response.ErrWalletAlreadyExists
+		}
+	}
+	if err = checkWallet(walletData); err != nil {
+		return err
+	}
+
+	walletRepository.wallets[walletData.Id] = walletData
+	return nil
+}
+
+// UpdateWallet only writes the status, like the postgres one
+func (walletRepository *memoryWalletRepository) UpdateWallet(ctx context.Context, walletData wallet.Wallet) (err error) {
+	walletRepository.mu.Lock()
+	defer walletRepository.mu.Unlock()
+
+	storedWallet, found := walletRepository.wallets[walletData.Id]
+	if !found {
+		return response.ErrWalletNotFound
+	}
+
+	storedWallet.Status = walletData.Status
+	storedWallet.EnabledAt = walletData.EnabledAt
+	if err = checkWallet(storedWallet); err != nil {
+		return err
+	}
+	storedWallet.Version++
+	walletRepository.wallets[walletData.Id] = storedWallet
+
+	return nil
+}
+
+func (walletRepository *memoryWalletRepository) CreateWalletTransaction(ctx context.Context, updatedWallet wallet.Wallet, walletTransaction wallet.WalletTransactionEntity) (err error) {
+	walletRepository.mu.Lock()
+	defer walletRepository.mu.Unlock()
+
+	if err = walletRepository.checkTransaction(walletTransaction); err != nil {
+		return err
+	}
+
+	// the version guard and the fencing token of the postgres update
+	storedWallet := walletRepository.wallets[walletTransaction.WalletId]
+	if storedWallet.Version != updatedWallet.Version || storedWallet.LockToken > updatedWallet.LockToken {
+		return response.ErrWalletConcurrent
+	}
+	if updatedWallet.Balance < 0 {
+		return response.ErrInsufficientFund
+	}
+
+	storedWallet.Balance = updatedWallet.Balance
+	storedWallet.Version++
+	storedWallet.LockToken = updatedWallet.LockToken
+	walletRepository.wallets[storedWallet.Id] = storedWallet
+	walletRepository.transactions = append(walletRepository.transactions, walletTransaction)
+
+	return nil
+}
+
+// CreateWalletTransactionForUpdate holds the repository lock while apply runs, apply must not call the repository
+func (walletRepository *memoryWalletRepository) CreateWalletTransactionForUpdate(ctx context.Context, walletId string, apply func(lockedWallet *wallet.Wallet) (walletTransaction wallet.WalletTransactionEntity, err error)) (res *wallet.Wallet, err error) {
+	walletRepository.mu.Lock()
+	defer walletRepository.mu.Unlock()
+
+	lockedWallet, found := walletRepository.wallets[walletId]
+	if !found {
+		return nil, response.ErrWalletNotFound
+	}
+
+	walletTransaction, err := apply(&lockedWallet)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = walletRepository.checkTransaction(walletTransaction); err != nil {
+		return nil, err
+	}
+	if lockedWallet.Balance < 0 {
+		return nil, response.ErrInsufficientFund
+	}
+
+	lockedWallet.Version++
+	walletRepository.wallets[walletId] = lockedWallet
+	walletRepository.transactions = append(walletRepository.transactions, walletTransaction)
+
+	return &lockedWallet, nil
+}
+
+func (walletRepository *memoryWalletRepository) CreateWalletTransactionAtomically(ctx context.Context, walletTransaction wallet.WalletTransactionEntity) (res *wallet.Wallet, err error) {
+	walletRepository.mu.Lock()
+	defer walletRepository.mu.Unlock()
+
+	updatedWallet, walletTransaction, err := walletRepository.applyWalletTransaction(walletRepository.wallets, walletTransaction)
+	if err != nil {
+		return nil, err
+	}
+	if err = walletRepository.checkTransaction(walletTransaction); err != nil {
+		return nil, err
+	}
+
+	walletRepository.wallets[updatedWallet.Id] = updatedWallet
+	walletRepository.transactions = append(walletRepository.transactions, walletTransaction)
+
+	return &updatedWallet, nil
+}
+
+func (walletRepository *memoryWalletRepository) CreateWalletTransactionsAtomically(ctx context.Context, walletTransactions []wallet.WalletTransactionEntity) (err error) {
+	walletRepository.mu.Lock()
+	defer walletRepository.mu.Unlock()
+
+	// the wallets are changed on a copy, which only replaces the stored ones once every item is applied
+	stagedWallets := map[string]wallet.Wallet{}
+	for walletId, walletData := range walletRepository.wallets {
+		stagedWallets[walletId] = walletData
+	}
+	committedTransactions := len(walletRepository.transactions)
+
+	for i, walletTransaction := range walletTransactions {
+		updatedWallet, walletTransaction, err := walletRepository.applyWalletTransaction(stagedWallets, walletTransaction)
+		if err == nil {
+			err = walletRepository.checkTransaction(walletTransaction)
+		}
+		if err != nil {
+			walletRepository.transactions = walletRepository.transactions[:committedTransactions]
+			return &wallet.TransactionItemError{Index: i, Err: err}
+		}
+
+		stagedWallets[updatedWallet.Id] = updatedWallet
+		walletRepository.transactions = append(walletRepository.transactions, walletTransaction)
+	}
+
+	walletRepository.wallets = stagedWallets
+	return nil
+}
+
+// applyWalletTransaction moves the balance of the wallet in wallets like the conditional update of the postgres one,
+// the caller stores the returned wallet and transaction
+func (walletRepository *memoryWalletRepository) applyWalletTransaction(wallets map[string]wallet.Wallet, walletTransaction wallet.WalletTransactionEntity) (updatedWallet wallet.Wallet, _ wallet.WalletTransactionEntity, err error) {
+	updatedWallet, found := wallets[walletTransaction.WalletId]
+	if !found {
+		return updatedWallet, walletTransaction, response.ErrWalletNotFound
+	}
+	if err = updatedWallet.ValidateWalletStatus(); err != nil {
+		return updatedWallet, walletTransaction, err
+	}
+	if updatedWallet.Balance+walletTransaction.BalanceDelta() < 0 {
+		return updatedWallet, walletTransaction, response.ErrInsufficientFund
+	}
+
+	updatedWallet.Balance += walletTransaction.BalanceDelta()
+	updatedWallet.Version++
+
+	if walletTransaction.CreatedBy == "" {
+		walletTransaction.CreatedBy = updatedWallet.OwnedBy
+	}
+
+	return updatedWallet, walletTransaction, nil
+}
+
+// checkWallet stands in for the check constraints of ms_wallet
+func checkWallet(walletData wallet.Wallet) error {
+	if walletData.Balance < 0 {
+		return response.ErrInsufficientFund
+	}
+
+	switch walletData.Status {
+	case wallet.WALLET_STATUS_ENABLED, wallet.WALLET_STATUS_DISABLED, wallet.WALLET_STATUS_FROZEN:
+		return nil
+	}
+
+	return response.ErrBadRequest
+}
+
+// checkTransaction stands in for the constraints of tr_wallet_transaction, it must be called with mu held
+func (walletRepository *memoryWalletRepository) checkTransaction(walletTransaction wallet.WalletTransactionEntity) error {
+	if walletTransaction.Amount <= 0 || walletTransaction.Status != wallet.WALLET_TRANSACTION_STATUS_SUCCESS ||
+		(walletTransaction.Type != wallet.WALLET_TRANSACTION_DEPOSIT && walletTransaction.Type != wallet.WALLET_TRANSACTION_WITHDRAWAL) {
+		return response.ErrBadRequest
+	}
+
+	if _, found := walletRepository.wallets[walletTransaction.WalletId]; !found {
+		return response.ErrWalletNotFound
+	}
+
+	for _, storedTransaction := range walletRepository.transactions {
+		if storedTransaction.Id == walletTransaction.Id {
+			return fmt.Errorf("wallet transaction %s already exists", walletTransaction.Id)
+		}
+		if storedTransaction.WalletId == walletTransaction.WalletId && storedTransaction.ReferenceId == walletTransaction.ReferenceId {
+			return response.ErrReferenceIdConflict
+		}
+	}
+
+	return nil
+}
+
+func (walletRepository *memoryWalletRepository) GetWalletTransactionByReferenceId(ctx context.Context, walletId string, referenceId string) (res *wallet.WalletTransactionEntity, err error) {
+	walletRepository.mu.RLock()
+	defer walletRepository.mu.RUnlock()
+
+	for _, walletTransaction := range walletRepository.transactions {
+		if walletTransaction.WalletId == walletId && walletTransaction.ReferenceId == referenceId {
+			return &walletTransaction, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (walletRepository *memoryWalletRepository) GetWalletTransactionById(ctx context.Context, transactionId string) (res *wallet.WalletTransactionEntity, err error) {
+	walletRepository.mu.RLock()
+	defer walletRepository.mu.RUnlock()
+
+	for _, walletTransaction := range walletRepository.transactions {
+		if walletTransaction.Id == transactionId {
+			return &walletTransaction, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (walletRepository *memoryWalletRepository) GetWalletTransactionsByWalletId(ctx context.Context, req wallet.GetWalletTransactionRequest, page int, size int) (res []wallet.WalletTransactionEntity, err error) {
+	if page < 1 || size < 1 {
+		return nil, response.ErrBadRequest
+	}
+
+	res = []wallet.WalletTransactionEntity{}
+	if req.WalletId == "" {
+		return res, nil
+	}
+
+	walletTransactions := walletRepository.filterWalletTransactions(req)
+	slices.Reverse(walletTransactions)
+	if offset := (page - 1) * size; offset < len(walletTransactions) {
+		res = walletTransactions[offset:min(offset+size, len(walletTransactions))]
+	}
+
+	return res, nil
+}
+
+func (walletRepository *memoryWalletRepository) StreamWalletTransactions(ctx context.Context, req wallet.GetWalletTransactionRequest, yield func(walletTransaction wallet.WalletTransactionEntity) error) (err error) {
+	// yield runs on a snapshot, so it may call the repository itself
+	for _, walletTransaction := range walletRepository.filterWalletTransactions(req) {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = yield(walletTransaction); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// filterWalletTransactions returns a copy of the transactions matching req, oldest first like the postgres stream.
+// an empty req.WalletId matches every wallet
+func (walletRepository *memoryWalletRepository) filterWalletTransactions(req wallet.GetWalletTransactionRequest) []wallet.WalletTransactionEntity {
+	walletRepository.mu.RLock()
+	defer walletRepository.mu.RUnlock()
+
+	walletTransactions := []wallet.WalletTransactionEntity{}
+	for _, walletTransaction := range walletRepository.transactions {
+		if req.WalletId != "" && walletTransaction.WalletId != req.WalletId {
+			continue
+		}
+		if req.Type != nil && walletTransaction.Type != *req.Type {
+			continue
+		}
+		if req.CreatedBy != nil && walletTransaction.CreatedBy != *req.CreatedBy {
+			continue
+		}
+
+		createdAt, _ := time.Parse(time.RFC3339, walletTransaction.CreatedAt)
+		if req.From != nil && createdAt.Before(*req.From) {
+			continue
+		}
+		if req.To != nil && !createdAt.Before(*req.To) {
+			continue
+		}
+
+		walletTransactions = append(walletTransactions, walletTransaction)
+	}
+
+	// ids are time ordered, they settle the transactions made within the same second
+	sort.SliceStable(walletTransactions, func(i, j int) bool {
+		createdAtI, _ := time.Parse(time.RFC3339, walletTransactions[i].CreatedAt)
+		createdAtJ, _ := time.Parse(time.RFC3339, walletTransactions[j].CreatedAt)
+		if !createdAtI.Equal(createdAtJ) {
+			return createdAtI.Before(createdAtJ)
+		}
+
+		return walletTransactions[i].Id < walletTransactions[j].Id
+	})
+
+	return walletTransactions
+}
@@ -0,0 +1,96 @@
+package wallet
+
+import (
+	"context"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"time"
+)
+
+type instrumentedWalletRepository struct {
+	walletRepository wallet.WalletRepository
+}
+
+// NewInstrumentedWalletRepository records the latency of every repository call on PostgresCallDuration
+func NewInstrumentedWalletRepository(walletRepository wallet.WalletRepository) wallet.WalletRepository {
+	return &instrumentedWalletRepository{
+		walletRepository: walletRepository,
+	}
+}
+
+func (repository *instrumentedWalletRepository) GetCustomerWallet(ctx context.Context, customerId string) (res *wallet.Wallet, err error) {
+	defer observePostgresCall("get_customer_wallet", time.Now(), &err)
+	return repository.walletRepository.GetCustomerWallet(ctx, customerId)
+}
+
+func (repository *instrumentedWalletRepository) GetCustomerWallets(ctx context.Context, customerId string) (res []wallet.Wallet, err error) {
+	defer observePostgresCall("get_customer_wallets", time.Now(), &err)
+	return repository.walletRepository.GetCustomerWallets(ctx, customerId)
+}
+
+func (repository *instrumentedWalletRepository) GetWalletById(ctx context.Context, walletId string) (res *wallet.Wallet, err error) {
+	defer observePostgresCall("get_wallet_by_id", time.Now(), &err)
+	return repository.walletRepository.GetWalletById(ctx, walletId)
+}
+
+func (repository *instrumentedWalletRepository) GetWallets(ctx context.Context, afterId string, limit int) (res []wallet.Wallet, err error) {
+	defer observePostgresCall("get_wallets", time.Now(), &err)
+	return repository.walletRepository.GetWallets(ctx, afterId, limit)
+}
+
+func (repository *instrumentedWalletRepository) InsertWallet(ctx context.Context, walletData wallet.Wallet) (err error) {
+	defer observePostgresCall("insert_wallet", time.Now(), &err)
+	return repository.walletRepository.InsertWallet(ctx, walletData)
+}
+
+func (repository *instrumentedWalletRepository) UpdateWallet(ctx context.Context, walletData wallet.Wallet) (err error) {
+	defer observePostgresCall("update_wallet", time.Now(), &err)
+	return repository.walletRepository.UpdateWallet(ctx, walletData)
+}
+
+func (repository *instrumentedWalletRepository) CreateWalletTransaction(ctx context.Context, updatedWallet wallet.Wallet, walletTransaction wallet.WalletTransactionEntity) (err error) {
+	defer observePostgresCall("create_wallet_transaction", time.Now(), &err)
+	return repository.walletRepository.CreateWalletTransaction(ctx, updatedWallet, walletTransaction)
+}
+
+func (repository *instrumentedWalletRepository) CreateWalletTransactionForUpdate(ctx context.Context, walletId string, apply func(lockedWallet *wallet.Wallet) (walletTransaction wallet.WalletTransactionEntity, err error)) (res *wallet.Wallet, err error) {
+	defer observePostgresCall("create_wallet_transaction_for_update", time.Now(), &err)
+	return repository.walletRepository.CreateWalletTransactionForUpdate(ctx, walletId, apply)
+}
+
+func (repository *instrumentedWalletRepository) CreateWalletTransactionAtomically(ctx context.Context, walletTransaction wallet.WalletTransactionEntity) (res *wallet.Wallet, err error) {
+	defer observePostgresCall("create_wallet_transaction_atomically", time.Now(), &err)
+	return repository.walletRepository.CreateWalletTransactionAtomically(ctx, walletTransaction)
+}
+
+func (repository *instrumentedWalletRepository) CreateWalletTransactionsAtomically(ctx context.Context, walletTransactions []wallet.WalletTransactionEntity) (err error) {
+	defer observePostgresCall("create_wallet_transactions_atomically", time.Now(), &err)
+	return repository.walletRepository.CreateWalletTransactionsAtomically(ctx, walletTransactions)
+}
+
+func (repository *instrumentedWalletRepository) GetWalletTransactionByReferenceId(ctx context.Context, walletId string, referenceId string) (res *wallet.WalletTransactionEntity, err error) {
+	defer observePostgresCall("get_wallet_transaction_by_reference_id", time.Now(), &err)
+	return repository.walletRepository.GetWalletTransactionByReferenceId(ctx, walletId, referenceId)
+}
+
+func (repository *instrumentedWalletRepository) GetWalletTransactionById(ctx context.Context, transactionId string) (res *wallet.WalletTransactionEntity, err error) {
+	defer observePostgresCall("get_wallet_transaction_by_id", time.Now(), &err)
+	return repository.walletRepository.GetWalletTransactionById(ctx, transactionId)
+}
+
+func (repository *instrumentedWalletRepository) GetWalletTransactionsByWalletId(ctx context.Context, req wallet.GetWalletTransactionRequest, page int, size int) (res []wallet.WalletTransactionEntity, err error) {
+	defer observePostgresCall("get_wallet_transactions_by_wallet_id", time.Now(), &err)
+	return repository.walletRepository.GetWalletTransactionsByWalletId(ctx, req, page, size)
+}
+
+// StreamWalletTransactions observes the whole stream, the time spent in yield included
+func (repository *instrumentedWalletRepository) StreamWalletTransactions(ctx context.Context, req wallet.GetWalletTransactionRequest, yield func(walletTransaction wallet.WalletTransactionEntity) error) (err error) {
+	defer observePostgresCall("stream_wallet_transactions", time.Now(), &err)
+	return repository.walletRepository.StreamWalletTransactions(ctx, req, yield)
+}
+
+func observePostgresCall(operation string, startedAt time.Time, err *error) {
+	infrastructure.PostgresCallDuration.
+		WithLabelValues(operation, infrastructure.MetricOutcome(*err)).
+		Observe(time.Since(startedAt).Seconds())
+}
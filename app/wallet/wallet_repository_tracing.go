@@ -0,0 +1,125 @@
+package wallet
+
+import (
+	"context"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type tracedWalletRepository struct {
+	walletRepository wallet.WalletRepository
+}
+
+// NewTracedWalletRepository opens a span per repository call,
+// the statements themselves are traced as child spans by the gorm callbacks
+func NewTracedWalletRepository(walletRepository wallet.WalletRepository) wallet.WalletRepository {
+	return &tracedWalletRepository{
+		walletRepository: walletRepository,
+	}
+}
+
+func (repository *tracedWalletRepository) GetCustomerWallet(ctx context.Context, customerId string) (res *wallet.Wallet, err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.GetCustomerWallet")
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.GetCustomerWallet(ctx, customerId)
+}
+
+func (repository *tracedWalletRepository) GetCustomerWallets(ctx context.Context, customerId string) (res []wallet.Wallet, err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.GetCustomerWallets")
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.GetCustomerWallets(ctx, customerId)
+}
+
+func (repository *tracedWalletRepository) GetWalletById(ctx context.Context, walletId string) (res *wallet.Wallet, err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.GetWalletById", attribute.String("wallet.id", walletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.GetWalletById(ctx, walletId)
+}
+
+func (repository *tracedWalletRepository) GetWallets(ctx context.Context, afterId string, limit int) (res []wallet.Wallet, err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.GetWallets")
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.GetWallets(ctx, afterId, limit)
+}
+
+func (repository *tracedWalletRepository) InsertWallet(ctx context.Context, walletData wallet.Wallet) (err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.InsertWallet", attribute.String("wallet.id", walletData.Id))
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.InsertWallet(ctx, walletData)
+}
+
+func (repository *tracedWalletRepository) UpdateWallet(ctx context.Context, walletData wallet.Wallet) (err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.UpdateWallet", attribute.String("wallet.id", walletData.Id))
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.UpdateWallet(ctx, walletData)
+}
+
+func (repository *tracedWalletRepository) CreateWalletTransaction(ctx context.Context, updatedWallet wallet.Wallet, walletTransaction wallet.WalletTransactionEntity) (err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.CreateWalletTransaction",
+		attribute.String("wallet.id", walletTransaction.WalletId),
+		attribute.String("wallet.transaction.type", walletTransaction.Type),
+	)
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.CreateWalletTransaction(ctx, updatedWallet, walletTransaction)
+}
+
+func (repository *tracedWalletRepository) CreateWalletTransactionForUpdate(ctx context.Context, walletId string, apply func(lockedWallet *wallet.Wallet) (walletTransaction wallet.WalletTransactionEntity, err error)) (res *wallet.Wallet, err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.CreateWalletTransactionForUpdate", attribute.String("wallet.id", walletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.CreateWalletTransactionForUpdate(ctx, walletId, apply)
+}
+
+func (repository *tracedWalletRepository) CreateWalletTransactionAtomically(ctx context.Context, walletTransaction wallet.WalletTransactionEntity) (res *wallet.Wallet, err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.CreateWalletTransactionAtomically",
+		attribute.String("wallet.id", walletTransaction.WalletId),
+		attribute.String("wallet.transaction.type", walletTransaction.Type),
+	)
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.CreateWalletTransactionAtomically(ctx, walletTransaction)
+}
+
+func (repository *tracedWalletRepository) CreateWalletTransactionsAtomically(ctx context.Context, walletTransactions []wallet.WalletTransactionEntity) (err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.CreateWalletTransactionsAtomically", attribute.Int("wallet.transaction.count", len(walletTransactions)))
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.CreateWalletTransactionsAtomically(ctx, walletTransactions)
+}
+
+func (repository *tracedWalletRepository) GetWalletTransactionByReferenceId(ctx context.Context, walletId string, referenceId string) (res *wallet.WalletTransactionEntity, err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.GetWalletTransactionByReferenceId", attribute.String("wallet.id", walletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.GetWalletTransactionByReferenceId(ctx, walletId, referenceId)
+}
+
+func (repository *tracedWalletRepository) GetWalletTransactionById(ctx context.Context, transactionId string) (res *wallet.WalletTransactionEntity, err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.GetWalletTransactionById", attribute.String("wallet.transaction.id", transactionId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.GetWalletTransactionById(ctx, transactionId)
+}
+
+func (repository *tracedWalletRepository) GetWalletTransactionsByWalletId(ctx context.Context, req wallet.GetWalletTransactionRequest, page int, size int) (res []wallet.WalletTransactionEntity, err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.GetWalletTransactionsByWalletId", attribute.String("wallet.id", req.WalletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.GetWalletTransactionsByWalletId(ctx, req, page, size)
+}
+
+func (repository *tracedWalletRepository) StreamWalletTransactions(ctx context.Context, req wallet.GetWalletTransactionRequest, yield func(walletTransaction wallet.WalletTransactionEntity) error) (err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletRepository.StreamWalletTransactions", attribute.String("wallet.id", req.WalletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return repository.walletRepository.StreamWalletTransactions(ctx, req, yield)
+}
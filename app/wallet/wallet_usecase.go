@@ -2,38 +2,47 @@ package wallet
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"fmt"
+	"io"
+	"math/rand"
 	"mini-wallet/domain"
 	"mini-wallet/domain/common/response"
 	"mini-wallet/domain/wallet"
 	"mini-wallet/infrastructure"
+	"strings"
 	"time"
 
-	"github.com/go-redsync/redsync/v4"
 	"github.com/google/uuid"
 )
 
 const (
-	mutexKey = "wallet-%s"
+	optimisticAttempts  = 10
+	optimisticBackoffMs = 5
+
+	// a release still runs when the request ctx is already done, bounded by this
+	lockReleaseTimeout = time.Second * 2
+
+	// including the main pocket
+	maxCustomerPockets = 10
 )
 
 type walletUsecase struct {
 	walletRepository wallet.WalletRepository
 	cache            infrastructure.Cache
 	config           infrastructure.Config
-	mutexProvider    *redsync.Redsync
+	walletLocker     wallet.WalletLocker
 }
 
 func NewWalletUsecase(
 	repositories domain.Repositories,
 	cache infrastructure.Cache,
-	mutexProvider *redsync.Redsync,
+	walletLocker wallet.WalletLocker,
 	config infrastructure.Config) wallet.WalletUsecase {
 	return &walletUsecase{
 		walletRepository: repositories.WalletRepository,
 		cache:            cache,
-		mutexProvider:    mutexProvider,
+		walletLocker:     walletLocker,
 		config:           config,
 	}
 }
@@ -41,16 +50,16 @@ func NewWalletUsecase(
 func (usecase *walletUsecase) GetWalletBalance(ctx context.Context, walletId string) (res *response.Response[wallet.Wallet], err error) {
 	walletResult, err := usecase.walletRepository.GetWalletById(ctx, walletId)
 	if err != nil {
-		infrastructure.Log("got error on usecase.walletRepository.GetWalletById() - GetWalletBalance")
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - GetWalletBalance", err)
 		return nil, err
 	}
 
 	if walletResult == nil {
-		return nil, errors.New("wallet not found")
+		return nil, response.ErrWalletNotFound
 	}
 
 	if err = walletResult.ValidateWalletStatus(); err != nil {
-		return nil, errors.New(response.ERROR_WALLET_DISABLED)
+		return nil, response.ErrWalletDisabled
 	}
 
 	return &response.Response[wallet.Wallet]{
@@ -61,12 +70,17 @@ func (usecase *walletUsecase) GetWalletBalance(ctx context.Context, walletId str
 func (usecase *walletUsecase) EnableWallet(ctx context.Context, walletId string) (res *response.Response[wallet.Wallet], err error) {
 	walletResult, err := usecase.walletRepository.GetWalletById(ctx, walletId)
 	if err != nil {
-		infrastructure.Log("got error on usecase.walletRepository.GetWalletById() - EnableWallet")
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - EnableWallet", err)
 		return nil, err
 	}
 
 	if walletResult == nil {
-		return nil, errors.New("wallet not found")
+		return nil, response.ErrWalletNotFound
+	}
+
+	// only an admin lifts the freeze of the reconciliation
+	if walletResult.Status == wallet.WALLET_STATUS_FROZEN {
+		return nil, response.ErrWalletFrozen
 	}
 
 	walletResult.Status = wallet.WALLET_STATUS_ENABLED
@@ -74,7 +88,7 @@ func (usecase *walletUsecase) EnableWallet(ctx context.Context, walletId string)
 	walletResult.EnabledAt = &nowString
 	err = usecase.walletRepository.UpdateWallet(ctx, *walletResult)
 	if err != nil {
-		infrastructure.Log("got error on usecase.walletRepository.UpdateWallet() - EnableWallet")
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.UpdateWallet() - EnableWallet", err)
 		return nil, err
 	}
 
@@ -86,19 +100,24 @@ func (usecase *walletUsecase) EnableWallet(ctx context.Context, walletId string)
 func (usecase *walletUsecase) DisableWallet(ctx context.Context, walletId string) (res *response.Response[wallet.Wallet], err error) {
 	walletResult, err := usecase.walletRepository.GetWalletById(ctx, walletId)
 	if err != nil {
-		infrastructure.Log("got error on usecase.walletRepository.GetWalletById() - EnableWallet")
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - DisableWallet", err)
 		return nil, err
 	}
 
 	if walletResult == nil {
-		return nil, errors.New("wallet not found")
+		return nil, response.ErrWalletNotFound
+	}
+
+	// only an admin lifts the freeze of the reconciliation
+	if walletResult.Status == wallet.WALLET_STATUS_FROZEN {
+		return nil, response.ErrWalletFrozen
 	}
 
 	walletResult.Status = wallet.WALLET_STATUS_DISABLED
 	walletResult.EnabledAt = nil
 	err = usecase.walletRepository.UpdateWallet(ctx, *walletResult)
 	if err != nil {
-		infrastructure.Log("got error on usecase.walletRepository.UpdateWallet() - EnableWallet")
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.UpdateWallet() - DisableWallet", err)
 		return nil, err
 	}
 
@@ -108,50 +127,41 @@ func (usecase *walletUsecase) DisableWallet(ctx context.Context, walletId string
 }
 
 func (usecase *walletUsecase) CreateWalletTransaction(ctx context.Context, req wallet.WalletTransactionRequest) (res *response.Response[wallet.Wallet], err error) {
-	var successResponse *response.Response[wallet.Wallet]
-	var walletLock *redsync.Mutex
-
-	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	ctx, cancel := context.WithTimeout(ctx, usecase.config.WALLET_TRANSACTION_TIMEOUT)
 	defer cancel()
 
+	if limits := infrastructure.GetLimits(); limits.MAX_TRANSACTION_AMOUNT > 0 && req.Amount > limits.MAX_TRANSACTION_AMOUNT {
+		return nil, response.ErrBadRequest
+	}
+
 	walletResult, err := usecase.walletRepository.GetWalletById(ctx, req.WalletId)
 	if err != nil {
-		infrastructure.Log("got error on usecase.walletRepository.GetWalletById() - CreateWalletTransaction")
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - CreateWalletTransaction", err)
 		return nil, err
 	}
 
 	if walletResult == nil {
-		return nil, errors.New("wallet not found")
+		return nil, response.ErrWalletNotFound
 	}
 
 	if err = walletResult.ValidateWalletStatus(); err != nil {
-		return nil, errors.New(response.ERROR_WALLET_DISABLED)
+		return nil, response.ErrWalletDisabled
 	}
 
-	// check if reference id already used before
-	walletTransaction, err := usecase.walletRepository.GetWalletTransactionByReferenceId(ctx, req.ReferenceId)
+	// check if reference id already used before, the unique index catches the concurrent ones
+	walletTransaction, err := usecase.walletRepository.GetWalletTransactionByReferenceId(ctx, walletResult.Id, req.ReferenceId)
 	if err != nil {
-		infrastructure.Log("got error on usecase.walletRepository.GetWalletTransactionByReferenceId() - CreateWalletTransaction")
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletTransactionByReferenceId() - CreateWalletTransaction", err)
 		return nil, err
 	}
 
 	if walletTransaction != nil {
-		return nil, errors.New("reference id already used")
-	}
-
-	successResponse = &response.Response[wallet.Wallet]{
-		Data: walletResult,
+		return nil, response.ErrReferenceIdConflict
 	}
 
-	// err = usecase.cache.Publish(ctx, usecase.config.WALLET_TRANSACTION_CHANNEL, req)
-	// if err != nil {
-	// 	infrastructure.Log("got error on usecase.cache.Publish() - CreateWalletTransaction")
-	// 	return nil, err
-	// }
-
-	transactionId, err := uuid.NewV6()
+	transactionId, err := uuid.NewV7()
 	if err != nil {
-		infrastructure.Log("got error on uuid.NewV6()")
+		infrastructure.LogError(ctx, "got error on uuid.NewV7() - CreateWalletTransaction", err)
 		return nil, err
 	}
 
@@ -160,52 +170,214 @@ func (usecase *walletUsecase) CreateWalletTransaction(ctx context.Context, req w
 		WalletId:    walletResult.Id,
 		Amount:      req.Amount,
 		CreatedAt:   time.Now().Format(time.RFC3339),
-		CreatedBy:   walletResult.OwnedBy,
+		CreatedBy:   req.CreatedBy,
+		Type:        req.Type,
 		Status:      wallet.WALLET_TRANSACTION_STATUS_SUCCESS,
 		ReferenceId: req.ReferenceId,
 	}
 
-	if walletLock, err = usecase.getWalletLock(walletResult.Id); err != nil {
-		infrastructure.Log("got error on usecase.getWalletLock()")
-		return nil, errors.New("another process maybe still modifying this wallet")
+	if transactionEntity.CreatedBy == "" {
+		transactionEntity.CreatedBy = walletResult.OwnedBy
+	}
+
+	// the wallet read above is only used to fail fast,
+	// every strategy below decides on a balance nobody else can change in the meantime
+	switch usecase.config.WALLET_CONCURRENCY_STRATEGY {
+	case wallet.CONCURRENCY_STRATEGY_SELECT_FOR_UPDATE:
+		walletResult, err = usecase.walletRepository.CreateWalletTransactionForUpdate(ctx, walletResult.Id, func(lockedWallet *wallet.Wallet) (wallet.WalletTransactionEntity, error) {
+			return transactionEntity, lockedWallet.ApplyTransaction(transactionEntity)
+		})
+	case wallet.CONCURRENCY_STRATEGY_CONDITIONAL_UPDATE:
+		walletResult, err = usecase.walletRepository.CreateWalletTransactionAtomically(ctx, transactionEntity)
+	case wallet.CONCURRENCY_STRATEGY_OPTIMISTIC:
+		walletResult, err = usecase.createWalletTransactionOptimistically(ctx, transactionEntity)
+	default:
+		walletResult, err = usecase.createWalletTransactionWithLock(ctx, transactionEntity)
+	}
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on creating the wallet transaction - CreateWalletTransaction", err,
+			"strategy", usecase.config.WALLET_CONCURRENCY_STRATEGY, "type", req.Type)
+		return nil, err
+	}
+
+	usecase.publishWalletTransaction(ctx, transactionEntity)
+
+	return &response.Response[wallet.Wallet]{
+		Data: walletResult,
+	}, nil
+}
+
+// publishWalletTransaction tells the subscribers of WALLET_TRANSACTION_CHANNEL about a written transaction.
+// the transaction stands either way, a failure is only logged
+func (usecase *walletUsecase) publishWalletTransaction(ctx context.Context, transactionEntity wallet.WalletTransactionEntity) {
+	if usecase.cache == nil {
+		return
+	}
+
+	if err := usecase.cache.Publish(ctx, usecase.config.WALLET_TRANSACTION_CHANNEL, transactionEntity); err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.cache.Publish() - publishWalletTransaction", err, "wallet_id", transactionEntity.WalletId)
+	}
+}
+
+func (usecase *walletUsecase) SubscribeWalletTransactions(ctx context.Context, walletId string, yield func(walletTransaction wallet.WalletTransactionEntity) error) (err error) {
+	// every wallet shares the channel, the transactions of the others are skipped
+	events, err := usecase.cache.Subscribe(ctx, usecase.config.WALLET_TRANSACTION_CHANNEL)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.cache.Subscribe() - SubscribeWalletTransactions", err)
+		return err
+	}
+
+	for event := range events {
+		walletTransaction := wallet.WalletTransactionEntity{}
+		if err := json.Unmarshal(event.Payload, &walletTransaction); err != nil {
+			infrastructure.LogError(ctx, "got error on json.Unmarshal() payload - SubscribeWalletTransactions", err)
+			continue
+		}
+
+		if walletTransaction.WalletId != walletId {
+			continue
+		}
+
+		if err = yield(walletTransaction); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createWalletTransactionWithLock reads the wallet only once the wallet lock is held. adjustments, pocket moves and
+// all_or_nothing batches write without it and may still bump the version in between, the write is then made again
+func (usecase *walletUsecase) createWalletTransactionWithLock(ctx context.Context, transactionEntity wallet.WalletTransactionEntity) (res *wallet.Wallet, err error) {
+	err = usecase.withWalletLock(ctx, transactionEntity.WalletId, func(ctx context.Context, walletLock wallet.WalletLock) error {
+		res, err = usecase.retryConcurrentWrite(ctx, func() (*wallet.Wallet, error) {
+			walletResult, err := usecase.walletRepository.GetWalletById(ctx, transactionEntity.WalletId)
+			if err != nil {
+				return nil, err
+			}
+
+			if walletResult == nil {
+				return nil, response.ErrWalletNotFound
+			}
+
+			if err = walletResult.ApplyTransaction(transactionEntity); err != nil {
+				return nil, err
+			}
+
+			walletResult.LockToken = walletLock.Token()
+			if err = usecase.walletRepository.CreateWalletTransaction(ctx, *walletResult, transactionEntity); err != nil {
+				return nil, err
+			}
+
+			walletResult.Version++
+			return walletResult, nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// withWalletLock runs fn while holding the wallet lock, the lock is released on every return path
+// and extended every half TTL while fn runs. fn's ctx is cancelled when an extension fails,
+// the fencing token still rejects a write made after that
+func (usecase *walletUsecase) withWalletLock(ctx context.Context, walletId string, fn func(ctx context.Context, walletLock wallet.WalletLock) error) (err error) {
+	walletLock, err := usecase.walletLocker.Acquire(ctx, walletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletLocker.Acquire() - withWalletLock", err)
+		return response.ErrWalletLocked
 	}
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lockReleaseTimeout)
+		defer cancel()
+		if releaseErr := walletLock.Release(releaseCtx); releaseErr != nil {
+			infrastructure.LogError(ctx, "got error on walletLock.Release() - withWalletLock", releaseErr)
+		}
+	}()
+
+	fnCtx, cancel := context.WithCancel(ctx)
+	keepAliveDone := make(chan struct{})
+	defer func() { <-keepAliveDone }()
+	go func() {
+		defer close(keepAliveDone)
+
+		ticker := time.NewTicker(usecase.config.WALLET_LOCK_TTL / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-fnCtx.Done():
+				return
+			case <-ticker.C:
+				if err := walletLock.Extend(fnCtx); err != nil && fnCtx.Err() == nil {
+					infrastructure.LogError(ctx, "got error on walletLock.Extend() - withWalletLock", err)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
 
-	switch req.Type {
-	case wallet.WALLET_TRANSACTION_DEPOSIT:
-		walletResult.Balance += req.Amount
-		transactionEntity.Type = wallet.WALLET_TRANSACTION_DEPOSIT
+	// stops the keep alive before it is waited for, defers unwind in reverse order
+	defer cancel()
 
-		err = usecase.walletRepository.CreateWalletTransaction(ctx, *walletResult, transactionEntity)
+	return fn(fnCtx, walletLock)
+}
+
+// createWalletTransactionOptimistically retries the read-modify-write while another write wins the version race
+func (usecase *walletUsecase) createWalletTransactionOptimistically(ctx context.Context, transactionEntity wallet.WalletTransactionEntity) (res *wallet.Wallet, err error) {
+	return usecase.retryConcurrentWrite(ctx, func() (*wallet.Wallet, error) {
+		walletResult, err := usecase.walletRepository.GetWalletById(ctx, transactionEntity.WalletId)
 		if err != nil {
-			infrastructure.Log("got error on usecase.walletRepository.CreateWalletDeposit() - CreateWalletTransaction")
 			return nil, err
 		}
-	case wallet.WALLET_TRANSACTION_WITHDRAWAL:
-		if walletResult.Balance < req.Amount {
-			return nil, errors.New(response.ERROR_INSSUFICIENT_FUND)
+
+		if walletResult == nil {
+			return nil, response.ErrWalletNotFound
 		}
 
-		walletResult.Balance -= req.Amount
-		transactionEntity.Type = wallet.WALLET_TRANSACTION_WITHDRAWAL
-		err = usecase.walletRepository.CreateWalletTransaction(ctx, *walletResult, transactionEntity)
-		if err != nil {
-			infrastructure.Log("got error on usecase.walletRepository.CreateWalletWithdrawal() - CreateWalletTransaction")
+		if err = walletResult.ApplyTransaction(transactionEntity); err != nil {
 			return nil, err
 		}
-	}
 
-	if ok, err := walletLock.Unlock(); !ok || err != nil {
-		infrastructure.Log("got error on usecase.relaseWalletLock()")
-		return nil, err
+		if err = usecase.walletRepository.CreateWalletTransaction(ctx, *walletResult, transactionEntity); err != nil {
+			return nil, err
+		}
+
+		walletResult.Version++
+		return walletResult, nil
+	})
+}
+
+// retryConcurrentWrite runs write again, up to optimisticAttempts times, while it fails with ErrWalletConcurrent
+func (usecase *walletUsecase) retryConcurrentWrite(ctx context.Context, write func() (*wallet.Wallet, error)) (res *wallet.Wallet, err error) {
+	for attempt := 1; attempt <= optimisticAttempts; attempt++ {
+		res, err = write()
+		if !errors.Is(err, response.ErrWalletConcurrent) {
+			return res, err
+		}
+
+		// back off a little so the competing writers spread out
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(rand.Intn(attempt*optimisticBackoffMs)+1) * time.Millisecond):
+		}
 	}
 
-	return successResponse, nil
+	return nil, response.ErrWalletConcurrent
 }
 
-func (usecase *walletUsecase) GetWalletTransactions(ctx context.Context, walletId string) (res *response.Response[[]wallet.WalletTransaction], err error) {
-	walletTransactions, err := usecase.walletRepository.GetWalletTransactionsByWalletId(ctx, walletId, 1, 10)
+func (usecase *walletUsecase) GetWalletTransactions(ctx context.Context, req wallet.GetWalletTransactionRequest) (res *response.Response[[]wallet.WalletTransaction], err error) {
+	if err = req.Validate(); err != nil {
+		return nil, err
+	}
+
+	walletTransactions, err := usecase.walletRepository.GetWalletTransactionsByWalletId(ctx, req, 1, infrastructure.GetLimits().TRANSACTION_PAGE_SIZE)
 	if err != nil {
-		infrastructure.Log("got error on usecase.walletRepository.GetWalletTransactionByReferenceId() - CreateWalletTransaction")
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletTransactionsByWalletId() - GetWalletTransactions", err)
 		return nil, err
 	}
 
@@ -225,13 +397,255 @@ func (usecase *walletUsecase) GetWalletTransactions(ctx context.Context, walletI
 	}, nil
 }
 
-func (usecase *walletUsecase) getWalletLock(walletId string) (mutex *redsync.Mutex, err error) {
-	walletMutexKey := fmt.Sprintf(mutexKey, walletId)
-	walletMutex := usecase.mutexProvider.NewMutex(walletMutexKey)
+func (usecase *walletUsecase) ExportWalletTransactions(ctx context.Context, req wallet.WalletTransactionExportRequest, w io.Writer) (err error) {
+	if err = req.Validate(); err != nil {
+		return err
+	}
+
+	exporter := newTransactionExporter(req.Format, req.Columns, w)
+	if err = exporter.Begin(); err != nil {
+		return err
+	}
+
+	err = usecase.walletRepository.StreamWalletTransactions(ctx, req.Filter, func(walletTransaction wallet.WalletTransactionEntity) error {
+		return exporter.Write(&walletTransaction)
+	})
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.StreamWalletTransactions() - ExportWalletTransactions", err)
+		return err
+	}
+
+	return exporter.End()
+}
+
+func (usecase *walletUsecase) GetPockets(ctx context.Context, walletId string) (res *response.Response[[]wallet.Wallet], err error) {
+	walletResult, err := usecase.walletRepository.GetWalletById(ctx, walletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - GetPockets", err)
+		return nil, err
+	}
+
+	if walletResult == nil {
+		return nil, response.ErrWalletNotFound
+	}
 
-	if err := walletMutex.Lock(); err != nil {
+	pockets, err := usecase.walletRepository.GetCustomerWallets(ctx, walletResult.OwnedBy)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetCustomerWallets() - GetPockets", err)
 		return nil, err
 	}
 
-	return walletMutex, nil
+	return &response.Response[[]wallet.Wallet]{
+		Data: &pockets,
+	}, nil
+}
+
+// CreatePocket opens another enabled pocket with a zero balance for the owner of req.WalletId
+func (usecase *walletUsecase) CreatePocket(ctx context.Context, req wallet.PocketCreationRequest) (res *response.Response[wallet.Wallet], err error) {
+	walletResult, err := usecase.walletRepository.GetWalletById(ctx, req.WalletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - CreatePocket", err)
+		return nil, err
+	}
+
+	if walletResult == nil {
+		return nil, response.ErrWalletNotFound
+	}
+
+	pockets, err := usecase.walletRepository.GetCustomerWallets(ctx, walletResult.OwnedBy)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetCustomerWallets() - CreatePocket", err)
+		return nil, err
+	}
+
+	if len(pockets) >= maxCustomerPockets {
+		return nil, response.ErrPocketLimit
+	}
+
+	pocketId, err := uuid.NewV7()
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on uuid.NewV7() - CreatePocket", err)
+		return nil, err
+	}
+
+	nowString := time.Now().Format(time.RFC3339)
+	pocket := wallet.Wallet{
+		Id:        pocketId.String(),
+		OwnedBy:   walletResult.OwnedBy,
+		Name:      req.Name,
+		EnabledAt: &nowString,
+		Balance:   0,
+		Status:    wallet.WALLET_STATUS_ENABLED,
+	}
+
+	err = usecase.walletRepository.InsertWallet(ctx, pocket)
+	if err != nil && errors.Is(err, response.ErrWalletAlreadyExists) {
+		return nil, response.ErrPocketAlreadyExists
+	}
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.InsertWallet() - CreatePocket", err)
+		return nil, err
+	}
+
+	return &response.Response[wallet.Wallet]{
+		Data: &pocket,
+	}, nil
+}
+
+// MovePocketBalance withdraws from one pocket and deposits into the other in a single database transaction,
+// the shared reference id can not be used again on either pocket so a retried move is rejected instead of applied twice
+func (usecase *walletUsecase) MovePocketBalance(ctx context.Context, req wallet.PocketMoveRequest) (res *response.Response[wallet.PocketMove], err error) {
+	ctx, cancel := context.WithTimeout(ctx, usecase.config.WALLET_TRANSACTION_TIMEOUT)
+	defer cancel()
+
+	walletResult, err := usecase.walletRepository.GetWalletById(ctx, req.WalletId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - MovePocketBalance", err)
+		return nil, err
+	}
+
+	if walletResult == nil {
+		return nil, response.ErrWalletNotFound
+	}
+
+	for _, pocketId := range []string{req.FromPocketId, req.ToPocketId} {
+		pocket, err := usecase.walletRepository.GetWalletById(ctx, pocketId)
+		if err != nil {
+			infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - MovePocketBalance", err)
+			return nil, err
+		}
+
+		if pocket == nil || pocket.OwnedBy != walletResult.OwnedBy {
+			return nil, response.ErrPocketNotFound
+		}
+	}
+
+	withdrawalId, err := uuid.NewV7()
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on uuid.NewV7() - MovePocketBalance", err)
+		return nil, err
+	}
+
+	depositId, err := uuid.NewV7()
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on uuid.NewV7() - MovePocketBalance", err)
+		return nil, err
+	}
+
+	nowString := time.Now().Format(time.RFC3339)
+	moveTransactions := []wallet.WalletTransactionEntity{
+		{
+			Id:          withdrawalId.String(),
+			WalletId:    req.FromPocketId,
+			Amount:      req.Amount,
+			CreatedAt:   nowString,
+			CreatedBy:   walletResult.OwnedBy,
+			Type:        wallet.WALLET_TRANSACTION_WITHDRAWAL,
+			Status:      wallet.WALLET_TRANSACTION_STATUS_SUCCESS,
+			ReferenceId: req.ReferenceId,
+		},
+		{
+			Id:          depositId.String(),
+			WalletId:    req.ToPocketId,
+			Amount:      req.Amount,
+			CreatedAt:   nowString,
+			CreatedBy:   walletResult.OwnedBy,
+			Type:        wallet.WALLET_TRANSACTION_DEPOSIT,
+			Status:      wallet.WALLET_TRANSACTION_STATUS_SUCCESS,
+			ReferenceId: req.ReferenceId,
+		},
+	}
+	err = usecase.walletRepository.CreateWalletTransactionsAtomically(ctx, moveTransactions)
+	if itemErr := (*wallet.TransactionItemError)(nil); errors.As(err, &itemErr) {
+		// which leg failed says nothing more to the customer than the reason
+		err = itemErr.Err
+	}
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.CreateWalletTransactionsAtomically() - MovePocketBalance", err)
+		return nil, err
+	}
+
+	for _, moveTransaction := range moveTransactions {
+		usecase.publishWalletTransaction(ctx, moveTransaction)
+	}
+
+	fromPocket, err := usecase.walletRepository.GetWalletById(ctx, req.FromPocketId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - MovePocketBalance", err)
+		return nil, err
+	}
+
+	toPocket, err := usecase.walletRepository.GetWalletById(ctx, req.ToPocketId)
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.GetWalletById() - MovePocketBalance", err)
+		return nil, err
+	}
+
+	if fromPocket == nil || toPocket == nil {
+		return nil, response.ErrPocketNotFound
+	}
+
+	move := wallet.PocketMove{
+		ReferenceId: req.ReferenceId,
+		Amount:      req.Amount,
+		From:        *fromPocket,
+		To:          *toPocket,
+	}
+
+	return &response.Response[wallet.PocketMove]{
+		Data: &move,
+	}, nil
+}
+
+func (usecase *walletUsecase) AdjustWalletBalance(ctx context.Context, req wallet.WalletAdjustmentRequest) (res *response.Response[wallet.Wallet], err error) {
+	if err = req.Validate(); err != nil {
+		return nil, err
+	}
+
+	transactionId, err := uuid.NewV7()
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on uuid.NewV7() - AdjustWalletBalance", err)
+		return nil, err
+	}
+
+	// kept on the transaction, whoever reads the history later needs to know why the balance moved
+	reason := strings.TrimSpace(req.Reason)
+	transactionEntity := wallet.WalletTransactionEntity{
+		Id:          transactionId.String(),
+		WalletId:    req.WalletId,
+		Amount:      req.Amount,
+		CreatedAt:   time.Now().Format(time.RFC3339),
+		CreatedBy:   wallet.WALLET_TRANSACTION_CREATED_BY_OPS,
+		Type:        wallet.WALLET_TRANSACTION_DEPOSIT,
+		Status:      wallet.WALLET_TRANSACTION_STATUS_SUCCESS,
+		ReferenceId: req.ReferenceId,
+		Reason:      &reason,
+	}
+	if req.Amount < 0 {
+		transactionEntity.Type = wallet.WALLET_TRANSACTION_WITHDRAWAL
+		transactionEntity.Amount = -req.Amount
+	}
+
+	// the row lock and the version bump keep the adjustment safe next to every concurrency strategy,
+	// the status is left out on purpose: a frozen wallet is the one needing it
+	walletResult, err := usecase.walletRepository.CreateWalletTransactionForUpdate(ctx, req.WalletId, func(lockedWallet *wallet.Wallet) (wallet.WalletTransactionEntity, error) {
+		if lockedWallet.Balance+transactionEntity.BalanceDelta() < 0 {
+			return transactionEntity, response.ErrInsufficientFund
+		}
+		lockedWallet.Balance += transactionEntity.BalanceDelta()
+
+		return transactionEntity, nil
+	})
+	if err != nil {
+		infrastructure.LogError(ctx, "got error on usecase.walletRepository.CreateWalletTransactionForUpdate() - AdjustWalletBalance", err, "wallet_id", req.WalletId)
+		return nil, err
+	}
+
+	infrastructure.LogInfo(ctx, "wallet balance adjusted", "wallet_id", req.WalletId, "amount", req.Amount,
+		"reference_id", req.ReferenceId, "reason", req.Reason)
+	usecase.publishWalletTransaction(ctx, transactionEntity)
+
+	return &response.Response[wallet.Wallet]{
+		Data: walletResult,
+	}, nil
 }
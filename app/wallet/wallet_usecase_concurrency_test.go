@@ -0,0 +1,194 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"mini-wallet/domain"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// set to a disposable database, e.g. "host=localhost user=postgres password=postgres dbname=wallet_test sslmode=disable"
+const testPostgresDsnEnv = "MINI_WALLET_TEST_POSTGRES_DSN"
+
+func TestCreateWalletTransactionHasNoLostUpdates(t *testing.T) {
+	dsn := os.Getenv(testPostgresDsnEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testPostgresDsnEnv)
+	}
+
+	ctx := context.Background()
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("connecting to postgres: %v", err)
+	}
+	if err = infrastructure.Migrate(ctx, db, infrastructure.MIGRATE_UP); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	const (
+		initialBalance = 1000
+		deposits       = 50
+		withdrawals    = 50
+		amount         = 10
+	)
+
+	for _, strategy := range []string{
+		wallet.CONCURRENCY_STRATEGY_REDIS_LOCK,
+		wallet.CONCURRENCY_STRATEGY_SELECT_FOR_UPDATE,
+		wallet.CONCURRENCY_STRATEGY_CONDITIONAL_UPDATE,
+		wallet.CONCURRENCY_STRATEGY_OPTIMISTIC,
+	} {
+		t.Run(strategy, func(t *testing.T) {
+			repository := NewWalletRepository(db, nil)
+			// the in-process locker stands in for redis, the lock semantics are the same
+			usecase := NewWalletUsecase(domain.Repositories{WalletRepository: repository}, nil, NewMemoryWalletLocker(time.Second*8), infrastructure.Config{
+				WALLET_LOCK_TTL:             time.Second * 8,
+				WALLET_TRANSACTION_TIMEOUT:  time.Second * 30,
+				WALLET_CONCURRENCY_STRATEGY: strategy,
+			})
+
+			enabledAt := time.Now().Format(time.RFC3339)
+			testWallet := wallet.Wallet{
+				Id:        uuid.NewString(),
+				OwnedBy:   uuid.NewString(),
+				EnabledAt: &enabledAt,
+				Balance:   initialBalance,
+				Status:    wallet.WALLET_STATUS_ENABLED,
+			}
+			if err := repository.InsertWallet(ctx, testWallet); err != nil {
+				t.Fatalf("inserting wallet: %v", err)
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, deposits+withdrawals)
+			for i := 0; i < deposits+withdrawals; i++ {
+				transactionType := wallet.WALLET_TRANSACTION_DEPOSIT
+				if i%2 == 1 {
+					transactionType = wallet.WALLET_TRANSACTION_WITHDRAWAL
+				}
+
+				wg.Add(1)
+				go func(i int, transactionType string) {
+					defer wg.Done()
+					_, err := usecase.CreateWalletTransaction(ctx, wallet.WalletTransactionRequest{
+						WalletId:    testWallet.Id,
+						Type:        transactionType,
+						Amount:      amount,
+						ReferenceId: fmt.Sprintf("%s-%d", strategy, i),
+					})
+					if err != nil {
+						errs <- fmt.Errorf("%s #%d: %w", transactionType, i, err)
+					}
+				}(i, transactionType)
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				t.Error(err)
+			}
+
+			result, err := repository.GetWalletById(ctx, testWallet.Id)
+			if err != nil || result == nil {
+				t.Fatalf("reading wallet: %v", err)
+			}
+			if want := initialBalance + (deposits-withdrawals)*amount; result.Balance != want {
+				t.Errorf("balance = %d, want %d", result.Balance, want)
+			}
+
+			var transactionCount int64
+			if err := db.Table("tr_wallet_transaction").Where("wallet_id = ?", testWallet.Id).Count(&transactionCount).Error; err != nil {
+				t.Fatalf("counting transactions: %v", err)
+			}
+			if transactionCount != deposits+withdrawals {
+				t.Errorf("transactions = %d, want %d", transactionCount, deposits+withdrawals)
+			}
+		})
+	}
+}
+
+// interleavingRepository adjusts the wallet right after the read numbered interleaveAfter, the way an adjustment
+// or a pocket move landing between the read and the write of a locked transaction would
+type interleavingRepository struct {
+	wallet.WalletRepository
+	interleaveAfter int
+	reads           int
+}
+
+func (repository *interleavingRepository) GetWalletById(ctx context.Context, walletId string) (*wallet.Wallet, error) {
+	walletResult, err := repository.WalletRepository.GetWalletById(ctx, walletId)
+	repository.reads++
+	if err != nil || repository.reads != repository.interleaveAfter {
+		return walletResult, err
+	}
+
+	_, err = repository.CreateWalletTransactionForUpdate(ctx, walletId, func(lockedWallet *wallet.Wallet) (wallet.WalletTransactionEntity, error) {
+		lockedWallet.Balance += 5
+		return wallet.WalletTransactionEntity{
+			Id:          uuid.NewString(),
+			WalletId:    walletId,
+			Amount:      5,
+			CreatedAt:   time.Now().Format(time.RFC3339),
+			CreatedBy:   wallet.WALLET_TRANSACTION_CREATED_BY_OPS,
+			Type:        wallet.WALLET_TRANSACTION_DEPOSIT,
+			Status:      wallet.WALLET_TRANSACTION_STATUS_SUCCESS,
+			ReferenceId: "interleaved",
+		}, nil
+	})
+
+	return walletResult, err
+}
+
+// adjustments and pocket moves bump the version without the wallet lock,
+// a locked deposit racing one of them is written again instead of failing
+func TestLockedTransactionSurvivesAnUnlockedWrite(t *testing.T) {
+	ctx := context.Background()
+	memoryRepository := NewMemoryWalletRepository()
+
+	enabledAt := time.Now().Format(time.RFC3339)
+	testWallet := wallet.Wallet{
+		Id:        uuid.NewString(),
+		OwnedBy:   uuid.NewString(),
+		EnabledAt: &enabledAt,
+		Balance:   1000,
+		Status:    wallet.WALLET_STATUS_ENABLED,
+	}
+	if err := memoryRepository.InsertWallet(ctx, testWallet); err != nil {
+		t.Fatalf("inserting wallet: %v", err)
+	}
+
+	// the first read only fails fast, the second one is made under the wallet lock
+	repository := &interleavingRepository{WalletRepository: memoryRepository, interleaveAfter: 2}
+	usecase := NewWalletUsecase(domain.Repositories{WalletRepository: repository}, nil, NewMemoryWalletLocker(time.Second*8), infrastructure.Config{
+		WALLET_LOCK_TTL:             time.Second * 8,
+		WALLET_TRANSACTION_TIMEOUT:  time.Second * 30,
+		WALLET_CONCURRENCY_STRATEGY: wallet.CONCURRENCY_STRATEGY_REDIS_LOCK,
+	})
+
+	_, err := usecase.CreateWalletTransaction(ctx, wallet.WalletTransactionRequest{
+		WalletId:    testWallet.Id,
+		Type:        wallet.WALLET_TRANSACTION_DEPOSIT,
+		Amount:      10,
+		ReferenceId: "deposit-1",
+	})
+	if err != nil {
+		t.Fatalf("deposit: %v", err)
+	}
+
+	result, err := memoryRepository.GetWalletById(ctx, testWallet.Id)
+	if err != nil || result == nil {
+		t.Fatalf("reading wallet: %v", err)
+	}
+	if result.Balance != 1015 {
+		t.Errorf("balance = %d, want 1015", result.Balance)
+	}
+}
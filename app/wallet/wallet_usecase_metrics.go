@@ -0,0 +1,56 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"strings"
+)
+
+type instrumentedWalletUsecase struct {
+	wallet.WalletUsecase
+}
+
+// NewInstrumentedWalletUsecase counts deposits and withdrawals by outcome,
+// the remaining methods are passed through untouched
+func NewInstrumentedWalletUsecase(walletUsecase wallet.WalletUsecase) wallet.WalletUsecase {
+	return &instrumentedWalletUsecase{
+		WalletUsecase: walletUsecase,
+	}
+}
+
+func (usecase *instrumentedWalletUsecase) CreateWalletTransaction(ctx context.Context, req wallet.WalletTransactionRequest) (res *response.Response[wallet.Wallet], err error) {
+	res, err = usecase.WalletUsecase.CreateWalletTransaction(ctx, req)
+
+	outcome := transactionOutcome(err)
+	infrastructure.WalletTransactionsTotal.WithLabelValues(req.Type, outcome).Inc()
+
+	if err == nil {
+		infrastructure.WalletTransactionAmount.WithLabelValues(req.Type).Observe(float64(req.Amount))
+	}
+
+	if err != nil && errors.Is(err, response.ErrReferenceIdConflict) {
+		infrastructure.WalletReferenceIdConflictsTotal.Inc()
+	}
+
+	return res, err
+}
+
+// transactionOutcome turns known user errors into a label, e.g. "insufficient fund" -> insufficient_fund
+func transactionOutcome(err error) string {
+	if err == nil {
+		return infrastructure.METRIC_OUTCOME_SUCCESS
+	}
+
+	switch domainError := response.DomainErrorOf(err); domainError {
+	case response.ErrWalletDisabled,
+		response.ErrWalletNotFound,
+		response.ErrInsufficientFund,
+		response.ErrReferenceIdConflict:
+		return strings.ReplaceAll(domainError.Message, " ", "_")
+	}
+
+	return infrastructure.METRIC_OUTCOME_ERROR
+}
@@ -0,0 +1,99 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestMovePocketBalance(t *testing.T) {
+	dsn := os.Getenv(testPostgresDsnEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testPostgresDsnEnv)
+	}
+
+	ctx := context.Background()
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("connecting to postgres: %v", err)
+	}
+	if err = infrastructure.Migrate(ctx, db, infrastructure.MIGRATE_UP); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	repository := NewWalletRepository(db, nil)
+	usecase := NewWalletUsecase(domain.Repositories{WalletRepository: repository}, nil, NewMemoryWalletLocker(time.Second*8), infrastructure.Config{
+		WALLET_LOCK_TTL:            time.Second * 8,
+		WALLET_TRANSACTION_TIMEOUT: time.Second * 30,
+	})
+
+	enabledAt := time.Now().Format(time.RFC3339)
+	mainPocket := wallet.Wallet{
+		Id:        uuid.NewString(),
+		OwnedBy:   uuid.NewString(),
+		Name:      wallet.WALLET_MAIN_POCKET,
+		EnabledAt: &enabledAt,
+		Balance:   1000,
+		Status:    wallet.WALLET_STATUS_ENABLED,
+	}
+	if err := repository.InsertWallet(ctx, mainPocket); err != nil {
+		t.Fatalf("inserting wallet: %v", err)
+	}
+
+	savings, err := usecase.CreatePocket(ctx, wallet.PocketCreationRequest{WalletId: mainPocket.Id, Name: "Savings"})
+	if err != nil {
+		t.Fatalf("creating pocket: %v", err)
+	}
+	if _, err := usecase.CreatePocket(ctx, wallet.PocketCreationRequest{WalletId: mainPocket.Id, Name: "Savings"}); err == nil || !errors.Is(err, response.ErrPocketAlreadyExists) {
+		t.Errorf("creating the same pocket again: err = %v, want %q", err, response.ERROR_POCKET_ALREADY_EXISTS)
+	}
+
+	move := wallet.PocketMoveRequest{
+		WalletId:     mainPocket.Id,
+		FromPocketId: mainPocket.Id,
+		ToPocketId:   savings.Data.Id,
+		Amount:       400,
+		ReferenceId:  uuid.NewString(),
+	}
+	result, err := usecase.MovePocketBalance(ctx, move)
+	if err != nil {
+		t.Fatalf("moving: %v", err)
+	}
+	if result.Data.From.Balance != 600 || result.Data.To.Balance != 400 {
+		t.Errorf("balances = %d/%d, want 600/400", result.Data.From.Balance, result.Data.To.Balance)
+	}
+
+	if _, err := usecase.MovePocketBalance(ctx, move); err == nil || !errors.Is(err, response.ErrReferenceIdConflict) {
+		t.Errorf("repeating the move: err = %v, want %q", err, response.ERROR_REFERENCE_ID_CONFLICT)
+	}
+
+	move.ReferenceId = uuid.NewString()
+	move.Amount = 601
+	if _, err := usecase.MovePocketBalance(ctx, move); err == nil || !errors.Is(err, response.ErrInsufficientFund) {
+		t.Errorf("moving more than the balance: err = %v, want %q", err, response.ERROR_INSSUFICIENT_FUND)
+	}
+
+	move.ToPocketId = uuid.NewString()
+	move.Amount = 1
+	if _, err := usecase.MovePocketBalance(ctx, move); err == nil || !errors.Is(err, response.ErrPocketNotFound) {
+		t.Errorf("moving to a pocket of nobody: err = %v, want %q", err, response.ERROR_POCKET_NOT_FOUND)
+	}
+
+	pockets, err := usecase.GetPockets(ctx, savings.Data.Id)
+	if err != nil {
+		t.Fatalf("listing pockets: %v", err)
+	}
+	if len(*pockets.Data) != 2 {
+		t.Errorf("pockets = %d, want 2", len(*pockets.Data))
+	}
+}
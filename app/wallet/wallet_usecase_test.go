@@ -0,0 +1,312 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var testStrategies = []string{
+	wallet.CONCURRENCY_STRATEGY_REDIS_LOCK,
+	wallet.CONCURRENCY_STRATEGY_SELECT_FOR_UPDATE,
+	wallet.CONCURRENCY_STRATEGY_CONDITIONAL_UPDATE,
+	wallet.CONCURRENCY_STRATEGY_OPTIMISTIC,
+}
+
+// newMemoryTestUsecase runs the usecase on the memory repository, cache and locker, like the memory storage does
+func newMemoryTestUsecase(t *testing.T, strategy string) (wallet.WalletUsecase, wallet.WalletRepository) {
+	t.Helper()
+
+	repository := NewMemoryWalletRepository()
+	usecase := NewWalletUsecase(domain.Repositories{WalletRepository: repository}, infrastructure.NewMemoryCache(), NewMemoryWalletLocker(time.Second*8), infrastructure.Config{
+		WALLET_LOCK_TTL:             time.Second * 8,
+		WALLET_TRANSACTION_TIMEOUT:  time.Second * 30,
+		WALLET_CONCURRENCY_STRATEGY: strategy,
+		WALLET_TRANSACTION_CHANNEL:  "wallet-transactions",
+	})
+
+	return usecase, repository
+}
+
+func insertTestWallet(t *testing.T, repository wallet.WalletRepository, status string, balance int) wallet.Wallet {
+	t.Helper()
+
+	enabledAt := time.Now().Format(time.RFC3339)
+	testWallet := wallet.Wallet{
+		Id:        uuid.NewString(),
+		OwnedBy:   uuid.NewString(),
+		Name:      wallet.WALLET_MAIN_POCKET,
+		EnabledAt: &enabledAt,
+		Balance:   balance,
+		Status:    status,
+	}
+	if err := repository.InsertWallet(context.Background(), testWallet); err != nil {
+		t.Fatalf("inserting wallet: %v", err)
+	}
+
+	return testWallet
+}
+
+func TestCreateWalletTransaction(t *testing.T) {
+	for _, strategy := range testStrategies {
+		t.Run(strategy, func(t *testing.T) {
+			ctx := context.Background()
+			usecase, repository := newMemoryTestUsecase(t, strategy)
+			testWallet := insertTestWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+
+			deposited, err := usecase.CreateWalletTransaction(ctx, wallet.WalletTransactionRequest{
+				WalletId: testWallet.Id, Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: 50, ReferenceId: "ref-1",
+			})
+			if err != nil || deposited.Data.Balance != 150 {
+				t.Fatalf("deposit = %v %v, want a balance of 150", deposited, err)
+			}
+
+			withdrawn, err := usecase.CreateWalletTransaction(ctx, wallet.WalletTransactionRequest{
+				WalletId: testWallet.Id, Type: wallet.WALLET_TRANSACTION_WITHDRAWAL, Amount: 30, ReferenceId: "ref-2",
+			})
+			if err != nil || withdrawn.Data.Balance != 120 {
+				t.Fatalf("withdrawal = %v %v, want a balance of 120", withdrawn, err)
+			}
+
+			for _, testCase := range []struct {
+				name string
+				req  wallet.WalletTransactionRequest
+				want error
+			}{
+				{"a reused reference id", wallet.WalletTransactionRequest{WalletId: testWallet.Id, Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: 10, ReferenceId: "ref-1"}, response.ErrReferenceIdConflict},
+				{"more than the balance", wallet.WalletTransactionRequest{WalletId: testWallet.Id, Type: wallet.WALLET_TRANSACTION_WITHDRAWAL, Amount: 121, ReferenceId: "ref-3"}, response.ErrInsufficientFund},
+				{"an unknown wallet", wallet.WalletTransactionRequest{WalletId: uuid.NewString(), Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: 10, ReferenceId: "ref-4"}, response.ErrWalletNotFound},
+			} {
+				if _, err := usecase.CreateWalletTransaction(ctx, testCase.req); !errors.Is(err, testCase.want) {
+					t.Errorf("%s: err = %v, want %v", testCase.name, err, testCase.want)
+				}
+			}
+
+			balance, err := usecase.GetWalletBalance(ctx, testWallet.Id)
+			if err != nil || balance.Data.Balance != 120 {
+				t.Errorf("balance after the refused transactions = %v %v, want 120", balance, err)
+			}
+		})
+	}
+}
+
+func TestCreateWalletTransactionOnDisabledWallet(t *testing.T) {
+	ctx := context.Background()
+	usecase, repository := newMemoryTestUsecase(t, wallet.CONCURRENCY_STRATEGY_REDIS_LOCK)
+	testWallet := insertTestWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+
+	if _, err := usecase.DisableWallet(ctx, testWallet.Id); err != nil {
+		t.Fatalf("disabling: %v", err)
+	}
+	_, err := usecase.CreateWalletTransaction(ctx, wallet.WalletTransactionRequest{
+		WalletId: testWallet.Id, Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: 10, ReferenceId: "ref-1",
+	})
+	if !errors.Is(err, response.ErrWalletDisabled) {
+		t.Errorf("deposit on a disabled wallet: err = %v, want %v", err, response.ErrWalletDisabled)
+	}
+	if _, err = usecase.GetWalletBalance(ctx, testWallet.Id); !errors.Is(err, response.ErrWalletDisabled) {
+		t.Errorf("balance of a disabled wallet: err = %v, want %v", err, response.ErrWalletDisabled)
+	}
+
+	enabled, err := usecase.EnableWallet(ctx, testWallet.Id)
+	if err != nil || enabled.Data.Status != wallet.WALLET_STATUS_ENABLED || enabled.Data.EnabledAt == nil {
+		t.Fatalf("enabling = %v %v", enabled, err)
+	}
+	if _, err = usecase.CreateWalletTransaction(ctx, wallet.WalletTransactionRequest{
+		WalletId: testWallet.Id, Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: 10, ReferenceId: "ref-1",
+	}); err != nil {
+		t.Errorf("deposit once enabled again: %v", err)
+	}
+}
+
+func TestCreateWalletTransactionHasNoLostUpdatesInMemory(t *testing.T) {
+	const (
+		initialBalance = 1000
+		deposits       = 50
+		withdrawals    = 50
+		amount         = 10
+	)
+
+	for _, strategy := range testStrategies {
+		t.Run(strategy, func(t *testing.T) {
+			ctx := context.Background()
+			usecase, repository := newMemoryTestUsecase(t, strategy)
+			testWallet := insertTestWallet(t, repository, wallet.WALLET_STATUS_ENABLED, initialBalance)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, deposits+withdrawals)
+			for i := 0; i < deposits+withdrawals; i++ {
+				transactionType := wallet.WALLET_TRANSACTION_DEPOSIT
+				if i%2 == 1 {
+					transactionType = wallet.WALLET_TRANSACTION_WITHDRAWAL
+				}
+
+				wg.Add(1)
+				go func(i int, transactionType string) {
+					defer wg.Done()
+					_, err := usecase.CreateWalletTransaction(ctx, wallet.WalletTransactionRequest{
+						WalletId:    testWallet.Id,
+						Type:        transactionType,
+						Amount:      amount,
+						ReferenceId: fmt.Sprintf("%s-%d", strategy, i),
+					})
+					if err != nil {
+						errs <- fmt.Errorf("%s #%d: %w", transactionType, i, err)
+					}
+				}(i, transactionType)
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				t.Error(err)
+			}
+
+			result, err := repository.GetWalletById(ctx, testWallet.Id)
+			if err != nil || result == nil {
+				t.Fatalf("reading wallet: %v", err)
+			}
+			if want := initialBalance + (deposits-withdrawals)*amount; result.Balance != want {
+				t.Errorf("balance = %d, want %d", result.Balance, want)
+			}
+
+			transactions, err := repository.GetWalletTransactionsByWalletId(ctx, wallet.GetWalletTransactionRequest{WalletId: testWallet.Id}, 1, deposits+withdrawals+1)
+			if err != nil || len(transactions) != deposits+withdrawals {
+				t.Errorf("transactions = %d %v, want %d", len(transactions), err, deposits+withdrawals)
+			}
+		})
+	}
+}
+
+func TestGetWalletTransactionsFilters(t *testing.T) {
+	ctx := context.Background()
+	usecase, repository := newMemoryTestUsecase(t, wallet.CONCURRENCY_STRATEGY_CONDITIONAL_UPDATE)
+	testWallet := insertTestWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+	otherWallet := insertTestWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+
+	for i, req := range []wallet.WalletTransactionRequest{
+		{WalletId: testWallet.Id, Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: 10},
+		{WalletId: testWallet.Id, Type: wallet.WALLET_TRANSACTION_WITHDRAWAL, Amount: 20},
+		{WalletId: testWallet.Id, Type: wallet.WALLET_TRANSACTION_WITHDRAWAL, Amount: 30},
+		{WalletId: otherWallet.Id, Type: wallet.WALLET_TRANSACTION_WITHDRAWAL, Amount: 40},
+	} {
+		req.ReferenceId = fmt.Sprintf("ref-%d", i)
+		if _, err := usecase.CreateWalletTransaction(ctx, req); err != nil {
+			t.Fatalf("transaction #%d: %v", i, err)
+		}
+	}
+
+	withdrawal := wallet.WALLET_TRANSACTION_WITHDRAWAL
+	result, err := usecase.GetWalletTransactions(ctx, wallet.GetWalletTransactionRequest{WalletId: testWallet.Id, Type: &withdrawal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transactions := *result.Data; len(transactions) != 2 || transactions[0].Amount != 30 || transactions[1].Amount != 20 {
+		t.Errorf("withdrawals = %+v, want the 30 and the 20 of the wallet, newest first", transactions)
+	}
+
+	future := time.Now().Add(time.Hour)
+	result, err = usecase.GetWalletTransactions(ctx, wallet.GetWalletTransactionRequest{WalletId: testWallet.Id, From: &future})
+	if err != nil || len(*result.Data) != 0 {
+		t.Errorf("transactions from an hour on = %v %v, want none", result, err)
+	}
+}
+
+func TestAdjustWalletBalance(t *testing.T) {
+	ctx := context.Background()
+	usecase, repository := newMemoryTestUsecase(t, wallet.CONCURRENCY_STRATEGY_REDIS_LOCK)
+	// adjustments are made on the wallets the reconciliation froze
+	testWallet := insertTestWallet(t, repository, wallet.WALLET_STATUS_FROZEN, 100)
+
+	adjusted, err := usecase.AdjustWalletBalance(ctx, wallet.WalletAdjustmentRequest{
+		WalletId: testWallet.Id, Amount: -40, Reason: "double settlement", ReferenceId: "adjust-1",
+	})
+	if err != nil || adjusted.Data.Balance != 60 || adjusted.Data.Status != wallet.WALLET_STATUS_FROZEN {
+		t.Fatalf("adjustment = %v %v, want a frozen wallet with 60", adjusted, err)
+	}
+
+	adjustment, err := repository.GetWalletTransactionByReferenceId(ctx, testWallet.Id, "adjust-1")
+	if err != nil || adjustment == nil {
+		t.Fatalf("adjustment transaction = %v %v", adjustment, err)
+	}
+	if adjustment.Type != wallet.WALLET_TRANSACTION_WITHDRAWAL || adjustment.Amount != 40 || adjustment.CreatedBy != wallet.WALLET_TRANSACTION_CREATED_BY_OPS {
+		t.Errorf("adjustment transaction = %+v, want a withdrawal of 40 by ops", adjustment)
+	}
+
+	for _, testCase := range []struct {
+		name string
+		req  wallet.WalletAdjustmentRequest
+		want error
+	}{
+		{"below zero", wallet.WalletAdjustmentRequest{WalletId: testWallet.Id, Amount: -61, Reason: "too much", ReferenceId: "adjust-2"}, response.ErrInsufficientFund},
+		{"a reused reference id", wallet.WalletAdjustmentRequest{WalletId: testWallet.Id, Amount: 10, Reason: "again", ReferenceId: "adjust-1"}, response.ErrReferenceIdConflict},
+		{"an unknown wallet", wallet.WalletAdjustmentRequest{WalletId: uuid.NewString(), Amount: 10, Reason: "unknown", ReferenceId: "adjust-3"}, response.ErrWalletNotFound},
+	} {
+		if _, err := usecase.AdjustWalletBalance(ctx, testCase.req); !errors.Is(err, testCase.want) {
+			t.Errorf("%s: err = %v, want %v", testCase.name, err, testCase.want)
+		}
+	}
+
+	var validationError *response.ValidationError
+	if _, err = usecase.AdjustWalletBalance(ctx, wallet.WalletAdjustmentRequest{WalletId: testWallet.Id, ReferenceId: "adjust-4"}); !errors.As(err, &validationError) {
+		t.Errorf("an adjustment without amount and reason: err = %v, want a validation error", err)
+	}
+}
+
+func TestSubscribeWalletTransactions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	usecase, repository := newMemoryTestUsecase(t, wallet.CONCURRENCY_STRATEGY_REDIS_LOCK)
+	testWallet := insertTestWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+	otherWallet := insertTestWallet(t, repository, wallet.WALLET_STATUS_ENABLED, 100)
+
+	received := make(chan wallet.WalletTransactionEntity)
+	subscribed := make(chan error, 1)
+	go func() {
+		subscribed <- usecase.SubscribeWalletTransactions(ctx, testWallet.Id, func(walletTransaction wallet.WalletTransactionEntity) error {
+			select {
+			case received <- walletTransaction:
+			case <-ctx.Done():
+			}
+			return nil
+		})
+	}()
+
+	// the subscription is only in place once the first transaction arrives, keep depositing until it does
+	var event wallet.WalletTransactionEntity
+	for i := 0; event.Id == ""; i++ {
+		if _, err := usecase.CreateWalletTransaction(ctx, wallet.WalletTransactionRequest{
+			WalletId: otherWallet.Id, Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: 1, ReferenceId: fmt.Sprintf("other-%d", i),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := usecase.CreateWalletTransaction(ctx, wallet.WalletTransactionRequest{
+			WalletId: testWallet.Id, Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: 5, ReferenceId: fmt.Sprintf("ref-%d", i),
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case event = <-received:
+		case <-time.After(time.Millisecond * 50):
+		}
+	}
+	if event.WalletId != testWallet.Id || event.Amount != 5 {
+		t.Errorf("event = %+v, want a deposit of 5 on the subscribed wallet only", event)
+	}
+
+	cancel()
+	if err := <-subscribed; err != nil {
+		t.Errorf("subscription ended with %v, want nil once ctx is done", err)
+	}
+}
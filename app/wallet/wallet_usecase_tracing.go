@@ -0,0 +1,112 @@
+package wallet
+
+import (
+	"context"
+	"io"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type tracedWalletUsecase struct {
+	walletUsecase wallet.WalletUsecase
+}
+
+func NewTracedWalletUsecase(walletUsecase wallet.WalletUsecase) wallet.WalletUsecase {
+	return &tracedWalletUsecase{
+		walletUsecase: walletUsecase,
+	}
+}
+
+func (usecase *tracedWalletUsecase) EnableWallet(ctx context.Context, walletId string) (res *response.Response[wallet.Wallet], err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletUsecase.EnableWallet", attribute.String("wallet.id", walletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return usecase.walletUsecase.EnableWallet(ctx, walletId)
+}
+
+func (usecase *tracedWalletUsecase) DisableWallet(ctx context.Context, walletId string) (res *response.Response[wallet.Wallet], err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletUsecase.DisableWallet", attribute.String("wallet.id", walletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return usecase.walletUsecase.DisableWallet(ctx, walletId)
+}
+
+func (usecase *tracedWalletUsecase) GetWalletBalance(ctx context.Context, walletId string) (res *response.Response[wallet.Wallet], err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletUsecase.GetWalletBalance", attribute.String("wallet.id", walletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return usecase.walletUsecase.GetWalletBalance(ctx, walletId)
+}
+
+func (usecase *tracedWalletUsecase) CreateWalletTransaction(ctx context.Context, req wallet.WalletTransactionRequest) (res *response.Response[wallet.Wallet], err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletUsecase.CreateWalletTransaction",
+		attribute.String("wallet.id", req.WalletId),
+		attribute.String("wallet.transaction.type", req.Type),
+		attribute.Int("wallet.transaction.amount", req.Amount),
+	)
+	defer infrastructure.EndSpan(span, &err)
+
+	return usecase.walletUsecase.CreateWalletTransaction(ctx, req)
+}
+
+func (usecase *tracedWalletUsecase) GetWalletTransactions(ctx context.Context, req wallet.GetWalletTransactionRequest) (res *response.Response[[]wallet.WalletTransaction], err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletUsecase.GetWalletTransactions", attribute.String("wallet.id", req.WalletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return usecase.walletUsecase.GetWalletTransactions(ctx, req)
+}
+
+func (usecase *tracedWalletUsecase) ExportWalletTransactions(ctx context.Context, req wallet.WalletTransactionExportRequest, w io.Writer) (err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletUsecase.ExportWalletTransactions",
+		attribute.String("wallet.id", req.Filter.WalletId),
+		attribute.String("wallet.export.format", req.Format),
+	)
+	defer infrastructure.EndSpan(span, &err)
+
+	return usecase.walletUsecase.ExportWalletTransactions(ctx, req, w)
+}
+
+func (usecase *tracedWalletUsecase) GetPockets(ctx context.Context, walletId string) (res *response.Response[[]wallet.Wallet], err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletUsecase.GetPockets", attribute.String("wallet.id", walletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return usecase.walletUsecase.GetPockets(ctx, walletId)
+}
+
+func (usecase *tracedWalletUsecase) CreatePocket(ctx context.Context, req wallet.PocketCreationRequest) (res *response.Response[wallet.Wallet], err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletUsecase.CreatePocket", attribute.String("wallet.id", req.WalletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return usecase.walletUsecase.CreatePocket(ctx, req)
+}
+
+func (usecase *tracedWalletUsecase) MovePocketBalance(ctx context.Context, req wallet.PocketMoveRequest) (res *response.Response[wallet.PocketMove], err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletUsecase.MovePocketBalance",
+		attribute.String("wallet.pocket.from", req.FromPocketId),
+		attribute.String("wallet.pocket.to", req.ToPocketId),
+		attribute.Int("wallet.transaction.amount", req.Amount),
+	)
+	defer infrastructure.EndSpan(span, &err)
+
+	return usecase.walletUsecase.MovePocketBalance(ctx, req)
+}
+
+func (usecase *tracedWalletUsecase) SubscribeWalletTransactions(ctx context.Context, walletId string, yield func(walletTransaction wallet.WalletTransactionEntity) error) (err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletUsecase.SubscribeWalletTransactions", attribute.String("wallet.id", walletId))
+	defer infrastructure.EndSpan(span, &err)
+
+	return usecase.walletUsecase.SubscribeWalletTransactions(ctx, walletId, yield)
+}
+
+func (usecase *tracedWalletUsecase) AdjustWalletBalance(ctx context.Context, req wallet.WalletAdjustmentRequest) (res *response.Response[wallet.Wallet], err error) {
+	ctx, span := infrastructure.StartSpan(ctx, "walletUsecase.AdjustWalletBalance",
+		attribute.String("wallet.id", req.WalletId),
+		attribute.Int("wallet.transaction.amount", req.Amount),
+	)
+	defer infrastructure.EndSpan(span, &err)
+
+	return usecase.walletUsecase.AdjustWalletBalance(ctx, req)
+}
@@ -3,7 +3,6 @@ package worker
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"mini-wallet/domain"
 	"mini-wallet/domain/wallet"
 	"mini-wallet/domain/worker"
@@ -30,7 +29,7 @@ func NewWorkerUsecase(cacheClient redis.Client, repositories domain.Repositories
 func (workerUsecase *workerUsecase) SubscribeWalletTransaction(ctx context.Context) (err error) {
 	subscriber := workerUsecase.cacheClient.Subscribe(workerUsecase.config.WALLET_TRANSACTION_CHANNEL)
 
-	transaction := wallet.WalletTransaction{}
+	transaction := wallet.WalletTransactionEntity{}
 	for {
 		time.Sleep(time.Second * 2)
 		msg, err := subscriber.ReceiveMessage()
@@ -38,19 +37,29 @@ func (workerUsecase *workerUsecase) SubscribeWalletTransaction(ctx context.Conte
 			continue
 		}
 
-		infrastructure.Log(fmt.Sprintf("message received - SubscribeWalletTransaction : %v", msg))
-
 		if err != nil {
-			infrastructure.Log("got error on usecase.walletRepository.GetWalletById() - SubscribeWalletTransaction")
+			infrastructure.LogError(ctx, "got error on subscriber.ReceiveMessage() - SubscribeWalletTransaction", err)
 			continue
 		}
 
-		if err := json.Unmarshal([]byte(msg.Payload), &transaction); err != nil {
-			infrastructure.Log("got error on usecase.walletRepository.GetWalletById() - SubscribeWalletTransaction")
+		event := infrastructure.Event{}
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			infrastructure.LogError(ctx, "got error on json.Unmarshal() event - SubscribeWalletTransaction", err)
+			continue
+		}
+
+		// continue the trace of the request that published the event
+		eventCtx, span := infrastructure.StartSpan(infrastructure.ExtractTraceContext(ctx, event.Headers), "worker.SubscribeWalletTransaction")
+		infrastructure.LogDebug(eventCtx, "message received - SubscribeWalletTransaction", "channel", msg.Channel)
+
+		if err := json.Unmarshal(event.Payload, &transaction); err != nil {
+			infrastructure.LogError(eventCtx, "got error on json.Unmarshal() payload - SubscribeWalletTransaction", err)
+			span.End()
 			continue
 		}
 
 		// ...
+		span.End()
 	}
 
 }
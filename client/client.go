@@ -0,0 +1,161 @@
+// Package client calls the /api/v1 routes of mini-wallet, those of a wallet token and those of the admin token.
+// The types and methods of client.gen.go are generated from app/openapi/openapi.json, run go generate after
+// changing the spec
+package client
+
+//go:generate go run ./gen -spec ../app/openapi/openapi.json -out client.gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type Client struct {
+	baseUrl    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient sends token as the bearer token of every request, the one given by InitUser or the admin token.
+// http.DefaultClient is used when httpClient is nil
+func NewClient(baseUrl string, token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+// WithToken is a copy of the client sending another token, e.g. the one just given by InitUser
+func (client *Client) WithToken(token string) *Client {
+	copied := *client
+	copied.token = token
+
+	return &copied
+}
+
+// File is an upload of a multipart body, Name is sent as its file name
+type File struct {
+	Name    string
+	Content io.Reader
+}
+
+// APIError is a failure answered by the API, Data.Code tells what went wrong
+type APIError struct {
+	StatusCode int
+	Status     string // fail or error
+	Data       Error
+}
+
+func (apiError *APIError) Error() string {
+	return fmt.Sprintf("mini-wallet: %d %s: %s", apiError.StatusCode, apiError.Data.Code, apiError.Data.Error)
+}
+
+// do sends the request and turns any status code but a 2xx into an *APIError.
+// the caller closes the body of the returned response
+func (client *Client) do(ctx context.Context, method string, path string, query url.Values, body io.Reader, contentType string) (res *http.Response, err error) {
+	target := client.baseUrl + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if client.token != "" {
+		req.Header.Set("Authorization", "Bearer "+client.token)
+	}
+
+	res, err = client.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+
+		errorResponse := ErrorResponse{}
+		if err = json.NewDecoder(res.Body).Decode(&errorResponse); err != nil {
+			return nil, fmt.Errorf("mini-wallet: %d without an error body: %w", res.StatusCode, err)
+		}
+
+		return nil, &APIError{
+			StatusCode: res.StatusCode,
+			Status:     errorResponse.Status,
+			Data:       errorResponse.Data,
+		}
+	}
+
+	return res, nil
+}
+
+func decodeResponse(res *http.Response, dst any) error {
+	defer res.Body.Close()
+
+	return json.NewDecoder(res.Body).Decode(dst)
+}
+
+func encodeJson(body any) (io.Reader, error) {
+	var buffer bytes.Buffer
+	if err := json.NewEncoder(&buffer).Encode(body); err != nil {
+		return nil, err
+	}
+
+	return &buffer, nil
+}
+
+// encodeMultipart reads every file into the body, the uploads are small enough to be sent at once
+func encodeMultipart(fields url.Values, files map[string]File) (io.Reader, string, error) {
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+
+	for _, name := range sortedKeys(fields) {
+		for _, value := range fields[name] {
+			if err := writer.WriteField(name, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	for _, name := range sortedKeys(files) {
+		part, err := writer.CreateFormFile(name, files[name].Name)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err = io.Copy(part, files[name].Content); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return &buffer, writer.FormDataContentType(), nil
+}
+
+// sortedKeys keeps the parts of a multipart body in the same order on every call
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
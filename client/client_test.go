@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateDeposit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/wallet/deposits" || r.URL.Query().Get("pocket_id") != "p-1" {
+			t.Errorf("request = %s %s", r.Method, r.URL)
+		}
+		if r.Header.Get("Authorization") != "Bearer token-1" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("headers = %v", r.Header)
+		}
+
+		body := WalletTransactionRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Amount != 1000 || body.ReferenceId != "ref-1" {
+			t.Errorf("body = %+v, %v", body, err)
+		}
+
+		w.Write([]byte(`{"status": "success", "data": {"id": "p-1", "owned_by": "c-1", "name": "Savings", "enabled_at": null, "balance": 1000, "status": "enabled"}}`))
+	}))
+	defer server.Close()
+
+	result, err := NewClient(server.URL+"/", "token-1", nil).CreateDeposit(context.Background(), CreateDepositParams{PocketId: "p-1"},
+		WalletTransactionRequest{Amount: 1000, ReferenceId: "ref-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != "success" || result.Data.Balance != 1000 || result.Data.EnabledAt != nil {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestCreatePocketSendsAForm(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" || r.FormValue("name") != "Savings" {
+			t.Errorf("form = %v", r.Form)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status": "success", "data": {"id": "p-2", "name": "Savings", "status": "disabled"}}`))
+	}))
+	defer server.Close()
+
+	result, err := NewClient(server.URL, "token-1", nil).CreatePocket(context.Background(), CreatePocketParams{}, PocketCreationRequest{Name: "Savings"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Data.Id != "p-2" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestIngestSettlementUploadsTheFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/settlements" {
+			t.Errorf("request = %s %s", r.Method, r.URL)
+		}
+
+		file, fileHeader, err := r.FormFile("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		content, err := io.ReadAll(file)
+		if err != nil || string(content) != "reference_id,amount\nref-1,1000\n" || fileHeader.Filename != "bank.csv" {
+			t.Errorf("file %s = %q, %v", fileHeader.Filename, content, err)
+		}
+		if r.FormValue("settlement_date") != "2026-10-18" || r.FormValue("format") != "" {
+			t.Errorf("form = %v", r.MultipartForm.Value)
+		}
+
+		w.Write([]byte(`{"status": "success", "data": {"id": "s-1", "file_name": "bank.csv", "format": "csv", "matched": 1}}`))
+	}))
+	defer server.Close()
+
+	result, err := NewClient(server.URL, "admin", nil).IngestSettlement(context.Background(), SettlementUpload{
+		File:           File{Name: "bank.csv", Content: strings.NewReader("reference_id,amount\nref-1,1000\n")},
+		SettlementDate: "2026-10-18",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Data.Id != "s-1" || result.Data.Matched != 1 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestPathParametersAreEscaped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.EscapedPath() != "/api/v1/wallet/schedules/a%2Fb/pause" || r.URL.Query().Get("pocket_id") != "p-1" {
+			t.Errorf("request = %s %s", r.Method, r.URL)
+		}
+
+		w.Write([]byte(`{"status": "success", "data": {"id": "a/b", "status": "paused"}}`))
+	}))
+	defer server.Close()
+
+	result, err := NewClient(server.URL, "token-1", nil).PauseSchedule(context.Background(), "a/b", PauseScheduleParams{PocketId: "p-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Data.Status != "paused" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestFailureIsAnAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"status": "fail", "data": {"error": "bad request: invalid value provided", "code": "invalid_value", "fields": [{"field": "amount", "message": "must be positive"}]}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL, "token-1", nil).CreateWithdrawal(context.Background(), CreateWithdrawalParams{},
+		WalletTransactionRequest{Amount: -1, ReferenceId: "ref-1"})
+
+	var apiError *APIError
+	if !errors.As(err, &apiError) {
+		t.Fatalf("err = %v, want an *APIError", err)
+	}
+	if apiError.StatusCode != http.StatusUnprocessableEntity || apiError.Data.Code != "invalid_value" ||
+		len(apiError.Data.Fields) != 1 || apiError.Data.Fields[0].Field != "amount" {
+		t.Errorf("err = %+v", apiError)
+	}
+}
+
+func TestExportReturnsTheBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "ndjson" || r.URL.Query().Get("wallet_id") != "" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"id\":\"t1\"}\n"))
+	}))
+	defer server.Close()
+
+	body, err := NewClient(server.URL, "admin", nil).ExportTransactions(context.Background(), ExportTransactionsParams{Format: "ndjson"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	exported, err := io.ReadAll(body)
+	if err != nil || string(exported) != "{\"id\":\"t1\"}\n" {
+		t.Errorf("export = %q, %v", exported, err)
+	}
+}
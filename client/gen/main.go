@@ -0,0 +1,448 @@
+// gen writes the types and methods of the client package from the OpenAPI spec. It knows the part of
+// OpenAPI 3 the spec uses: object schemas, path and query parameters, json, form or multipart request
+// bodies and json or binary responses
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type spec struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas    map[string]*schema    `json:"schemas"`
+		Parameters map[string]*parameter `json:"parameters"`
+		Responses  map[string]*response  `json:"responses"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationId string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Description string               `json:"description"`
+	Parameters  []*parameter         `json:"parameters"`
+	RequestBody *content             `json:"requestBody"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type response struct {
+	Ref string `json:"$ref"`
+	content
+}
+
+type content struct {
+	Content map[string]struct {
+		Schema *schema `json:"schema"`
+	} `json:"content"`
+}
+
+type schema struct {
+	Ref         string             `json:"$ref"`
+	Type        string             `json:"type"`
+	Format      string             `json:"format"`
+	Description string             `json:"description"`
+	Nullable    bool               `json:"nullable"`
+	Required    []string           `json:"required"`
+	Properties  map[string]*schema `json:"properties"`
+	Items       *schema            `json:"items"`
+	OneOf       []*schema          `json:"oneOf"`
+}
+
+func main() {
+	specPath := flag.String("spec", "", "the OpenAPI spec to read")
+	outPath := flag.String("out", "", "the go file to write")
+	flag.Parse()
+
+	specData, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	source, err := generate(specData)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = os.WriteFile(*outPath, source, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func generate(specData []byte) ([]byte, error) {
+	apiSpec := spec{}
+	if err := json.Unmarshal(specData, &apiSpec); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+
+	for _, name := range sortedKeys(apiSpec.Components.Schemas) {
+		if err := writeSchema(&out, name, apiSpec.Components.Schemas[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, path := range sortedKeys(apiSpec.Paths) {
+		for _, method := range sortedKeys(apiSpec.Paths[path]) {
+			if err := writeOperation(&out, apiSpec, path, method, apiSpec.Paths[path][method]); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+		}
+	}
+
+	// only the packages the generated code ended up using
+	var header bytes.Buffer
+	header.WriteString("// Code generated by client/gen from app/openapi/openapi.json. DO NOT EDIT.\n\n")
+	header.WriteString("package client\n\nimport (\n")
+	for _, importPath := range []string{"context", "encoding/json", "io", "net/url", "strconv", "strings"} {
+		if bytes.Contains(out.Bytes(), []byte(importPath[strings.LastIndex(importPath, "/")+1:]+".")) {
+			fmt.Fprintf(&header, "%q\n", importPath)
+		}
+	}
+	header.WriteString(")\n\n")
+
+	source, err := format.Source(append(header.Bytes(), out.Bytes()...))
+	if err != nil {
+		return nil, fmt.Errorf("formatting the generated code: %w", err)
+	}
+
+	return source, nil
+}
+
+func writeSchema(out *bytes.Buffer, name string, objectSchema *schema) error {
+	if objectSchema.Type != "object" {
+		return fmt.Errorf("schema %s: only objects are generated, got %q", name, objectSchema.Type)
+	}
+
+	writeComment(out, name, objectSchema.Description)
+	fmt.Fprintf(out, "type %s struct {\n", name)
+	for _, property := range sortedKeys(objectSchema.Properties) {
+		propertySchema := objectSchema.Properties[property]
+		required := contains(objectSchema.Required, property)
+
+		tag := property
+		if !required {
+			tag += ",omitempty"
+		}
+		if propertySchema.Format == "binary" {
+			// an upload, sent as a multipart file
+			tag = "-"
+		}
+		if propertySchema.Description != "" {
+			fmt.Fprintf(out, "// %s\n", propertySchema.Description)
+		}
+		fmt.Fprintf(out, "%s %s `json:%q`\n", goName(property), goType(propertySchema, required), tag)
+	}
+	out.WriteString("}\n\n")
+
+	return nil
+}
+
+func writeOperation(out *bytes.Buffer, apiSpec spec, path string, method string, op *operation) error {
+	name := goName(op.OperationId)
+	arguments := []string{"ctx context.Context"}
+
+	var queryParameters []*parameter
+	for _, param := range op.Parameters {
+		if param.Ref != "" {
+			param = apiSpec.Components.Parameters[refName(param.Ref)]
+		}
+		switch {
+		case param == nil:
+			return fmt.Errorf("a parameter does not resolve")
+		case param.In == "path":
+			arguments = append(arguments, param.Name+" string")
+		case param.In == "query":
+			queryParameters = append(queryParameters, param)
+		default:
+			return fmt.Errorf("only path and query parameters are generated")
+		}
+	}
+	if len(queryParameters) > 0 {
+		fmt.Fprintf(out, "// %sParams are sent in the query, an empty one is left out\n", name)
+		fmt.Fprintf(out, "type %sParams struct {\n", name)
+		for _, param := range queryParameters {
+			if param.Description != "" {
+				fmt.Fprintf(out, "// %s\n", param.Description)
+			}
+			fmt.Fprintf(out, "%s string\n", goName(param.Name))
+		}
+		out.WriteString("}\n\n")
+		arguments = append(arguments, fmt.Sprintf("params %sParams", name))
+	}
+
+	var bodySchema *schema
+	bodyMediaType := ""
+	if op.RequestBody != nil {
+		// the first the body is offered in, a multipart one is left for the uploads
+		for _, mediaType := range []string{"application/json", "application/x-www-form-urlencoded", "multipart/form-data"} {
+			if media, ok := op.RequestBody.Content[mediaType]; ok {
+				bodySchema, bodyMediaType = media.Schema, mediaType
+				break
+			}
+		}
+		if bodySchema == nil || bodySchema.Ref == "" {
+			return fmt.Errorf("the request body has to reference a json, form or multipart schema")
+		}
+		arguments = append(arguments, "body "+refName(bodySchema.Ref))
+	}
+
+	resultType, binary, err := successType(apiSpec, op)
+	if err != nil {
+		return err
+	}
+
+	writeComment(out, name, op.Summary)
+	if op.Description != "" {
+		fmt.Fprintf(out, "//\n// %s\n", op.Description)
+	}
+	fmt.Fprintf(out, "//\n// %s %s\n", strings.ToUpper(method), path)
+	if binary {
+		out.WriteString("// the caller closes the returned body\n")
+		fmt.Fprintf(out, "func (client *Client) %s(%s) (io.ReadCloser, error) {\n", name, strings.Join(arguments, ", "))
+	} else {
+		fmt.Fprintf(out, "func (client *Client) %s(%s) (*%s, error) {\n", name, strings.Join(arguments, ", "), resultType)
+	}
+
+	out.WriteString("query := url.Values{}\n")
+	for _, param := range queryParameters {
+		field := goName(param.Name)
+		fmt.Fprintf(out, "if params.%s != \"\" {\nquery.Set(%q, params.%s)\n}\n", field, param.Name, field)
+	}
+
+	out.WriteString("var requestBody io.Reader\ncontentType := \"\"\n")
+	switch bodyMediaType {
+	case "application/json":
+		out.WriteString("encoded, err := encodeJson(body)\nif err != nil {\nreturn nil, err\n}\n")
+		out.WriteString("requestBody, contentType = encoded, \"application/json\"\n")
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		objectSchema := apiSpec.Components.Schemas[refName(bodySchema.Ref)]
+		if objectSchema == nil {
+			return fmt.Errorf("%s does not resolve", bodySchema.Ref)
+		}
+		out.WriteString("form := url.Values{}\n")
+		if bodyMediaType == "multipart/form-data" {
+			out.WriteString("files := map[string]File{}\n")
+		}
+		for _, property := range sortedKeys(objectSchema.Properties) {
+			propertySchema, required := objectSchema.Properties[property], contains(objectSchema.Required, property)
+			if propertySchema.Format == "binary" && bodyMediaType == "multipart/form-data" {
+				writeFileField(out, property, required)
+				continue
+			}
+			if err := writeFormField(out, property, propertySchema, required); err != nil {
+				return err
+			}
+		}
+		if bodyMediaType == "multipart/form-data" {
+			out.WriteString("encoded, encodedType, err := encodeMultipart(form, files)\nif err != nil {\nreturn nil, err\n}\n")
+			out.WriteString("requestBody, contentType = encoded, encodedType\n")
+		} else {
+			out.WriteString("requestBody, contentType = strings.NewReader(form.Encode()), \"application/x-www-form-urlencoded\"\n")
+		}
+	}
+
+	fmt.Fprintf(out, "res, err := client.do(ctx, %q, %s, query, requestBody, contentType)\n", strings.ToUpper(method), pathExpression(path))
+	out.WriteString("if err != nil {\nreturn nil, err\n}\n")
+	if binary {
+		out.WriteString("return res.Body, nil\n}\n\n")
+		return nil
+	}
+
+	fmt.Fprintf(out, "result := &%s{}\n", resultType)
+	out.WriteString("if err = decodeResponse(res, result); err != nil {\nreturn nil, err\n}\n")
+	out.WriteString("return result, nil\n}\n\n")
+
+	return nil
+}
+
+// successType is the schema shared by every 2xx response of op, binary when it is a download.
+// a download offered in json too is binary as well, the caller decodes the format they asked for
+func successType(apiSpec spec, op *operation) (name string, binary bool, err error) {
+	found := false
+	for _, status := range sortedKeys(op.Responses) {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+
+		success := op.Responses[status]
+		if success.Ref != "" {
+			success = apiSpec.Components.Responses[refName(success.Ref)]
+		}
+
+		statusName, statusBinary := "", false
+		for _, media := range success.Content {
+			statusBinary = statusBinary || (media.Schema != nil && media.Schema.Format == "binary")
+		}
+		if media, ok := success.Content["application/json"]; ok && !statusBinary && media.Schema != nil && media.Schema.Ref != "" {
+			statusName = refName(media.Schema.Ref)
+		} else if !statusBinary {
+			return "", false, fmt.Errorf("response %s is neither a json schema nor binary", status)
+		}
+
+		if found && (name != statusName || binary != statusBinary) {
+			return "", false, fmt.Errorf("every success response has to share one schema")
+		}
+		name, binary, found = statusName, statusBinary, true
+	}
+
+	if !found {
+		return "", false, fmt.Errorf("no success response")
+	}
+
+	return name, binary, nil
+}
+
+func writeFormField(out *bytes.Buffer, property string, propertySchema *schema, required bool) error {
+	field := "body." + goName(property)
+
+	var value, zero string
+	switch propertySchema.Type {
+	case "string":
+		value, zero = field, `""`
+	case "integer":
+		value, zero = "strconv.Itoa("+field+")", "0"
+	case "boolean":
+		value, zero = "strconv.FormatBool("+field+")", "false"
+	default:
+		return fmt.Errorf("form field %s: only strings, integers and booleans are generated", property)
+	}
+
+	if required {
+		fmt.Fprintf(out, "form.Set(%q, %s)\n", property, value)
+		return nil
+	}
+
+	fmt.Fprintf(out, "if %s != %s {\nform.Set(%q, %s)\n}\n", field, zero, property, value)
+	return nil
+}
+
+// writeFileField adds an upload to the files of a multipart body
+func writeFileField(out *bytes.Buffer, property string, required bool) {
+	field := "body." + goName(property)
+	if required {
+		fmt.Fprintf(out, "files[%q] = %s\n", property, field)
+		return
+	}
+
+	fmt.Fprintf(out, "if %s != nil {\nfiles[%q] = *%s\n}\n", field, property, field)
+}
+
+// pathExpression is the go expression of path, its parameters are the escaped arguments of the same name,
+// e.g. /api/v1/batches/{batchId} -> "/api/v1/batches/" + url.PathEscape(batchId)
+func pathExpression(path string) string {
+	var parts []string
+	for path != "" {
+		start := strings.Index(path, "{")
+		if start < 0 {
+			parts = append(parts, strconv.Quote(path))
+			break
+		}
+		end := strings.Index(path, "}")
+		if start > 0 {
+			parts = append(parts, strconv.Quote(path[:start]))
+		}
+		parts = append(parts, "url.PathEscape("+path[start+1:end]+")")
+		path = path[end+1:]
+	}
+
+	return strings.Join(parts, " + ")
+}
+
+func goType(propertySchema *schema, required bool) string {
+	switch {
+	case propertySchema.Format == "binary":
+		if required {
+			return "File"
+		}
+		return "*File"
+	case propertySchema.Ref != "":
+		if required && !propertySchema.Nullable {
+			return refName(propertySchema.Ref)
+		}
+		return "*" + refName(propertySchema.Ref)
+	case len(propertySchema.OneOf) > 0:
+		// which one it is depends on the request, the caller decodes it
+		return "json.RawMessage"
+	case propertySchema.Type == "array":
+		return "[]" + goType(propertySchema.Items, true)
+	}
+
+	var primitive string
+	switch propertySchema.Type {
+	case "integer":
+		primitive = "int"
+	case "boolean":
+		primitive = "bool"
+	default:
+		primitive = "string"
+	}
+
+	if propertySchema.Nullable {
+		return "*" + primitive
+	}
+
+	return primitive
+}
+
+// goName turns snake_case or camelCase into an exported name, e.g. customer_xid -> CustomerXid, initUser -> InitUser
+func goName(name string) string {
+	var out strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		out.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	return out.String()
+}
+
+func writeComment(out *bytes.Buffer, name string, text string) {
+	if text == "" {
+		return
+	}
+
+	fmt.Fprintf(out, "// %s %s\n", name, text)
+}
+
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestGeneratedClientIsUpToDate(t *testing.T) {
+	specData, err := os.ReadFile("../../app/openapi/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := generate(specData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := os.ReadFile("../client.gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(source, generated) {
+		t.Errorf("client.gen.go is out of date with the spec, run go generate ./client")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"mini-wallet/domain/common/response"
 	"net/http"
+
+	"google.golang.org/grpc"
 )
 
 type Token struct {
@@ -12,10 +14,22 @@ type Token struct {
 
 type AuthUsecase interface {
 	AuthorizeRequestMiddleware(next http.Handler) http.Handler
+	AuthorizeAdminMiddleware(next http.Handler) http.Handler
+	// SelectWalletMiddleware resolves the member behind the token and the wallet the request acts on,
+	// with the role the member has on it. Must run after AuthorizeRequestMiddleware
+	SelectWalletMiddleware(next http.Handler) http.Handler
+	// RequireRoleMiddleware lets the request through only when the role on the selected wallet is one of roles
+	RequireRoleMiddleware(roles ...string) func(next http.Handler) http.Handler
 	InitUser(ctx context.Context, customerId string) (token *response.Response[Token], err error)
+	// AuthorizeUnaryInterceptor and AuthorizeStreamInterceptor are AuthorizeRequestMiddleware for the grpc server,
+	// reading the token from the "authorization" metadata. InitUser and the health service need no token
+	AuthorizeUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error)
+	AuthorizeStreamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error)
 }
 
 type AuthRepository interface {
 	AddToken(ctx context.Context, token string, walletId string) (err error)
 	GetTokenWalletId(ctx context.Context, token string) (walletId string, err error)
+	// RevokeToken ends the token before its TTL, revoking an unknown token is not an error
+	RevokeToken(ctx context.Context, token string) (err error)
 }
@@ -0,0 +1,96 @@
+package batch
+
+import (
+	"context"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+)
+
+const (
+	BATCH_MODE_ALL_OR_NOTHING = "all_or_nothing" // every item is applied in one database transaction, or none is
+	BATCH_MODE_BEST_EFFORT    = "best_effort"    // every item is applied on its own, failures do not stop the others
+
+	BATCH_STATUS_PENDING    = "pending"
+	BATCH_STATUS_PROCESSING = "processing"
+	BATCH_STATUS_COMPLETED  = "completed" // every item was processed, the item counts tell how many failed
+	BATCH_STATUS_FAILED     = "failed"    // all_or_nothing only, nothing was applied
+
+	BATCH_ITEM_STATUS_PENDING     = "pending"
+	BATCH_ITEM_STATUS_SUCCEEDED   = "succeeded"
+	BATCH_ITEM_STATUS_FAILED      = "failed"
+	BATCH_ITEM_STATUS_NOT_APPLIED = "not_applied" // all_or_nothing only, rolled back because another item failed
+
+	BATCH_FORMAT_JSON = "json"
+	BATCH_FORMAT_CSV  = "csv"
+)
+
+type Batch struct {
+	Id         string  `json:"id" gorm:"column:id"`
+	Mode       string  `json:"mode" gorm:"column:mode"`
+	Status     string  `json:"status" gorm:"column:status"`
+	TotalItems int     `json:"total_items" gorm:"column:total_items"`
+	Error      *string `json:"error,omitempty" gorm:"column:error"` // why an all_or_nothing batch failed
+	CreatedAt  string  `json:"created_at" gorm:"column:created_at"`
+	FinishedAt *string `json:"finished_at" gorm:"column:finished_at"`
+
+	// counted from the items on read
+	SucceededItems int `json:"succeeded_items" gorm:"column:succeeded_items;->"`
+	FailedItems    int `json:"failed_items" gorm:"column:failed_items;->"`
+	PendingItems   int `json:"pending_items" gorm:"column:pending_items;->"`
+}
+
+type BatchItem struct {
+	BatchId       string  `json:"-" gorm:"column:batch_id"`
+	Line          int     `json:"line" gorm:"column:line"` // 1-based position in the uploaded file
+	WalletId      string  `json:"wallet_id" gorm:"column:wallet_id"`
+	Type          string  `json:"type" gorm:"column:type"`
+	Amount        int     `json:"amount" gorm:"column:amount"`
+	ReferenceId   string  `json:"reference_id" gorm:"column:reference_id"`
+	Status        string  `json:"status" gorm:"column:status"`
+	Error         *string `json:"error,omitempty" gorm:"column:error"`
+	TransactionId *string `json:"transaction_id,omitempty" gorm:"column:transaction_id"`
+}
+
+func (item *BatchItem) ToTransactionRequest() wallet.WalletTransactionRequest {
+	return wallet.WalletTransactionRequest{
+		WalletId:    item.WalletId,
+		Type:        item.Type,
+		Amount:      item.Amount,
+		ReferenceId: item.ReferenceId,
+	}
+}
+
+func (item *BatchItem) Succeed(transactionId string) {
+	item.Status = BATCH_ITEM_STATUS_SUCCEEDED
+	item.TransactionId = &transactionId
+	item.Error = nil
+}
+
+func (item *BatchItem) Fail(err error) {
+	msg := err.Error()
+	item.Status = BATCH_ITEM_STATUS_FAILED
+	item.Error = &msg
+}
+
+type BatchRequest struct {
+	Mode  string                            `json:"mode"`
+	Items []wallet.WalletTransactionRequest `json:"items"`
+}
+
+type BatchUsecase interface {
+	// CreateBatch validates and stores the batch, the items are processed in the background
+	CreateBatch(ctx context.Context, req BatchRequest) (res *response.Response[Batch], err error)
+	GetBatch(ctx context.Context, batchId string) (res *response.Response[Batch], err error)
+	GetBatchItems(ctx context.Context, batchId string) (res *response.Response[[]BatchItem], err error)
+	// ResumeBatches picks up the batches left unfinished by a previous run
+	ResumeBatches(ctx context.Context) (err error)
+}
+
+type BatchRepository interface {
+	InsertBatch(ctx context.Context, batch Batch, items []BatchItem) (err error)
+	GetBatchById(ctx context.Context, batchId string) (res *Batch, err error)
+	GetBatchItems(ctx context.Context, batchId string) (res []BatchItem, err error)
+	GetUnfinishedBatches(ctx context.Context) (res []Batch, err error)
+	UpdateBatch(ctx context.Context, batch Batch) (err error)
+	UpdateBatchItems(ctx context.Context, items []BatchItem) (err error)
+}
@@ -0,0 +1,238 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mini-wallet/domain/common/response"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BODY_LIMIT = 1 << 20 // 1MB, uploads have their own limit
+)
+
+var (
+	// read by the middlewares before the body is decoded, e.g. the pocket_id of SelectWalletMiddleware
+	passthroughFields = map[string]struct{}{
+		"pocket_id": {},
+	}
+)
+
+// BodyLimitMiddleware caps the body at BODY_LIMIT before any middleware parses it as a form
+func BodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, BODY_LIMIT)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Decode fills the fields of dst, a pointer to a struct, tagged `schema:"name"` or `schema:"name,required"`
+// from a json object, a form or a multipart form, told apart by the Content-Type. a body without one is read as a form.
+// the query counts as form values like with r.FormValue. an unknown field, a value of the wrong type or a required
+// field missing is refused as a *response.ValidationError listing every field at fault.
+// a pointer field is optional, it stays nil when the field is missing, null or an empty form value.
+// a time.Time is read as RFC 3339
+func Decode(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, BODY_LIMIT)
+
+	fields := schemaFields(dst)
+	target := reflect.ValueOf(dst).Elem()
+	validationError := &response.ValidationError{}
+	seen := map[string]struct{}{}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		values := map[string]json.RawMessage{}
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&values); err != nil {
+			return invalidBody(err)
+		}
+		// a single object, nothing after it
+		if _, err := decoder.Token(); err != io.EOF {
+			return invalidBody(err)
+		}
+
+		for name, value := range values {
+			field, ok := fields[name]
+			if !ok {
+				validationError.Add(name, "unknown field")
+				continue
+			}
+			if string(value) == "null" {
+				continue
+			}
+
+			seen[name] = struct{}{}
+			if err := json.Unmarshal(value, target.FieldByIndex(field.index).Addr().Interface()); err != nil {
+				validationError.Add(name, "must be "+typeName(field.fieldType))
+			}
+		}
+	case mediaType == "", mediaType == "application/x-www-form-urlencoded", mediaType == "multipart/form-data":
+		var err error
+		if mediaType == "multipart/form-data" {
+			err = r.ParseMultipartForm(BODY_LIMIT)
+		} else {
+			err = r.ParseForm()
+		}
+		if err != nil {
+			return invalidBody(err)
+		}
+
+		for name, value := range r.Form {
+			if _, ok := passthroughFields[name]; ok {
+				continue
+			}
+
+			field, ok := fields[name]
+			if !ok {
+				validationError.Add(name, "unknown field")
+				continue
+			}
+
+			seen[name] = struct{}{}
+			if len(value) > 1 {
+				validationError.Add(name, "must be given once")
+				continue
+			}
+			if err := setFormValue(target.FieldByIndex(field.index), value[0]); err != nil {
+				validationError.Add(name, "must be "+typeName(field.fieldType))
+			}
+		}
+		if r.MultipartForm != nil {
+			for name := range r.MultipartForm.File {
+				validationError.Add(name, "unknown field")
+			}
+		}
+	default:
+		return response.ErrUnsupportedContentType
+	}
+
+	for name, field := range fields {
+		if _, ok := seen[name]; !ok && field.required {
+			validationError.Add(name, "is required")
+		}
+	}
+
+	if len(validationError.Fields) > 0 {
+		sort.SliceStable(validationError.Fields, func(i, j int) bool {
+			return validationError.Fields[i].Field < validationError.Fields[j].Field
+		})
+		return validationError
+	}
+
+	return nil
+}
+
+type schemaField struct {
+	index     []int
+	fieldType reflect.Type
+	required  bool
+}
+
+func schemaFields(dst any) map[string]schemaField {
+	fields := map[string]schemaField{}
+
+	structType := reflect.TypeOf(dst).Elem()
+	for i := 0; i < structType.NumField(); i++ {
+		structField := structType.Field(i)
+		tag, ok := structField.Tag.Lookup("schema")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		fields[name] = schemaField{
+			index:     structField.Index,
+			fieldType: structField.Type,
+			required:  options == "required",
+		}
+	}
+
+	return fields
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func setFormValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		if value == "" {
+			return nil
+		}
+
+		elem := reflect.New(field.Type().Elem())
+		if err := setFormValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	if field.Type() == timeType {
+		parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || field.OverflowInt(parsed) {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	default:
+		return fmt.Errorf("unsupported field kind %s", field.Kind())
+	}
+
+	return nil
+}
+
+func typeName(fieldType reflect.Type) string {
+	if fieldType.Kind() == reflect.Pointer {
+		fieldType = fieldType.Elem()
+	}
+	if fieldType == timeType {
+		return "an RFC 3339 time"
+	}
+
+	switch fieldType.Kind() {
+	case reflect.Int, reflect.Int64:
+		return "an integer"
+	case reflect.Bool:
+		return "a boolean"
+	}
+
+	return "a string"
+}
+
+// invalidBody tells a body over the size limit apart from a malformed one
+func invalidBody(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return response.ErrRequestTooLarge
+	}
+
+	return &response.ValidationError{
+		Fields: []response.FieldError{{Field: "body", Message: "must be a single json object or form"}},
+	}
+}
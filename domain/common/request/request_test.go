@@ -0,0 +1,169 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"mini-wallet/domain/common/response"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type decodeTestRequest struct {
+	Amount      int    `schema:"amount,required"`
+	ReferenceId string `schema:"reference_id,required"`
+	Note        string `schema:"note"`
+	WalletId    string // never read from the body
+}
+
+func decode(t *testing.T, contentType string, body string) (req decodeTestRequest, err error) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+
+	err = Decode(httptest.NewRecorder(), r, &req)
+	return req, err
+}
+
+func fieldErrors(t *testing.T, err error) []response.FieldError {
+	var validationError *response.ValidationError
+	if !errors.As(err, &validationError) {
+		t.Fatalf("err = %v, want a validation error", err)
+	}
+	if !errors.Is(err, response.ErrBadRequest) {
+		t.Fatalf("message = %q, want %q", err.Error(), response.ERROR_BAD_REQUEST)
+	}
+
+	return validationError.Fields
+}
+
+func TestDecodeJsonAndForm(t *testing.T) {
+	want := decodeTestRequest{Amount: 1000, ReferenceId: "ref-1"}
+
+	for name, testCase := range map[string]struct {
+		contentType string
+		body        string
+	}{
+		"json":          {"application/json; charset=utf-8", `{"amount": 1000, "reference_id": "ref-1"}`},
+		"form":          {"application/x-www-form-urlencoded", "amount=1000&reference_id=ref-1"},
+		"form routing":  {"application/x-www-form-urlencoded", "amount=1000&reference_id=ref-1&pocket_id=p-1"},
+		"json nullable": {"application/json", `{"amount": 1000, "reference_id": "ref-1", "note": null}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, err := decode(t, testCase.contentType, testCase.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(req, want) {
+				t.Errorf("req = %+v, want %+v", req, want)
+			}
+		})
+	}
+}
+
+func TestDecodeMultipart(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("amount", "250")
+	writer.WriteField("reference_id", "ref-2")
+	writer.Close()
+
+	req, err := decode(t, writer.FormDataContentType(), body.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Amount != 250 || req.ReferenceId != "ref-2" {
+		t.Errorf("req = %+v", req)
+	}
+}
+
+func TestDecodeFieldErrors(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		contentType string
+		body        string
+		want        []response.FieldError
+	}{
+		"json wrong type": {"application/json", `{"amount": "1000", "reference_id": "ref-1"}`,
+			[]response.FieldError{{Field: "amount", Message: "must be an integer"}}},
+		"json unknown and missing": {"application/json", `{"amount": 1, "wallet_id": "w-1"}`,
+			[]response.FieldError{{Field: "reference_id", Message: "is required"}, {Field: "wallet_id", Message: "unknown field"}}},
+		"form not a number": {"application/x-www-form-urlencoded", "amount=abc&reference_id=ref-1",
+			[]response.FieldError{{Field: "amount", Message: "must be an integer"}}},
+		"form repeated": {"application/x-www-form-urlencoded", "amount=1&amount=2&reference_id=ref-1",
+			[]response.FieldError{{Field: "amount", Message: "must be given once"}}},
+		"json two objects": {"application/json", `{"amount": 1, "reference_id": "ref-1"} {}`,
+			[]response.FieldError{{Field: "body", Message: "must be a single json object or form"}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := decode(t, testCase.contentType, testCase.body)
+			if got := fieldErrors(t, err); !reflect.DeepEqual(got, testCase.want) {
+				t.Errorf("fields = %+v, want %+v", got, testCase.want)
+			}
+		})
+	}
+}
+
+type optionalTestRequest struct {
+	Limit   *int       `schema:"limit"`
+	StartAt *time.Time `schema:"start_at"`
+}
+
+func TestDecodeOptionalFields(t *testing.T) {
+	limit := 500
+	startAt := time.Date(2026, 10, 1, 9, 0, 0, 0, time.FixedZone("", 7*60*60))
+
+	for name, testCase := range map[string]struct {
+		contentType string
+		body        string
+		want        optionalTestRequest
+		wantFields  []response.FieldError
+	}{
+		"json":       {"application/json", `{"limit": 500, "start_at": "2026-10-01T09:00:00+07:00"}`, optionalTestRequest{&limit, &startAt}, nil},
+		"json null":  {"application/json", `{"limit": null}`, optionalTestRequest{}, nil},
+		"form":       {"application/x-www-form-urlencoded", "limit=500&start_at=2026-10-01T09:00:00%2B07:00", optionalTestRequest{&limit, &startAt}, nil},
+		"form empty": {"application/x-www-form-urlencoded", "limit=&start_at=", optionalTestRequest{}, nil},
+		"form wrong types": {"application/x-www-form-urlencoded", "limit=many&start_at=tomorrow", optionalTestRequest{},
+			[]response.FieldError{{Field: "limit", Message: "must be an integer"}, {Field: "start_at", Message: "must be an RFC 3339 time"}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(testCase.body))
+			r.Header.Set("Content-Type", testCase.contentType)
+
+			req := optionalTestRequest{}
+			err := Decode(httptest.NewRecorder(), r, &req)
+			if testCase.wantFields != nil {
+				if got := fieldErrors(t, err); !reflect.DeepEqual(got, testCase.wantFields) {
+					t.Errorf("fields = %+v, want %+v", got, testCase.wantFields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if (req.Limit == nil) != (testCase.want.Limit == nil) || (req.Limit != nil && *req.Limit != *testCase.want.Limit) {
+				t.Errorf("limit = %v, want %v", req.Limit, testCase.want.Limit)
+			}
+			if (req.StartAt == nil) != (testCase.want.StartAt == nil) || (req.StartAt != nil && !req.StartAt.Equal(*testCase.want.StartAt)) {
+				t.Errorf("start_at = %v, want %v", req.StartAt, testCase.want.StartAt)
+			}
+		})
+	}
+}
+
+func TestDecodeRefusesBody(t *testing.T) {
+	_, err := decode(t, "text/plain", "amount=1")
+	if err == nil || !errors.Is(err, response.ErrUnsupportedContentType) {
+		t.Errorf("err = %v, want %q", err, response.ERROR_UNSUPPORTED_CONTENT_TYPE)
+	}
+
+	_, err = decode(t, "application/json", `{"reference_id": "`+strings.Repeat("x", BODY_LIMIT)+`"}`)
+	if err == nil || !errors.Is(err, response.ErrRequestTooLarge) {
+		t.Errorf("err = %v, want %q", err, response.ERROR_REQUEST_TOO_LARGE)
+	}
+}
@@ -0,0 +1,128 @@
+package response
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+)
+
+const (
+	// every catalogued error answered 400 and anything else 500, for the clients written before the codes
+	ERROR_FORMAT_LEGACY = "legacy"
+	// the status code of the catalogued error, 404 for not found, 409 for a conflict, 422 for an invalid value, 423 for locked
+	ERROR_FORMAT_CODED = "coded"
+
+	// the code of any error missing from the catalogue
+	CODE_INTERNAL_ERROR = "internal_error"
+)
+
+// DomainError is an error the client can act on. Code is stable across releases, Message is the text
+// clients matched on before the codes and stays the same as well
+type DomainError struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	StatusCode int    `json:"status_code"`
+}
+
+func (domainError *DomainError) Error() string {
+	return domainError.Message
+}
+
+var (
+	ErrWalletDisabled         = &DomainError{"wallet_disabled", ERROR_WALLET_DISABLED, http.StatusConflict}
+	ErrWalletFrozen           = &DomainError{"wallet_frozen", ERROR_WALLET_FROZEN, http.StatusLocked}
+	ErrWalletNotFound         = &DomainError{"wallet_not_found", ERROR_WALLET_NOT_FOUND, http.StatusNotFound}
+	ErrInsufficientFund       = &DomainError{"insufficient_fund", ERROR_INSSUFICIENT_FUND, http.StatusConflict}
+	ErrReferenceIdConflict    = &DomainError{"reference_id_conflict", ERROR_REFERENCE_ID_CONFLICT, http.StatusConflict}
+	ErrWalletAlreadyExists    = &DomainError{"wallet_already_exists", ERROR_WALLET_ALREADY_EXISTS, http.StatusConflict}
+	ErrWalletConcurrent       = &DomainError{"wallet_concurrent", ERROR_WALLET_CONCURRENT, http.StatusConflict}
+	ErrWalletLocked           = &DomainError{"wallet_locked", ERROR_WALLET_LOCKED, http.StatusLocked}
+	ErrWalletLockExpired      = &DomainError{"wallet_lock_expired", ERROR_WALLET_LOCK_EXPIRED, http.StatusInternalServerError}
+	ErrBatchNotFound          = &DomainError{"batch_not_found", ERROR_BATCH_NOT_FOUND, http.StatusNotFound}
+	ErrBatchInvalidFile       = &DomainError{"batch_invalid_file", ERROR_BATCH_INVALID_FILE, http.StatusUnprocessableEntity}
+	ErrBatchTooLarge          = &DomainError{"batch_too_large", ERROR_BATCH_TOO_LARGE, http.StatusUnprocessableEntity}
+	ErrScheduleNotFound       = &DomainError{"schedule_not_found", ERROR_SCHEDULE_NOT_FOUND, http.StatusNotFound}
+	ErrScheduleStatus         = &DomainError{"schedule_status", ERROR_SCHEDULE_STATUS, http.StatusConflict}
+	ErrPocketNotFound         = &DomainError{"pocket_not_found", ERROR_POCKET_NOT_FOUND, http.StatusNotFound}
+	ErrPocketAlreadyExists    = &DomainError{"pocket_already_exists", ERROR_POCKET_ALREADY_EXISTS, http.StatusConflict}
+	ErrPocketLimit            = &DomainError{"pocket_limit", ERROR_POCKET_LIMIT, http.StatusConflict}
+	ErrMemberNotFound         = &DomainError{"member_not_found", ERROR_MEMBER_NOT_FOUND, http.StatusNotFound}
+	ErrMemberAlreadyExists    = &DomainError{"member_already_exists", ERROR_MEMBER_ALREADY_EXISTS, http.StatusConflict}
+	ErrMemberForbidden        = &DomainError{"member_forbidden", ERROR_MEMBER_FORBIDDEN, http.StatusForbidden}
+	ErrSpendingLimit          = &DomainError{"spending_limit", ERROR_SPENDING_LIMIT, http.StatusConflict}
+	ErrApprovalNotFound       = &DomainError{"approval_not_found", ERROR_APPROVAL_NOT_FOUND, http.StatusNotFound}
+	ErrApprovalStatus         = &DomainError{"approval_status", ERROR_APPROVAL_STATUS, http.StatusConflict}
+	ErrReconciliationNotFound = &DomainError{"reconciliation_not_found", ERROR_RECONCILIATION_NOT_FOUND, http.StatusNotFound}
+	ErrSettlementNotFound     = &DomainError{"settlement_not_found", ERROR_SETTLEMENT_NOT_FOUND, http.StatusNotFound}
+	ErrSettlementInvalidFile  = &DomainError{"settlement_invalid_file", ERROR_SETTLEMENT_INVALID_FILE, http.StatusUnprocessableEntity}
+	ErrSettlementTooLarge     = &DomainError{"settlement_too_large", ERROR_SETTLEMENT_TOO_LARGE, http.StatusRequestEntityTooLarge}
+	ErrSettlementConcurrent   = &DomainError{"settlement_concurrent", ERROR_SETTLEMENT_CONCURRENT, http.StatusConflict}
+	ErrExceptionNotFound      = &DomainError{"exception_not_found", ERROR_EXCEPTION_NOT_FOUND, http.StatusNotFound}
+	ErrExceptionResolved      = &DomainError{"exception_resolved", ERROR_EXCEPTION_RESOLVED, http.StatusConflict}
+	ErrBadRequest             = &DomainError{"invalid_value", ERROR_BAD_REQUEST, http.StatusUnprocessableEntity}
+	ErrUnsupportedContentType = &DomainError{"unsupported_content_type", ERROR_UNSUPPORTED_CONTENT_TYPE, http.StatusUnsupportedMediaType}
+	ErrRequestTooLarge        = &DomainError{"request_too_large", ERROR_REQUEST_TOO_LARGE, http.StatusRequestEntityTooLarge}
+	ErrUnauthorized           = &DomainError{"unauthorized", ERROR_UNAUTHORIZED, http.StatusUnauthorized}
+
+	// Catalogue lists every code a client may be answered with, documented in the README
+	Catalogue = []*DomainError{
+		ErrWalletDisabled, ErrWalletFrozen, ErrWalletNotFound, ErrInsufficientFund, ErrReferenceIdConflict,
+		ErrWalletAlreadyExists, ErrWalletConcurrent, ErrWalletLocked, ErrWalletLockExpired,
+		ErrBatchNotFound, ErrBatchInvalidFile, ErrBatchTooLarge,
+		ErrScheduleNotFound, ErrScheduleStatus,
+		ErrPocketNotFound, ErrPocketAlreadyExists, ErrPocketLimit,
+		ErrMemberNotFound, ErrMemberAlreadyExists, ErrMemberForbidden, ErrSpendingLimit,
+		ErrApprovalNotFound, ErrApprovalStatus,
+		ErrReconciliationNotFound,
+		ErrSettlementNotFound, ErrSettlementInvalidFile, ErrSettlementTooLarge, ErrSettlementConcurrent,
+		ErrExceptionNotFound, ErrExceptionResolved,
+		ErrBadRequest, ErrUnsupportedContentType, ErrRequestTooLarge, ErrUnauthorized,
+	}
+
+	// the baseline answered a 400 for its five user errors only, these failures of the baseline routes
+	// got a 500 and the legacy format keeps it: a wallet locked by another process, its lock lost mid-way
+	// or its balance changed under a concurrent update, a wallet created twice by a race on /init,
+	// and a missing or expired token
+	legacyServerErrors = map[*DomainError]struct{}{
+		ErrWalletLocked:        {},
+		ErrWalletLockExpired:   {},
+		ErrWalletConcurrent:    {},
+		ErrWalletAlreadyExists: {},
+		ErrUnauthorized:        {},
+	}
+
+	codedErrors atomic.Bool
+)
+
+// SetErrorFormat picks ERROR_FORMAT_LEGACY or ERROR_FORMAT_CODED for every error response from now on
+func SetErrorFormat(format string) {
+	codedErrors.Store(format == ERROR_FORMAT_CODED)
+}
+
+// DomainErrorOf finds the catalogued error behind err, nil for an unexpected one.
+// a *ValidationError is an ErrBadRequest naming its fields
+func DomainErrorOf(err error) *DomainError {
+	var domainError *DomainError
+	if errors.As(err, &domainError) {
+		return domainError
+	}
+
+	var validationError *ValidationError
+	if errors.As(err, &validationError) {
+		return ErrBadRequest
+	}
+
+	return nil
+}
+
+func statusCodeOf(domainError *DomainError) int {
+	if codedErrors.Load() {
+		return domainError.StatusCode
+	}
+
+	if _, ok := legacyServerErrors[domainError]; ok {
+		return http.StatusInternalServerError
+	}
+
+	return http.StatusBadRequest
+}
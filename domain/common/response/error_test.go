@@ -0,0 +1,133 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCatalogueIsDocumented(t *testing.T) {
+	readme, err := os.ReadFile("../../../README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes := map[string]struct{}{}
+	for _, domainError := range Catalogue {
+		if _, ok := codes[domainError.Code]; ok {
+			t.Errorf("code %s used twice", domainError.Code)
+		}
+		codes[domainError.Code] = struct{}{}
+
+		if !strings.Contains(string(readme), fmt.Sprintf("| `%s` | %d |", domainError.Code, domainError.StatusCode)) {
+			t.Errorf("code %s (%d) missing from the README catalogue", domainError.Code, domainError.StatusCode)
+		}
+	}
+}
+
+func TestErrorStatusCode(t *testing.T) {
+	defer SetErrorFormat(ERROR_FORMAT_LEGACY)
+
+	validationError := &ValidationError{}
+	validationError.Add("amount", "is required")
+
+	for _, testCase := range []struct {
+		err        error
+		format     string
+		status     string
+		statusCode int
+		code       string
+	}{
+		{ErrWalletNotFound, ERROR_FORMAT_LEGACY, STATUS_FAIL, http.StatusBadRequest, "wallet_not_found"},
+		{ErrWalletNotFound, ERROR_FORMAT_CODED, STATUS_FAIL, http.StatusNotFound, "wallet_not_found"},
+		{fmt.Errorf("approving: %w", ErrWalletFrozen), ERROR_FORMAT_CODED, STATUS_FAIL, http.StatusLocked, "wallet_frozen"},
+		{validationError, ERROR_FORMAT_LEGACY, STATUS_FAIL, http.StatusBadRequest, "invalid_value"},
+		{validationError, ERROR_FORMAT_CODED, STATUS_FAIL, http.StatusUnprocessableEntity, "invalid_value"},
+		{ErrUnauthorized, ERROR_FORMAT_LEGACY, STATUS_ERROR, http.StatusInternalServerError, "unauthorized"},
+		{ErrUnauthorized, ERROR_FORMAT_CODED, STATUS_FAIL, http.StatusUnauthorized, "unauthorized"},
+		// the same message as a catalogued error is still unexpected without its type
+		{errors.New(ERROR_WALLET_NOT_FOUND), ERROR_FORMAT_CODED, STATUS_ERROR, http.StatusInternalServerError, CODE_INTERNAL_ERROR},
+	} {
+		SetErrorFormat(testCase.format)
+
+		resp := &Response[Error]{Data: NewError(testCase.err)}
+		resp.Error(testCase.err)
+		if resp.Status != testCase.status || resp.StatusCode != testCase.statusCode || resp.Data.Code != testCase.code {
+			t.Errorf("%s %v = %s %d %s, want %s %d %s", testCase.format, testCase.err, resp.Status, resp.StatusCode, resp.Data.Code,
+				testCase.status, testCase.statusCode, testCase.code)
+		}
+	}
+
+	if !errors.Is(validationError, ErrBadRequest) {
+		t.Errorf("a validation error is not an ErrBadRequest")
+	}
+	if fields := NewError(validationError).Fields; len(fields) != 1 || fields[0].Field != "amount" {
+		t.Errorf("fields = %+v", fields)
+	}
+}
+
+// baselineError answers msg the way every handler did before the codes
+func baselineError(msg string) (string, int) {
+	userErrors := map[string]struct{}{
+		ERROR_WALLET_DISABLED:       {},
+		ERROR_WALLET_NOT_FOUND:      {},
+		ERROR_INSSUFICIENT_FUND:     {},
+		ERROR_REFERENCE_ID_CONFLICT: {},
+		ERROR_BAD_REQUEST:           {},
+	}
+	if _, isUserError := userErrors[msg]; isUserError {
+		return STATUS_FAIL, http.StatusBadRequest
+	}
+
+	return STATUS_ERROR, http.StatusInternalServerError
+}
+
+func TestLegacyErrorMatchesTheBaseline(t *testing.T) {
+	SetErrorFormat(ERROR_FORMAT_LEGACY)
+
+	// the errors of the baseline routes, the last ones were plain errors or a lock failure back then
+	baselineErrors := []*DomainError{
+		ErrWalletDisabled, ErrWalletNotFound, ErrInsufficientFund, ErrReferenceIdConflict, ErrBadRequest,
+		ErrWalletLocked, ErrWalletLockExpired, ErrWalletConcurrent, ErrWalletAlreadyExists, ErrUnauthorized,
+	}
+
+	answer := func(err error) (int, Response[Error]) {
+		w := httptest.NewRecorder()
+		errResp := &Response[Error]{Data: NewError(err)}
+		errResp.Error(err)
+		errResp.WriteResponse(w)
+
+		resp := Response[Error]{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body.String(), err)
+		}
+		return w.Code, resp
+	}
+
+	seen := map[*DomainError]struct{}{}
+	for _, domainError := range baselineErrors {
+		seen[domainError] = struct{}{}
+
+		status, statusCode := baselineError(domainError.Message)
+		code, resp := answer(fmt.Errorf("handling: %w", domainError))
+		if code != statusCode || resp.Status != status || resp.Data == nil || resp.Data.Error != "handling: "+domainError.Message {
+			t.Errorf("%s = %d %s %+v, want %d %s %q", domainError.Code, code, resp.Status, resp.Data, statusCode, status, "handling: "+domainError.Message)
+		}
+	}
+
+	// came with the routes added after the baseline, a catalogued error of those is a 400
+	for _, domainError := range Catalogue {
+		if _, ok := seen[domainError]; ok {
+			continue
+		}
+
+		if code, resp := answer(domainError); code != http.StatusBadRequest || resp.Status != STATUS_FAIL {
+			t.Errorf("%s = %d %s, want %d %s", domainError.Code, code, resp.Status, http.StatusBadRequest, STATUS_FAIL)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package response
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+const (
+	// the domain of the ErrorInfo detail of a grpc error, its reason is the code of the catalogue
+	GRPC_ERROR_DOMAIN = "mini-wallet"
+)
+
+var (
+	// the grpc code of a catalogued error follows its status code, except for these
+	grpcCodeOverrides = map[*DomainError]codes.Code{
+		ErrReferenceIdConflict:  codes.AlreadyExists,
+		ErrWalletAlreadyExists:  codes.AlreadyExists,
+		ErrPocketAlreadyExists:  codes.AlreadyExists,
+		ErrMemberAlreadyExists:  codes.AlreadyExists,
+		ErrWalletConcurrent:     codes.Aborted,
+		ErrSettlementConcurrent: codes.Aborted,
+		ErrWalletLocked:         codes.Aborted,
+	}
+)
+
+// GRPCError is the grpc status of err, whatever ERROR_FORMAT says. a catalogued error carries its code
+// as the reason of an ErrorInfo detail, a *ValidationError its fields as a BadRequest detail
+func GRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	domainError := DomainErrorOf(err)
+	if domainError == nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	grpcStatus := status.New(grpcCodeOf(domainError), err.Error())
+	details := []protoadapt.MessageV1{
+		&errdetails.ErrorInfo{
+			Reason: domainError.Code,
+			Domain: GRPC_ERROR_DOMAIN,
+		},
+	}
+
+	var validationError *ValidationError
+	if errors.As(err, &validationError) {
+		badRequest := &errdetails.BadRequest{}
+		for _, field := range validationError.Fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field.Field,
+				Description: field.Message,
+			})
+		}
+		details = append(details, badRequest)
+	}
+
+	withDetails, detailsErr := grpcStatus.WithDetails(details...)
+	if detailsErr != nil {
+		return grpcStatus.Err()
+	}
+
+	return withDetails.Err()
+}
+
+func grpcCodeOf(domainError *DomainError) codes.Code {
+	if code, ok := grpcCodeOverrides[domainError]; ok {
+		return code
+	}
+
+	switch domainError.StatusCode {
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict, http.StatusLocked:
+		return codes.FailedPrecondition
+	case http.StatusUnprocessableEntity, http.StatusUnsupportedMediaType:
+		return codes.InvalidArgument
+	case http.StatusRequestEntityTooLarge:
+		return codes.ResourceExhausted
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	}
+
+	return codes.Internal
+}
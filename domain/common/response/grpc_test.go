@@ -0,0 +1,66 @@
+package response
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCError(t *testing.T) {
+	// the grpc code does not depend on the error format
+	SetErrorFormat(ERROR_FORMAT_LEGACY)
+
+	validationError := &ValidationError{}
+	validationError.Add("amount", "must be positive")
+
+	for _, testCase := range []struct {
+		err    error
+		code   codes.Code
+		reason string
+	}{
+		{ErrWalletNotFound, codes.NotFound, "wallet_not_found"},
+		{fmt.Errorf("withdrawing: %w", ErrInsufficientFund), codes.FailedPrecondition, "insufficient_fund"},
+		{ErrReferenceIdConflict, codes.AlreadyExists, "reference_id_conflict"},
+		{ErrWalletConcurrent, codes.Aborted, "wallet_concurrent"},
+		{ErrWalletFrozen, codes.FailedPrecondition, "wallet_frozen"},
+		{validationError, codes.InvalidArgument, "invalid_value"},
+		{ErrUnauthorized, codes.Unauthenticated, "unauthorized"},
+		{ErrMemberForbidden, codes.PermissionDenied, "member_forbidden"},
+		{ErrWalletLockExpired, codes.Internal, "wallet_lock_expired"},
+		{errors.New("connection refused"), codes.Internal, ""},
+		{context.Canceled, codes.Canceled, ""},
+		{status.Error(codes.Unavailable, "draining"), codes.Unavailable, ""},
+	} {
+		grpcStatus := status.Convert(GRPCError(testCase.err))
+
+		reason := ""
+		for _, detail := range grpcStatus.Details() {
+			if errorInfo, ok := detail.(*errdetails.ErrorInfo); ok {
+				reason = errorInfo.Reason
+			}
+		}
+
+		if grpcStatus.Code() != testCase.code || reason != testCase.reason {
+			t.Errorf("%v = %s %q, want %s %q", testCase.err, grpcStatus.Code(), reason, testCase.code, testCase.reason)
+		}
+	}
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range status.Convert(GRPCError(validationError)).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations = badRequest.FieldViolations
+		}
+	}
+	if len(violations) != 1 || violations[0].Field != "amount" || violations[0].Description != "must be positive" {
+		t.Errorf("field violations = %v", violations)
+	}
+
+	if GRPCError(nil) != nil {
+		t.Errorf("a nil error is not nil")
+	}
+}
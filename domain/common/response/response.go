@@ -2,6 +2,7 @@ package response
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
@@ -10,26 +11,100 @@ const (
 	STATUS_FAIL    = "fail"
 	STATUS_ERROR   = "error"
 
-	ERROR_WALLET_DISABLED       = "wallet disabled"
-	ERROR_WALLET_NOT_FOUND      = "wallet not found"
-	ERROR_INSSUFICIENT_FUND     = "insufficient fund"
-	ERROR_REFERENCE_ID_CONFLICT = "reference id already used"
-	ERROR_BAD_REQUEST           = "bad request: invalid value provided"
-	ERROR_UNAUTHORIZED          = "unauthorized"
+	ERROR_WALLET_DISABLED          = "wallet disabled"
+	ERROR_WALLET_FROZEN            = "wallet frozen, please contact support"
+	ERROR_WALLET_NOT_FOUND         = "wallet not found"
+	ERROR_INSSUFICIENT_FUND        = "insufficient fund"
+	ERROR_REFERENCE_ID_CONFLICT    = "reference id already used"
+	ERROR_WALLET_ALREADY_EXISTS    = "wallet already exists"
+	ERROR_WALLET_CONCURRENT        = "wallet is being modified by another transaction, please retry"
+	ERROR_WALLET_LOCKED            = "another process maybe still modifying this wallet"
+	ERROR_WALLET_LOCK_EXPIRED      = "wallet lock expired"
+	ERROR_BATCH_NOT_FOUND          = "batch not found"
+	ERROR_BATCH_INVALID_FILE       = "bad request: invalid batch file"
+	ERROR_BATCH_TOO_LARGE          = "bad request: batch has too many items"
+	ERROR_SCHEDULE_NOT_FOUND       = "schedule not found"
+	ERROR_SCHEDULE_STATUS          = "schedule can not be changed in its current status"
+	ERROR_POCKET_NOT_FOUND         = "pocket not found"
+	ERROR_POCKET_ALREADY_EXISTS    = "pocket name already used"
+	ERROR_POCKET_LIMIT             = "maximum number of pockets reached"
+	ERROR_MEMBER_NOT_FOUND         = "member not found"
+	ERROR_MEMBER_ALREADY_EXISTS    = "member already invited"
+	ERROR_MEMBER_FORBIDDEN         = "not allowed for your role on this wallet"
+	ERROR_SPENDING_LIMIT           = "spending limit exceeded"
+	ERROR_APPROVAL_NOT_FOUND       = "approval not found"
+	ERROR_APPROVAL_STATUS          = "approval already decided"
+	ERROR_RECONCILIATION_NOT_FOUND = "reconciliation run not found"
+	ERROR_SETTLEMENT_NOT_FOUND     = "settlement not found"
+	ERROR_SETTLEMENT_INVALID_FILE  = "bad request: invalid settlement file"
+	ERROR_SETTLEMENT_TOO_LARGE     = "bad request: settlement file too large"
+	ERROR_SETTLEMENT_CONCURRENT    = "another settlement file is settling the same deposits, please retry"
+	ERROR_EXCEPTION_NOT_FOUND      = "settlement exception not found"
+	ERROR_EXCEPTION_RESOLVED       = "settlement exception already resolved"
+	ERROR_BAD_REQUEST              = "bad request: invalid value provided"
+	ERROR_UNSUPPORTED_CONTENT_TYPE = "bad request: unsupported content type"
+	ERROR_REQUEST_TOO_LARGE        = "bad request: request body too large"
+	ERROR_UNAUTHORIZED             = "unauthorized"
 )
 
-var (
-	userErrors = map[string]struct{}{
-		ERROR_WALLET_DISABLED:       {},
-		ERROR_WALLET_NOT_FOUND:      {},
-		ERROR_INSSUFICIENT_FUND:     {},
-		ERROR_REFERENCE_ID_CONFLICT: {},
-		ERROR_BAD_REQUEST:           {},
+type Error struct {
+	Error  string       `json:"error"`
+	Code   string       `json:"code"`             // one of the Catalogue, CODE_INTERNAL_ERROR for anything else
+	Fields []FieldError `json:"fields,omitempty"` // what is wrong with each field of a bad request
+}
+
+// NewError carries the code of the error and the fields of a *ValidationError along with the message
+func NewError(err error) *Error {
+	res := &Error{
+		Error: err.Error(),
+		Code:  CODE_INTERNAL_ERROR,
 	}
-)
 
-type Error struct {
-	Error string `json:"error"`
+	if domainError := DomainErrorOf(err); domainError != nil {
+		res.Code = domainError.Code
+	}
+
+	var validationError *ValidationError
+	if errors.As(err, &validationError) {
+		res.Fields = validationError.Fields
+	}
+
+	return res
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError reads as ERROR_BAD_REQUEST, clients matching the message keep working
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (validationError *ValidationError) Error() string {
+	return ERROR_BAD_REQUEST
+}
+
+// Is makes every validation error an ErrBadRequest
+func (validationError *ValidationError) Is(target error) bool {
+	return target == ErrBadRequest
+}
+
+func (validationError *ValidationError) Add(field string, message string) {
+	validationError.Fields = append(validationError.Fields, FieldError{
+		Field:   field,
+		Message: message,
+	})
+}
+
+// Err is nil when no field was added
+func (validationError *ValidationError) Err() error {
+	if len(validationError.Fields) == 0 {
+		return nil
+	}
+
+	return validationError
 }
 
 type Response[T any] struct {
@@ -43,13 +118,16 @@ func (payload *Response[T]) Success(msg string, data T) {
 	payload.StatusCode = http.StatusOK
 }
 
-func (payload *Response[T]) Error(msg string) {
+// Error answers a catalogued error as a fail with the status code of the error format, anything else as a 500
+func (payload *Response[T]) Error(err error) {
 	payload.Status = STATUS_ERROR
 	payload.StatusCode = http.StatusInternalServerError
 
-	if _, isUserError := userErrors[msg]; isUserError {
+	if domainError := DomainErrorOf(err); domainError != nil {
+		payload.StatusCode = statusCodeOf(domainError)
+	}
+	if payload.StatusCode < http.StatusInternalServerError {
 		payload.Status = STATUS_FAIL
-		payload.StatusCode = http.StatusBadRequest
 	}
 }
 
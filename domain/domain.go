@@ -2,15 +2,35 @@ package domain
 
 import (
 	"mini-wallet/domain/auth"
+	"mini-wallet/domain/batch"
+	"mini-wallet/domain/health"
+	"mini-wallet/domain/member"
+	"mini-wallet/domain/reconciliation"
+	"mini-wallet/domain/schedule"
+	"mini-wallet/domain/settlement"
+	"mini-wallet/domain/statement"
 	"mini-wallet/domain/wallet"
 )
 
 type Repositories struct {
-	WalletRepository wallet.WalletRepository
-	AuthRepository   auth.AuthRepository
+	WalletRepository         wallet.WalletRepository
+	AuthRepository           auth.AuthRepository
+	BatchRepository          batch.BatchRepository
+	ScheduleRepository       schedule.ScheduleRepository
+	MemberRepository         member.MemberRepository
+	StatementRepository      statement.StatementRepository
+	ReconciliationRepository reconciliation.ReconciliationRepository
+	SettlementRepository     settlement.SettlementRepository
 }
 
 type Usecases struct {
-	WalletUsecase wallet.WalletUsecase
-	AuthUsecase   auth.AuthUsecase
+	WalletUsecase         wallet.WalletUsecase
+	AuthUsecase           auth.AuthUsecase
+	HealthUsecase         health.HealthUsecase
+	BatchUsecase          batch.BatchUsecase
+	ScheduleUsecase       schedule.ScheduleUsecase
+	MemberUsecase         member.MemberUsecase
+	StatementUsecase      statement.StatementUsecase
+	ReconciliationUsecase reconciliation.ReconciliationUsecase
+	SettlementUsecase     settlement.SettlementUsecase
 }
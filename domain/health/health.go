@@ -0,0 +1,78 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"mini-wallet/domain/common/response"
+)
+
+const (
+	CHECK_STATUS_UP   = "up"
+	CHECK_STATUS_DOWN = "down"
+
+	CHECK_POSTGRES          = "postgres"
+	CHECK_REDIS             = "redis"
+	CHECK_MIGRATION_VERSION = "migration_version"
+)
+
+type Check struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Detail    string `json:"detail,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks,omitempty"`
+}
+
+// IsUp is true only when every check reported up
+func (report *Report) IsUp() bool {
+	for _, check := range report.Checks {
+		if check.Status != CHECK_STATUS_UP {
+			return false
+		}
+	}
+
+	return true
+}
+
+type RedisPoolStats struct {
+	Hits       uint32 `json:"hits"`
+	Misses     uint32 `json:"misses"`
+	Timeouts   uint32 `json:"timeouts"`
+	TotalConns uint32 `json:"total_conns"`
+	IdleConns  uint32 `json:"idle_conns"`
+	StaleConns uint32 `json:"stale_conns"`
+}
+
+type LockStats struct {
+	Acquired    int64 `json:"acquired"`
+	Failed      int64 `json:"failed"`
+	Released    int64 `json:"released"`
+	WaitMsTotal int64 `json:"wait_ms_total"`
+}
+
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+type Status struct {
+	Readiness    Report         `json:"readiness"`
+	PostgresPool sql.DBStats    `json:"postgres_pool"`
+	RedisPool    RedisPoolStats `json:"redis_pool"`
+	Locks        LockStats      `json:"locks"`
+	Build        BuildInfo      `json:"build"`
+	Uptime       string         `json:"uptime"`
+}
+
+type HealthUsecase interface {
+	Liveness(ctx context.Context) (res *response.Response[Report])
+	Readiness(ctx context.Context) (res *response.Response[Report])
+	Status(ctx context.Context) (res *response.Response[Status])
+}
@@ -0,0 +1,173 @@
+package member
+
+import (
+	"context"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"time"
+)
+
+const (
+	MEMBER_ROLE_OWNER   = "owner"   // the customer owning the wallet, everything is allowed
+	MEMBER_ROLE_SPENDER = "spender" // reads, deposits and withdrawals within the member limits
+	MEMBER_ROLE_VIEWER  = "viewer"  // reads only
+
+	MEMBER_STATUS_INVITED = "invited"
+	MEMBER_STATUS_ACTIVE  = "active"
+
+	SPENDING_APPROVAL_STATUS_PENDING   = "pending"
+	SPENDING_APPROVAL_STATUS_APPROVING = "approving" // claimed by the owner, the withdrawal is being made
+	SPENDING_APPROVAL_STATUS_APPROVED  = "approved"  // and the withdrawal was made
+	SPENDING_APPROVAL_STATUS_REJECTED  = "rejected"
+	SPENDING_APPROVAL_STATUS_FAILED    = "failed" // approved, but the withdrawal was refused, e.g. insufficient fund
+
+	// the spending limit of a member covers their withdrawals over this window
+	SPENDING_LIMIT_WINDOW = time.Hour * 24
+	// an approval left approving longer than this was interrupted midway, e.g. by a crash, and can be approved again
+	SPENDING_APPROVAL_CLAIM_TIMEOUT = time.Minute
+)
+
+type Member struct {
+	WalletId          string  `json:"wallet_id" gorm:"column:wallet_id"`
+	MemberId          string  `json:"member_id" gorm:"column:member_id"` // customer_xid of the member
+	Role              string  `json:"role" gorm:"column:role"`
+	SpendingLimit     *int    `json:"spending_limit" gorm:"column:spending_limit"`         // withdrawn within SPENDING_LIMIT_WINDOW, nil is unlimited
+	ApprovalThreshold *int    `json:"approval_threshold" gorm:"column:approval_threshold"` // withdrawals above need the owner's approval, nil never do
+	Status            string  `json:"status" gorm:"column:status"`
+	InvitedBy         string  `json:"invited_by" gorm:"column:invited_by"`
+	CreatedAt         string  `json:"created_at" gorm:"column:created_at"`
+	AcceptedAt        *string `json:"accepted_at" gorm:"column:accepted_at"`
+}
+
+// NeedsApproval tells whether a withdrawal of amount has to wait for the owner
+func (member *Member) NeedsApproval(amount int) bool {
+	return member.ApprovalThreshold != nil && amount > *member.ApprovalThreshold
+}
+
+// ValidateSpending checks a withdrawal of amount against what the member already withdrew within the window,
+// plus what is still waiting for the owner's approval
+func (member *Member) ValidateSpending(amount int, spent int) error {
+	if member.SpendingLimit != nil && spent+amount > *member.SpendingLimit {
+		return response.ErrSpendingLimit
+	}
+
+	return nil
+}
+
+type SpendingApproval struct {
+	Id          string  `json:"id" gorm:"column:id"`
+	WalletId    string  `json:"wallet_id" gorm:"column:wallet_id"`
+	MemberId    string  `json:"member_id" gorm:"column:member_id"`
+	Amount      int     `json:"amount" gorm:"column:amount"`
+	ReferenceId string  `json:"reference_id" gorm:"column:reference_id"`
+	Status      string  `json:"status" gorm:"column:status"`
+	Error       *string `json:"error,omitempty" gorm:"column:error"`
+	DecidedBy   *string `json:"decided_by" gorm:"column:decided_by"`
+	DecidedAt   *string `json:"decided_at" gorm:"column:decided_at"`
+	CreatedAt   string  `json:"created_at" gorm:"column:created_at"`
+}
+
+// Interrupted tells whether the approval was left approving longer than SPENDING_APPROVAL_CLAIM_TIMEOUT, e.g. by a crash
+func (approval *SpendingApproval) Interrupted(now time.Time) bool {
+	if approval.Status != SPENDING_APPROVAL_STATUS_APPROVING || approval.DecidedAt == nil {
+		return false
+	}
+
+	claimedAt, err := time.Parse(time.RFC3339, *approval.DecidedAt)
+	return err == nil && now.Sub(claimedAt) > SPENDING_APPROVAL_CLAIM_TIMEOUT
+}
+
+// MadeBy tells whether walletTransaction is the withdrawal of the approval, rather than another one using its reference id
+func (approval *SpendingApproval) MadeBy(walletTransaction *wallet.WalletTransactionEntity) bool {
+	return walletTransaction.Type == wallet.WALLET_TRANSACTION_WITHDRAWAL && walletTransaction.Amount == approval.Amount &&
+		walletTransaction.CreatedBy == approval.MemberId
+}
+
+func (approval *SpendingApproval) ToTransactionRequest() wallet.WalletTransactionRequest {
+	return wallet.WalletTransactionRequest{
+		WalletId:    approval.WalletId,
+		Type:        wallet.WALLET_TRANSACTION_WITHDRAWAL,
+		Amount:      approval.Amount,
+		ReferenceId: approval.ReferenceId,
+		CreatedBy:   approval.MemberId,
+	}
+}
+
+// MemberWithdrawal is either made right away or waits for the owner's approval
+type MemberWithdrawal struct {
+	Wallet   *wallet.Wallet    `json:"wallet,omitempty"`
+	Approval *SpendingApproval `json:"approval,omitempty"`
+}
+
+type MemberInvitationRequest struct {
+	WalletId          string `json:"wallet_id"`
+	MemberId          string `json:"member_id" schema:"member_id,required"`
+	Role              string `json:"role" schema:"role,required"`
+	SpendingLimit     *int   `json:"spending_limit" schema:"spending_limit"`
+	ApprovalThreshold *int   `json:"approval_threshold" schema:"approval_threshold"`
+	InvitedBy         string `json:"invited_by"`
+}
+
+func (req *MemberInvitationRequest) Validate() error {
+	validationError := &response.ValidationError{}
+
+	if len(req.MemberId) == 0 {
+		validationError.Add("member_id", "is required")
+	} else if req.MemberId == req.InvitedBy {
+		validationError.Add("member_id", "must not be the owner")
+	}
+	if req.Role != MEMBER_ROLE_SPENDER && req.Role != MEMBER_ROLE_VIEWER {
+		validationError.Add("role", "must be spender or viewer")
+	}
+	if req.SpendingLimit != nil && *req.SpendingLimit <= 0 {
+		validationError.Add("spending_limit", "must be positive")
+	}
+	if req.ApprovalThreshold != nil && *req.ApprovalThreshold <= 0 {
+		validationError.Add("approval_threshold", "must be positive")
+	}
+
+	return validationError.Err()
+}
+
+type MemberUsecase interface {
+	InviteMember(ctx context.Context, req MemberInvitationRequest) (res *response.Response[Member], err error)
+	// GetMembers lists the owner first, then every invited and active member
+	GetMembers(ctx context.Context, walletId string) (res *response.Response[[]Member], err error)
+	RemoveMember(ctx context.Context, walletId string, memberId string) (err error)
+
+	// GetMemberships lists the wallets shared with memberId, invitations included
+	GetMemberships(ctx context.Context, memberId string) (res *response.Response[[]Member], err error)
+	AcceptInvitation(ctx context.Context, memberId string, walletId string) (res *response.Response[Member], err error)
+	// LeaveWallet declines an invitation or ends an accepted membership
+	LeaveWallet(ctx context.Context, memberId string, walletId string) (err error)
+
+	// CreateMemberWithdrawal applies the spending limit and approval threshold of a spender before withdrawing
+	CreateMemberWithdrawal(ctx context.Context, req wallet.WalletTransactionRequest) (res *response.Response[MemberWithdrawal], err error)
+	// GetSpendingApprovals lists the approvals of the wallet, only those of memberId when it is not empty
+	GetSpendingApprovals(ctx context.Context, walletId string, memberId string) (res *response.Response[[]SpendingApproval], err error)
+	ApproveSpending(ctx context.Context, walletId string, approvalId string, ownerId string) (res *response.Response[SpendingApproval], err error)
+	RejectSpending(ctx context.Context, walletId string, approvalId string, ownerId string) (res *response.Response[SpendingApproval], err error)
+}
+
+type MemberRepository interface {
+	InsertMember(ctx context.Context, member Member) (err error)
+	GetMember(ctx context.Context, walletId string, memberId string) (res *Member, err error)
+	GetMembersByWalletId(ctx context.Context, walletId string) (res []Member, err error)
+	GetMembersByMemberId(ctx context.Context, memberId string) (res []Member, err error)
+	UpdateMember(ctx context.Context, member Member) (err error)
+	DeleteMember(ctx context.Context, walletId string, memberId string) (deleted bool, err error)
+	// WithMemberForUpdate runs fn holding a lock on the membership of memberId, a second call for the same member waits
+	// until fn returned. membership is nil when memberId is no member of the wallet
+	WithMemberForUpdate(ctx context.Context, walletId string, memberId string, fn func(membership *Member) error) (err error)
+	// GetMemberSpending sums the withdrawals memberId made from the wallet since
+	GetMemberSpending(ctx context.Context, walletId string, memberId string, since time.Time) (spent int, err error)
+	// GetMemberPendingSpending sums the approvals of memberId still pending or being approved
+	GetMemberPendingSpending(ctx context.Context, walletId string, memberId string) (pending int, err error)
+
+	InsertSpendingApproval(ctx context.Context, approval SpendingApproval) (err error)
+	GetSpendingApprovalById(ctx context.Context, approvalId string) (res *SpendingApproval, err error)
+	GetSpendingApprovals(ctx context.Context, walletId string, memberId string) (res []SpendingApproval, err error)
+	// UpdateSpendingApproval records the status and decision of approval only while the stored one still has the status
+	// and decided_at of previous, so of two concurrent decisions only one is recorded. updated is false for the other
+	UpdateSpendingApproval(ctx context.Context, approval SpendingApproval, previous SpendingApproval) (updated bool, err error)
+}
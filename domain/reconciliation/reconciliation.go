@@ -0,0 +1,110 @@
+package reconciliation
+
+import (
+	"context"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"time"
+)
+
+const (
+	RECONCILIATION_TRIGGER_JOB     = "job"     // RECONCILIATION_INTERVAL
+	RECONCILIATION_TRIGGER_COMMAND = "command" // mini-wallet reconcile
+
+	RECONCILIATION_STATUS_RUNNING   = "running"
+	RECONCILIATION_STATUS_COMPLETED = "completed" // every wallet was checked, mismatches or not
+	RECONCILIATION_STATUS_FAILED    = "failed"
+)
+
+type ReconciliationOptions struct {
+	Trigger       string `json:"trigger"`
+	WalletId      string `json:"wallet_id"`      // only this wallet when not empty
+	FreezeWallets bool   `json:"freeze_wallets"` // freeze the drifting wallets
+	RepairDryRun  bool   `json:"repair_dry_run"` // propose the adjustment correcting every drift, nothing is applied
+}
+
+type ReconciliationRun struct {
+	Id             string  `json:"id" gorm:"column:id"`
+	Trigger        string  `json:"trigger" gorm:"column:trigger"`
+	WalletId       *string `json:"wallet_id,omitempty" gorm:"column:wallet_id"`
+	FreezeWallets  bool    `json:"freeze_wallets" gorm:"column:freeze_wallets"`
+	RepairDryRun   bool    `json:"repair_dry_run" gorm:"column:repair_dry_run"`
+	Status         string  `json:"status" gorm:"column:status"`
+	WalletsChecked int     `json:"wallets_checked" gorm:"column:wallets_checked"`
+	Mismatches     int     `json:"mismatches" gorm:"column:mismatches"`
+	FrozenWallets  int     `json:"frozen_wallets" gorm:"column:frozen_wallets"`
+	Error          *string `json:"error,omitempty" gorm:"column:error"`
+	StartedAt      string  `json:"started_at" gorm:"column:started_at"`
+	FinishedAt     *string `json:"finished_at" gorm:"column:finished_at"`
+}
+
+// WalletBalanceCheck holds the stored balance and the one computed from the history, read in the same snapshot
+type WalletBalanceCheck struct {
+	WalletId          string     `gorm:"column:wallet_id"`
+	OwnedBy           string     `gorm:"column:owned_by"`
+	WalletStatus      string     `gorm:"column:wallet_status"`
+	StoredBalance     int        `gorm:"column:stored_balance"`
+	ComputedBalance   int        `gorm:"column:computed_balance"`
+	TransactionCount  int        `gorm:"column:transaction_count"`
+	LastTransactionAt *time.Time `gorm:"column:last_transaction_at"`
+}
+
+// Drift is positive when the stored balance is above what the history adds up to
+func (check *WalletBalanceCheck) Drift() int {
+	return check.StoredBalance - check.ComputedBalance
+}
+
+type Mismatch struct {
+	RunId             string     `json:"run_id" gorm:"column:run_id"`
+	WalletId          string     `json:"wallet_id" gorm:"column:wallet_id"`
+	OwnedBy           string     `json:"owned_by" gorm:"column:owned_by"`
+	WalletStatus      string     `json:"wallet_status" gorm:"column:wallet_status"` // when it was checked
+	StoredBalance     int        `json:"stored_balance" gorm:"column:stored_balance"`
+	ComputedBalance   int        `json:"computed_balance" gorm:"column:computed_balance"`
+	Drift             int        `json:"drift" gorm:"column:drift"`
+	TransactionCount  int        `json:"transaction_count" gorm:"column:transaction_count"`
+	LastTransactionAt *time.Time `json:"last_transaction_at" gorm:"column:last_transaction_at"`
+	Frozen            bool       `json:"frozen" gorm:"column:frozen"` // by this run
+
+	// the history is taken as the truth: the adjustment moves the stored balance to the computed one.
+	// only proposed by a repair dry run
+	AdjustmentType        *string `json:"adjustment_type,omitempty" gorm:"column:adjustment_type"`
+	AdjustmentAmount      *int    `json:"adjustment_amount,omitempty" gorm:"column:adjustment_amount"`
+	AdjustmentReferenceId *string `json:"adjustment_reference_id,omitempty" gorm:"column:adjustment_reference_id"`
+}
+
+type ReconciliationReport struct {
+	Run        ReconciliationRun `json:"run"`
+	Mismatches []Mismatch        `json:"mismatches"`
+}
+
+type ReconciliationUsecase interface {
+	// Reconcile recomputes the balance of every wallet from its history and records each mismatch.
+	// a failed run still returns the report of the wallets checked until then
+	Reconcile(ctx context.Context, options ReconciliationOptions) (res *response.Response[ReconciliationReport], err error)
+	// GetReconciliationRuns lists the latest runs first
+	GetReconciliationRuns(ctx context.Context) (res *response.Response[[]ReconciliationRun], err error)
+	GetReconciliationReport(ctx context.Context, runId string) (res *response.Response[ReconciliationReport], err error)
+	// FreezeWallet refuses every transaction on the wallet until UnfreezeWallet, freezing it twice is not an error
+	FreezeWallet(ctx context.Context, walletId string) (res *response.Response[wallet.Wallet], err error)
+	// UnfreezeWallet leaves the wallet disabled, its owner enables it again
+	UnfreezeWallet(ctx context.Context, walletId string) (res *response.Response[wallet.Wallet], err error)
+	// RunReconciliationJob calls Reconcile every RECONCILIATION_INTERVAL until ctx is done
+	RunReconciliationJob(ctx context.Context)
+}
+
+type ReconciliationRepository interface {
+	InsertReconciliationRun(ctx context.Context, run ReconciliationRun) (err error)
+	UpdateReconciliationRun(ctx context.Context, run ReconciliationRun) (err error)
+	GetReconciliationRuns(ctx context.Context, limit int) (res []ReconciliationRun, err error)
+	GetReconciliationRunById(ctx context.Context, runId string) (res *ReconciliationRun, err error)
+	InsertMismatch(ctx context.Context, mismatch Mismatch) (err error)
+	GetMismatches(ctx context.Context, runId string) (res []Mismatch, err error)
+
+	// GetWalletBalanceChecks pages by id through the wallets, only walletId when it is not empty
+	GetWalletBalanceChecks(ctx context.Context, walletId string, afterId string, limit int) (res []WalletBalanceCheck, err error)
+	// FreezeWallet is false when the wallet was frozen already
+	FreezeWallet(ctx context.Context, walletId string) (frozen bool, err error)
+	// UnfreezeWallet disables a frozen wallet, false when it was not frozen
+	UnfreezeWallet(ctx context.Context, walletId string) (unfrozen bool, err error)
+}
@@ -0,0 +1,116 @@
+package schedule
+
+import (
+	"context"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"time"
+)
+
+const (
+	SCHEDULE_RULE_CRON  = "cron"  // standard 5 field cron expression, e.g. "0 9 1 * *"
+	SCHEDULE_RULE_RRULE = "rrule" // RFC 5545 recurrence rule, e.g. "FREQ=MONTHLY;BYMONTHDAY=1;BYHOUR=9"
+
+	SCHEDULE_STATUS_ACTIVE    = "active"
+	SCHEDULE_STATUS_PAUSED    = "paused"    // by the customer, or because the wallet got disabled
+	SCHEDULE_STATUS_FINISHED  = "finished"  // end_at or max_count reached
+	SCHEDULE_STATUS_CANCELLED = "cancelled" // by the customer, can not be resumed
+
+	SCHEDULE_EXECUTION_STATUS_SUCCEEDED = "succeeded"
+	SCHEDULE_EXECUTION_STATUS_FAILED    = "failed"
+	SCHEDULE_EXECUTION_STATUS_SKIPPED   = "skipped" // the wallet was disabled, the schedule got paused
+)
+
+type Schedule struct {
+	Id             string     `json:"id" gorm:"column:id"`
+	WalletId       string     `json:"wallet_id" gorm:"column:wallet_id"`
+	Type           string     `json:"type" gorm:"column:type"`
+	Amount         int        `json:"amount" gorm:"column:amount"`
+	RuleType       string     `json:"rule_type" gorm:"column:rule_type"`
+	Rule           string     `json:"rule" gorm:"column:rule"`
+	Timezone       string     `json:"timezone" gorm:"column:timezone"` // IANA name the rule is evaluated in
+	StartAt        time.Time  `json:"start_at" gorm:"column:start_at"`
+	EndAt          *time.Time `json:"end_at" gorm:"column:end_at"`
+	MaxCount       *int       `json:"max_count" gorm:"column:max_count"`
+	Status         string     `json:"status" gorm:"column:status"`
+	PausedReason   *string    `json:"paused_reason,omitempty" gorm:"column:paused_reason"`
+	ExecutionCount int        `json:"execution_count" gorm:"column:execution_count"`
+	NextRunAt      *time.Time `json:"next_run_at" gorm:"column:next_run_at"` // nil once finished
+	Version        int        `json:"-" gorm:"column:version"`               // incremented on every update
+	CreatedAt      string     `json:"created_at" gorm:"column:created_at"`
+}
+
+type ScheduleExecution struct {
+	ScheduleId    string    `json:"-" gorm:"column:schedule_id"`
+	ScheduledAt   time.Time `json:"scheduled_at" gorm:"column:scheduled_at"`
+	ReferenceId   string    `json:"reference_id" gorm:"column:reference_id"`
+	Status        string    `json:"status" gorm:"column:status"`
+	Error         *string   `json:"error,omitempty" gorm:"column:error"`
+	TransactionId *string   `json:"transaction_id,omitempty" gorm:"column:transaction_id"`
+	ExecutedAt    string    `json:"executed_at" gorm:"column:executed_at"`
+}
+
+type ScheduleRequest struct {
+	WalletId string     `json:"wallet_id"`
+	Type     string     `json:"type" schema:"type,required"`
+	Amount   int        `json:"amount" schema:"amount,required"`
+	RuleType string     `json:"rule_type" schema:"rule_type,required"`
+	Rule     string     `json:"rule" schema:"rule,required"`
+	Timezone string     `json:"timezone" schema:"timezone"`
+	StartAt  *time.Time `json:"start_at" schema:"start_at"` // defaults to now
+	EndAt    *time.Time `json:"end_at" schema:"end_at"`
+	MaxCount *int       `json:"max_count" schema:"max_count"`
+}
+
+func (req *ScheduleRequest) Validate() error {
+	validationError := &response.ValidationError{}
+
+	if req.Amount <= 0 {
+		validationError.Add("amount", "must be positive")
+	}
+	if len(req.Rule) == 0 {
+		validationError.Add("rule", "is required")
+	}
+	if req.Type != wallet.WALLET_TRANSACTION_DEPOSIT && req.Type != wallet.WALLET_TRANSACTION_WITHDRAWAL {
+		validationError.Add("type", "must be deposit or withdrawal")
+	}
+	if req.RuleType != SCHEDULE_RULE_CRON && req.RuleType != SCHEDULE_RULE_RRULE {
+		validationError.Add("rule_type", "must be cron or rrule")
+	}
+	if req.MaxCount != nil && *req.MaxCount <= 0 {
+		validationError.Add("max_count", "must be positive")
+	}
+	if req.EndAt != nil && req.StartAt != nil && !req.EndAt.After(*req.StartAt) {
+		validationError.Add("end_at", "must be after start_at")
+	}
+
+	return validationError.Err()
+}
+
+type ScheduleUsecase interface {
+	CreateSchedule(ctx context.Context, req ScheduleRequest) (res *response.Response[Schedule], err error)
+	GetSchedules(ctx context.Context, walletId string) (res *response.Response[[]Schedule], err error)
+	GetScheduleExecutions(ctx context.Context, walletId string, scheduleId string) (res *response.Response[[]ScheduleExecution], err error)
+	PauseSchedule(ctx context.Context, walletId string, scheduleId string) (res *response.Response[Schedule], err error)
+	// ResumeSchedule continues from the next occurrence after now, missed ones are not caught up
+	ResumeSchedule(ctx context.Context, walletId string, scheduleId string) (res *response.Response[Schedule], err error)
+	CancelSchedule(ctx context.Context, walletId string, scheduleId string) (res *response.Response[Schedule], err error)
+
+	// RunDueSchedules fires every occurrence due at now
+	RunDueSchedules(ctx context.Context, now time.Time) (err error)
+	// RunScheduler calls RunDueSchedules on every tick until ctx is done
+	RunScheduler(ctx context.Context)
+}
+
+type ScheduleRepository interface {
+	InsertSchedule(ctx context.Context, schedule Schedule) (err error)
+	GetScheduleById(ctx context.Context, scheduleId string) (res *Schedule, err error)
+	GetSchedulesByWalletId(ctx context.Context, walletId string) (res []Schedule, err error)
+	GetDueSchedules(ctx context.Context, now time.Time, limit int) (res []Schedule, err error)
+	// UpdateSchedule writes the status and progress only if the version did not change since schedule was read,
+	// so two scheduler instances never advance the same occurrence twice. updated is false when it lost
+	UpdateSchedule(ctx context.Context, schedule Schedule) (updated bool, err error)
+	// InsertScheduleExecution keeps the first record of an occurrence
+	InsertScheduleExecution(ctx context.Context, execution ScheduleExecution) (err error)
+	GetScheduleExecutions(ctx context.Context, scheduleId string) (res []ScheduleExecution, err error)
+}
@@ -0,0 +1,166 @@
+package settlement
+
+import (
+	"context"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"strings"
+	"time"
+)
+
+const (
+	SETTLEMENT_FORMAT_CSV         = "csv"
+	SETTLEMENT_FORMAT_FIXED_WIDTH = "fixed_width"
+
+	SETTLEMENT_DATE_LAYOUT = "2006-01-02"
+
+	SETTLEMENT_RESULT_MATCHED            = "matched"
+	SETTLEMENT_RESULT_MISSING_INTERNALLY = "missing_internally" // in the file, no deposit left with its reference id
+	SETTLEMENT_RESULT_MISSING_EXTERNALLY = "missing_externally" // a deposit of the settlement date no file has settled
+	SETTLEMENT_RESULT_AMOUNT_MISMATCH    = "amount_mismatch"    // same reference id, another amount
+
+	SETTLEMENT_EXCEPTION_OPEN     = "open"
+	SETTLEMENT_EXCEPTION_RESOLVED = "resolved"
+
+	// fixed width records, the amount is zero padded on the left and the reference id space padded on the right:
+	//   H<settlement date YYYYMMDD>
+	//   D<reference id><amount>
+	//   T<detail count><amount total>
+	SETTLEMENT_FIXED_WIDTH_HEADER      = 'H'
+	SETTLEMENT_FIXED_WIDTH_DETAIL      = 'D'
+	SETTLEMENT_FIXED_WIDTH_TRAILER     = 'T'
+	SETTLEMENT_FIXED_WIDTH_REFERENCE   = 36
+	SETTLEMENT_FIXED_WIDTH_AMOUNT      = 15
+	SETTLEMENT_FIXED_WIDTH_COUNT       = 9
+	SETTLEMENT_FIXED_WIDTH_DATE_LAYOUT = "20060102"
+)
+
+var (
+	settlementFormats = map[string]struct{}{
+		SETTLEMENT_FORMAT_CSV:         {},
+		SETTLEMENT_FORMAT_FIXED_WIDTH: {},
+	}
+)
+
+// Settlement is an ingested file of the bank, its counts tell how its lines were matched
+type Settlement struct {
+	Id                string `json:"id" gorm:"column:id"`
+	FileName          string `json:"file_name" gorm:"column:file_name"`
+	Format            string `json:"format" gorm:"column:format"`
+	SettlementDate    string `json:"settlement_date" gorm:"column:settlement_date"`
+	Checksum          string `json:"checksum" gorm:"column:checksum"` // sha256 of the file, the same file is ingested once
+	TotalLines        int    `json:"total_lines" gorm:"column:total_lines"`
+	Matched           int    `json:"matched" gorm:"column:matched"`
+	MissingInternally int    `json:"missing_internally" gorm:"column:missing_internally"`
+	MissingExternally int    `json:"missing_externally" gorm:"column:missing_externally"`
+	AmountMismatch    int    `json:"amount_mismatch" gorm:"column:amount_mismatch"`
+	CreatedAt         string `json:"created_at" gorm:"column:created_at"`
+}
+
+// Count adds a result to the counts of its status
+func (settlement *Settlement) Count(status string) {
+	switch status {
+	case SETTLEMENT_RESULT_MATCHED:
+		settlement.Matched++
+	case SETTLEMENT_RESULT_MISSING_INTERNALLY:
+		settlement.MissingInternally++
+	case SETTLEMENT_RESULT_MISSING_EXTERNALLY:
+		settlement.MissingExternally++
+	case SETTLEMENT_RESULT_AMOUNT_MISMATCH:
+		settlement.AmountMismatch++
+	}
+}
+
+type SettlementLine struct {
+	Line        int // 1-based position in the file
+	ReferenceId string
+	Amount      int
+}
+
+// SettlementResult is a line of the file, or a deposit missing from it. anything but matched is an exception to look into
+type SettlementResult struct {
+	Id              string  `json:"id" gorm:"column:id"`
+	SettlementId    string  `json:"settlement_id" gorm:"column:settlement_id"`
+	Status          string  `json:"status" gorm:"column:status"`
+	Line            *int    `json:"line" gorm:"column:line"` // empty when missing externally
+	ReferenceId     string  `json:"reference_id" gorm:"column:reference_id"`
+	ExternalAmount  *int    `json:"external_amount" gorm:"column:external_amount"`
+	InternalAmount  *int    `json:"internal_amount" gorm:"column:internal_amount"`
+	TransactionId   *string `json:"transaction_id" gorm:"column:transaction_id"`
+	WalletId        *string `json:"wallet_id" gorm:"column:wallet_id"`
+	ExceptionStatus *string `json:"exception_status,omitempty" gorm:"column:exception_status"`
+	ResolutionNote  *string `json:"resolution_note,omitempty" gorm:"column:resolution_note"`
+	ResolvedAt      *string `json:"resolved_at,omitempty" gorm:"column:resolved_at"`
+}
+
+type SettlementReport struct {
+	Settlement Settlement         `json:"settlement"`
+	Results    []SettlementResult `json:"results"`
+}
+
+type SettlementRequest struct {
+	FileName       string
+	Format         string
+	SettlementDate string // YYYY-MM-DD, read from the header of a fixed width file when empty
+	Content        []byte
+}
+
+func (req *SettlementRequest) Validate() error {
+	if _, ok := settlementFormats[req.Format]; !ok || len(req.Content) == 0 {
+		return response.ErrSettlementInvalidFile
+	}
+
+	if req.SettlementDate == "" && req.Format == SETTLEMENT_FORMAT_CSV {
+		return response.ErrBadRequest
+	}
+
+	if req.SettlementDate != "" {
+		if _, err := time.Parse(SETTLEMENT_DATE_LAYOUT, req.SettlementDate); err != nil {
+			return response.ErrBadRequest
+		}
+	}
+
+	return nil
+}
+
+// ExceptionResolutionRequest closes an exception, whoever looks at the queue later needs to know why
+type ExceptionResolutionRequest struct {
+	Note string `json:"note" schema:"note,required"`
+}
+
+func (req *ExceptionResolutionRequest) Validate() error {
+	validationError := &response.ValidationError{}
+
+	if strings.TrimSpace(req.Note) == "" {
+		validationError.Add("note", "is required")
+	}
+
+	return validationError.Err()
+}
+
+type SettlementUsecase interface {
+	// IngestSettlement matches every line of the file against the deposits, a file ingested before returns its first result
+	IngestSettlement(ctx context.Context, req SettlementRequest) (res *response.Response[Settlement], err error)
+	// GetSettlements lists the latest files first
+	GetSettlements(ctx context.Context) (res *response.Response[[]Settlement], err error)
+	// GetSettlementReport returns the results of one status only when status is not empty
+	GetSettlementReport(ctx context.Context, settlementId string, status string) (res *response.Response[SettlementReport], err error)
+	// GetSettlementExceptions returns the oldest exceptions first, of every file
+	GetSettlementExceptions(ctx context.Context, exceptionStatus string) (res *response.Response[[]SettlementResult], err error)
+	ResolveSettlementException(ctx context.Context, resultId string, note string) (res *response.Response[SettlementResult], err error)
+}
+
+type SettlementRepository interface {
+	GetSettlementByChecksum(ctx context.Context, checksum string) (res *Settlement, err error)
+	// GetSettlementCandidates returns the deposits not settled yet carrying one of referenceIds or created within [from, to)
+	GetSettlementCandidates(ctx context.Context, referenceIds []string, from time.Time, to time.Time) (res []wallet.WalletTransactionEntity, err error)
+	// InsertSettlement also resolves the exceptions of the deposits missing from an earlier file this one settles
+	InsertSettlement(ctx context.Context, settlement Settlement, results []SettlementResult) (err error)
+	GetSettlements(ctx context.Context, limit int) (res []Settlement, err error)
+	GetSettlementById(ctx context.Context, settlementId string) (res *Settlement, err error)
+	GetSettlementResults(ctx context.Context, settlementId string, status string) (res []SettlementResult, err error)
+	GetSettlementExceptions(ctx context.Context, exceptionStatus string, limit int) (res []SettlementResult, err error)
+	GetSettlementResultById(ctx context.Context, resultId string) (res *SettlementResult, err error)
+	// ResolveSettlementException is false when the exception was not open
+	ResolveSettlementException(ctx context.Context, resultId string, note string, resolvedAt string) (resolved bool, err error)
+}
@@ -0,0 +1,137 @@
+package statement
+
+import (
+	"context"
+	"fmt"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"time"
+)
+
+const (
+	STATEMENT_FORMAT_JSON = "json"
+	STATEMENT_FORMAT_CSV  = "csv"
+	STATEMENT_FORMAT_PDF  = "pdf"
+
+	// from and to are calendar days in the statement timezone, both inclusive
+	STATEMENT_DATE_LAYOUT = "2006-01-02"
+	// longest period a single statement can cover
+	STATEMENT_MAX_DAYS = 366
+)
+
+var (
+	StatementFormats = []string{STATEMENT_FORMAT_JSON, STATEMENT_FORMAT_CSV, STATEMENT_FORMAT_PDF}
+
+	statementContentTypes = map[string]string{
+		STATEMENT_FORMAT_JSON: "application/json",
+		STATEMENT_FORMAT_CSV:  "text/csv",
+		STATEMENT_FORMAT_PDF:  "application/pdf",
+	}
+)
+
+// Statement is computed from the transactions of the wallet only, the stored balance is not read
+type Statement struct {
+	WalletId       string           `json:"wallet_id"`
+	From           string           `json:"from"`
+	To             string           `json:"to"`
+	Timezone       string           `json:"timezone"`
+	OpeningBalance int              `json:"opening_balance"`
+	Transactions   []StatementLine  `json:"transactions"`
+	Totals         []StatementTotal `json:"totals"`
+	ClosingBalance int              `json:"closing_balance"`
+}
+
+type StatementLine struct {
+	Id          string `json:"id"`
+	CreatedAt   string `json:"created_at"`
+	CreatedBy   string `json:"created_by"`
+	Type        string `json:"type"`
+	Amount      int    `json:"amount"`
+	ReferenceId string `json:"reference_id"`
+	Balance     int    `json:"balance"` // right after this transaction
+}
+
+type StatementTotal struct {
+	Type   string `json:"type"`
+	Count  int    `json:"count"`
+	Amount int    `json:"amount"`
+}
+
+// StatementDocument is a statement rendered in one format, stored once the period is over
+type StatementDocument struct {
+	WalletId   string `json:"wallet_id" gorm:"column:wallet_id"`
+	PeriodFrom string `json:"period_from" gorm:"column:period_from"`
+	PeriodTo   string `json:"period_to" gorm:"column:period_to"`
+	Format     string `json:"format" gorm:"column:format"`
+	Content    []byte `json:"-" gorm:"column:content"`
+	Checksum   string `json:"checksum" gorm:"column:checksum"` // hex sha256 of content
+	CreatedAt  string `json:"created_at" gorm:"column:created_at"`
+}
+
+func (document *StatementDocument) ContentType() string {
+	return statementContentTypes[document.Format]
+}
+
+func (document *StatementDocument) FileName() string {
+	return fmt.Sprintf("statement-%s-%s-%s.%s", document.WalletId, document.PeriodFrom, document.PeriodTo, document.Format)
+}
+
+type StatementRequest struct {
+	WalletId string `json:"wallet_id"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Format   string `json:"format"`
+}
+
+func (req *StatementRequest) Validate() error {
+	if _, ok := statementContentTypes[req.Format]; !ok {
+		return response.ErrBadRequest
+	}
+
+	from, errFrom := time.Parse(STATEMENT_DATE_LAYOUT, req.From)
+	to, errTo := time.Parse(STATEMENT_DATE_LAYOUT, req.To)
+	if errFrom != nil || errTo != nil || to.Before(from) {
+		return response.ErrBadRequest
+	}
+
+	if to.Sub(from) >= time.Hour*24*STATEMENT_MAX_DAYS {
+		return response.ErrBadRequest
+	}
+
+	return nil
+}
+
+// Period is the time range the statement covers in location, start inclusive and end exclusive
+func (req *StatementRequest) Period(location *time.Location) (start time.Time, end time.Time, err error) {
+	if start, err = time.ParseInLocation(STATEMENT_DATE_LAYOUT, req.From, location); err != nil {
+		return start, end, err
+	}
+	if end, err = time.ParseInLocation(STATEMENT_DATE_LAYOUT, req.To, location); err != nil {
+		return start, end, err
+	}
+
+	return start, end.AddDate(0, 0, 1), nil
+}
+
+type StatementUsecase interface {
+	// GetStatement returns the stored statement of a period which is over, generating and storing it on the first call.
+	// a period which is not over yet is generated on every call and never stored
+	GetStatement(ctx context.Context, req StatementRequest) (res *response.Response[StatementDocument], err error)
+	// GenerateMonthlyStatements stores the statements of the month before now, in every format, for the wallets missing them
+	GenerateMonthlyStatements(ctx context.Context, now time.Time) (err error)
+	// RunStatementJob calls GenerateMonthlyStatements every STATEMENT_INTERVAL until ctx is done
+	RunStatementJob(ctx context.Context)
+}
+
+type StatementRepository interface {
+	GetStatementDocument(ctx context.Context, walletId string, periodFrom string, periodTo string, format string) (res *StatementDocument, err error)
+	// InsertStatementDocument keeps the document stored first for the same period and format, inserted is false then
+	InsertStatementDocument(ctx context.Context, document StatementDocument) (inserted bool, err error)
+	// GetWalletIdsWithoutStatements pages by id through the wallets missing any format of the period
+	GetWalletIdsWithoutStatements(ctx context.Context, periodFrom string, periodTo string, afterId string, limit int) (res []string, err error)
+
+	// GetBalanceBefore sums the transactions of the wallet made before
+	GetBalanceBefore(ctx context.Context, walletId string, before time.Time) (balance int, err error)
+	// GetTransactionsBetween lists the transactions of the wallet made from start until end excluded, oldest first
+	GetTransactionsBetween(ctx context.Context, walletId string, start time.Time, end time.Time) (res []wallet.WalletTransactionEntity, err error)
+}
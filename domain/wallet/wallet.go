@@ -2,8 +2,11 @@ package wallet
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"io"
 	"mini-wallet/domain/common/response"
+	"strings"
+	"time"
 )
 
 const (
@@ -11,34 +14,94 @@ const (
 	WALLET_TRANSACTION_WITHDRAWAL     = "withdrawal"
 	WALLET_STATUS_DISABLED            = "disabled"
 	WALLET_STATUS_ENABLED             = "enabled"
+	WALLET_STATUS_FROZEN              = "frozen" // by the reconciliation, refused like disabled until an admin lifts it
 	WALLET_TRANSACTION_STATUS_SUCCESS = "success"
+	WALLET_MAIN_POCKET                = "Main" // created on init, the auth token points at it
+	WALLET_TRANSACTION_CREATED_BY_OPS = "ops"  // the creator of a balance adjustment made with the admin tool
+
+	// how concurrent deposits and withdrawals on the same wallet are serialized, see WALLET_CONCURRENCY_STRATEGY
+	CONCURRENCY_STRATEGY_REDIS_LOCK         = "redis_lock"         // distributed lock, then read-modify-write
+	CONCURRENCY_STRATEGY_SELECT_FOR_UPDATE  = "select_for_update"  // postgres row lock, then read-modify-write
+	CONCURRENCY_STRATEGY_CONDITIONAL_UPDATE = "conditional_update" // a single UPDATE ... WHERE balance >= amount
+	CONCURRENCY_STRATEGY_OPTIMISTIC         = "optimistic"         // read-modify-write retried on version conflict
+
+	// which WalletLocker backs the redis_lock strategy, see WALLET_LOCKER
+	WALLET_LOCKER_REDIS  = "redis"  // shared by every instance
+	WALLET_LOCKER_MEMORY = "memory" // only within this process, for tests and single node deployments
+
+	WALLET_EXPORT_FORMAT_CSV    = "csv"
+	WALLET_EXPORT_FORMAT_NDJSON = "ndjson" // one json object per line
+)
+
+var (
+	// the columns an export can select, in their default order
+	WalletTransactionColumns = []string{"id", "wallet_id", "type", "amount", "status", "reference_id", "created_at", "created_by"}
 )
 
 type Wallet struct {
 	Id        string  `json:"id" gorm:"column:id"`
 	OwnedBy   string  `json:"owned_by" gorm:"column:owned_by"` // customer_xid on wallet creation
+	Name      string  `json:"name" gorm:"column:name"`         // pocket name, unique per customer
 	EnabledAt *string `json:"enabled_at" gorm:"column:enabled_at"`
 	Balance   int     `json:"balance" gorm:"column:balance"`
 	Status    string  `json:"status" gorm:"column:status"`
+	Version   int     `json:"-" gorm:"column:version"`    // incremented on every write, used for optimistic concurrency
+	LockToken int64   `json:"-" gorm:"column:lock_token"` // fencing token of the latest lock holder which wrote the wallet
 }
 
 func (wallet *Wallet) ValidateWalletStatus() error {
 	if wallet.Status != WALLET_STATUS_ENABLED {
-		return errors.New(response.ERROR_WALLET_DISABLED)
+		return response.ErrWalletDisabled
+	}
+
+	return nil
+}
+
+// ApplyTransaction validates the transaction against the wallet and updates the balance
+func (wallet *Wallet) ApplyTransaction(walletTransaction WalletTransactionEntity) error {
+	if err := wallet.ValidateWalletStatus(); err != nil {
+		return err
+	}
+
+	switch walletTransaction.Type {
+	case WALLET_TRANSACTION_DEPOSIT:
+		wallet.Balance += walletTransaction.Amount
+	case WALLET_TRANSACTION_WITHDRAWAL:
+		if wallet.Balance < walletTransaction.Amount {
+			return response.ErrInsufficientFund
+		}
+		wallet.Balance -= walletTransaction.Amount
+	default:
+		return response.ErrBadRequest
 	}
 
 	return nil
 }
 
 type WalletTransactionEntity struct {
-	Id          string `json:"id" gorm:"column:id"`
-	WalletId    string `json:"wallet_id" gorm:"column:wallet_id"`
-	Amount      int    `json:"amount" gorm:"column:amount"`
-	CreatedAt   string `json:"created_at" gorm:"column:created_at"`
-	CreatedBy   string `json:"created_by" gorm:"column:created_by"`
-	Type        string `json:"type" gorm:"column:type"`
-	Status      string `json:"status" gorm:"column:status"`
-	ReferenceId string `json:"reference_id" gorm:"column:reference_id"`
+	Id          string  `json:"id" gorm:"column:id"`
+	WalletId    string  `json:"wallet_id" gorm:"column:wallet_id"`
+	Amount      int     `json:"amount" gorm:"column:amount"`
+	CreatedAt   string  `json:"created_at" gorm:"column:created_at"`
+	CreatedBy   string  `json:"created_by" gorm:"column:created_by"`
+	Type        string  `json:"type" gorm:"column:type"`
+	Status      string  `json:"status" gorm:"column:status"`
+	ReferenceId string  `json:"reference_id" gorm:"column:reference_id"`
+	Reason      *string `json:"reason,omitempty" gorm:"column:reason"` // of an adjustment by an operator, nil otherwise
+}
+
+// TransactionItemError points at the transaction which made a write of several fail
+type TransactionItemError struct {
+	Index int
+	Err   error
+}
+
+func (itemError *TransactionItemError) Error() string {
+	return fmt.Sprintf("transaction #%d: %s", itemError.Index+1, itemError.Err)
+}
+
+func (itemError *TransactionItemError) Unwrap() error {
+	return itemError.Err
 }
 
 type WalletTransaction struct {
@@ -52,6 +115,15 @@ type WalletTransaction struct {
 	WithdrawnBy *string `json:"withdrawn_by,omitempty"`
 }
 
+// BalanceDelta is the signed change this transaction makes on the wallet balance
+func (walletTransaction *WalletTransactionEntity) BalanceDelta() int {
+	if walletTransaction.Type == WALLET_TRANSACTION_WITHDRAWAL {
+		return -walletTransaction.Amount
+	}
+
+	return walletTransaction.Amount
+}
+
 func (walletTransaction *WalletTransactionEntity) ToWithdrawalTransaction() WalletTransaction {
 	return WalletTransaction{
 		Id:          walletTransaction.Id,
@@ -74,37 +146,182 @@ func (walletTransaction *WalletTransactionEntity) ToDepositTransaction() WalletT
 	}
 }
 
+// WalletTransactionRequest reads amount and reference_id from the request body, the rest is set by the handler
 type WalletTransactionRequest struct {
 	WalletId    string `json:"wallet_id"`
 	Type        string `json:"type"`
-	Amount      int    `json:"amount"`
-	ReferenceId string `json:"reference_id"`
+	Amount      int    `json:"amount" schema:"amount,required"`
+	ReferenceId string `json:"reference_id" schema:"reference_id,required"`
 	Timestamp   int    `json:"timestamp"`
+	CreatedBy   string `json:"created_by"` // the member making it, the wallet owner when empty
 }
 
 func (transactionRequest *WalletTransactionRequest) Validate() error {
-	if transactionRequest.Amount <= 0 || len(transactionRequest.ReferenceId) == 0 {
-		return errors.New(response.ERROR_BAD_REQUEST)
+	validationError := &response.ValidationError{}
+
+	if transactionRequest.Amount <= 0 {
+		validationError.Add("amount", "must be positive")
+	}
+	if len(transactionRequest.ReferenceId) == 0 {
+		validationError.Add("reference_id", "is required")
+	} else if len(transactionRequest.ReferenceId) > 36 {
+		validationError.Add("reference_id", "must be at most 36 characters")
+	}
+	if transactionRequest.Type != WALLET_TRANSACTION_DEPOSIT && transactionRequest.Type != WALLET_TRANSACTION_WITHDRAWAL {
+		validationError.Add("type", "must be deposit or withdrawal")
 	}
 
-	return nil
+	return validationError.Err()
+}
+
+// WalletAdjustmentRequest corrects a balance by hand, e.g. after the reconciliation found a drift. it is recorded as
+// a deposit for a positive amount and a withdrawal for a negative one, whatever the status of the wallet is
+type WalletAdjustmentRequest struct {
+	WalletId    string `json:"wallet_id"`
+	Amount      int    `json:"amount"`
+	Reason      string `json:"reason"`
+	ReferenceId string `json:"reference_id"`
+}
+
+func (req *WalletAdjustmentRequest) Validate() error {
+	validationError := &response.ValidationError{}
+
+	if len(req.WalletId) == 0 {
+		validationError.Add("wallet_id", "is required")
+	}
+	if req.Amount == 0 {
+		validationError.Add("amount", "can not be zero")
+	}
+	if len(strings.TrimSpace(req.Reason)) == 0 || len(req.Reason) > 255 {
+		validationError.Add("reason", "must be between 1 and 255 characters")
+	}
+	if len(req.ReferenceId) == 0 || len(req.ReferenceId) > 36 {
+		validationError.Add("reference_id", "must be between 1 and 36 characters")
+	}
+
+	return validationError.Err()
 }
 
 type WalletCreationRequest struct {
-	CustomerId string `schema:"customer_xid,required"`
+	CustomerId string `json:"customer_xid" schema:"customer_xid,required"`
 }
 
 func (payload *WalletCreationRequest) Validate() error {
+	validationError := &response.ValidationError{}
+
 	if len(payload.CustomerId) == 0 {
-		return errors.New(response.ERROR_BAD_REQUEST)
+		validationError.Add("customer_xid", "is required")
 	}
 
-	return nil
+	return validationError.Err()
+}
+
+type PocketCreationRequest struct {
+	WalletId string `json:"wallet_id"` // any pocket of the customer
+	Name     string `json:"name" schema:"name,required"`
+}
+
+func (payload *PocketCreationRequest) Validate() error {
+	validationError := &response.ValidationError{}
+
+	if len(payload.Name) == 0 || len(payload.Name) > 50 {
+		validationError.Add("name", "must be 1 to 50 characters")
+	}
+
+	return validationError.Err()
+}
+
+// PocketMoveRequest moves balance between two pockets of the same customer
+type PocketMoveRequest struct {
+	WalletId     string `json:"wallet_id"` // the pocket of the request, both pockets must belong to its owner
+	FromPocketId string `json:"from_pocket_id" schema:"from_pocket_id"`
+	ToPocketId   string `json:"to_pocket_id" schema:"to_pocket_id,required"`
+	Amount       int    `json:"amount" schema:"amount,required"`
+	ReferenceId  string `json:"reference_id" schema:"reference_id,required"`
+}
+
+func (payload *PocketMoveRequest) Validate() error {
+	validationError := &response.ValidationError{}
+
+	if payload.Amount <= 0 {
+		validationError.Add("amount", "must be positive")
+	}
+	if len(payload.ReferenceId) == 0 {
+		validationError.Add("reference_id", "is required")
+	} else if len(payload.ReferenceId) > 36 {
+		validationError.Add("reference_id", "must be at most 36 characters")
+	}
+	if len(payload.FromPocketId) == 0 {
+		validationError.Add("from_pocket_id", "is required")
+	}
+	if len(payload.ToPocketId) == 0 {
+		validationError.Add("to_pocket_id", "is required")
+	} else if payload.FromPocketId == payload.ToPocketId {
+		validationError.Add("to_pocket_id", "must differ from from_pocket_id")
+	}
+
+	return validationError.Err()
 }
 
+// PocketMove is recorded as a withdrawal from one pocket and a deposit into the other, sharing the reference id
+type PocketMove struct {
+	ReferenceId string `json:"reference_id"`
+	Amount      int    `json:"amount"`
+	From        Wallet `json:"from"`
+	To          Wallet `json:"to"`
+}
+
+// GetWalletTransactionRequest filters the transaction list and exports, a nil field does not filter
 type GetWalletTransactionRequest struct {
-	WalletId string  `json:"wallet_id"`
-	Type     *string `json:"type"`
+	WalletId  string     `json:"wallet_id"` // empty only for the admin export of every wallet
+	Type      *string    `json:"type"`
+	CreatedBy *string    `json:"created_by"`
+	From      *time.Time `json:"from"` // created at or after
+	To        *time.Time `json:"to"`   // created before
+}
+
+func (req *GetWalletTransactionRequest) Validate() error {
+	if req.Type != nil && *req.Type != WALLET_TRANSACTION_DEPOSIT && *req.Type != WALLET_TRANSACTION_WITHDRAWAL {
+		return response.ErrBadRequest
+	}
+
+	if req.From != nil && req.To != nil && !req.From.Before(*req.To) {
+		return response.ErrBadRequest
+	}
+
+	return nil
+}
+
+type WalletTransactionExportRequest struct {
+	Filter  GetWalletTransactionRequest `json:"filter"`
+	Format  string                      `json:"format"`
+	Columns []string                    `json:"columns"` // WalletTransactionColumns when empty
+}
+
+func (req *WalletTransactionExportRequest) Validate() error {
+	if err := req.Filter.Validate(); err != nil {
+		return err
+	}
+
+	if req.Format != WALLET_EXPORT_FORMAT_CSV && req.Format != WALLET_EXPORT_FORMAT_NDJSON {
+		return response.ErrBadRequest
+	}
+
+	selected := map[string]struct{}{}
+	for _, column := range req.Columns {
+		if _, duplicate := selected[column]; duplicate {
+			return response.ErrBadRequest
+		}
+		selected[column] = struct{}{}
+	}
+	for _, column := range WalletTransactionColumns {
+		delete(selected, column)
+	}
+	if len(selected) > 0 {
+		return response.ErrBadRequest
+	}
+
+	return nil
 }
 
 type WalletUsecase interface {
@@ -112,15 +329,63 @@ type WalletUsecase interface {
 	DisableWallet(ctx context.Context, walletId string) (res *response.Response[Wallet], err error)
 	GetWalletBalance(ctx context.Context, walletId string) (res *response.Response[Wallet], err error)
 	CreateWalletTransaction(ctx context.Context, req WalletTransactionRequest) (res *response.Response[Wallet], err error)
-	GetWalletTransactions(ctx context.Context, walletId string) (res *response.Response[[]WalletTransaction], err error)
+	GetWalletTransactions(ctx context.Context, req GetWalletTransactionRequest) (res *response.Response[[]WalletTransaction], err error)
+	// ExportWalletTransactions writes every transaction matching req.Filter to w, oldest first.
+	// once the export started an error can only cut it short
+	ExportWalletTransactions(ctx context.Context, req WalletTransactionExportRequest, w io.Writer) (err error)
+	// GetPockets lists every pocket of the customer owning walletId
+	GetPockets(ctx context.Context, walletId string) (res *response.Response[[]Wallet], err error)
+	CreatePocket(ctx context.Context, req PocketCreationRequest) (res *response.Response[Wallet], err error)
+	MovePocketBalance(ctx context.Context, req PocketMoveRequest) (res *response.Response[PocketMove], err error)
+	// SubscribeWalletTransactions calls yield with every transaction created on walletId from now on, by any instance.
+	// it returns once ctx is done, or with the error of yield
+	SubscribeWalletTransactions(ctx context.Context, walletId string, yield func(walletTransaction WalletTransactionEntity) error) (err error)
+	// AdjustWalletBalance writes an adjustment under the row lock of the wallet, it may not take the balance below zero
+	AdjustWalletBalance(ctx context.Context, req WalletAdjustmentRequest) (res *response.Response[Wallet], err error)
+}
+
+// WalletLocker serializes the read-modify-write of a single wallet
+type WalletLocker interface {
+	// Acquire waits until the lock is held or ctx is done
+	Acquire(ctx context.Context, walletId string) (lock WalletLock, err error)
+}
+
+type WalletLock interface {
+	// Token is the fencing token, it only ever grows for a wallet and is checked by the repository on write
+	// so a holder whose lock expired meanwhile can not overwrite the next holder
+	Token() int64
+	// Extend pushes the expiry out by another lock TTL, for operations that may outlive it
+	Extend(ctx context.Context) (err error)
+	// Release may be called more than once, and after the lock already expired
+	Release(ctx context.Context) (err error)
 }
 
+// WalletRepository answers a missing wallet or transaction of a Get method with nil, nil and a list without match
+// with an empty slice. A write on a missing wallet fails with response.ErrWalletNotFound, every write is all or nothing
 type WalletRepository interface {
+	// GetCustomerWallet returns the main pocket of the customer
 	GetCustomerWallet(ctx context.Context, customerId string) (res *Wallet, err error)
+	GetCustomerWallets(ctx context.Context, customerId string) (res []Wallet, err error)
 	GetWalletById(ctx context.Context, walletId string) (res *Wallet, err error)
+	// GetWallets pages by id through every wallet
+	GetWallets(ctx context.Context, afterId string, limit int) (res []Wallet, err error)
 	InsertWallet(ctx context.Context, wallet Wallet) (err error)
 	UpdateWallet(ctx context.Context, wallet Wallet) (err error)
+	// CreateWalletTransaction writes the balance computed by the caller, only if the wallet version did not change since it was read
+	// and no lock holder with a newer fencing token than updatedWallet.LockToken wrote it
 	CreateWalletTransaction(ctx context.Context, updatedWallet Wallet, walletTransaction WalletTransactionEntity) (err error)
-	GetWalletTransactionByReferenceId(ctx context.Context, referenceId string) (res *WalletTransactionEntity, err error)
-	GetWalletTransactionsByWalletId(ctx context.Context, walletId string, page int, size int) (res []WalletTransactionEntity, err error)
+	// CreateWalletTransactionForUpdate locks the wallet row, lets apply change it and writes it back in the same database transaction
+	CreateWalletTransactionForUpdate(ctx context.Context, walletId string, apply func(lockedWallet *Wallet) (walletTransaction WalletTransactionEntity, err error)) (res *Wallet, err error)
+	// CreateWalletTransactionAtomically moves the balance with a single conditional UPDATE, no read beforehand
+	CreateWalletTransactionAtomically(ctx context.Context, walletTransaction WalletTransactionEntity) (res *Wallet, err error)
+	// CreateWalletTransactionsAtomically applies every transaction like CreateWalletTransactionAtomically in one database transaction,
+	// when one is rejected nothing is written and err is a *TransactionItemError
+	CreateWalletTransactionsAtomically(ctx context.Context, walletTransactions []WalletTransactionEntity) (err error)
+	GetWalletTransactionByReferenceId(ctx context.Context, walletId string, referenceId string) (res *WalletTransactionEntity, err error)
+	GetWalletTransactionById(ctx context.Context, transactionId string) (res *WalletTransactionEntity, err error)
+	// GetWalletTransactionsByWalletId pages through the transactions of req.WalletId newest first, page starts at 1
+	GetWalletTransactionsByWalletId(ctx context.Context, req GetWalletTransactionRequest, page int, size int) (res []WalletTransactionEntity, err error)
+	// StreamWalletTransactions calls yield with every transaction matching req, oldest first. they are fetched in pages
+	// from a server side cursor, so the history is never held in memory. an error of yield stops the stream and is returned
+	StreamWalletTransactions(ctx context.Context, req GetWalletTransactionRequest, yield func(walletTransaction WalletTransactionEntity) error) (err error)
 }
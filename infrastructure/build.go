@@ -0,0 +1,23 @@
+package infrastructure
+
+import (
+	"runtime"
+	"time"
+)
+
+// set on build time, e.g. go build -ldflags "-X mini-wallet/infrastructure.Version=v1.0.0"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+
+	startedAt = time.Now()
+)
+
+func GoVersion() string {
+	return runtime.Version()
+}
+
+func Uptime() time.Duration {
+	return time.Since(startedAt)
+}
@@ -0,0 +1,119 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	memorySubscriberBuffer = 256 // events a slow subscriber may lag behind before it misses some
+)
+
+type memoryCache struct {
+	mu          sync.Mutex
+	values      map[string]memoryCacheValue
+	subscribers map[string]map[chan Event]struct{}
+}
+
+type memoryCacheValue struct {
+	value     string
+	expiresAt time.Time // zero when the key never expires
+}
+
+// NewMemoryCache keeps the keys and the pub/sub channels in this process, for tests and the memory storage.
+// a missing key is redis.Nil like on redis, so the callers can not tell the difference
+func NewMemoryCache() Cache {
+	return &memoryCache{
+		values:      map[string]memoryCacheValue{},
+		subscribers: map[string]map[chan Event]struct{}{},
+	}
+}
+
+func (cache *memoryCache) SetString(ctx context.Context, key string, obj string, ttlInSec int) (err error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cacheValue := memoryCacheValue{value: obj}
+	if ttlInSec > 0 {
+		cacheValue.expiresAt = time.Now().Add(time.Second * time.Duration(ttlInSec))
+	}
+	cache.values[key] = cacheValue
+
+	return nil
+}
+
+func (cache *memoryCache) GetString(ctx context.Context, key string) (result string, err error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cacheValue, found := cache.values[key]
+	if !found {
+		return "", redis.Nil
+	}
+	// expired keys are only dropped once they are read
+	if !cacheValue.expiresAt.IsZero() && !time.Now().Before(cacheValue.expiresAt) {
+		delete(cache.values, key)
+		return "", redis.Nil
+	}
+
+	return cacheValue.value, nil
+}
+
+func (cache *memoryCache) Del(ctx context.Context, key string) (err error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	delete(cache.values, key)
+	return nil
+}
+
+// Publish encodes the event like the redis cache, a subscriber lagging more than memorySubscriberBuffer events misses it
+func (cache *memoryCache) Publish(ctx context.Context, channel string, payload interface{}) (err error) {
+	payloadInBytes, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	event := Event{
+		Headers: InjectTraceContext(ctx),
+		Payload: payloadInBytes,
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for subscriber := range cache.subscribers[channel] {
+		select {
+		case subscriber <- event:
+		default:
+			LogWarn(ctx, "subscriber is lagging behind, event dropped", "channel", channel)
+		}
+	}
+
+	return nil
+}
+
+func (cache *memoryCache) Subscribe(ctx context.Context, channel string) (events <-chan Event, err error) {
+	received := make(chan Event, memorySubscriberBuffer)
+
+	cache.mu.Lock()
+	if cache.subscribers[channel] == nil {
+		cache.subscribers[channel] = map[chan Event]struct{}{}
+	}
+	cache.subscribers[channel][received] = struct{}{}
+	cache.mu.Unlock()
+
+	// closed under mu, so Publish never sends on a closed channel
+	context.AfterFunc(ctx, func() {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+
+		delete(cache.subscribers[channel], received)
+		close(received)
+	})
+
+	return received, nil
+}
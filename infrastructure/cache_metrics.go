@@ -0,0 +1,54 @@
+package infrastructure
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+type instrumentedCache struct {
+	cache Cache
+}
+
+// NewInstrumentedCache records the latency of every cache call on RedisCallDuration
+func NewInstrumentedCache(cache Cache) Cache {
+	return &instrumentedCache{
+		cache: cache,
+	}
+}
+
+func (instrumented *instrumentedCache) SetString(ctx context.Context, key string, obj string, ttlInSec int) (err error) {
+	defer observeRedisCall("set_string", time.Now(), &err)
+	return instrumented.cache.SetString(ctx, key, obj, ttlInSec)
+}
+
+func (instrumented *instrumentedCache) GetString(ctx context.Context, key string) (result string, err error) {
+	defer observeRedisCall("get_string", time.Now(), &err)
+	return instrumented.cache.GetString(ctx, key)
+}
+
+func (instrumented *instrumentedCache) Del(ctx context.Context, key string) (err error) {
+	defer observeRedisCall("del", time.Now(), &err)
+	return instrumented.cache.Del(ctx, key)
+}
+
+func (instrumented *instrumentedCache) Publish(ctx context.Context, channel string, payload interface{}) (err error) {
+	defer observeRedisCall("publish", time.Now(), &err)
+	return instrumented.cache.Publish(ctx, channel, payload)
+}
+
+func (instrumented *instrumentedCache) Subscribe(ctx context.Context, channel string) (events <-chan Event, err error) {
+	defer observeRedisCall("subscribe", time.Now(), &err)
+	return instrumented.cache.Subscribe(ctx, channel)
+}
+
+func observeRedisCall(operation string, startedAt time.Time, err *error) {
+	outcome := MetricOutcome(*err)
+	if *err == redis.Nil {
+		// a missing key is an expected answer, not a redis failure
+		outcome = METRIC_OUTCOME_SUCCESS
+	}
+
+	RedisCallDuration.WithLabelValues(operation, outcome).Observe(time.Since(startedAt).Seconds())
+}
@@ -0,0 +1,74 @@
+package infrastructure
+
+import (
+	"context"
+
+	"github.com/go-redis/redis"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracedCache struct {
+	cache Cache
+}
+
+// NewTracedCache opens a client span for every cache call
+func NewTracedCache(cache Cache) Cache {
+	return &tracedCache{
+		cache: cache,
+	}
+}
+
+func (traced *tracedCache) SetString(ctx context.Context, key string, obj string, ttlInSec int) (err error) {
+	ctx, span := startRedisSpan(ctx, "SET")
+	defer EndSpan(span, &err)
+
+	return traced.cache.SetString(ctx, key, obj, ttlInSec)
+}
+
+func (traced *tracedCache) GetString(ctx context.Context, key string) (result string, err error) {
+	ctx, span := startRedisSpan(ctx, "GET")
+	defer func() {
+		// a missing key is an expected answer
+		if err == redis.Nil {
+			span.SetAttributes(attribute.Bool("cache.hit", false))
+			span.End()
+			return
+		}
+		EndSpan(span, &err)
+	}()
+
+	return traced.cache.GetString(ctx, key)
+}
+
+func (traced *tracedCache) Del(ctx context.Context, key string) (err error) {
+	ctx, span := startRedisSpan(ctx, "DEL")
+	defer EndSpan(span, &err)
+
+	return traced.cache.Del(ctx, key)
+}
+
+func (traced *tracedCache) Publish(ctx context.Context, channel string, payload interface{}) (err error) {
+	ctx, span := startRedisSpan(ctx, "PUBLISH", attribute.String("messaging.destination.name", channel))
+	defer EndSpan(span, &err)
+
+	return traced.cache.Publish(ctx, channel, payload)
+}
+
+// the span only covers subscribing, not the events received afterwards
+func (traced *tracedCache) Subscribe(ctx context.Context, channel string) (events <-chan Event, err error) {
+	ctx, span := startRedisSpan(ctx, "SUBSCRIBE", attribute.String("messaging.destination.name", channel))
+	defer EndSpan(span, &err)
+
+	return traced.cache.Subscribe(ctx, channel)
+}
+
+// keys are tokens on this service, so they are never attached to the span
+func startRedisSpan(ctx context.Context, command string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	attributes = append(attributes,
+		attribute.String("db.system", "redis"),
+		attribute.String("db.operation", command),
+	)
+
+	return Tracer().Start(ctx, "redis "+command, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
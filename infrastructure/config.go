@@ -1,31 +1,347 @@
 package infrastructure
 
 import (
-	"os"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ory/viper"
+	"github.com/spf13/pflag"
+)
+
+const (
+	STORAGE_POSTGRES = "postgres" // postgres, and redis for the tokens, the locks and the events
+	STORAGE_MEMORY   = "memory"   // this process only, for demos and tests
 )
 
+// Config is loaded from (highest priority first) flags, env, the config file and defaults.
+// keys are the lower cased field names: POSTGRES_HOST is read from the env POSTGRES_HOST,
+// the flag --postgres-host or postgres_host in the config file
 type Config struct {
-	POSTGRES_DB       string
-	POSTGRES_HOST     string
-	POSTGRES_PORT     string
-	POSTGRES_USER     string
-	POSTGRES_PASSWORD string
+	// postgres, or memory to run without any outside service. nothing survives a restart of the memory storage
+	STORAGE string `mapstructure:"storage"`
+
+	// the keys marked external are only required by the postgres storage
+	POSTGRES_DB       string `mapstructure:"postgres_db" required:"true" external:"true"`
+	POSTGRES_HOST     string `mapstructure:"postgres_host" required:"true" external:"true"`
+	POSTGRES_PORT     string `mapstructure:"postgres_port" required:"true" external:"true"`
+	POSTGRES_USER     string `mapstructure:"postgres_user" required:"true" external:"true"`
+	POSTGRES_PASSWORD string `mapstructure:"postgres_password" secret:"true"`
+
+	REDIS_HOST string `mapstructure:"redis_host" required:"true" external:"true"`
+	REDIS_PORT string `mapstructure:"redis_port" required:"true" external:"true"`
+
+	WALLET_TRANSACTION_CHANNEL string `mapstructure:"wallet_transaction_channel"`
+
+	ADMIN_TOKEN string `mapstructure:"admin_token" secret:"true"`
+
+	HTTP_PORT    int    `mapstructure:"http_port"`
+	GRPC_PORT    int    `mapstructure:"grpc_port"`    // the grpc api, 0 disables it on this instance
+	ERROR_FORMAT string `mapstructure:"error_format"` // legacy answers every client error with a 400, coded with the status code of its error code
+
+	TOKEN_TTL                  time.Duration `mapstructure:"token_ttl"`
+	WALLET_LOCK_TTL            time.Duration `mapstructure:"wallet_lock_ttl"`
+	WALLET_TRANSACTION_TIMEOUT time.Duration `mapstructure:"wallet_transaction_timeout"`
+
+	// redis_lock, select_for_update, conditional_update or optimistic
+	WALLET_CONCURRENCY_STRATEGY string `mapstructure:"wallet_concurrency_strategy"`
+	// redis or memory, the lock behind the redis_lock strategy
+	WALLET_LOCKER string `mapstructure:"wallet_locker"`
+
+	BATCH_MAX_ITEMS   int `mapstructure:"batch_max_items"`   // items accepted in one uploaded batch
+	BATCH_CONCURRENCY int `mapstructure:"batch_concurrency"` // wallets of a best_effort batch processed at once
+
+	SCHEDULER_INTERVAL time.Duration `mapstructure:"scheduler_interval"` // how often due schedules are fired, 0 disables the scheduler on this instance
+
+	STATEMENT_INTERVAL time.Duration `mapstructure:"statement_interval"` // how often last month's missing statements are generated, 0 disables the job on this instance
+	STATEMENT_TIMEZONE string        `mapstructure:"statement_timezone"` // IANA name the statement periods are cut in
 
-	REDIS_HOST string
-	REDIS_PORT string
+	RECONCILIATION_INTERVAL time.Duration `mapstructure:"reconciliation_interval"` // how often every balance is checked against its history, 0 disables the job on this instance
+	RECONCILIATION_FREEZE   bool          `mapstructure:"reconciliation_freeze"`   // freeze the drifting wallets found by the job
 
-	WALLET_TRANSACTION_CHANNEL string
+	SETTLEMENT_TIMEZONE string `mapstructure:"settlement_timezone"` // IANA name of the bank's zone, a settlement date covers the deposits of that day in it
+
+	LOG_LEVEL string `mapstructure:"log_level"` // debug, info, warn or error, hot reloaded
+
+	MAX_TRANSACTION_AMOUNT int `mapstructure:"max_transaction_amount"` // 0 means unlimited, hot reloaded
+	TRANSACTION_PAGE_SIZE  int `mapstructure:"transaction_page_size"`  // hot reloaded
+
+	TRACING_EXPORTER string `mapstructure:"tracing_exporter"` // none, stdout or otlp
+	OTLP_ENDPOINT    string `mapstructure:"otlp_endpoint"`    // e.g. http://otel-collector:4318, defaults to OTEL_EXPORTER_OTLP_ENDPOINT
+
+	CONFIG_FILE string `mapstructure:"config_file"`
+}
+
+// Limits are the settings which can be changed without restarting the service
+type Limits struct {
+	MAX_TRANSACTION_AMOUNT int
+	TRANSACTION_PAGE_SIZE  int
 }
 
-func GetConfig() Config {
-	return Config{
-		POSTGRES_DB:                os.Getenv("POSTGRES_DB"),
-		POSTGRES_HOST:              os.Getenv("POSTGRES_HOST"),
-		POSTGRES_PORT:              os.Getenv("POSTGRES_PORT"),
-		POSTGRES_USER:              os.Getenv("POSTGRES_USER"),
-		POSTGRES_PASSWORD:          os.Getenv("POSTGRES_PASSWORD"),
-		REDIS_HOST:                 os.Getenv("REDIS_HOST"),
-		REDIS_PORT:                 os.Getenv("REDIS_PORT"),
-		WALLET_TRANSACTION_CHANNEL: os.Getenv("WALLET_TRANSACTION_CHANNEL"),
+var (
+	configDefaults = map[string]interface{}{
+		"storage":                     STORAGE_POSTGRES,
+		"wallet_transaction_channel":  "wallet-transactions",
+		"http_port":                   3000,
+		"grpc_port":                   3001,
+		"error_format":                "legacy",
+		"token_ttl":                   time.Second * 6000,
+		"wallet_lock_ttl":             time.Second * 8,
+		"wallet_transaction_timeout":  time.Second * 5,
+		"wallet_concurrency_strategy": "redis_lock",
+		"wallet_locker":               "redis",
+		"batch_max_items":             10000,
+		"batch_concurrency":           4,
+		"scheduler_interval":          time.Second * 30,
+		"statement_interval":          time.Hour,
+		"statement_timezone":          "UTC",
+		"reconciliation_interval":     time.Hour * 24,
+		"reconciliation_freeze":       false,
+		"settlement_timezone":         "UTC",
+		"log_level":                   "info",
+		"max_transaction_amount":      0,
+		"transaction_page_size":       10,
+		"tracing_exporter":            TRACING_EXPORTER_NONE,
 	}
+
+	storages = []string{STORAGE_POSTGRES, STORAGE_MEMORY}
+	// mirrors the wallet.CONCURRENCY_STRATEGY_* constants
+	concurrencyStrategies = []string{"redis_lock", "select_for_update", "conditional_update", "optimistic"}
+	// mirrors the wallet.WALLET_LOCKER_* constants
+	walletLockers = []string{"redis", "memory"}
+	// mirrors the response.ERROR_FORMAT_* constants
+	errorFormats = []string{"legacy", "coded"}
+
+	currentLimits atomic.Pointer[Limits]
+)
+
+// LoadConfig reads the config and validates it, args are the command line arguments without the program name.
+// the returned viper instance is needed by WatchConfig
+func LoadConfig(args []string) (config Config, configLoader *viper.Viper, err error) {
+	configLoader = viper.New()
+	for key, value := range configDefaults {
+		configLoader.SetDefault(key, value)
+	}
+
+	flags := pflag.NewFlagSet("mini-wallet", pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	for _, key := range configKeys() {
+		flags.String(configFlagName(key), "", fmt.Sprintf("overrides %s", strings.ToUpper(key)))
+	}
+	if err = flags.Parse(args); err != nil {
+		return config, nil, err
+	}
+	// only flags explicitly given may win over env and the config file
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed {
+			configLoader.Set(strings.ReplaceAll(flag.Name, "-", "_"), flag.Value.String())
+		}
+	})
+
+	configLoader.AutomaticEnv()
+	for _, key := range configKeys() {
+		// AutomaticEnv only applies to keys viper already knows about on Unmarshal
+		if err = configLoader.BindEnv(key, strings.ToUpper(key)); err != nil {
+			return config, nil, err
+		}
+	}
+
+	if configFile := configLoader.GetString("config_file"); configFile != "" {
+		configLoader.SetConfigFile(configFile)
+		if err = configLoader.ReadInConfig(); err != nil {
+			return config, nil, fmt.Errorf("config: reading %s: %w", configFile, err)
+		}
+	}
+
+	if config, err = decodeConfig(configLoader); err != nil {
+		return config, nil, err
+	}
+
+	applyHotReloadable(config)
+
+	return config, configLoader, nil
+}
+
+// WatchConfig reloads the log level and the limits whenever the config file changes.
+// other settings (connections, ports, TTLs) are only read on startup
+func WatchConfig(configLoader *viper.Viper) {
+	if configLoader.ConfigFileUsed() == "" {
+		return
+	}
+
+	configLoader.OnConfigChange(func(event fsnotify.Event) {
+		config, err := decodeConfig(configLoader)
+		if err != nil {
+			Logger().Error("config reload rejected, keeping the previous settings", "error", err, "file", event.Name)
+			return
+		}
+
+		applyHotReloadable(config)
+		Logger().Info("config reloaded", "file", event.Name, "log_level", config.LOG_LEVEL,
+			"max_transaction_amount", config.MAX_TRANSACTION_AMOUNT, "transaction_page_size", config.TRANSACTION_PAGE_SIZE)
+	})
+	configLoader.WatchConfig()
+}
+
+// GetLimits returns the latest hot reloadable limits
+func GetLimits() Limits {
+	if limits := currentLimits.Load(); limits != nil {
+		return *limits
+	}
+
+	return Limits{
+		TRANSACTION_PAGE_SIZE: configDefaults["transaction_page_size"].(int),
+	}
+}
+
+// Validate names the first missing or invalid key
+func (config Config) Validate() error {
+	configValue := reflect.ValueOf(config)
+	configType := configValue.Type()
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		if config.STORAGE == STORAGE_MEMORY && field.Tag.Get("external") == "true" {
+			continue
+		}
+		if field.Tag.Get("required") == "true" && configValue.Field(i).IsZero() {
+			key := field.Tag.Get("mapstructure")
+			return fmt.Errorf("config: missing required key %s (env %s, flag --%s)", key, strings.ToUpper(key), configFlagName(key))
+		}
+	}
+
+	if !containsString(storages, config.STORAGE) {
+		return fmt.Errorf("config: storage must be one of %s, got %q", strings.Join(storages, ", "), config.STORAGE)
+	}
+	if config.HTTP_PORT <= 0 || config.HTTP_PORT > 65535 {
+		return fmt.Errorf("config: http_port must be between 1 and 65535, got %d", config.HTTP_PORT)
+	}
+	if config.GRPC_PORT < 0 || config.GRPC_PORT > 65535 {
+		return fmt.Errorf("config: grpc_port must be between 0 and 65535, got %d", config.GRPC_PORT)
+	}
+	if config.GRPC_PORT == config.HTTP_PORT {
+		return fmt.Errorf("config: grpc_port must differ from http_port, both are %d", config.GRPC_PORT)
+	}
+	if !containsString(errorFormats, config.ERROR_FORMAT) {
+		return fmt.Errorf("config: error_format must be one of %s, got %q", strings.Join(errorFormats, ", "), config.ERROR_FORMAT)
+	}
+	if config.TOKEN_TTL < time.Second {
+		return fmt.Errorf("config: token_ttl must be at least 1s, got %s", config.TOKEN_TTL)
+	}
+	if config.WALLET_LOCK_TTL <= 0 {
+		return fmt.Errorf("config: wallet_lock_ttl must be positive, got %s", config.WALLET_LOCK_TTL)
+	}
+	if config.WALLET_TRANSACTION_TIMEOUT <= 0 {
+		return fmt.Errorf("config: wallet_transaction_timeout must be positive, got %s", config.WALLET_TRANSACTION_TIMEOUT)
+	}
+	if !containsString(concurrencyStrategies, config.WALLET_CONCURRENCY_STRATEGY) {
+		return fmt.Errorf("config: wallet_concurrency_strategy must be one of %s, got %q", strings.Join(concurrencyStrategies, ", "), config.WALLET_CONCURRENCY_STRATEGY)
+	}
+	if !containsString(walletLockers, config.WALLET_LOCKER) {
+		return fmt.Errorf("config: wallet_locker must be one of %s, got %q", strings.Join(walletLockers, ", "), config.WALLET_LOCKER)
+	}
+	if config.BATCH_MAX_ITEMS <= 0 {
+		return fmt.Errorf("config: batch_max_items must be positive, got %d", config.BATCH_MAX_ITEMS)
+	}
+	if config.BATCH_CONCURRENCY <= 0 {
+		return fmt.Errorf("config: batch_concurrency must be positive, got %d", config.BATCH_CONCURRENCY)
+	}
+	if config.SCHEDULER_INTERVAL < 0 {
+		return fmt.Errorf("config: scheduler_interval can not be negative, got %s", config.SCHEDULER_INTERVAL)
+	}
+	if config.STATEMENT_INTERVAL < 0 {
+		return fmt.Errorf("config: statement_interval can not be negative, got %s", config.STATEMENT_INTERVAL)
+	}
+	if _, err := time.LoadLocation(config.STATEMENT_TIMEZONE); err != nil || config.STATEMENT_TIMEZONE == "" {
+		return fmt.Errorf("config: statement_timezone must be an IANA time zone, got %q", config.STATEMENT_TIMEZONE)
+	}
+	if config.RECONCILIATION_INTERVAL < 0 {
+		return fmt.Errorf("config: reconciliation_interval can not be negative, got %s", config.RECONCILIATION_INTERVAL)
+	}
+	if _, err := time.LoadLocation(config.SETTLEMENT_TIMEZONE); err != nil || config.SETTLEMENT_TIMEZONE == "" {
+		return fmt.Errorf("config: settlement_timezone must be an IANA time zone, got %q", config.SETTLEMENT_TIMEZONE)
+	}
+	if config.MAX_TRANSACTION_AMOUNT < 0 {
+		return fmt.Errorf("config: max_transaction_amount can not be negative, got %d", config.MAX_TRANSACTION_AMOUNT)
+	}
+	if config.TRANSACTION_PAGE_SIZE <= 0 {
+		return fmt.Errorf("config: transaction_page_size must be positive, got %d", config.TRANSACTION_PAGE_SIZE)
+	}
+
+	return nil
+}
+
+// Redacted is safe to be logged, secret values are replaced
+func (config Config) Redacted() map[string]interface{} {
+	redacted := map[string]interface{}{}
+
+	configValue := reflect.ValueOf(config)
+	configType := configValue.Type()
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		value := configValue.Field(i).Interface()
+		if field.Tag.Get("secret") == "true" && !configValue.Field(i).IsZero() {
+			value = redactedValue
+		}
+		if duration, ok := value.(time.Duration); ok {
+			value = duration.String()
+		}
+
+		redacted[field.Tag.Get("mapstructure")] = value
+	}
+
+	return redacted
+}
+
+// String keeps %v from ever printing a secret
+func (config Config) String() string {
+	return fmt.Sprintf("%v", config.Redacted())
+}
+
+func decodeConfig(configLoader *viper.Viper) (config Config, err error) {
+	if err = configLoader.Unmarshal(&config); err != nil {
+		return config, fmt.Errorf("config: %w", err)
+	}
+
+	if err = config.Validate(); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+func applyHotReloadable(config Config) {
+	if err := SetLogLevel(config.LOG_LEVEL); err != nil {
+		Logger().Warn("invalid log_level, keeping the previous one", "log_level", config.LOG_LEVEL)
+	}
+
+	currentLimits.Store(&Limits{
+		MAX_TRANSACTION_AMOUNT: config.MAX_TRANSACTION_AMOUNT,
+		TRANSACTION_PAGE_SIZE:  config.TRANSACTION_PAGE_SIZE,
+	})
+}
+
+func configKeys() (keys []string) {
+	configType := reflect.TypeOf(Config{})
+	for i := 0; i < configType.NumField(); i++ {
+		keys = append(keys, configType.Field(i).Tag.Get("mapstructure"))
+	}
+
+	return keys
+}
+
+func configFlagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,47 @@
+package infrastructure
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// lock counters are process wide, they are shown on /debug/status
+var (
+	lockAcquired    int64
+	lockFailed      int64
+	lockReleased    int64
+	lockWaitMsTotal int64
+)
+
+type LockCounters struct {
+	Acquired    int64
+	Failed      int64
+	Released    int64
+	WaitMsTotal int64
+}
+
+func RecordLockAcquired(wait time.Duration) {
+	atomic.AddInt64(&lockAcquired, 1)
+	atomic.AddInt64(&lockWaitMsTotal, wait.Milliseconds())
+	WalletLockWaitSeconds.Observe(wait.Seconds())
+}
+
+func RecordLockFailed(wait time.Duration) {
+	atomic.AddInt64(&lockFailed, 1)
+	atomic.AddInt64(&lockWaitMsTotal, wait.Milliseconds())
+	WalletLockWaitSeconds.Observe(wait.Seconds())
+	WalletLockFailuresTotal.Inc()
+}
+
+func RecordLockReleased() {
+	atomic.AddInt64(&lockReleased, 1)
+}
+
+func GetLockCounters() LockCounters {
+	return LockCounters{
+		Acquired:    atomic.LoadInt64(&lockAcquired),
+		Failed:      atomic.LoadInt64(&lockFailed),
+		Released:    atomic.LoadInt64(&lockReleased),
+		WaitMsTotal: atomic.LoadInt64(&lockWaitMsTotal),
+	}
+}
@@ -1,11 +1,146 @@
 package infrastructure
 
-import "log"
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	redactedValue = "[REDACTED]"
+
+	requestIdContextKey = "requestId"
+	walletIdContextKey  = "walletId" // set by AuthorizeRequestMiddleware
+)
 
 var (
-	logger = log.Default()
+	// logLevel can be changed at runtime with SetLogLevel
+	logLevel = new(slog.LevelVar)
+
+	logger = newLogger(os.Stdout)
+
+	// attribute keys ending with one of these words, e.g. pin, admin_token or wallet.lock_token, are never written as is.
+	// a word inside a key, like the pin of shipping, does not count
+	sensitiveKeys = []string{"token", "pin", "password", "secret", "authorization"}
 )
 
-func Log(any string) {
-	logger.Println(any)
+func newLogger(w io.Writer) *slog.Logger {
+	return slog.New(&contextHandler{
+		handler: slog.NewJSONHandler(w, &slog.HandlerOptions{
+			Level:       logLevel,
+			ReplaceAttr: redactAttr,
+		}),
+	})
+}
+
+// SetLogOutput writes the log lines to w from now on, e.g. stderr for a command printing its result on stdout.
+// meant to be called once on startup
+func SetLogOutput(w io.Writer) {
+	logger = newLogger(w)
+}
+
+func Logger() *slog.Logger {
+	return logger
+}
+
+// SetLogLevel accepts debug, info, warn or error
+func SetLogLevel(level string) error {
+	var parsedLevel slog.Level
+	if err := parsedLevel.UnmarshalText([]byte(level)); err != nil {
+		return err
+	}
+
+	logLevel.Set(parsedLevel)
+	return nil
+}
+
+func LogDebug(ctx context.Context, msg string, args ...any) {
+	logger.DebugContext(ctx, msg, args...)
+}
+
+func LogInfo(ctx context.Context, msg string, args ...any) {
+	logger.InfoContext(ctx, msg, args...)
+}
+
+func LogWarn(ctx context.Context, msg string, args ...any) {
+	logger.WarnContext(ctx, msg, args...)
+}
+
+// LogError always carries the error value on the "error" field
+func LogError(ctx context.Context, msg string, err error, args ...any) {
+	logger.ErrorContext(ctx, msg, append([]any{slog.Any("error", err)}, args...)...)
+}
+
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdContextKey, requestId)
+}
+
+// contextHandler enriches every record with request id, wallet id and trace id found on the context
+type contextHandler struct {
+	handler slog.Handler
+}
+
+func (ctxHandler *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return ctxHandler.handler.Enabled(ctx, level)
+}
+
+func (ctxHandler *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		if requestId, ok := ctx.Value(requestIdContextKey).(string); ok && requestId != "" {
+			record.AddAttrs(slog.String("request_id", requestId))
+		}
+
+		if walletId, ok := ctx.Value(walletIdContextKey).(string); ok && walletId != "" {
+			record.AddAttrs(slog.String("wallet_id", walletId))
+		}
+
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			record.AddAttrs(
+				slog.String("trace_id", spanContext.TraceID().String()),
+				slog.String("span_id", spanContext.SpanID().String()),
+			)
+		}
+	}
+
+	return ctxHandler.handler.Handle(ctx, record)
+}
+
+func (ctxHandler *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{handler: ctxHandler.handler.WithAttrs(attrs)}
+}
+
+func (ctxHandler *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{handler: ctxHandler.handler.WithGroup(name)}
+}
+
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if isSensitiveKey(attr.Key) {
+		return slog.String(attr.Key, redactedValue)
+	}
+
+	return attr
+}
+
+func isSensitiveKey(key string) bool {
+	words := strings.FieldsFunc(key, func(r rune) bool {
+		return r == '_' || r == '-' || r == '.'
+	})
+	if len(words) == 0 {
+		return false
+	}
+
+	lastWord := words[len(words)-1]
+	for _, sensitiveKey := range sensitiveKeys {
+		// adminToken ends with the word as well
+		camelCased := strings.ToUpper(sensitiveKey[:1]) + sensitiveKey[1:]
+		if strings.EqualFold(lastWord, sensitiveKey) || strings.HasSuffix(lastWord, camelCased) {
+			return true
+		}
+	}
+
+	return false
 }
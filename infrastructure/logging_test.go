@@ -0,0 +1,41 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestLogRedactsSensitiveKeys(t *testing.T) {
+	defer func(previous *slog.Logger) { logger = previous }(logger)
+
+	for key, redacted := range map[string]bool{
+		"pin":               true,
+		"PIN":               true,
+		"admin_token":       true,
+		"wallet.lock_token": true,
+		"adminToken":        true,
+		"postgres_password": true,
+		"Authorization":     true,
+		"token_ttl":         false,
+		"ping":              false,
+		"mapping":           false,
+		"shipping":          false,
+		"spinner":           false,
+		"tokenizer":         false,
+	} {
+		buffer := &bytes.Buffer{}
+		SetLogOutput(buffer)
+		LogInfo(context.Background(), "logging", key, "value")
+
+		line := map[string]any{}
+		if err := json.Unmarshal(buffer.Bytes(), &line); err != nil {
+			t.Fatalf("decoding %s: %v", buffer.String(), err)
+		}
+		if got := line[key] == redactedValue; got != redacted {
+			t.Errorf("%s = %v, redacted %v, want %v", key, line[key], got, redacted)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package infrastructure
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsNamespace = "mini_wallet"
+
+	METRIC_OUTCOME_SUCCESS = "success"
+	METRIC_OUTCOME_ERROR   = "error"
+)
+
+var (
+	metricsRegistry = newMetricsRegistry()
+	metricsFactory  = promauto.With(metricsRegistry)
+
+	HttpRequestsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	HttpRequestDuration = metricsFactory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	WalletTransactionsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "wallet_transactions_total",
+		Help:      "Deposits and withdrawals by type and outcome.",
+	}, []string{"type", "outcome"})
+
+	WalletTransactionAmount = metricsFactory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "wallet_transaction_amount",
+		Help:      "Amount of successful deposits and withdrawals.",
+		Buckets:   prometheus.ExponentialBuckets(1000, 10, 7),
+	}, []string{"type"})
+
+	WalletReferenceIdConflictsTotal = metricsFactory.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "wallet_reference_id_conflicts_total",
+		Help:      "Transactions rejected because the reference id was already used.",
+	})
+
+	WalletLockWaitSeconds = metricsFactory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "wallet_lock_wait_seconds",
+		Help:      "Time spent waiting for the wallet lock, including failed attempts.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	WalletLockFailuresTotal = metricsFactory.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "wallet_lock_failures_total",
+		Help:      "Wallet lock acquisitions that failed.",
+	})
+
+	BatchItemsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "batch_items_total",
+		Help:      "Processed batch items by batch mode and item status.",
+	}, []string{"mode", "status"})
+
+	ScheduleExecutionsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "schedule_executions_total",
+		Help:      "Fired schedule occurrences by execution status.",
+	}, []string{"status"})
+
+	ReconciliationRunsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconciliation_runs_total",
+		Help:      "Balance reconciliation runs by status.",
+	}, []string{"status"})
+
+	ReconciliationDriftingWallets = metricsFactory.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconciliation_drifting_wallets",
+		Help:      "Wallets whose stored balance differs from their history, as of the last completed reconciliation.",
+	})
+
+	ReconciliationDriftAmount = metricsFactory.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconciliation_drift_amount",
+		Help:      "Sum of the absolute drift of every wallet, as of the last completed reconciliation.",
+	})
+
+	ReconciliationFrozenWalletsTotal = metricsFactory.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconciliation_frozen_wallets_total",
+		Help:      "Wallets frozen by the reconciliation because of a drift.",
+	})
+
+	ReconciliationLastCompletedTimestamp = metricsFactory.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconciliation_last_completed_timestamp_seconds",
+		Help:      "Unix time the last reconciliation completed at.",
+	})
+
+	RedisCallDuration = metricsFactory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "redis_call_duration_seconds",
+		Help:      "Redis call latency by operation and outcome.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "outcome"})
+
+	PostgresCallDuration = metricsFactory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "postgres_call_duration_seconds",
+		Help:      "Postgres call latency by repository operation and outcome.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "outcome"})
+)
+
+func newMetricsRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return registry
+}
+
+// MetricsHandler serves every registered metric in prometheus text format
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+func MetricOutcome(err error) string {
+	if err != nil {
+		return METRIC_OUTCOME_ERROR
+	}
+
+	return METRIC_OUTCOME_SUCCESS
+}
@@ -0,0 +1,133 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mini-wallet/infrastructure/migrations"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pressly/goose/v3"
+	"gorm.io/gorm"
+)
+
+const (
+	MIGRATE_UP     = "up"
+	MIGRATE_DOWN   = "down"
+	MIGRATE_STATUS = "status"
+	MIGRATE_REDO   = "redo"
+
+	migrationsDir = "."
+)
+
+func init() {
+	goose.SetBaseFS(migrations.FS)
+	goose.SetLogger(log.New(os.Stdout, "", 0))
+	if err := goose.SetDialect("postgres"); err != nil {
+		panic(err)
+	}
+}
+
+// Migrate runs one of the goose commands against the embedded migrations
+func Migrate(ctx context.Context, db *gorm.DB, command string) error {
+	sqlDb, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case MIGRATE_UP:
+		return goose.UpContext(ctx, sqlDb, migrationsDir)
+	case MIGRATE_DOWN:
+		return goose.DownContext(ctx, sqlDb, migrationsDir)
+	case MIGRATE_STATUS:
+		return goose.StatusContext(ctx, sqlDb, migrationsDir)
+	case MIGRATE_REDO:
+		return goose.RedoContext(ctx, sqlDb, migrationsDir)
+	}
+
+	return fmt.Errorf("unknown migrate command %q, expected one of up, down, status or redo", command)
+}
+
+// ExpectedSchemaVersion is the version of the newest migration embedded in this binary
+func ExpectedSchemaVersion() (int64, error) {
+	migrations, err := goose.CollectMigrations(migrationsDir, 0, goose.MaxVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	lastMigration, err := migrations.Last()
+	if err != nil {
+		return 0, err
+	}
+
+	return lastMigration.Version, nil
+}
+
+// CurrentSchemaVersion reads the latest applied version the same way goose does,
+// without creating the goose table. 0 means no migration was ever applied
+func CurrentSchemaVersion(ctx context.Context, db *gorm.DB) (version int64, err error) {
+	rows, err := db.WithContext(ctx).Raw("SELECT version_id, is_applied FROM goose_db_version ORDER BY id DESC").Rows()
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == PG_UNDEFINED_TABLE {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	// a rolled back version has a newer row with is_applied = false
+	rolledBack := map[int64]bool{}
+	for rows.Next() {
+		var isApplied bool
+		if err = rows.Scan(&version, &isApplied); err != nil {
+			return 0, err
+		}
+
+		if rolledBack[version] {
+			continue
+		}
+
+		if isApplied {
+			return version, nil
+		}
+
+		rolledBack[version] = true
+	}
+
+	return 0, rows.Err()
+}
+
+// CheckSchemaVersion fails when the database is not on the version this binary was built for
+func CheckSchemaVersion(ctx context.Context, db *gorm.DB) (version int64, err error) {
+	expectedVersion, err := ExpectedSchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	version, err = CurrentSchemaVersion(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	if version != expectedVersion {
+		return version, &SchemaVersionMismatchError{
+			Current:  version,
+			Expected: expectedVersion,
+		}
+	}
+
+	return version, nil
+}
+
+type SchemaVersionMismatchError struct {
+	Current  int64
+	Expected int64
+}
+
+func (mismatch *SchemaVersionMismatchError) Error() string {
+	return fmt.Sprintf("schema version mismatch: database is on %d, this binary expects %d, run `migrate up`", mismatch.Current, mismatch.Expected)
+}
@@ -14,5 +14,5 @@ CREATE TABLE IF NOT EXISTS tr_wallet_transaction (
 
 -- +goose Down
 -- +goose StatementBegin
-DROP TABLE IF EXISTS wallet_transactions;
+DROP TABLE IF EXISTS tr_wallet_transaction;
 -- +goose StatementEnd
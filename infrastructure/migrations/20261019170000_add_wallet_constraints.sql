@@ -0,0 +1,32 @@
+-- +goose Up
+ALTER TABLE ms_wallet
+    ADD CONSTRAINT ms_wallet_balance_non_negative CHECK (balance >= 0),
+    ADD CONSTRAINT ms_wallet_status_check CHECK (status IN ('enabled', 'disabled')),
+    ADD CONSTRAINT ms_wallet_owned_by_key UNIQUE (owned_by);
+
+ALTER TABLE tr_wallet_transaction
+    ADD CONSTRAINT tr_wallet_transaction_wallet_id_fkey FOREIGN KEY (wallet_id) REFERENCES ms_wallet (id),
+    ADD CONSTRAINT tr_wallet_transaction_amount_positive CHECK (amount > 0),
+    ADD CONSTRAINT tr_wallet_transaction_type_check CHECK (type IN ('deposit', 'withdrawal')),
+    ADD CONSTRAINT tr_wallet_transaction_status_check CHECK (status IN ('success'));
+
+-- a reference id can only be used once per wallet
+CREATE UNIQUE INDEX tr_wallet_transaction_wallet_id_reference_id_key ON tr_wallet_transaction (wallet_id, reference_id);
+
+-- transaction history of a wallet
+CREATE INDEX tr_wallet_transaction_wallet_id_created_at_idx ON tr_wallet_transaction (wallet_id, created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS tr_wallet_transaction_wallet_id_created_at_idx;
+DROP INDEX IF EXISTS tr_wallet_transaction_wallet_id_reference_id_key;
+
+ALTER TABLE tr_wallet_transaction
+    DROP CONSTRAINT IF EXISTS tr_wallet_transaction_status_check,
+    DROP CONSTRAINT IF EXISTS tr_wallet_transaction_type_check,
+    DROP CONSTRAINT IF EXISTS tr_wallet_transaction_amount_positive,
+    DROP CONSTRAINT IF EXISTS tr_wallet_transaction_wallet_id_fkey;
+
+ALTER TABLE ms_wallet
+    DROP CONSTRAINT IF EXISTS ms_wallet_owned_by_key,
+    DROP CONSTRAINT IF EXISTS ms_wallet_status_check,
+    DROP CONSTRAINT IF EXISTS ms_wallet_balance_non_negative;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- incremented on every write of the wallet, for optimistic concurrency
+ALTER TABLE ms_wallet ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE ms_wallet DROP COLUMN IF EXISTS version;
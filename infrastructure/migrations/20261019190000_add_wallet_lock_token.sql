@@ -0,0 +1,6 @@
+-- +goose Up
+-- fencing token of the latest lock holder which wrote the wallet, a write with an older token is rejected
+ALTER TABLE ms_wallet ADD COLUMN lock_token BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE ms_wallet DROP COLUMN IF EXISTS lock_token;
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tr_batch (
+    id VARCHAR(36) PRIMARY KEY,
+    mode VARCHAR(15) NOT NULL,
+    status VARCHAR(15) NOT NULL,
+    total_items INTEGER NOT NULL,
+    error TEXT,
+    created_at VARCHAR(30) NOT NULL,
+    finished_at VARCHAR(30),
+    CONSTRAINT tr_batch_mode_check CHECK (mode IN ('all_or_nothing', 'best_effort')),
+    CONSTRAINT tr_batch_status_check CHECK (status IN ('pending', 'processing', 'completed', 'failed'))
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tr_batch_item (
+    batch_id VARCHAR(36) NOT NULL REFERENCES tr_batch (id) ON DELETE CASCADE,
+    line INTEGER NOT NULL,
+    -- kept as uploaded, an invalid item is stored as failed
+    wallet_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    amount INTEGER NOT NULL,
+    reference_id TEXT NOT NULL,
+    status VARCHAR(15) NOT NULL,
+    error TEXT,
+    transaction_id VARCHAR(36),
+    PRIMARY KEY (batch_id, line),
+    CONSTRAINT tr_batch_item_status_check CHECK (status IN ('pending', 'succeeded', 'failed', 'not_applied'))
+);
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE IF EXISTS tr_batch_item;
+DROP TABLE IF EXISTS tr_batch;
@@ -0,0 +1,50 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS ms_schedule (
+    id VARCHAR(36) PRIMARY KEY,
+    wallet_id VARCHAR(36) NOT NULL REFERENCES ms_wallet (id),
+    type VARCHAR(15) NOT NULL,
+    amount INTEGER NOT NULL,
+    rule_type VARCHAR(10) NOT NULL,
+    rule TEXT NOT NULL,
+    timezone TEXT NOT NULL,
+    start_at TIMESTAMPTZ NOT NULL,
+    end_at TIMESTAMPTZ,
+    max_count INTEGER,
+    status VARCHAR(15) NOT NULL,
+    paused_reason TEXT,
+    execution_count INTEGER NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMPTZ,
+    version BIGINT NOT NULL DEFAULT 0,
+    created_at VARCHAR(30) NOT NULL,
+    CONSTRAINT ms_schedule_type_check CHECK (type IN ('deposit', 'withdrawal')),
+    CONSTRAINT ms_schedule_amount_positive CHECK (amount > 0),
+    CONSTRAINT ms_schedule_rule_type_check CHECK (rule_type IN ('cron', 'rrule')),
+    CONSTRAINT ms_schedule_max_count_positive CHECK (max_count > 0),
+    CONSTRAINT ms_schedule_status_check CHECK (status IN ('active', 'paused', 'finished', 'cancelled'))
+);
+-- +goose StatementEnd
+
+-- what the scheduler polls
+CREATE INDEX ms_schedule_status_next_run_at_idx ON ms_schedule (status, next_run_at);
+CREATE INDEX ms_schedule_wallet_id_idx ON ms_schedule (wallet_id);
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tr_schedule_execution (
+    schedule_id VARCHAR(36) NOT NULL REFERENCES ms_schedule (id) ON DELETE CASCADE,
+    scheduled_at TIMESTAMPTZ NOT NULL,
+    reference_id VARCHAR(36) NOT NULL,
+    status VARCHAR(15) NOT NULL,
+    error TEXT,
+    transaction_id VARCHAR(36),
+    executed_at VARCHAR(30) NOT NULL,
+    PRIMARY KEY (schedule_id, scheduled_at),
+    CONSTRAINT tr_schedule_execution_status_check CHECK (status IN ('succeeded', 'failed', 'skipped'))
+);
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE IF EXISTS tr_schedule_execution;
+DROP INDEX IF EXISTS ms_schedule_wallet_id_idx;
+DROP INDEX IF EXISTS ms_schedule_status_next_run_at_idx;
+DROP TABLE IF EXISTS ms_schedule;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- every existing wallet becomes the main pocket of its owner
+ALTER TABLE ms_wallet ADD COLUMN name VARCHAR(50) NOT NULL DEFAULT 'Main';
+
+ALTER TABLE ms_wallet
+    DROP CONSTRAINT ms_wallet_owned_by_key,
+    ADD CONSTRAINT ms_wallet_owned_by_name_key UNIQUE (owned_by, name);
+
+-- +goose Down
+-- fails while a customer still owns more than one pocket
+ALTER TABLE ms_wallet
+    DROP CONSTRAINT IF EXISTS ms_wallet_owned_by_name_key,
+    ADD CONSTRAINT ms_wallet_owned_by_key UNIQUE (owned_by);
+
+ALTER TABLE ms_wallet DROP COLUMN IF EXISTS name;
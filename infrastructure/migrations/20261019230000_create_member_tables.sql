@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS ms_wallet_member (
+    wallet_id VARCHAR(36) NOT NULL REFERENCES ms_wallet (id),
+    member_id VARCHAR(36) NOT NULL, -- customer_xid of the member
+    role VARCHAR(15) NOT NULL,
+    spending_limit INTEGER,
+    approval_threshold INTEGER,
+    status VARCHAR(15) NOT NULL,
+    invited_by VARCHAR(36) NOT NULL,
+    created_at VARCHAR(30) NOT NULL,
+    accepted_at VARCHAR(30),
+    PRIMARY KEY (wallet_id, member_id),
+    -- the owner is the customer owning the wallet, never a member row
+    CONSTRAINT ms_wallet_member_role_check CHECK (role IN ('spender', 'viewer')),
+    CONSTRAINT ms_wallet_member_spending_limit_positive CHECK (spending_limit > 0),
+    CONSTRAINT ms_wallet_member_approval_threshold_positive CHECK (approval_threshold > 0),
+    CONSTRAINT ms_wallet_member_status_check CHECK (status IN ('invited', 'active'))
+);
+-- +goose StatementEnd
+
+-- the wallets shared with a customer
+CREATE INDEX ms_wallet_member_member_id_idx ON ms_wallet_member (member_id);
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tr_spending_approval (
+    id VARCHAR(36) PRIMARY KEY,
+    wallet_id VARCHAR(36) NOT NULL REFERENCES ms_wallet (id),
+    member_id VARCHAR(36) NOT NULL,
+    amount INTEGER NOT NULL,
+    reference_id VARCHAR(36) NOT NULL,
+    status VARCHAR(15) NOT NULL,
+    error TEXT,
+    decided_by VARCHAR(36),
+    decided_at VARCHAR(30),
+    created_at VARCHAR(30) NOT NULL,
+    CONSTRAINT tr_spending_approval_amount_positive CHECK (amount > 0),
+    CONSTRAINT tr_spending_approval_status_check CHECK (status IN ('pending', 'approved', 'rejected', 'failed'))
+);
+-- +goose StatementEnd
+
+-- the withdrawal is made with the reference id once approved, it can only be requested once
+CREATE UNIQUE INDEX tr_spending_approval_wallet_id_reference_id_key ON tr_spending_approval (wallet_id, reference_id);
+
+-- spending of a member over the last day
+CREATE INDEX tr_wallet_transaction_wallet_id_created_by_idx ON tr_wallet_transaction (wallet_id, created_by);
+
+-- +goose Down
+DROP INDEX IF EXISTS tr_wallet_transaction_wallet_id_created_by_idx;
+DROP INDEX IF EXISTS tr_spending_approval_wallet_id_reference_id_key;
+DROP TABLE IF EXISTS tr_spending_approval;
+DROP INDEX IF EXISTS ms_wallet_member_member_id_idx;
+DROP TABLE IF EXISTS ms_wallet_member;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tr_statement (
+    wallet_id VARCHAR(36) NOT NULL REFERENCES ms_wallet (id),
+    period_from VARCHAR(10) NOT NULL,
+    period_to VARCHAR(10) NOT NULL,
+    format VARCHAR(4) NOT NULL,
+    content BYTEA NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    created_at VARCHAR(30) NOT NULL,
+    PRIMARY KEY (wallet_id, period_from, period_to, format),
+    CONSTRAINT tr_statement_format_check CHECK (format IN ('json', 'csv', 'pdf')),
+    CONSTRAINT tr_statement_period_check CHECK (period_from <= period_to)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE IF EXISTS tr_statement;
@@ -0,0 +1,56 @@
+-- +goose Up
+-- frozen by the reconciliation, only an admin can lift it
+ALTER TABLE ms_wallet
+    DROP CONSTRAINT IF EXISTS ms_wallet_status_check,
+    ADD CONSTRAINT ms_wallet_status_check CHECK (status IN ('enabled', 'disabled', 'frozen'));
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tr_reconciliation_run (
+    id VARCHAR(36) PRIMARY KEY,
+    trigger VARCHAR(15) NOT NULL,
+    wallet_id VARCHAR(36),
+    freeze_wallets BOOLEAN NOT NULL,
+    repair_dry_run BOOLEAN NOT NULL,
+    status VARCHAR(15) NOT NULL,
+    wallets_checked INTEGER NOT NULL DEFAULT 0,
+    mismatches INTEGER NOT NULL DEFAULT 0,
+    frozen_wallets INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at VARCHAR(30) NOT NULL,
+    finished_at VARCHAR(30),
+    CONSTRAINT tr_reconciliation_run_trigger_check CHECK (trigger IN ('job', 'command')),
+    CONSTRAINT tr_reconciliation_run_status_check CHECK (status IN ('running', 'completed', 'failed'))
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tr_reconciliation_mismatch (
+    run_id VARCHAR(36) NOT NULL REFERENCES tr_reconciliation_run (id) ON DELETE CASCADE,
+    wallet_id VARCHAR(36) NOT NULL REFERENCES ms_wallet (id),
+    owned_by VARCHAR(36) NOT NULL,
+    wallet_status VARCHAR(50) NOT NULL,
+    stored_balance INTEGER NOT NULL,
+    computed_balance INTEGER NOT NULL,
+    drift INTEGER NOT NULL,
+    transaction_count INTEGER NOT NULL,
+    last_transaction_at TIMESTAMPTZ,
+    frozen BOOLEAN NOT NULL,
+    adjustment_type VARCHAR(15),
+    adjustment_amount INTEGER,
+    adjustment_reference_id VARCHAR(36),
+    PRIMARY KEY (run_id, wallet_id)
+);
+-- +goose StatementEnd
+
+-- the drift history of a wallet
+CREATE INDEX tr_reconciliation_mismatch_wallet_id_idx ON tr_reconciliation_mismatch (wallet_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS tr_reconciliation_mismatch_wallet_id_idx;
+DROP TABLE IF EXISTS tr_reconciliation_mismatch;
+DROP TABLE IF EXISTS tr_reconciliation_run;
+
+UPDATE ms_wallet SET status = 'disabled', enabled_at = NULL WHERE status = 'frozen';
+ALTER TABLE ms_wallet
+    DROP CONSTRAINT IF EXISTS ms_wallet_status_check,
+    ADD CONSTRAINT ms_wallet_status_check CHECK (status IN ('enabled', 'disabled'));
@@ -0,0 +1,65 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tr_settlement (
+    id VARCHAR(36) PRIMARY KEY,
+    file_name TEXT NOT NULL,
+    format VARCHAR(15) NOT NULL,
+    settlement_date VARCHAR(10) NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    total_lines INTEGER NOT NULL,
+    matched INTEGER NOT NULL,
+    missing_internally INTEGER NOT NULL,
+    missing_externally INTEGER NOT NULL,
+    amount_mismatch INTEGER NOT NULL,
+    created_at VARCHAR(30) NOT NULL,
+    CONSTRAINT tr_settlement_checksum_key UNIQUE (checksum),
+    CONSTRAINT tr_settlement_format_check CHECK (format IN ('csv', 'fixed_width'))
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tr_settlement_result (
+    id VARCHAR(36) PRIMARY KEY,
+    settlement_id VARCHAR(36) NOT NULL REFERENCES tr_settlement (id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL,
+    line INTEGER,
+    -- kept as found in the file
+    reference_id TEXT NOT NULL,
+    external_amount INTEGER,
+    internal_amount INTEGER,
+    transaction_id VARCHAR(36) REFERENCES tr_wallet_transaction (id),
+    wallet_id VARCHAR(36),
+    exception_status VARCHAR(10),
+    resolution_note TEXT,
+    resolved_at VARCHAR(30),
+    CONSTRAINT tr_settlement_result_status_check CHECK (status IN ('matched', 'missing_internally', 'missing_externally', 'amount_mismatch')),
+    CONSTRAINT tr_settlement_result_exception_status_check CHECK (
+        (status = 'matched' AND exception_status IS NULL) OR exception_status IN ('open', 'resolved')
+    )
+);
+-- +goose StatementEnd
+
+CREATE INDEX tr_settlement_result_settlement_id_idx ON tr_settlement_result (settlement_id, line);
+
+-- the exceptions queue
+CREATE INDEX tr_settlement_result_exception_status_idx ON tr_settlement_result (exception_status, id) WHERE exception_status IS NOT NULL;
+
+-- a deposit is settled by one line only, two files ingested at once can not both claim it
+CREATE UNIQUE INDEX tr_settlement_result_settled_transaction_id_key ON tr_settlement_result (transaction_id)
+    WHERE status IN ('matched', 'amount_mismatch');
+
+-- deposits missing from the files
+CREATE INDEX tr_settlement_result_missing_transaction_id_idx ON tr_settlement_result (transaction_id)
+    WHERE status = 'missing_externally';
+
+-- the bank knows the reference id only, not the wallet
+CREATE INDEX tr_wallet_transaction_deposit_reference_id_idx ON tr_wallet_transaction (reference_id) WHERE type = 'deposit';
+
+-- +goose Down
+DROP INDEX IF EXISTS tr_wallet_transaction_deposit_reference_id_idx;
+DROP INDEX IF EXISTS tr_settlement_result_missing_transaction_id_idx;
+DROP INDEX IF EXISTS tr_settlement_result_settled_transaction_id_key;
+DROP INDEX IF EXISTS tr_settlement_result_exception_status_idx;
+DROP INDEX IF EXISTS tr_settlement_result_settlement_id_idx;
+DROP TABLE IF EXISTS tr_settlement_result;
+DROP TABLE IF EXISTS tr_settlement;
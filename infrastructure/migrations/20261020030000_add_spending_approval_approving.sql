@@ -0,0 +1,17 @@
+-- +goose Up
+-- approving: claimed by the owner, the withdrawal is being made. a concurrent rejection can no longer win
+ALTER TABLE tr_spending_approval
+    DROP CONSTRAINT IF EXISTS tr_spending_approval_status_check,
+    ADD CONSTRAINT tr_spending_approval_status_check CHECK (status IN ('pending', 'approving', 'approved', 'rejected', 'failed'));
+
+-- amounts still waiting for the owner count against the spending limit of their member
+CREATE INDEX tr_spending_approval_wallet_id_member_id_status_idx ON tr_spending_approval (wallet_id, member_id, status);
+
+-- +goose Down
+DROP INDEX IF EXISTS tr_spending_approval_wallet_id_member_id_status_idx;
+
+UPDATE tr_spending_approval SET status = 'pending', decided_by = NULL, decided_at = NULL WHERE status = 'approving';
+
+ALTER TABLE tr_spending_approval
+    DROP CONSTRAINT IF EXISTS tr_spending_approval_status_check,
+    ADD CONSTRAINT tr_spending_approval_status_check CHECK (status IN ('pending', 'approved', 'rejected', 'failed'));
@@ -0,0 +1,38 @@
+-- +goose Up
+-- created_at is RFC 3339 text, the check makes sure every value carries its offset (Z or +hh:mm).
+-- a value without one would be read in the TimeZone of the session, only a value with one reads the same everywhere
+ALTER TABLE tr_wallet_transaction ADD CONSTRAINT tr_wallet_transaction_created_at_offset_check
+    CHECK (created_at ~ '^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$');
+
+-- the bare cast is only stable since it may read the session TimeZone and can not be indexed. under the check above
+-- it never does, which is what makes declaring the function IMMUTABLE safe. the queries compare and order on it instead
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION created_at_timestamptz(created_at VARCHAR) RETURNS TIMESTAMPTZ
+    LANGUAGE SQL IMMUTABLE STRICT PARALLEL SAFE
+    AS $$ SELECT created_at::timestamptz $$;
+-- +goose StatementEnd
+
+-- transaction history, exports and statements of a wallet, the id settles the same second
+CREATE INDEX tr_wallet_transaction_wallet_id_created_at_ts_idx ON tr_wallet_transaction (wallet_id, created_at_timestamptz(created_at), id);
+
+-- spending of a member within the limit window
+CREATE INDEX tr_wallet_transaction_wallet_id_created_by_created_at_idx ON tr_wallet_transaction (wallet_id, created_by, created_at_timestamptz(created_at));
+
+-- deposits of a settlement date, across every wallet
+CREATE INDEX tr_wallet_transaction_deposit_created_at_idx ON tr_wallet_transaction (created_at_timestamptz(created_at), id) WHERE type = 'deposit';
+
+-- superseded by the indexes above
+DROP INDEX IF EXISTS tr_wallet_transaction_wallet_id_created_at_idx;
+DROP INDEX IF EXISTS tr_wallet_transaction_wallet_id_created_by_idx;
+
+-- +goose Down
+CREATE INDEX IF NOT EXISTS tr_wallet_transaction_wallet_id_created_by_idx ON tr_wallet_transaction (wallet_id, created_by);
+CREATE INDEX IF NOT EXISTS tr_wallet_transaction_wallet_id_created_at_idx ON tr_wallet_transaction (wallet_id, created_at);
+
+DROP INDEX IF EXISTS tr_wallet_transaction_deposit_created_at_idx;
+DROP INDEX IF EXISTS tr_wallet_transaction_wallet_id_created_by_created_at_idx;
+DROP INDEX IF EXISTS tr_wallet_transaction_wallet_id_created_at_ts_idx;
+
+DROP FUNCTION IF EXISTS created_at_timestamptz(VARCHAR);
+
+ALTER TABLE tr_wallet_transaction DROP CONSTRAINT IF EXISTS tr_wallet_transaction_created_at_offset_check;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- why an operator adjusted the balance by hand, null for every other transaction
+ALTER TABLE tr_wallet_transaction ADD COLUMN IF NOT EXISTS reason VARCHAR(255);
+
+-- +goose Down
+ALTER TABLE tr_wallet_transaction DROP COLUMN IF EXISTS reason;
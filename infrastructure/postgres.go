@@ -2,7 +2,7 @@ package infrastructure
 
 import (
 	"fmt"
-	"log"
+	"strings"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -10,12 +10,32 @@ import (
 	_ "github.com/lib/pq"
 )
 
-func NewPostgresConn(config Config) *gorm.DB {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Asia/Jakarta", config.POSTGRES_HOST, config.POSTGRES_USER, config.POSTGRES_PASSWORD, config.POSTGRES_DB, config.POSTGRES_PORT)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// NewPostgresConn does not ping the database, an unreachable postgres
+// must not crash the service. it is reported by /readyz instead.
+func NewPostgresConn(config Config) (*gorm.DB, error) {
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Asia/Jakarta",
+		quoteDsnValue(config.POSTGRES_HOST),
+		quoteDsnValue(config.POSTGRES_USER),
+		quoteDsnValue(config.POSTGRES_PASSWORD),
+		quoteDsnValue(config.POSTGRES_DB),
+		quoteDsnValue(config.POSTGRES_PORT),
+	)
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               postgresLogger{},
+	})
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return db
+	if err = registerTracingCallbacks(db); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// quoteDsnValue keeps an empty or spaced value (e.g. an empty password) from swallowing the next key
+func quoteDsnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
 }
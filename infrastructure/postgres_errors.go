@@ -0,0 +1,44 @@
+package infrastructure
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+)
+
+// postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	PG_UNIQUE_VIOLATION      = "23505"
+	PG_FOREIGN_KEY_VIOLATION = "23503"
+	PG_CHECK_VIOLATION       = "23514"
+	PG_UNDEFINED_TABLE       = "42P01"
+)
+
+// ConstraintViolation is the driver independent part of a constraint error
+type ConstraintViolation struct {
+	Code       string
+	Constraint string
+}
+
+// AsConstraintViolation unwraps a pgx (used by gorm) or lib/pq error,
+// ok is false when err is not a postgres error
+func AsConstraintViolation(err error) (violation ConstraintViolation, ok bool) {
+	var pgxErr *pgconn.PgError
+	if errors.As(err, &pgxErr) {
+		return ConstraintViolation{
+			Code:       pgxErr.Code,
+			Constraint: pgxErr.ConstraintName,
+		}, true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return ConstraintViolation{
+			Code:       string(pqErr.Code),
+			Constraint: pqErr.Constraint,
+		}, true
+	}
+
+	return violation, false
+}
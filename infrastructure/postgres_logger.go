@@ -0,0 +1,52 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+const (
+	slowQueryThreshold = time.Millisecond * 200
+)
+
+// postgresLogger sends gorm logs to the structured logger,
+// statements are only logged on debug level, when they fail or when they are slow
+type postgresLogger struct{}
+
+func (postgresLogger postgresLogger) LogMode(gormLogger.LogLevel) gormLogger.Interface {
+	return postgresLogger
+}
+
+func (postgresLogger postgresLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	LogInfo(ctx, fmt.Sprintf(msg, data...))
+}
+
+func (postgresLogger postgresLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	LogWarn(ctx, fmt.Sprintf(msg, data...))
+}
+
+func (postgresLogger postgresLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	logger.ErrorContext(ctx, fmt.Sprintf(msg, data...))
+}
+
+func (postgresLogger postgresLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	elapsed := time.Since(begin)
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		LogError(ctx, "postgres statement failed", err, slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("elapsed", elapsed))
+	case elapsed > slowQueryThreshold:
+		sql, rows := fc()
+		LogWarn(ctx, "slow postgres statement", slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("elapsed", elapsed))
+	case logger.Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		LogDebug(ctx, "postgres statement", slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("elapsed", elapsed))
+	}
+}
@@ -0,0 +1,81 @@
+package infrastructure
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	gormSpanKey = "otel:span"
+)
+
+// registerTracingCallbacks opens a span around every statement gorm runs,
+// the executed sql is attached as db.statement
+func registerTracingCallbacks(db *gorm.DB) (err error) {
+	callbacks := db.Callback()
+
+	registrations := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", callbacks.Create().Before("gorm:create").Register, callbacks.Create().After("gorm:create").Register},
+		{"query", callbacks.Query().Before("gorm:query").Register, callbacks.Query().After("gorm:query").Register},
+		{"update", callbacks.Update().Before("gorm:update").Register, callbacks.Update().After("gorm:update").Register},
+		{"delete", callbacks.Delete().Before("gorm:delete").Register, callbacks.Delete().After("gorm:delete").Register},
+		{"row", callbacks.Row().Before("gorm:row").Register, callbacks.Row().After("gorm:row").Register},
+		{"raw", callbacks.Raw().Before("gorm:raw").Register, callbacks.Raw().After("gorm:raw").Register},
+	}
+
+	for _, registration := range registrations {
+		if err = registration.before("otel:before_"+registration.operation, startStatementSpan(registration.operation)); err != nil {
+			return err
+		}
+		if err = registration.after("otel:after_"+registration.operation, endStatementSpan); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func startStatementSpan(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx, span := Tracer().Start(db.Statement.Context, "postgres "+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", "postgresql"),
+				attribute.String("db.operation", operation),
+			),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func endStatementSpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("db.statement", db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+	if db.Statement.Table != "" {
+		span.SetAttributes(attribute.String("db.sql.table", db.Statement.Table))
+	}
+
+	err := db.Error
+	if err == gorm.ErrRecordNotFound {
+		err = nil
+	}
+	EndSpan(span, &err)
+}
@@ -19,11 +19,25 @@ func NewRedisClient(ctx context.Context, config Config) redis.Client {
 	return *rdb
 }
 
+func PingRedis(ctx context.Context, client redis.Client) error {
+	return client.WithContext(ctx).Ping().Err()
+}
+
 type Cache interface {
 	SetString(ctx context.Context, key string, obj string, ttlInSec int) (err error)
 	GetString(ctx context.Context, key string) (result string, err error)
 	Del(ctx context.Context, key string) (err error)
 	Publish(ctx context.Context, channel string, payload interface{}) (err error)
+	// Subscribe receives every event published on channel from the time it returns,
+	// the events are closed once ctx is done
+	Subscribe(ctx context.Context, channel string) (events <-chan Event, err error)
+}
+
+// Event is what goes through redis pub/sub, headers carry the W3C trace context
+// of the publisher so subscribers can continue the same trace
+type Event struct {
+	Headers map[string]string `json:"headers,omitempty"`
+	Payload json.RawMessage   `json:"payload"`
 }
 
 type redisCache struct {
@@ -37,7 +51,15 @@ func NewCache(redisClient redis.Client) Cache {
 }
 
 func (cache *redisCache) Publish(ctx context.Context, channel string, payload interface{}) (err error) {
-	payloadInString, err := json.Marshal(payload)
+	payloadInBytes, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	payloadInString, err := json.Marshal(Event{
+		Headers: InjectTraceContext(ctx),
+		Payload: payloadInBytes,
+	})
 	if err != nil {
 		return
 	}
@@ -46,6 +68,48 @@ func (cache *redisCache) Publish(ctx context.Context, channel string, payload in
 	return err
 }
 
+func (cache *redisCache) Subscribe(ctx context.Context, channel string) (events <-chan Event, err error) {
+	subscriber := cache.client.Subscribe(channel)
+
+	// wait for the subscription, an event published after Subscribe returns is not missed
+	if _, err = subscriber.Receive(); err != nil {
+		subscriber.Close()
+		return nil, err
+	}
+
+	received := make(chan Event)
+	go func() {
+		defer close(received)
+		defer subscriber.Close()
+
+		messages := subscriber.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				event := Event{}
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					LogError(ctx, "got error on json.Unmarshal() event - Subscribe", err, "channel", channel)
+					continue
+				}
+
+				select {
+				case received <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return received, nil
+}
+
 func (cache *redisCache) SetString(ctx context.Context, key string, obj string, ttlInSec int) (err error) {
 	return cache.client.Set(key, obj, time.Second*time.Duration(ttlInSec)).Err()
 }
@@ -60,6 +124,5 @@ func (cache *redisCache) GetString(ctx context.Context, key string) (result stri
 }
 
 func (cache *redisCache) Del(ctx context.Context, key string) (err error) {
-	// TODO: not used
-	return
+	return cache.client.Del(key).Err()
 }
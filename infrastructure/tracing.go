@@ -0,0 +1,97 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TRACING_EXPORTER_NONE   = "none"
+	TRACING_EXPORTER_STDOUT = "stdout" // prints to stderr, stdout is kept for the logs
+	TRACING_EXPORTER_OTLP   = "otlp"
+
+	tracerName  = "mini-wallet"
+	serviceName = "mini-wallet"
+)
+
+// InitTracing installs the global tracer provider and the W3C trace context propagator.
+// the propagator is installed even when tracing is disabled so incoming trace ids are still forwarded
+func InitTracing(ctx context.Context, config Config) (shutdown func(ctx context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch config.TRACING_EXPORTER {
+	case "", TRACING_EXPORTER_NONE:
+		return func(ctx context.Context) error { return nil }, nil
+	case TRACING_EXPORTER_STDOUT:
+		// stdout carries the json log lines, spans interleaved with them would break whatever parses the logs
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+	case TRACING_EXPORTER_OTLP:
+		options := []otlptracehttp.Option{}
+		if config.OTLP_ENDPOINT != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(config.OTLP_ENDPOINT))
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", config.TRACING_EXPORTER)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(Version),
+		)),
+	)
+	otel.SetTracerProvider(tracerProvider)
+
+	return tracerProvider.Shutdown, nil
+}
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// StartSpan is a shorthand of Tracer().Start
+func StartSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// EndSpan marks the span as failed when err is not nil, meant to be deferred
+func EndSpan(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+
+	span.End()
+}
+
+// InjectTraceContext writes the trace context of ctx into a plain map, e.g. to be published along an event
+func InjectTraceContext(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	return carrier
+}
+
+// ExtractTraceContext is the counterpart of InjectTraceContext
+func ExtractTraceContext(ctx context.Context, headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}
@@ -1,14 +1,63 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"mini-wallet/infrastructure"
 	"mini-wallet/presentation"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
-	router := presentation.InitServer()
+	// anything but a flag as first argument is a subcommand, e.g. `mini-wallet migrate up`
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if err := presentation.RunCommand(context.Background(), os.Args[1:]); err != nil {
+			infrastructure.LogError(context.Background(), "got error on presentation.RunCommand() - main", err, "command", os.Args[1])
+			os.Exit(1)
+		}
+		return
+	}
 
-	http.ListenAndServe(":3000", router)
-	fmt.Println("server listening on port 3000")
+	config, configLoader, err := infrastructure.LoadConfig(os.Args[1:])
+	if err != nil {
+		infrastructure.LogError(context.Background(), "got error on infrastructure.LoadConfig() - main", err)
+		os.Exit(1)
+	}
+	infrastructure.WatchConfig(configLoader)
+
+	router, err := presentation.InitServer(config)
+	if err != nil {
+		infrastructure.LogError(context.Background(), "got error on presentation.InitServer() - main", err)
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", config.HTTP_PORT),
+		Handler: router,
+	}
+
+	go func() {
+		infrastructure.LogInfo(context.Background(), "server listening", "port", config.HTTP_PORT)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			infrastructure.LogError(context.Background(), "got error on server.ListenAndServe() - main", err)
+			os.Exit(1)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		infrastructure.LogError(shutdownCtx, "got error on server.Shutdown() - main", err)
+	}
+	presentation.StopServer(shutdownCtx)
 }
@@ -0,0 +1,166 @@
+package presentation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mini-wallet/app/reconciliation"
+	"mini-wallet/app/wallet"
+	"mini-wallet/domain"
+	reconciliationDomain "mini-wallet/domain/reconciliation"
+	"mini-wallet/infrastructure"
+	"os"
+	"strconv"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	commandUsage = `usage: mini-wallet [command]
+
+without a command the http and grpc servers are started. the commands are:
+  migrate up|down|status|redo       applies the schema migrations
+  reconcile                         checks every balance against its history
+  wallet show <wallet-id>           prints a wallet
+  wallet freeze <wallet-id>         refuses every transaction until it is unfrozen
+  wallet adjust <wallet-id>         corrects a balance with --amount and --reason
+  tx list --wallet <wallet-id>      lists the transactions of a wallet, newest first
+  tx show <transaction-id>          prints a transaction
+  token revoke <token>              ends a token before its TTL
+  loadtest --target <url>           runs virtual customers against a service and checks their balances
+
+every command but loadtest reads the config of the server, e.g. --postgres-host, and prints a table or --output json`
+)
+
+// RunCommand runs a one-off subcommand instead of the http server, e.g. `mini-wallet migrate up`.
+// the log goes to stderr, the result of the command to stdout
+func RunCommand(ctx context.Context, args []string) error {
+	infrastructure.SetLogOutput(os.Stderr)
+
+	switch args[0] {
+	case "migrate":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: mini-wallet migrate up|down|status|redo [flags]")
+		}
+
+		return runMigrate(ctx, args[1], args[2:])
+	case "reconcile":
+		return runReconcile(ctx, args[1:])
+	case "wallet":
+		return runWallet(ctx, args[1:])
+	case "tx":
+		return runTx(ctx, args[1:])
+	case "token":
+		return runToken(ctx, args[1:])
+	case "loadtest":
+		return runLoadtest(ctx, args[1:])
+	case "help":
+		fmt.Println(commandUsage)
+		return nil
+	}
+
+	return fmt.Errorf("unknown command %q\n%s", args[0], commandUsage)
+}
+
+func runMigrate(ctx context.Context, command string, args []string) error {
+	config, _, err := infrastructure.LoadConfig(args)
+	if err != nil {
+		return err
+	}
+
+	postgresDb, err := infrastructure.NewPostgresConn(config)
+	if err != nil {
+		return err
+	}
+
+	return infrastructure.Migrate(ctx, postgresDb, command)
+}
+
+// runReconcile prints the report to stdout and fails when a wallet is drifting, e.g.
+// `mini-wallet reconcile --freeze --repair-dry-run` or `mini-wallet reconcile --wallet-id <id>`
+func runReconcile(ctx context.Context, args []string) error {
+	options := reconciliationDomain.ReconciliationOptions{
+		Trigger: reconciliationDomain.RECONCILIATION_TRIGGER_COMMAND,
+	}
+
+	flags := pflag.NewFlagSet("reconcile", pflag.ContinueOnError)
+	// the config flags are left to LoadConfig
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	output := outputFlag(flags, OUTPUT_TABLE)
+	flags.BoolVar(&options.FreezeWallets, "freeze", false, "freeze the drifting wallets")
+	flags.BoolVar(&options.RepairDryRun, "repair-dry-run", false, "propose the adjustment correcting every drift, nothing is applied")
+	flags.StringVar(&options.WalletId, "wallet-id", "", "only reconcile this wallet")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+
+	config, _, err := infrastructure.LoadConfig(args)
+	if err != nil {
+		return err
+	}
+
+	postgresDb, err := infrastructure.NewPostgresConn(config)
+	if err != nil {
+		return err
+	}
+
+	// the cache only serves the wallet locks, the reconciliation never takes one
+	repositories := domain.Repositories{
+		WalletRepository:         wallet.NewWalletRepository(postgresDb, nil),
+		ReconciliationRepository: reconciliation.NewReconciliationRepository(postgresDb),
+	}
+
+	result, err := reconciliation.NewReconciliationUsecase(repositories, config).Reconcile(ctx, options)
+	if err != nil {
+		return err
+	}
+
+	report := result.Data
+	if err := writeReconciliationReport(os.Stdout, *output, *report); err != nil {
+		return err
+	}
+
+	if report.Run.Status != reconciliationDomain.RECONCILIATION_STATUS_COMPLETED {
+		return fmt.Errorf("reconciliation %s %s", report.Run.Id, report.Run.Status)
+	}
+	if report.Run.Mismatches > 0 {
+		return fmt.Errorf("reconciliation %s found %d drifting wallets", report.Run.Id, report.Run.Mismatches)
+	}
+
+	return nil
+}
+
+// writeReconciliationReport prints the run as a table, followed by a second table of its drifting wallets when any,
+// or the whole report as json
+func writeReconciliationReport(w io.Writer, output string, report reconciliationDomain.ReconciliationReport) error {
+	run := report.Run
+	err := writeOutput(w, output, report, table{
+		header: []string{"RUN_ID", "STATUS", "WALLETS_CHECKED", "MISMATCHES", "FROZEN_WALLETS", "ERROR"},
+		rows: [][]string{{
+			run.Id, run.Status, strconv.Itoa(run.WalletsChecked), strconv.Itoa(run.Mismatches), strconv.Itoa(run.FrozenWallets), orDash(run.Error),
+		}},
+	})
+	if err != nil || output == OUTPUT_JSON || len(report.Mismatches) == 0 {
+		return err
+	}
+
+	mismatchTable := table{
+		header: []string{"WALLET_ID", "OWNED_BY", "STORED_BALANCE", "COMPUTED_BALANCE", "DRIFT", "FROZEN", "ADJUSTMENT_TYPE", "ADJUSTMENT_AMOUNT"},
+	}
+	for _, mismatch := range report.Mismatches {
+		adjustmentAmount := "-"
+		if mismatch.AdjustmentAmount != nil {
+			adjustmentAmount = strconv.Itoa(*mismatch.AdjustmentAmount)
+		}
+		mismatchTable.rows = append(mismatchTable.rows, []string{
+			mismatch.WalletId, mismatch.OwnedBy, strconv.Itoa(mismatch.StoredBalance), strconv.Itoa(mismatch.ComputedBalance),
+			strconv.Itoa(mismatch.Drift), strconv.FormatBool(mismatch.Frozen), orDash(mismatch.AdjustmentType), adjustmentAmount,
+		})
+	}
+
+	fmt.Fprintln(w)
+	return writeOutput(w, output, report, mismatchTable)
+}
@@ -0,0 +1,565 @@
+package presentation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"mini-wallet/client"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/pflag"
+)
+
+const (
+	LOADTEST_DEPOSIT    = "deposit"
+	LOADTEST_WITHDRAWAL = "withdrawal"
+	LOADTEST_BALANCE    = "balance"
+	LOADTEST_DUPLICATE  = "duplicate" // replays a deposit or withdrawal the wallet already accepted, it must be refused
+
+	LOADTEST_RESULT_OK       = "ok"
+	LOADTEST_RESULT_REJECTED = "rejected" // refused as it should be, e.g. a withdrawal above the balance
+	LOADTEST_RESULT_FAILED   = "failed"
+
+	loadtestTransportError = "transport" // no answer, the operation may or may not have been applied
+)
+
+var (
+	loadtestOperations  = []string{LOADTEST_DEPOSIT, LOADTEST_WITHDRAWAL, LOADTEST_BALANCE, LOADTEST_DUPLICATE}
+	loadtestPercentiles = []float64{50, 90, 99}
+)
+
+type loadtestOptions struct {
+	Target         string
+	Customers      int
+	Workers        int // concurrent requests of one customer, all on their main pocket
+	Operations     int // per customer
+	Duration       time.Duration
+	Mix            map[string]int // weight of each operation
+	MaxAmount      int
+	InitialBalance int
+	Timeout        time.Duration
+}
+
+// loadtestCustomer is one virtual customer and what the service told them, to be checked against its own books at the end
+type loadtestCustomer struct {
+	client   *client.Client
+	walletId string
+
+	mu       sync.Mutex
+	accepted map[string]int // times each reference id was accepted
+	history  []loadtestTransaction
+	balance  int                            // the sum of the accepted deposits and withdrawals
+	unknown  map[string]loadtestTransaction // by reference id, sent without an answer
+}
+
+type loadtestTransaction struct {
+	Type        string `json:"type"`
+	Amount      int    `json:"amount"`
+	ReferenceId string `json:"reference_id"`
+}
+
+type loadtestReport struct {
+	Customers         int                       `json:"customers"`
+	Requests          int                       `json:"requests"`
+	ElapsedMs         float64                   `json:"elapsed_ms"`
+	RequestsPerSecond float64                   `json:"requests_per_second"`
+	Operations        []loadtestOperationReport `json:"operations"`
+	Violations        []string                  `json:"violations"`
+}
+
+type loadtestOperationReport struct {
+	Operation string             `json:"operation"`
+	Requests  int                `json:"requests"`
+	Ok        int                `json:"ok"`
+	Rejected  int                `json:"rejected"`
+	Failed    int                `json:"failed"`
+	ErrorRate float64            `json:"error_rate"` // failed out of requests
+	Errors    map[string]int     `json:"errors"`     // failed by error code
+	LatencyMs map[string]float64 `json:"latency_ms"` // p50, p90, p99 and max
+}
+
+// loadtestStats collects the latency and result of every request
+type loadtestStats struct {
+	mu         sync.Mutex
+	operations map[string]*loadtestOperationStats
+}
+
+type loadtestOperationStats struct {
+	latencies []time.Duration
+	results   map[string]int
+	errors    map[string]int
+}
+
+// runLoadtest runs virtual customers against a running service and checks their balances afterwards, e.g.
+// `mini-wallet loadtest --target http://localhost:3000 --customers 20 --workers 4 --mix deposit=4,withdrawal=4,balance=1,duplicate=1`
+func runLoadtest(ctx context.Context, args []string) error {
+	options := loadtestOptions{}
+
+	// nothing but the target is read, so a misspelt flag is an error rather than a config key
+	flags := pflag.NewFlagSet("loadtest", pflag.ContinueOnError)
+	flags.StringVar(&options.Target, "target", "http://localhost:3000", "base url of the service")
+	flags.IntVar(&options.Customers, "customers", 10, "virtual customers, each on a wallet of their own")
+	flags.IntVar(&options.Workers, "workers", 4, "concurrent requests of each customer")
+	flags.IntVar(&options.Operations, "operations", 100, "operations of each customer")
+	flags.DurationVar(&options.Duration, "duration", 0, "stops sending once elapsed, 0 runs every operation")
+	flags.StringToIntVar(&options.Mix, "mix", map[string]int{LOADTEST_DEPOSIT: 40, LOADTEST_WITHDRAWAL: 30, LOADTEST_BALANCE: 20, LOADTEST_DUPLICATE: 10},
+		"weight of deposit, withdrawal, balance and duplicate")
+	flags.IntVar(&options.MaxAmount, "max-amount", 1000, "deposits and withdrawals are of 1 to max-amount")
+	flags.IntVar(&options.InitialBalance, "initial-balance", 10000, "deposited on each wallet before the run")
+	flags.DurationVar(&options.Timeout, "timeout", time.Second*10, "of each request")
+	output := outputFlag(flags, OUTPUT_TABLE)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+	if err := options.validate(); err != nil {
+		return err
+	}
+
+	report, err := loadtest(ctx, options)
+	if err != nil {
+		return err
+	}
+
+	if err := writeOutput(os.Stdout, *output, report, report.table()); err != nil {
+		return err
+	}
+	if *output == OUTPUT_TABLE {
+		fmt.Printf("\n%d requests in %.1fs, %.1f/s\n", report.Requests, report.ElapsedMs/1000, report.RequestsPerSecond)
+		for _, violation := range report.Violations {
+			fmt.Println("violation:", violation)
+		}
+	}
+
+	if len(report.Violations) > 0 {
+		return fmt.Errorf("loadtest found %d violations", len(report.Violations))
+	}
+
+	return nil
+}
+
+func (options loadtestOptions) validate() error {
+	if options.Customers < 1 || options.Workers < 1 || options.Operations < 1 {
+		return fmt.Errorf("--customers, --workers and --operations must be at least 1")
+	}
+	if options.MaxAmount < 1 || options.InitialBalance < 0 {
+		return fmt.Errorf("--max-amount must be at least 1 and --initial-balance at least 0")
+	}
+
+	total := 0
+	for operation, weight := range options.Mix {
+		if !slices.Contains(loadtestOperations, operation) {
+			return fmt.Errorf("--mix: unknown operation %q, must be one of %s", operation, strings.Join(loadtestOperations, ", "))
+		}
+		if weight < 0 {
+			return fmt.Errorf("--mix: the weight of %s must be at least 0", operation)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("--mix: at least one operation needs a weight")
+	}
+
+	return nil
+}
+
+// loadtest sets up the customers, runs them and checks every wallet once all of them are done
+func loadtest(ctx context.Context, options loadtestOptions) (report loadtestReport, err error) {
+	httpClient := &http.Client{
+		Timeout: options.Timeout,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: options.Customers * options.Workers,
+		},
+	}
+	baseClient := client.NewClient(options.Target, "", httpClient)
+
+	customers := make([]*loadtestCustomer, options.Customers)
+	for i := range customers {
+		if customers[i], err = newLoadtestCustomer(ctx, baseClient, options.InitialBalance); err != nil {
+			return report, fmt.Errorf("setting up customer %d: %w", i, err)
+		}
+	}
+
+	runCtx := ctx
+	if options.Duration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, options.Duration)
+		defer cancel()
+	}
+
+	stats := &loadtestStats{operations: map[string]*loadtestOperationStats{}}
+	startedAt := time.Now()
+
+	var wg sync.WaitGroup
+	for _, customer := range customers {
+		remaining := int64(options.Operations)
+		for i := 0; i < options.Workers; i++ {
+			wg.Add(1)
+			go func(customer *loadtestCustomer) {
+				defer wg.Done()
+
+				random := rand.New(rand.NewSource(time.Now().UnixNano()))
+				for atomic.AddInt64(&remaining, -1) >= 0 && runCtx.Err() == nil {
+					customer.run(runCtx, stats, options, random)
+				}
+			}(customer)
+		}
+	}
+	wg.Wait()
+
+	elapsed := time.Since(startedAt)
+	report = stats.report()
+	report.Customers = options.Customers
+	report.ElapsedMs = float64(elapsed.Microseconds()) / 1000
+	report.RequestsPerSecond = float64(report.Requests) / elapsed.Seconds()
+	report.Violations = []string{}
+
+	// on the parent ctx, the run may have been cut by --duration
+	for _, customer := range customers {
+		violations, err := customer.check(ctx)
+		if err != nil {
+			return report, fmt.Errorf("checking wallet %s: %w", customer.walletId, err)
+		}
+		report.Violations = append(report.Violations, violations...)
+	}
+
+	return report, nil
+}
+
+// newLoadtestCustomer inits and enables a wallet of a new customer, and funds it with initialBalance
+func newLoadtestCustomer(ctx context.Context, baseClient *client.Client, initialBalance int) (*loadtestCustomer, error) {
+	token, err := baseClient.InitUser(ctx, client.InitRequest{CustomerXid: "loadtest-" + uuid.NewString()})
+	if err != nil {
+		return nil, err
+	}
+
+	customer := &loadtestCustomer{
+		client:   baseClient.WithToken(token.Data.Token),
+		accepted: map[string]int{},
+		unknown:  map[string]loadtestTransaction{},
+	}
+
+	enabled, err := customer.client.EnableWallet(ctx, client.EnableWalletParams{})
+	if err != nil {
+		return nil, err
+	}
+	customer.walletId = enabled.Data.Id
+
+	if initialBalance > 0 {
+		funding := loadtestTransaction{Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: initialBalance, ReferenceId: uuid.NewString()}
+		if err := customer.send(ctx, funding); err != nil {
+			return nil, err
+		}
+		customer.record(funding, nil)
+	}
+
+	return customer, nil
+}
+
+// run sends one operation drawn from the mix and records how it went
+func (customer *loadtestCustomer) run(ctx context.Context, stats *loadtestStats, options loadtestOptions, random *rand.Rand) {
+	operation := pickLoadtestOperation(options.Mix, random)
+	amount := 1 + random.Intn(options.MaxAmount)
+
+	var err error
+	startedAt := time.Now()
+	switch operation {
+	case LOADTEST_DEPOSIT, LOADTEST_WITHDRAWAL:
+		walletTransaction := loadtestTransaction{Type: operation, Amount: amount, ReferenceId: uuid.NewString()}
+		err = customer.send(ctx, walletTransaction)
+		customer.record(walletTransaction, err)
+	case LOADTEST_DUPLICATE:
+		walletTransaction, found := customer.pickAccepted(random)
+		if !found {
+			// nothing to replay yet
+			walletTransaction = loadtestTransaction{Type: wallet.WALLET_TRANSACTION_DEPOSIT, Amount: amount, ReferenceId: uuid.NewString()}
+			err = customer.send(ctx, walletTransaction)
+			customer.record(walletTransaction, err)
+			operation = LOADTEST_DEPOSIT
+			break
+		}
+		err = customer.send(ctx, walletTransaction)
+		if err == nil {
+			// a violation, told apart by check
+			customer.record(walletTransaction, nil)
+		}
+	case LOADTEST_BALANCE:
+		_, err = customer.client.GetWallet(ctx, client.GetWalletParams{})
+	}
+
+	// cut by --duration rather than failed, check still finds out whether it was applied
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	stats.record(operation, time.Since(startedAt), err)
+}
+
+func (customer *loadtestCustomer) send(ctx context.Context, walletTransaction loadtestTransaction) (err error) {
+	body := client.WalletTransactionRequest{Amount: walletTransaction.Amount, ReferenceId: walletTransaction.ReferenceId}
+	if walletTransaction.Type == wallet.WALLET_TRANSACTION_WITHDRAWAL {
+		_, err = customer.client.CreateWithdrawal(ctx, client.CreateWithdrawalParams{}, body)
+	} else {
+		_, err = customer.client.CreateDeposit(ctx, client.CreateDepositParams{}, body)
+	}
+
+	return err
+}
+
+// record books an accepted transaction, or keeps one without an answer until check looks it up
+func (customer *loadtestCustomer) record(walletTransaction loadtestTransaction, err error) {
+	customer.mu.Lock()
+	defer customer.mu.Unlock()
+
+	if err != nil {
+		var apiError *client.APIError
+		if !errors.As(err, &apiError) || apiError.StatusCode >= http.StatusInternalServerError {
+			customer.unknown[walletTransaction.ReferenceId] = walletTransaction
+		}
+		return
+	}
+
+	customer.accepted[walletTransaction.ReferenceId]++
+	customer.history = append(customer.history, walletTransaction)
+	customer.balance += walletTransaction.signedAmount()
+}
+
+func (customer *loadtestCustomer) pickAccepted(random *rand.Rand) (walletTransaction loadtestTransaction, found bool) {
+	customer.mu.Lock()
+	defer customer.mu.Unlock()
+
+	if len(customer.history) == 0 {
+		return walletTransaction, false
+	}
+
+	return customer.history[random.Intn(len(customer.history))], true
+}
+
+// check compares the wallet with what its customer was told: its balance is the sum of the accepted transactions,
+// plus those sent without an answer the service did apply, and no reference id was accepted twice
+func (customer *loadtestCustomer) check(ctx context.Context) (violations []string, err error) {
+	current, err := customer.client.GetWallet(ctx, client.GetWalletParams{})
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := customer.client.ExportWalletTransactions(ctx, client.ExportWalletTransactionsParams{
+		Format:  wallet.WALLET_EXPORT_FORMAT_NDJSON,
+		Columns: "type,amount,reference_id",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var stored []loadtestTransaction
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		walletTransaction := loadtestTransaction{}
+		if err := json.Unmarshal(scanner.Bytes(), &walletTransaction); err != nil {
+			return nil, err
+		}
+		stored = append(stored, walletTransaction)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	customer.mu.Lock()
+	defer customer.mu.Unlock()
+
+	return customer.violations(current.Data.Balance, stored), nil
+}
+
+func (customer *loadtestCustomer) violations(balance int, stored []loadtestTransaction) (violations []string) {
+	for referenceId, times := range customer.accepted {
+		if times > 1 {
+			violations = append(violations, fmt.Sprintf("wallet %s: reference id %s was accepted %d times", customer.walletId, referenceId, times))
+		}
+	}
+
+	storedTimes := map[string]int{}
+	storedBalance := 0
+	want := customer.balance
+	for _, walletTransaction := range stored {
+		storedTimes[walletTransaction.ReferenceId]++
+		storedBalance += walletTransaction.signedAmount()
+		if _, found := customer.unknown[walletTransaction.ReferenceId]; found && customer.accepted[walletTransaction.ReferenceId] == 0 {
+			want += walletTransaction.signedAmount()
+		}
+	}
+	for referenceId, times := range storedTimes {
+		if times > 1 {
+			violations = append(violations, fmt.Sprintf("wallet %s: reference id %s is stored %d times", customer.walletId, referenceId, times))
+		}
+	}
+	for referenceId := range customer.accepted {
+		if storedTimes[referenceId] == 0 {
+			violations = append(violations, fmt.Sprintf("wallet %s: reference id %s was accepted but is not stored", customer.walletId, referenceId))
+		}
+	}
+
+	if balance != want {
+		violations = append(violations, fmt.Sprintf("wallet %s: balance is %d, the successful operations sum to %d", customer.walletId, balance, want))
+	}
+	if balance != storedBalance {
+		violations = append(violations, fmt.Sprintf("wallet %s: balance is %d, its transactions sum to %d", customer.walletId, balance, storedBalance))
+	}
+
+	sort.Strings(violations)
+	return violations
+}
+
+func (walletTransaction loadtestTransaction) signedAmount() int {
+	if walletTransaction.Type == wallet.WALLET_TRANSACTION_WITHDRAWAL {
+		return -walletTransaction.Amount
+	}
+
+	return walletTransaction.Amount
+}
+
+func pickLoadtestOperation(mix map[string]int, random *rand.Rand) string {
+	total := 0
+	for _, operation := range loadtestOperations {
+		total += mix[operation]
+	}
+
+	drawn := random.Intn(total)
+	for _, operation := range loadtestOperations {
+		if drawn < mix[operation] {
+			return operation
+		}
+		drawn -= mix[operation]
+	}
+
+	return LOADTEST_BALANCE
+}
+
+// loadtestResult tells an expected refusal, e.g. a withdrawal above the balance, from a failure, named by its error code
+func loadtestResult(operation string, err error) (result string, code string) {
+	if err == nil {
+		return LOADTEST_RESULT_OK, ""
+	}
+
+	var apiError *client.APIError
+	if !errors.As(err, &apiError) {
+		return LOADTEST_RESULT_FAILED, loadtestTransportError
+	}
+
+	code = apiError.Data.Code
+	if operation == LOADTEST_WITHDRAWAL && code == response.ErrInsufficientFund.Code ||
+		operation == LOADTEST_DUPLICATE && code == response.ErrReferenceIdConflict.Code {
+		return LOADTEST_RESULT_REJECTED, code
+	}
+
+	return LOADTEST_RESULT_FAILED, code
+}
+
+func (stats *loadtestStats) record(operation string, latency time.Duration, err error) {
+	result, code := loadtestResult(operation, err)
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	operationStats := stats.operations[operation]
+	if operationStats == nil {
+		operationStats = &loadtestOperationStats{results: map[string]int{}, errors: map[string]int{}}
+		stats.operations[operation] = operationStats
+	}
+
+	operationStats.latencies = append(operationStats.latencies, latency)
+	operationStats.results[result]++
+	if result == LOADTEST_RESULT_FAILED {
+		operationStats.errors[code]++
+	}
+}
+
+func (stats *loadtestStats) report() (report loadtestReport) {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	report.Operations = []loadtestOperationReport{}
+	for _, operation := range loadtestOperations {
+		operationStats := stats.operations[operation]
+		if operationStats == nil {
+			continue
+		}
+
+		latencies := slices.Clone(operationStats.latencies)
+		slices.Sort(latencies)
+
+		operationReport := loadtestOperationReport{
+			Operation: operation,
+			Requests:  len(latencies),
+			Ok:        operationStats.results[LOADTEST_RESULT_OK],
+			Rejected:  operationStats.results[LOADTEST_RESULT_REJECTED],
+			Failed:    operationStats.results[LOADTEST_RESULT_FAILED],
+			Errors:    operationStats.errors,
+			LatencyMs: map[string]float64{"max": durationMs(latencies[len(latencies)-1])},
+		}
+		operationReport.ErrorRate = float64(operationReport.Failed) / float64(operationReport.Requests)
+		for _, percentile := range loadtestPercentiles {
+			operationReport.LatencyMs[fmt.Sprintf("p%g", percentile)] = durationMs(percentileOf(latencies, percentile))
+		}
+
+		report.Requests += operationReport.Requests
+		report.Operations = append(report.Operations, operationReport)
+	}
+
+	return report
+}
+
+func (report loadtestReport) table() table {
+	resultTable := table{header: []string{"OPERATION", "REQUESTS", "OK", "REJECTED", "FAILED", "ERROR RATE", "P50", "P90", "P99", "MAX", "ERRORS"}}
+	for _, operation := range report.Operations {
+		codes := []string{}
+		for code, count := range operation.Errors {
+			codes = append(codes, fmt.Sprintf("%s=%d", code, count))
+		}
+		sort.Strings(codes)
+		errorCodes := strings.Join(codes, ",")
+
+		resultTable.rows = append(resultTable.rows, []string{
+			operation.Operation,
+			strconv.Itoa(operation.Requests),
+			strconv.Itoa(operation.Ok),
+			strconv.Itoa(operation.Rejected),
+			strconv.Itoa(operation.Failed),
+			fmt.Sprintf("%.2f%%", operation.ErrorRate*100),
+			fmt.Sprintf("%.1fms", operation.LatencyMs["p50"]),
+			fmt.Sprintf("%.1fms", operation.LatencyMs["p90"]),
+			fmt.Sprintf("%.1fms", operation.LatencyMs["p99"]),
+			fmt.Sprintf("%.1fms", operation.LatencyMs["max"]),
+			orDash(&errorCodes),
+		})
+	}
+
+	return resultTable
+}
+
+// percentileOf is the nearest rank percentile of sorted, which is not empty
+func percentileOf(sorted []time.Duration, percentile float64) time.Duration {
+	rank := int(math.Ceil(percentile / 100 * float64(len(sorted))))
+
+	return sorted[max(rank, 1)-1]
+}
+
+func durationMs(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
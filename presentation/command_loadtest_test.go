@@ -0,0 +1,109 @@
+package presentation
+
+import (
+	"context"
+	"mini-wallet/app/auth"
+	"mini-wallet/app/wallet"
+	"mini-wallet/infrastructure"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestLoadtest(t *testing.T) {
+	config := infrastructure.Config{
+		WALLET_TRANSACTION_CHANNEL:  "wallet-transactions",
+		TOKEN_TTL:                   time.Minute,
+		WALLET_LOCK_TTL:             time.Second * 8,
+		WALLET_TRANSACTION_TIMEOUT:  time.Second * 30,
+		WALLET_CONCURRENCY_STRATEGY: "redis_lock",
+	}
+	_, usecases := newMemoryDomain(config)
+	router := chi.NewRouter()
+	wallet.SetWalletHandler(router, usecases)
+	auth.SetAuthHandler(router, usecases)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	report, err := loadtest(context.Background(), loadtestOptions{
+		Target:         server.URL,
+		Customers:      3,
+		Workers:        8,
+		Operations:     50,
+		Mix:            map[string]int{LOADTEST_DEPOSIT: 2, LOADTEST_WITHDRAWAL: 2, LOADTEST_BALANCE: 1, LOADTEST_DUPLICATE: 1},
+		MaxAmount:      100,
+		InitialBalance: 200,
+		Timeout:        time.Second * 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Requests != 150 || len(report.Violations) != 0 {
+		t.Errorf("report = %d requests, violations %v, want 150 without violation", report.Requests, report.Violations)
+	}
+	for _, operation := range report.Operations {
+		if operation.Failed != 0 {
+			t.Errorf("%s failed %d times: %v", operation.Operation, operation.Failed, operation.Errors)
+		}
+		if operation.Operation == LOADTEST_DUPLICATE && operation.Ok != 0 {
+			t.Errorf("%d duplicates were accepted", operation.Ok)
+		}
+	}
+}
+
+func TestLoadtestCustomerViolations(t *testing.T) {
+	deposit := loadtestTransaction{Type: "deposit", Amount: 100, ReferenceId: "ref-1"}
+	withdrawal := loadtestTransaction{Type: "withdrawal", Amount: 30, ReferenceId: "ref-2"}
+	timedOut := loadtestTransaction{Type: "deposit", Amount: 5, ReferenceId: "ref-3"}
+
+	customer := &loadtestCustomer{walletId: "wallet-1", accepted: map[string]int{}, unknown: map[string]loadtestTransaction{}}
+	customer.record(deposit, nil)
+	customer.record(withdrawal, nil)
+	customer.record(timedOut, context.DeadlineExceeded)
+
+	// a timed out deposit the service applied counts, one it did not is left out
+	if violations := customer.violations(75, []loadtestTransaction{deposit, withdrawal, timedOut}); len(violations) != 0 {
+		t.Errorf("violations = %v, want none with the timed out deposit applied", violations)
+	}
+	if violations := customer.violations(70, []loadtestTransaction{deposit, withdrawal}); len(violations) != 0 {
+		t.Errorf("violations = %v, want none without the timed out deposit", violations)
+	}
+
+	// a replayed deposit the service accepted again
+	customer.record(deposit, nil)
+	violations := customer.violations(170, []loadtestTransaction{deposit, withdrawal, deposit})
+	if len(violations) != 2 || !strings.Contains(violations[0], "stored 2 times") || !strings.Contains(violations[1], "accepted 2 times") {
+		t.Errorf("violations = %v, want ref-1 accepted and stored twice", violations)
+	}
+
+	// a lost update
+	violations = customer.violations(100, []loadtestTransaction{deposit, withdrawal, deposit})
+	if len(violations) != 4 || !strings.Contains(strings.Join(violations, "\n"), "balance is 100, the successful operations sum to 170") {
+		t.Errorf("violations = %v, want the balance reported", violations)
+	}
+}
+
+func TestPercentileOf(t *testing.T) {
+	latencies := []time.Duration{}
+	for i := 1; i <= 200; i++ {
+		latencies = append(latencies, time.Millisecond*time.Duration(i))
+	}
+
+	for _, testCase := range []struct {
+		percentile float64
+		want       time.Duration
+	}{
+		{50, time.Millisecond * 100},
+		{99, time.Millisecond * 198},
+		{100, time.Millisecond * 200},
+		{0, time.Millisecond},
+	} {
+		if got := percentileOf(latencies, testCase.percentile); got != testCase.want {
+			t.Errorf("p%g = %s, want %s", testCase.percentile, got, testCase.want)
+		}
+	}
+}
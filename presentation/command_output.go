@@ -0,0 +1,61 @@
+package presentation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	OUTPUT_TABLE = "table"
+	OUTPUT_JSON  = "json" // the same fields as the http api, indented
+)
+
+// table is the tabular form of a command result, the json form is the result itself
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// outputFlag adds --output (-o) to flags, table unless the command says otherwise
+func outputFlag(flags *pflag.FlagSet, defaultOutput string) *string {
+	return flags.StringP("output", "o", defaultOutput, "table or json")
+}
+
+func validateOutput(output string) error {
+	if output != OUTPUT_TABLE && output != OUTPUT_JSON {
+		return fmt.Errorf("--output must be %s or %s, got %q", OUTPUT_TABLE, OUTPUT_JSON, output)
+	}
+
+	return nil
+}
+
+// writeOutput prints result as json, or rows under header as a table with aligned columns
+func writeOutput(w io.Writer, output string, result any, resultTable table) error {
+	if output == OUTPUT_JSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	tableWriter := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tableWriter, strings.Join(resultTable.header, "\t"))
+	for _, row := range resultTable.rows {
+		fmt.Fprintln(tableWriter, strings.Join(row, "\t"))
+	}
+
+	return tableWriter.Flush()
+}
+
+// orDash stands in for an empty or missing cell, so the columns stay aligned
+func orDash(value *string) string {
+	if value == nil || *value == "" {
+		return "-"
+	}
+
+	return *value
+}
@@ -0,0 +1,101 @@
+package presentation
+
+import (
+	"bytes"
+	"encoding/json"
+	reconciliationDomain "mini-wallet/domain/reconciliation"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteOutput(t *testing.T) {
+	resultTable := table{
+		header: []string{"ID", "BALANCE"},
+		rows:   [][]string{{"wallet-1", "100"}, {"a-longer-wallet-id", "5"}},
+	}
+
+	var tableOutput bytes.Buffer
+	if err := writeOutput(&tableOutput, OUTPUT_TABLE, nil, resultTable); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(tableOutput.String(), "\n"), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID ") {
+		t.Fatalf("table = %q, want a header and 2 rows", tableOutput.String())
+	}
+	if column := strings.Index(lines[0], "BALANCE"); strings.Index(lines[1], "100") != column || strings.Index(lines[2], "5") != column {
+		t.Errorf("table = %q, want the columns aligned", tableOutput.String())
+	}
+
+	var jsonOutput bytes.Buffer
+	if err := writeOutput(&jsonOutput, OUTPUT_JSON, map[string]int{"balance": 100}, resultTable); err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]int{}
+	if err := json.Unmarshal(jsonOutput.Bytes(), &result); err != nil || result["balance"] != 100 {
+		t.Errorf("json = %q %v, want the result rather than the table", jsonOutput.String(), err)
+	}
+
+	if validateOutput("yaml") == nil {
+		t.Errorf("an unknown output is accepted")
+	}
+}
+
+func TestTransactionFilterFromFlags(t *testing.T) {
+	req, err := transactionFilterFromFlags("wallet-1", "withdrawal", "", "2024-03-01T00:00:00Z", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Type == nil || *req.Type != "withdrawal" || req.CreatedBy != nil || req.To != nil {
+		t.Errorf("filter = %+v, want only the type and from", req)
+	}
+	if req.From == nil || !req.From.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("from = %v, want 2024-03-01", req.From)
+	}
+
+	if _, err = transactionFilterFromFlags("wallet-1", "", "", "yesterday", ""); err == nil {
+		t.Errorf("a from that is not RFC 3339 is accepted")
+	}
+}
+
+func TestWriteReconciliationReport(t *testing.T) {
+	adjustmentType := "withdrawal"
+	adjustmentAmount := 50
+	report := reconciliationDomain.ReconciliationReport{
+		Run: reconciliationDomain.ReconciliationRun{Id: "run-1", Status: reconciliationDomain.RECONCILIATION_STATUS_COMPLETED, WalletsChecked: 3, Mismatches: 1},
+		Mismatches: []reconciliationDomain.Mismatch{{
+			RunId: "run-1", WalletId: "wallet-1", StoredBalance: 150, ComputedBalance: 100, Drift: 50,
+			AdjustmentType: &adjustmentType, AdjustmentAmount: &adjustmentAmount,
+		}},
+	}
+
+	var tableOutput bytes.Buffer
+	if err := writeReconciliationReport(&tableOutput, OUTPUT_TABLE, report); err != nil {
+		t.Fatal(err)
+	}
+	tables := strings.Split(strings.TrimRight(tableOutput.String(), "\n"), "\n\n")
+	if len(tables) != 2 || !strings.HasPrefix(tables[0], "RUN_ID") || !strings.HasPrefix(tables[1], "WALLET_ID") {
+		t.Fatalf("table = %q, want the run then its mismatches", tableOutput.String())
+	}
+	if !strings.Contains(tables[1], "wallet-1") || !strings.Contains(tables[1], "withdrawal") {
+		t.Errorf("mismatches = %q, want wallet-1 and its adjustment", tables[1])
+	}
+
+	report.Mismatches, report.Run.Mismatches = nil, 0
+	tableOutput.Reset()
+	if err := writeReconciliationReport(&tableOutput, OUTPUT_TABLE, report); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimRight(tableOutput.String(), "\n"), "\n"); len(lines) != 2 {
+		t.Errorf("table = %q, want only the run", tableOutput.String())
+	}
+
+	var jsonOutput bytes.Buffer
+	if err := writeReconciliationReport(&jsonOutput, OUTPUT_JSON, report); err != nil {
+		t.Fatal(err)
+	}
+	decoded := reconciliationDomain.ReconciliationReport{}
+	if err := json.Unmarshal(jsonOutput.Bytes(), &decoded); err != nil || decoded.Run.Id != "run-1" {
+		t.Errorf("json = %q %v, want the report", jsonOutput.String(), err)
+	}
+}
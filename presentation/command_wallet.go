@@ -0,0 +1,372 @@
+package presentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	"mini-wallet/domain/wallet"
+	"mini-wallet/infrastructure"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/google/uuid"
+	"github.com/spf13/pflag"
+)
+
+// openDomain connects to postgres and redis with the config of args, and wires the layers like the server does
+func openDomain(ctx context.Context, args []string) (repositories domain.Repositories, usecases domain.Usecases, err error) {
+	config, _, err := infrastructure.LoadConfig(args)
+	if err != nil {
+		return repositories, usecases, err
+	}
+
+	postgresDb, err := infrastructure.NewPostgresConn(config)
+	if err != nil {
+		return repositories, usecases, err
+	}
+
+	// a command must not write what the server would refuse to read
+	if _, err = infrastructure.CheckSchemaVersion(ctx, postgresDb); err != nil {
+		return repositories, usecases, err
+	}
+
+	repositories, usecases = newDomain(config, postgresDb, infrastructure.NewRedisClient(ctx, config))
+	return repositories, usecases, nil
+}
+
+// newCommandFlags leaves the config flags, e.g. --postgres-host, to LoadConfig
+func newCommandFlags(name string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+
+	return flags
+}
+
+// runWallet shows, freezes or adjusts a single wallet, e.g. `mini-wallet wallet adjust <wallet-id> --amount=-500 --reason "double settlement"`
+func runWallet(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: mini-wallet wallet show|freeze|adjust <wallet-id> [flags]")
+	}
+
+	switch args[0] {
+	case "show":
+		return runWalletShow(ctx, args[1:])
+	case "freeze":
+		return runWalletFreeze(ctx, args[1:])
+	case "adjust":
+		return runWalletAdjust(ctx, args[1:])
+	}
+
+	return fmt.Errorf("unknown command %q, usage: mini-wallet wallet show|freeze|adjust <wallet-id> [flags]", "wallet "+args[0])
+}
+
+func runWalletShow(ctx context.Context, args []string) error {
+	flags := newCommandFlags("wallet show")
+	output := outputFlag(flags, OUTPUT_TABLE)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: mini-wallet wallet show <wallet-id> [--output table|json]")
+	}
+
+	repositories, _, err := openDomain(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	walletResult, err := repositories.WalletRepository.GetWalletById(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if walletResult == nil {
+		return response.ErrWalletNotFound
+	}
+
+	return writeOutput(os.Stdout, *output, walletResult, walletTable(*walletResult))
+}
+
+func runWalletFreeze(ctx context.Context, args []string) error {
+	flags := newCommandFlags("wallet freeze")
+	output := outputFlag(flags, OUTPUT_TABLE)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: mini-wallet wallet freeze <wallet-id> [--output table|json]")
+	}
+
+	_, usecases, err := openDomain(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	result, err := usecases.ReconciliationUsecase.FreezeWallet(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(os.Stdout, *output, result.Data, walletTable(*result.Data))
+}
+
+// walletAdjustment is what the adjust command prints, the reference id is the one to search the transaction by
+type walletAdjustment struct {
+	ReferenceId string        `json:"reference_id"`
+	Amount      int           `json:"amount"`
+	Reason      string        `json:"reason"`
+	Wallet      wallet.Wallet `json:"wallet"`
+}
+
+func runWalletAdjust(ctx context.Context, args []string) error {
+	req := wallet.WalletAdjustmentRequest{}
+
+	flags := newCommandFlags("wallet adjust")
+	output := outputFlag(flags, OUTPUT_TABLE)
+	flags.IntVar(&req.Amount, "amount", 0, "deposited when positive, withdrawn when negative, e.g. --amount=-500")
+	flags.StringVar(&req.Reason, "reason", "", "why the balance is corrected, stored with the transaction")
+	flags.StringVar(&req.ReferenceId, "reference-id", "", "a retried adjustment reusing it is refused, a new one by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: mini-wallet wallet adjust <wallet-id> --amount <amount> --reason <reason> [--reference-id <id>] [--output table|json]")
+	}
+
+	req.WalletId = flags.Arg(0)
+	if req.ReferenceId == "" {
+		req.ReferenceId = uuid.NewString()
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	_, usecases, err := openDomain(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	result, err := usecases.WalletUsecase.AdjustWalletBalance(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	adjustment := walletAdjustment{
+		ReferenceId: req.ReferenceId,
+		Amount:      req.Amount,
+		Reason:      req.Reason,
+		Wallet:      *result.Data,
+	}
+
+	return writeOutput(os.Stdout, *output, adjustment, table{
+		header: []string{"REFERENCE_ID", "AMOUNT", "WALLET_ID", "BALANCE", "STATUS"},
+		rows: [][]string{{
+			adjustment.ReferenceId, strconv.Itoa(adjustment.Amount), adjustment.Wallet.Id,
+			strconv.Itoa(adjustment.Wallet.Balance), adjustment.Wallet.Status,
+		}},
+	})
+}
+
+// runTx lists the transactions of a wallet or shows a single one, e.g. `mini-wallet tx list --wallet <wallet-id> --type withdrawal`
+func runTx(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: mini-wallet tx list|show [flags]")
+	}
+
+	switch args[0] {
+	case "list":
+		return runTxList(ctx, args[1:])
+	case "show":
+		return runTxShow(ctx, args[1:])
+	}
+
+	return fmt.Errorf("unknown command %q, usage: mini-wallet tx list|show [flags]", "tx "+args[0])
+}
+
+func runTxList(ctx context.Context, args []string) error {
+	var walletId, transactionType, createdBy, from, to string
+	var page, size int
+
+	flags := newCommandFlags("tx list")
+	output := outputFlag(flags, OUTPUT_TABLE)
+	flags.StringVar(&walletId, "wallet", "", "the wallet id, required")
+	flags.StringVar(&transactionType, "type", "", "deposit or withdrawal, both by default")
+	flags.StringVar(&createdBy, "created-by", "", "the member who made the transactions")
+	flags.StringVar(&from, "from", "", "RFC 3339, created at or after")
+	flags.StringVar(&to, "to", "", "RFC 3339, created before")
+	flags.IntVar(&page, "page", 1, "the page, newest transactions first")
+	flags.IntVar(&size, "size", 20, "transactions per page")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+	if walletId == "" || page < 1 || size < 1 {
+		return fmt.Errorf("usage: mini-wallet tx list --wallet <wallet-id> [--type deposit|withdrawal] [--created-by <id>] [--from <time>] [--to <time>] [--page 1] [--size 20] [--output table|json]")
+	}
+
+	req, err := transactionFilterFromFlags(walletId, transactionType, createdBy, from, to)
+	if err != nil {
+		return err
+	}
+
+	repositories, _, err := openDomain(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	walletTransactions, err := repositories.WalletRepository.GetWalletTransactionsByWalletId(ctx, req, page, size)
+	if err != nil {
+		return err
+	}
+	if walletTransactions == nil {
+		walletTransactions = []wallet.WalletTransactionEntity{}
+	}
+
+	return writeOutput(os.Stdout, *output, walletTransactions, transactionTable(walletTransactions...))
+}
+
+func runTxShow(ctx context.Context, args []string) error {
+	flags := newCommandFlags("tx show")
+	output := outputFlag(flags, OUTPUT_TABLE)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: mini-wallet tx show <transaction-id> [--output table|json]")
+	}
+
+	repositories, _, err := openDomain(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	walletTransaction, err := repositories.WalletRepository.GetWalletTransactionById(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if walletTransaction == nil {
+		return fmt.Errorf("transaction %s not found", flags.Arg(0))
+	}
+
+	return writeOutput(os.Stdout, *output, walletTransaction, transactionTable(*walletTransaction))
+}
+
+// runToken revokes a token before its TTL, e.g. one leaked in a log: `mini-wallet token revoke <token>`
+func runToken(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "revoke" {
+		return fmt.Errorf("usage: mini-wallet token revoke <token> [--output table|json]")
+	}
+
+	flags := newCommandFlags("token revoke")
+	output := outputFlag(flags, OUTPUT_TABLE)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if err := validateOutput(*output); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: mini-wallet token revoke <token> [--output table|json]")
+	}
+
+	repositories, _, err := openDomain(ctx, args[1:])
+	if err != nil {
+		return err
+	}
+
+	token := flags.Arg(0)
+	walletId, err := repositories.AuthRepository.GetTokenWalletId(ctx, token)
+	if errors.Is(err, redis.Nil) || (err == nil && walletId == "") {
+		return fmt.Errorf("the token is unknown or expired already")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = repositories.AuthRepository.RevokeToken(ctx, token); err != nil {
+		return err
+	}
+
+	revoked := struct {
+		WalletId string `json:"wallet_id"`
+		Revoked  bool   `json:"revoked"`
+	}{walletId, true}
+
+	return writeOutput(os.Stdout, *output, revoked, table{
+		header: []string{"WALLET_ID", "REVOKED"},
+		rows:   [][]string{{revoked.WalletId, strconv.FormatBool(revoked.Revoked)}},
+	})
+}
+
+// transactionFilterFromFlags reads the filters of tx list like the transaction list endpoint reads its query
+func transactionFilterFromFlags(walletId string, transactionType string, createdBy string, from string, to string) (req wallet.GetWalletTransactionRequest, err error) {
+	req = wallet.GetWalletTransactionRequest{
+		WalletId: walletId,
+	}
+	if transactionType != "" {
+		req.Type = &transactionType
+	}
+	if createdBy != "" {
+		req.CreatedBy = &createdBy
+	}
+
+	for _, bound := range []struct {
+		name  string
+		value string
+		dst   **time.Time
+	}{{"from", from, &req.From}, {"to", to, &req.To}} {
+		if bound.value == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return req, fmt.Errorf("--%s must be an RFC 3339 time, e.g. 2024-03-01T00:00:00Z", bound.name)
+		}
+		*bound.dst = &parsed
+	}
+
+	return req, req.Validate()
+}
+
+func walletTable(walletResult wallet.Wallet) table {
+	return table{
+		header: []string{"ID", "OWNED_BY", "NAME", "STATUS", "BALANCE", "ENABLED_AT"},
+		rows: [][]string{{
+			walletResult.Id, walletResult.OwnedBy, walletResult.Name, walletResult.Status,
+			strconv.Itoa(walletResult.Balance), orDash(walletResult.EnabledAt),
+		}},
+	}
+}
+
+func transactionTable(walletTransactions ...wallet.WalletTransactionEntity) table {
+	resultTable := table{
+		header: []string{"ID", "WALLET_ID", "TYPE", "AMOUNT", "STATUS", "REFERENCE_ID", "CREATED_AT", "CREATED_BY", "REASON"},
+	}
+	for _, walletTransaction := range walletTransactions {
+		resultTable.rows = append(resultTable.rows, []string{
+			walletTransaction.Id, walletTransaction.WalletId, walletTransaction.Type, strconv.Itoa(walletTransaction.Amount),
+			walletTransaction.Status, walletTransaction.ReferenceId, walletTransaction.CreatedAt, walletTransaction.CreatedBy,
+			orDash(walletTransaction.Reason),
+		})
+	}
+
+	return resultTable
+}
@@ -0,0 +1,176 @@
+package presentation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"mini-wallet/app/auth"
+	"mini-wallet/app/wallet"
+	"mini-wallet/domain"
+	"mini-wallet/domain/health"
+	"mini-wallet/infrastructure"
+	"mini-wallet/proto/walletpb"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcHealth "google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// how often the grpc health service takes over the readiness of /readyz
+	grpcHealthInterval = time.Second * 10
+)
+
+// startGrpcServer serves AuthService, WalletService and the grpc health service on GRPC_PORT with the usecases
+// of the http api. the port is bound before it returns, so a port in use fails the startup like the http server does
+func startGrpcServer(ctx context.Context, config infrastructure.Config, usecases domain.Usecases) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", config.GRPC_PORT))
+	if err != nil {
+		return err
+	}
+
+	// open streams never end on their own, cancelling them lets GracefulStop return
+	streamsCtx, stopStreams := context.WithCancel(ctx)
+
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(grpcAccessLogUnaryInterceptor, grpcRecoverUnaryInterceptor, usecases.AuthUsecase.AuthorizeUnaryInterceptor),
+		grpc.ChainStreamInterceptor(grpcAccessLogStreamInterceptor, grpcRecoverStreamInterceptor, grpcStopStreamInterceptor(streamsCtx), usecases.AuthUsecase.AuthorizeStreamInterceptor),
+	)
+	auth.SetAuthGrpcServer(server, usecases)
+	wallet.SetWalletGrpcServer(server, usecases)
+
+	healthServer := grpcHealth.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+	healthCtx, stopHealth := context.WithCancel(ctx)
+	go watchGrpcHealth(healthCtx, healthServer, usecases.HealthUsecase)
+
+	go func() {
+		infrastructure.LogInfo(ctx, "grpc server listening", "port", config.GRPC_PORT)
+		if err := server.Serve(listener); err != nil {
+			infrastructure.LogError(ctx, "got error on server.Serve() - startGrpcServer", err)
+		}
+	}()
+
+	shutdownHooks = append(shutdownHooks, func(ctx context.Context) error {
+		stopHealth()
+		healthServer.Shutdown()
+		stopStreams()
+
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			server.Stop()
+			return ctx.Err()
+		}
+	})
+
+	return nil
+}
+
+// watchGrpcHealth reports every service as serving while the readiness checks are up
+func watchGrpcHealth(ctx context.Context, healthServer *grpcHealth.Server, healthUsecase health.HealthUsecase) {
+	ticker := time.NewTicker(grpcHealthInterval)
+	defer ticker.Stop()
+
+	for {
+		servingStatus := grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		if report := healthUsecase.Readiness(ctx); report.Data != nil && report.Data.IsUp() {
+			servingStatus = grpc_health_v1.HealthCheckResponse_SERVING
+		}
+		for _, service := range []string{"", walletpb.AuthService_ServiceDesc.ServiceName, walletpb.WalletService_ServiceDesc.ServiceName} {
+			healthServer.SetServingStatus(service, servingStatus)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// grpcAccessLogUnaryInterceptor writes one line per call, like accessLogMiddleware
+func grpcAccessLogUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	startedAt := time.Now()
+	resp, err = handler(ctx, req)
+	logGrpcCall(ctx, info.FullMethod, startedAt, err)
+
+	return resp, err
+}
+
+func grpcAccessLogStreamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	startedAt := time.Now()
+	err = handler(srv, stream)
+	logGrpcCall(stream.Context(), info.FullMethod, startedAt, err)
+
+	return err
+}
+
+func logGrpcCall(ctx context.Context, method string, startedAt time.Time, err error) {
+	code := status.Code(err)
+
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unimplemented:
+		level = slog.LevelError
+	}
+
+	infrastructure.Logger().LogAttrs(ctx, level, "grpc request",
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(startedAt)),
+	)
+}
+
+// grpcRecoverUnaryInterceptor answers a panicking call with Internal instead of taking the process down,
+// net/http does the same for a panicking handler
+func grpcRecoverUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer recoverGrpcCall(ctx, info.FullMethod, &err)
+
+	return handler(ctx, req)
+}
+
+func grpcRecoverStreamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer recoverGrpcCall(stream.Context(), info.FullMethod, &err)
+
+	return handler(srv, stream)
+}
+
+func recoverGrpcCall(ctx context.Context, method string, err *error) {
+	if recovered := recover(); recovered != nil {
+		infrastructure.LogError(ctx, "got panic on grpc call - recoverGrpcCall", fmt.Errorf("%v", recovered), "method", method)
+		*err = status.Error(codes.Internal, "internal error")
+	}
+}
+
+// grpcStopStreamInterceptor ends the ctx of every stream once streamsCtx is done
+func grpcStopStreamInterceptor(streamsCtx context.Context) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx, cancel := context.WithCancel(stream.Context())
+		defer cancel()
+		stop := context.AfterFunc(streamsCtx, cancel)
+		defer stop()
+
+		return handler(srv, &stoppableServerStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+type stoppableServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *stoppableServerStream) Context() context.Context {
+	return stream.ctx
+}
@@ -0,0 +1,110 @@
+package presentation
+
+import (
+	"log/slog"
+	"mini-wallet/infrastructure"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// metricsMiddleware labels requests with the chi route pattern (e.g. /api/v1/wallet/deposits)
+// instead of the raw path, so the label cardinality stays bounded
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startedAt := time.Now()
+		wrappedWriter := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(wrappedWriter, r)
+
+		route := "unmatched"
+		if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+			route = routeContext.RoutePattern()
+		}
+
+		status := wrappedWriter.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		labels := []string{r.Method, route, strconv.Itoa(status)}
+		infrastructure.HttpRequestsTotal.WithLabelValues(labels...).Inc()
+		infrastructure.HttpRequestDuration.WithLabelValues(labels...).Observe(time.Since(startedAt).Seconds())
+	})
+}
+
+// tracingMiddleware continues the trace from the incoming traceparent header (if any)
+// and opens the server span every handler, usecase and repository span hangs on
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := infrastructure.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		wrappedWriter := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(wrappedWriter, r.WithContext(ctx))
+
+		if routeContext := chi.RouteContext(r.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+			span.SetName(r.Method + " " + routeContext.RoutePattern())
+			span.SetAttributes(attribute.String("http.route", routeContext.RoutePattern()))
+		}
+
+		status := wrappedWriter.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// accessLogMiddleware writes one line per request through the structured logger,
+// the request id generated by middleware.RequestID is also put on the context for every other log line
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startedAt := time.Now()
+		requestId := middleware.GetReqID(r.Context())
+		ctx := infrastructure.WithRequestId(r.Context(), requestId)
+
+		w.Header().Set(middleware.RequestIDHeader, requestId)
+		wrappedWriter := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(wrappedWriter, r.WithContext(ctx))
+
+		status := wrappedWriter.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		infrastructure.Logger().LogAttrs(ctx, level, "http request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", wrappedWriter.BytesWritten()),
+			slog.Duration("duration", time.Since(startedAt)),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("user_agent", r.UserAgent()),
+		)
+	})
+}
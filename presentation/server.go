@@ -2,50 +2,67 @@ package presentation
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"mini-wallet/app/auth"
+	"mini-wallet/app/batch"
+	"mini-wallet/app/health"
+	"mini-wallet/app/member"
+	"mini-wallet/app/openapi"
+	"mini-wallet/app/reconciliation"
+	"mini-wallet/app/schedule"
+	"mini-wallet/app/settlement"
+	"mini-wallet/app/statement"
 	"mini-wallet/app/wallet"
 
 	"mini-wallet/domain"
+	"mini-wallet/domain/common/response"
+	walletDomain "mini-wallet/domain/wallet"
 	"mini-wallet/infrastructure"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-redsync/redsync/v4"
-	"github.com/go-redsync/redsync/v4/redis/goredis"
+	"github.com/go-redis/redis"
+	"gorm.io/gorm"
 )
 
-func InitServer() chi.Router {
+// shutdownHooks are run by StopServer, e.g. flushing pending spans
+var shutdownHooks []func(ctx context.Context) error
+
+func InitServer(config infrastructure.Config) (chi.Router, error) {
 	ctx := context.Background()
 	router := chi.NewRouter()
-	router.Use(middleware.Logger)
-
-	config := infrastructure.GetConfig()
+	router.Use(middleware.RequestID)
+	router.Use(tracingMiddleware)
+	router.Use(accessLogMiddleware)
+	router.Use(metricsMiddleware)
 
-	fmt.Println(fmt.Sprintf("config got: %v", config))
+	infrastructure.LogInfo(ctx, "config loaded", "config", config.Redacted())
 
-	postgresDb := infrastructure.NewPostgresConn(config)
-	redisClient := infrastructure.NewRedisClient(ctx, config)
-	cache := infrastructure.NewCache(redisClient)
+	response.SetErrorFormat(config.ERROR_FORMAT)
 
-	// redsync for distributed mutual exclusion
-	pool := goredis.NewPool(&redisClient)
-	mutexProvider := redsync.New(pool)
+	shutdownTracing, err := infrastructure.InitTracing(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	shutdownHooks = append(shutdownHooks, shutdownTracing)
 
-	repositories := domain.Repositories{
-		WalletRepository: wallet.NewWalletRepository(postgresDb, cache),
-		AuthRepository:   auth.NewAuthRepository(cache),
+	var usecases domain.Usecases
+	if config.STORAGE == infrastructure.STORAGE_MEMORY {
+		infrastructure.LogWarn(ctx, "running on the memory storage, nothing survives a restart")
+		_, usecases = newMemoryDomain(config)
+	} else if usecases, err = newPostgresDomain(ctx, config); err != nil {
+		return nil, err
 	}
+	startBackgroundJobs(ctx, config, usecases)
 
-	usecases := domain.Usecases{
-		AuthUsecase:   auth.NewAuthUsecase(repositories),
-		WalletUsecase: wallet.NewWalletUsecase(repositories, cache, mutexProvider, config),
+	// the same usecases on their own port, for the services talking grpc
+	if config.GRPC_PORT > 0 {
+		if err := startGrpcServer(ctx, config, usecases); err != nil {
+			return nil, err
+		}
 	}
 
-	wallet.SetWalletHandler(router, usecases)
-	// in terms of authorization, a token should not be a forever-lived value
-	// provided a /refresh endpoint to get fresh token
-	auth.SetAuthHandler(router, usecases)
+	SetHandlers(router, usecases)
 
 	// 1.
 	// starting worker to listen wallet transaction
@@ -69,9 +86,171 @@ func InitServer() chi.Router {
 	// req II -> check balance sent when req I is still in process.
 	/*** there is context with timeout while updating wallet balance ***/
 
-	return router
+	return router, nil
+}
+
+// SetHandlers mounts every route of the server, the openapi tests walk the same routes the spec documents
+func SetHandlers(router *chi.Mux, usecases domain.Usecases) {
+	router.Handle("/metrics", infrastructure.MetricsHandler())
+
+	// liveness, readiness and the admin-only /debug/status
+	health.SetHealthHandler(router, usecases)
+	wallet.SetWalletHandler(router, usecases)
+	// in terms of authorization, a token should not be a forever-lived value
+	// provided a /refresh endpoint to get fresh token
+	auth.SetAuthHandler(router, usecases)
+	member.SetMemberHandler(router, usecases)
+	batch.SetBatchHandler(router, usecases)
+	schedule.SetScheduleHandler(router, usecases)
+	statement.SetStatementHandler(router, usecases)
+	reconciliation.SetReconciliationHandler(router, usecases)
+	settlement.SetSettlementHandler(router, usecases)
+	// the contract of every /api/v1 route, and swagger ui on /docs
+	openapi.SetOpenapiHandler(router)
+}
+
+// newPostgresDomain connects to postgres and redis, and wires the usecases on them
+func newPostgresDomain(ctx context.Context, config infrastructure.Config) (usecases domain.Usecases, err error) {
+	postgresDb, err := infrastructure.NewPostgresConn(config)
+	if err != nil {
+		return usecases, err
+	}
+
+	// an unreachable postgres is left to /readyz, a reachable one on the wrong schema is fatal
+	if _, err := infrastructure.CheckSchemaVersion(ctx, postgresDb); err != nil {
+		var mismatch *infrastructure.SchemaVersionMismatchError
+		if errors.As(err, &mismatch) {
+			return usecases, err
+		}
+		infrastructure.LogWarn(ctx, "schema version could not be checked on startup", "error", err)
+	}
+
+	redisClient := infrastructure.NewRedisClient(ctx, config)
+	if err := infrastructure.PingRedis(ctx, redisClient); err != nil {
+		// not fatal, /readyz keeps reporting it until redis is reachable
+		infrastructure.LogWarn(ctx, "redis is not reachable on startup", "error", err)
+	}
+	_, usecases = newDomain(config, postgresDb, redisClient)
+
+	return usecases, nil
+}
+
+// startBackgroundJobs resumes the interrupted batches and starts the scheduler, statement and reconciliation jobs
+// the config enables, whatever the storage
+func startBackgroundJobs(ctx context.Context, config infrastructure.Config, usecases domain.Usecases) {
+	// batches interrupted by the previous shutdown carry on in the background
+	go func() {
+		if err := usecases.BatchUsecase.ResumeBatches(ctx); err != nil {
+			infrastructure.LogError(ctx, "got error on usecases.BatchUsecase.ResumeBatches() - startBackgroundJobs", err)
+		}
+	}()
+
+	if config.SCHEDULER_INTERVAL > 0 {
+		schedulerCtx, stopScheduler := context.WithCancel(ctx)
+		go usecases.ScheduleUsecase.RunScheduler(schedulerCtx)
+		shutdownHooks = append(shutdownHooks, func(ctx context.Context) error {
+			stopScheduler()
+			return nil
+		})
+	}
+
+	if config.STATEMENT_INTERVAL > 0 {
+		statementCtx, stopStatementJob := context.WithCancel(ctx)
+		go usecases.StatementUsecase.RunStatementJob(statementCtx)
+		shutdownHooks = append(shutdownHooks, func(ctx context.Context) error {
+			stopStatementJob()
+			return nil
+		})
+	}
+
+	if config.RECONCILIATION_INTERVAL > 0 {
+		reconciliationCtx, stopReconciliationJob := context.WithCancel(ctx)
+		go usecases.ReconciliationUsecase.RunReconciliationJob(reconciliationCtx)
+		shutdownHooks = append(shutdownHooks, func(ctx context.Context) error {
+			stopReconciliationJob()
+			return nil
+		})
+	}
 }
 
-func StopServer() {
+// newDomain wires the repositories and the usecases, shared by the servers and the admin commands
+func newDomain(config infrastructure.Config, postgresDb *gorm.DB, redisClient redis.Client) (domain.Repositories, domain.Usecases) {
+	cache := infrastructure.NewInstrumentedCache(infrastructure.NewTracedCache(infrastructure.NewCache(redisClient)))
 
+	// redsync for distributed mutual exclusion, unless a single instance is deployed
+	var walletLocker walletDomain.WalletLocker
+	switch config.WALLET_LOCKER {
+	case walletDomain.WALLET_LOCKER_MEMORY:
+		walletLocker = wallet.NewMemoryWalletLocker(config.WALLET_LOCK_TTL)
+	default:
+		walletLocker = wallet.NewRedisWalletLocker(redisClient, config.WALLET_LOCK_TTL)
+	}
+	walletLocker = wallet.NewInstrumentedWalletLocker(wallet.NewTracedWalletLocker(walletLocker))
+
+	repositories := domain.Repositories{
+		WalletRepository:         wallet.NewInstrumentedWalletRepository(wallet.NewTracedWalletRepository(wallet.NewWalletRepository(postgresDb, cache))),
+		AuthRepository:           auth.NewAuthRepository(cache, config),
+		BatchRepository:          batch.NewBatchRepository(postgresDb),
+		ScheduleRepository:       schedule.NewScheduleRepository(postgresDb),
+		MemberRepository:         member.NewMemberRepository(postgresDb),
+		StatementRepository:      statement.NewStatementRepository(postgresDb),
+		ReconciliationRepository: reconciliation.NewReconciliationRepository(postgresDb),
+		SettlementRepository:     settlement.NewSettlementRepository(postgresDb),
+	}
+
+	usecases := domain.Usecases{
+		AuthUsecase:           auth.NewAuthUsecase(repositories, config),
+		WalletUsecase:         wallet.NewInstrumentedWalletUsecase(wallet.NewTracedWalletUsecase(wallet.NewWalletUsecase(repositories, cache, walletLocker, config))),
+		HealthUsecase:         health.NewHealthUsecase(postgresDb, redisClient),
+		StatementUsecase:      statement.NewStatementUsecase(repositories, config),
+		ReconciliationUsecase: reconciliation.NewReconciliationUsecase(repositories, config),
+		SettlementUsecase:     settlement.NewSettlementUsecase(repositories, config),
+	}
+	// batch items go through the instrumented wallet usecase like single transactions
+	usecases.BatchUsecase = batch.NewBatchUsecase(repositories, usecases.WalletUsecase, config)
+	usecases.ScheduleUsecase = schedule.NewScheduleUsecase(repositories, usecases.WalletUsecase, config)
+	usecases.MemberUsecase = member.NewMemberUsecase(repositories, usecases.WalletUsecase, config)
+
+	return repositories, usecases
+}
+
+// newMemoryDomain wires the usecases on the memory repositories, cache and locker
+func newMemoryDomain(config infrastructure.Config) (domain.Repositories, domain.Usecases) {
+	cache := infrastructure.NewInstrumentedCache(infrastructure.NewTracedCache(infrastructure.NewMemoryCache()))
+	// a single process, whatever WALLET_LOCKER says
+	walletLocker := wallet.NewInstrumentedWalletLocker(wallet.NewTracedWalletLocker(wallet.NewMemoryWalletLocker(config.WALLET_LOCK_TTL)))
+
+	walletRepository := wallet.NewInstrumentedWalletRepository(wallet.NewTracedWalletRepository(wallet.NewMemoryWalletRepository()))
+	repositories := domain.Repositories{
+		WalletRepository:         walletRepository,
+		AuthRepository:           auth.NewMemoryAuthRepository(config),
+		BatchRepository:          batch.NewMemoryBatchRepository(),
+		ScheduleRepository:       schedule.NewMemoryScheduleRepository(walletRepository),
+		MemberRepository:         member.NewMemoryMemberRepository(walletRepository),
+		StatementRepository:      statement.NewMemoryStatementRepository(walletRepository),
+		ReconciliationRepository: reconciliation.NewMemoryReconciliationRepository(walletRepository),
+		SettlementRepository:     settlement.NewMemorySettlementRepository(walletRepository),
+	}
+
+	usecases := domain.Usecases{
+		AuthUsecase:           auth.NewAuthUsecase(repositories, config),
+		WalletUsecase:         wallet.NewInstrumentedWalletUsecase(wallet.NewTracedWalletUsecase(wallet.NewWalletUsecase(repositories, cache, walletLocker, config))),
+		HealthUsecase:         health.NewMemoryHealthUsecase(),
+		StatementUsecase:      statement.NewStatementUsecase(repositories, config),
+		ReconciliationUsecase: reconciliation.NewReconciliationUsecase(repositories, config),
+		SettlementUsecase:     settlement.NewSettlementUsecase(repositories, config),
+	}
+	usecases.BatchUsecase = batch.NewBatchUsecase(repositories, usecases.WalletUsecase, config)
+	usecases.ScheduleUsecase = schedule.NewScheduleUsecase(repositories, usecases.WalletUsecase, config)
+	usecases.MemberUsecase = member.NewMemberUsecase(repositories, usecases.WalletUsecase, config)
+
+	return repositories, usecases
+}
+
+func StopServer(ctx context.Context) {
+	for _, shutdown := range shutdownHooks {
+		if err := shutdown(ctx); err != nil {
+			infrastructure.LogError(ctx, "got error on shutdown hook - StopServer", err)
+		}
+	}
 }